@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LastDiffFile is the name of the file holding the most recently read diff
+const LastDiffFile = "last.diff"
+
+// ExplainedFile is the name of the file holding the hash of the most recently
+// explained diff
+const ExplainedFile = "explained.sha256"
+
+// ErrNoLastDiff is returned when no diff has been explained yet
+var ErrNoLastDiff = errors.New("no previous diff found, run difx first")
+
+// Dir returns the directory where difx keeps cached data, creating it if needed
+func Dir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(base, "difx")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return dir, nil
+}
+
+// SaveLastDiff stores the diff so it can be explained again later
+func SaveLastDiff(diffOutput string) error {
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, LastDiffFile), []byte(diffOutput), 0600); err != nil {
+		return fmt.Errorf("failed to save last diff: %w", err)
+	}
+	return nil
+}
+
+// MarkExplained records the hash of a diff once its explanation came back,
+// apart from the last diff, which is saved before the request is sent
+func MarkExplained(diffOutput string) error {
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ExplainedFile), []byte(Hash(diffOutput)), 0600); err != nil {
+		return fmt.Errorf("failed to mark diff as explained: %w", err)
+	}
+	return nil
+}
+
+// IsExplained reports whether the diff has the same hash as the most recently explained one
+func IsExplained(diffOutput string) bool {
+	dir, err := Dir()
+	if err != nil {
+		return false
+	}
+
+	explained, err := os.ReadFile(filepath.Join(dir, ExplainedFile))
+	if err != nil {
+		return false
+	}
+	return string(explained) == Hash(diffOutput)
+}
+
+// LoadLastDiff returns the most recently read diff, whether or not its
+// explanation came back
+func LoadLastDiff() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, LastDiffFile))
+	if os.IsNotExist(err) {
+		return "", ErrNoLastDiff
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read last diff: %w", err)
+	}
+	return string(content), nil
+}
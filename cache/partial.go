@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// PartialDir is the subdirectory holding explanations that were cut off
+// while streaming, so a later run can continue them
+const PartialDir = "partial"
+
+// partialPath returns the file that stores the partial explanation for a key
+func partialPath(key string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	partialDir := filepath.Join(dir, PartialDir)
+	if err := os.MkdirAll(partialDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create partial explanation directory: %w", err)
+	}
+	return filepath.Join(partialDir, key+".txt"), nil
+}
+
+// StartPartial begins the partial explanation for a key with text, replacing
+// whatever was saved before
+func StartPartial(key string, text string) error {
+	path, err := partialPath(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, []byte(text), 0600); err != nil {
+		return fmt.Errorf("failed to save partial explanation: %w", err)
+	}
+	return nil
+}
+
+// AppendPartial adds streamed text to the partial explanation for a key. Each
+// piece is written right away, so an interrupted run keeps what it received.
+func AppendPartial(key string, text string) error {
+	path, err := partialPath(key)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open partial explanation: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(text); err != nil {
+		return fmt.Errorf("failed to save partial explanation: %w", err)
+	}
+	return nil
+}
+
+// LoadPartial returns the partial explanation for a key. The second return
+// value is false if there is none.
+func LoadPartial(key string) (string, bool, error) {
+	path, err := partialPath(key)
+	if err != nil {
+		return "", false, err
+	}
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read partial explanation: %w", err)
+	}
+	return string(content), true, nil
+}
+
+// RemovePartial deletes the partial explanation for a key, once the
+// explanation is complete
+func RemovePartial(key string) error {
+	path, err := partialPath(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove partial explanation: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ResponsesDir is the subdirectory holding cached model responses
+const ResponsesDir = "responses"
+
+// Entry is a cached model response along with what produced it
+type Entry struct {
+	Model       string    `json:"model"`
+	CreatedAt   time.Time `json:"created_at"`
+	OptionsHash string    `json:"options_hash"`
+	Response    string    `json:"response"`
+}
+
+// Key returns the cache key for a prompt sent to a model. Any change to the
+// prompt template, options or diff produces a different key.
+func Key(model string, prompt string) string {
+	return Hash(model + "\x00" + prompt)
+}
+
+// Hash returns the hex encoded SHA-256 of the text
+func Hash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// entryPath returns the file that stores the entry for a key
+func entryPath(key string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	responsesDir := filepath.Join(dir, ResponsesDir)
+	if err := os.MkdirAll(responsesDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create response cache directory: %w", err)
+	}
+	return filepath.Join(responsesDir, key+".json"), nil
+}
+
+// LoadResponse returns the cached entry for a key. The second return value
+// is false if there is no entry.
+func LoadResponse(key string) (*Entry, bool, error) {
+	path, err := entryPath(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open cache entry: %w", err)
+	}
+	defer file.Close()
+
+	var entry Entry
+	if err := json.NewDecoder(file).Decode(&entry); err != nil {
+		return nil, false, fmt.Errorf("failed to decode cache entry: %w", err)
+	}
+	return &entry, true, nil
+}
+
+// StoreResponse saves the entry under the key
+func StoreResponse(key string, entry Entry) error {
+	path, err := entryPath(key)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(entry); err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tydin/difx/cache"
+)
+
+var againCmd = &cobra.Command{
+	Use:   "again",
+	Short: "Explain the last diff again",
+	Long: `Re-explain the diff from the previous difx run without running git diff again.
+Combine with --model to compare how different models explain the same change.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, span := startSpan(cmd, "difx again")
+		defer span.End()
+
+		cfg := loadConfig()
+
+		diffOutput, err := cache.LoadLastDiff()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading last diff: %s\n", err)
+			exit(1)
+		}
+
+		ensureAPIKey(cfg)
+		addCommitLog(ctx, cfg)
+		addProjectContext(ctx, cfg)
+		explain(ctx, cfg, diffOutput)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(againCmd)
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/tydin/difx/diff"
+)
+
+// compareBaseline prints a line diff between a previously saved explanation
+// and the new one. Colors are stripped from both first so they don't show up
+// as changes.
+func compareBaseline(w io.Writer, path string, explanation string) error {
+	baseline, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read baseline: %w", err)
+	}
+
+	oldText := plainText(string(baseline))
+	newText := plainText(explanation)
+
+	fmt.Fprintf(w, "\nChanges from baseline %s:\n", path)
+	if oldText == newText {
+		fmt.Fprintln(w, "  (no changes)")
+		return nil
+	}
+
+	added := color.New(activeScheme.add...)
+	removed := color.New(activeScheme.del...)
+	for _, line := range diff.LineDiff(oldText, newText) {
+		switch {
+		case strings.HasPrefix(line, "+ "):
+			added.Fprintln(w, line)
+		case strings.HasPrefix(line, "- "):
+			removed.Fprintln(w, line)
+		default:
+			fmt.Fprintln(w, line)
+		}
+	}
+	return nil
+}
+
+// plainText renders an explanation without any colors and with normalized whitespace
+func plainText(text string) string {
+	return strings.TrimSpace(stripEscapeSequences(normalizeEscapes(text)))
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/tydin/difx/config"
+)
+
+// colorScheme holds the terminal attributes used for added and deleted text
+type colorScheme struct {
+	add []color.Attribute
+	del []color.Attribute
+}
+
+// orange is the 256-color palette entry used by the colorblind scheme
+var orange = []color.Attribute{38, 5, 208}
+
+// colorSchemes are the built-in presets for --color-scheme
+var colorSchemes = map[string]colorScheme{
+	config.ColorSchemeDefault: {
+		add: []color.Attribute{color.FgGreen, color.Bold},
+		del: []color.Attribute{color.FgRed, color.Bold},
+	},
+	config.ColorSchemeLight: {
+		add: []color.Attribute{color.FgGreen},
+		del: []color.Attribute{color.FgRed},
+	},
+	config.ColorSchemeColorblind: {
+		add: []color.Attribute{color.FgBlue, color.Bold},
+		del: append(append([]color.Attribute{}, orange...), color.Bold),
+	},
+}
+
+// activeScheme is the scheme used when rendering the explanation
+var activeScheme = colorSchemes[config.ColorSchemeDefault]
+
+// schemeReplacer maps the color codes the model is asked to emit to the active scheme
+var schemeReplacer = newSchemeReplacer(activeScheme)
+
+// sequence returns the ANSI escape sequence that turns on the attributes
+func sequence(attrs []color.Attribute) string {
+	codes := make([]string, len(attrs))
+	for i, attr := range attrs {
+		codes[i] = strconv.Itoa(int(attr))
+	}
+	return "\033[" + strings.Join(codes, ";") + "m"
+}
+
+// newSchemeReplacer builds the replacer from the model's semantic add/delete codes to the scheme's codes
+func newSchemeReplacer(scheme colorScheme) *strings.Replacer {
+	return strings.NewReplacer(
+		`\033[32;1m`, sequence(scheme.add),
+		`\033[32m`, sequence(scheme.add),
+		`\033[31;1m`, sequence(scheme.del),
+		`\033[31m`, sequence(scheme.del),
+	)
+}
+
+// setColorScheme selects the scheme named in the config
+func setColorScheme(cfg *config.Config) error {
+	name := cfg.ColorScheme
+	if name == "" {
+		name = config.ColorSchemeDefault
+	}
+
+	scheme, ok := colorSchemes[name]
+	if name == config.ColorSchemeCustom {
+		add, err := parseHexColor(cfg.CustomAddColor)
+		if err != nil {
+			return fmt.Errorf("invalid custom_add_color: %w", err)
+		}
+		del, err := parseHexColor(cfg.CustomDeleteColor)
+		if err != nil {
+			return fmt.Errorf("invalid custom_delete_color: %w", err)
+		}
+		scheme, ok = colorScheme{add: add, del: del}, true
+	}
+	if !ok {
+		return fmt.Errorf("unknown color scheme %q (use default, light, colorblind or custom)", name)
+	}
+
+	activeScheme = scheme
+	schemeReplacer = newSchemeReplacer(scheme)
+	return nil
+}
+
+// parseHexColor turns a color like #1e90ff into 24-bit foreground attributes
+func parseHexColor(hex string) ([]color.Attribute, error) {
+	value := strings.TrimPrefix(hex, "#")
+	if len(value) != 6 {
+		return nil, fmt.Errorf("%q is not a #rrggbb color", hex)
+	}
+
+	rgb, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a #rrggbb color", hex)
+	}
+
+	return []color.Attribute{38, 2, color.Attribute(rgb >> 16 & 0xff), color.Attribute(rgb >> 8 & 0xff), color.Attribute(rgb & 0xff)}, nil
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/tydin/difx/config"
+)
+
+func TestColorSchemes(t *testing.T) {
+	t.Cleanup(func() {
+		setColorScheme(&config.Config{})
+	})
+
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want string
+	}{
+		{
+			name: "default",
+			cfg:  config.Config{},
+			want: "\x1b[32;1m+ a\x1b[0m \x1b[31;1m- b\x1b[0m",
+		},
+		{
+			name: "light",
+			cfg:  config.Config{ColorScheme: config.ColorSchemeLight},
+			want: "\x1b[32m+ a\x1b[0m \x1b[31m- b\x1b[0m",
+		},
+		{
+			name: "colorblind",
+			cfg:  config.Config{ColorScheme: config.ColorSchemeColorblind},
+			want: "\x1b[34;1m+ a\x1b[0m \x1b[38;5;208;1m- b\x1b[0m",
+		},
+		{
+			name: "custom",
+			cfg:  config.Config{ColorScheme: config.ColorSchemeCustom, CustomAddColor: "#1e90ff", CustomDeleteColor: "ff8c00"},
+			want: "\x1b[38;2;30;144;255m+ a\x1b[0m \x1b[38;2;255;140;0m- b\x1b[0m",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := setColorScheme(&tt.cfg); err != nil {
+				t.Fatalf("setColorScheme: %s", err)
+			}
+			if got := convertEscapeSequences(`\033[32;1m+ a\033[0m \033[31;1m- b\033[0m`); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColorSchemeErrors(t *testing.T) {
+	t.Cleanup(func() {
+		setColorScheme(&config.Config{})
+	})
+
+	if err := setColorScheme(&config.Config{ColorScheme: "neon"}); err == nil {
+		t.Error("unknown scheme was accepted")
+	}
+	if err := setColorScheme(&config.Config{ColorScheme: config.ColorSchemeCustom, CustomAddColor: "green", CustomDeleteColor: "#ff0000"}); err == nil {
+		t.Error("invalid custom color was accepted")
+	}
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tydin/difx/config"
+	"github.com/tydin/difx/diff"
+)
+
+// compareBaseArgs are the git diff arguments that compare the working tree
+// with HEAD, the index with HEAD, or the working tree with the index
+var compareBaseArgs = map[string][]string{
+	config.CompareHead:    {"HEAD"},
+	config.CompareIndex:   {"--cached"},
+	config.CompareWorking: nil,
+}
+
+// against returns the comparison base given with --against, if any
+func against(cmd *cobra.Command) string {
+	base, _ := cmd.Flags().GetString("against")
+	return base
+}
+
+// compareArgs adds the comparison base of --against or the config to the git
+// diff arguments of a run, and exits if they don't go together
+func compareArgs(cmd *cobra.Command, cfg *config.Config, args []string) []string {
+	// The configured base doesn't replace a diff piped to difx
+	defaultBase := cfg.DefaultCompare
+	if contextFile != "-" && diff.StdinIsPipe() {
+		defaultBase = ""
+	}
+
+	args, err := withCompareBase(against(cmd), defaultBase, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		exit(1)
+	}
+	return args
+}
+
+// withCompareBase puts the git diff arguments of the comparison base before
+// args. The base is the one given with --against, or else defaultBase from
+// the config. Commits, a range or --cached in args already choose what is
+// compared, so --against is refused with them, while the default just
+// doesn't apply.
+func withCompareBase(base string, defaultBase string, args []string) ([]string, error) {
+	explicit := base != ""
+	if !explicit {
+		base = defaultBase
+	}
+	if base == "" {
+		return args, nil
+	}
+
+	baseArgs, ok := compareBaseArgs[base]
+	if !ok {
+		return nil, fmt.Errorf("unknown comparison base %q (use %s, %s or %s)", base, config.CompareHead, config.CompareIndex, config.CompareWorking)
+	}
+
+	if choosesComparison(args) {
+		if explicit {
+			return nil, fmt.Errorf("--against can't be combined with commits, a range or --cached (put paths after --)")
+		}
+		return args, nil
+	}
+	return append(slices.Clone(baseArgs), args...), nil
+}
+
+// choosesComparison tells whether the git diff arguments before -- name a
+// commit or select the index. Like git, an argument that is an existing file
+// is taken as a path rather than a revision.
+func choosesComparison(args []string) bool {
+	for _, arg := range args {
+		switch {
+		case arg == "--":
+			return false
+		case arg == "--cached" || arg == "--staged":
+			return true
+		case strings.HasPrefix(arg, "-"):
+			continue
+		}
+		if _, err := os.Stat(arg); err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// compareCompletion completes the comparison bases of --against
+var compareCompletion = fixedCompletion(config.CompareHead, config.CompareIndex, config.CompareWorking)
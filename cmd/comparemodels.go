@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tydin/difx/config"
+	"github.com/tydin/difx/diff"
+)
+
+// minCompareColumn is the narrowest column the explanations of --compare are
+// put side by side in; on a narrower terminal they are printed one after the
+// other
+const minCompareColumn = 40
+
+// compareSeparator goes between the columns of --compare
+const compareSeparator = " │ "
+
+// comparedModels are the models of --compare, none without it
+var comparedModels []string
+
+// parseCompareModels returns the two models named in a --compare value such
+// as "claude,azure_openai"
+func parseCompareModels(value string) ([]string, error) {
+	var models []string
+	for _, name := range strings.Split(value, ",") {
+		name = config.CanonicalModel(strings.TrimSpace(name))
+		if !slices.Contains(config.Models, name) {
+			return nil, fmt.Errorf("unsupported model %q in --compare (use %s)", name, strings.Join(config.Models, " or "))
+		}
+		if slices.Contains(models, name) {
+			return nil, fmt.Errorf("--compare names %s twice", name)
+		}
+		models = append(models, name)
+	}
+	if len(models) != 2 {
+		return nil, fmt.Errorf("--compare takes two models, such as %s", strings.Join(config.Models, ","))
+	}
+	return models, nil
+}
+
+// modelRun is the explanation of one model in --compare and what it took
+type modelRun struct {
+	model   string
+	result  diff.Result
+	err     error
+	latency time.Duration
+}
+
+// printComparison explains the diff with each model of --compare at the same
+// time, prints the explanations side by side and reports what each took, and
+// the files it left out, on stderr. Each explanation goes to --post-hook as
+// well, under the name of its model. It returns the explanation of the first
+// model.
+func printComparison(ctx context.Context, cfg *config.Config, diffOutput string) string {
+	runs := make([]modelRun, len(comparedModels))
+	var wg sync.WaitGroup
+	for i, model := range comparedModels {
+		modelCfg := *cfg
+		modelCfg.ActiveModel = model
+		// Both responses are printed once complete
+		modelCfg.Streaming = false
+		ensureAPIKey(&modelCfg)
+
+		wg.Add(1)
+		go func(i int, cfg *config.Config) {
+			defer wg.Done()
+			start := time.Now()
+			result, err := diff.GetExplanationResult(ctx, diffOutput, cfg, nil)
+			runs[i] = modelRun{model: cfg.ActiveModel, result: result, err: err, latency: time.Since(start)}
+		}(i, &modelCfg)
+	}
+	wg.Wait()
+
+	width := termWidth()
+	if column := (width - len(compareSeparator)) / 2; column >= minCompareColumn {
+		printColumns(output, runs, column)
+	} else {
+		printSequential(output, runs)
+	}
+	reportComparison(os.Stderr, cfg, diff.BuildPrompt(ctx, diffOutput, cfg), runs)
+
+	for _, run := range runs {
+		if missing := omittedFiles(cfg, diffOutput, run.result.Text); run.err == nil && len(missing) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %s omitted: %s\n", run.model, strings.Join(missing, ", "))
+		}
+	}
+
+	for _, run := range runs {
+		if cfg.PostHook == "" || run.err != nil || strings.TrimSpace(run.result.Text) == "" {
+			continue
+		}
+		hookCfg := *cfg
+		hookCfg.ActiveModel = run.model
+		if err := runPostHook(ctx, &hookCfg, diffOutput, run.result.Text); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			if failOnError {
+				exit(exitError)
+			}
+		}
+	}
+
+	for _, run := range runs {
+		if run.err != nil && failOnError {
+			exit(exitAPI)
+		}
+	}
+	return runs[0].result.Text
+}
+
+// comparisonText is what is shown for a run: its explanation, or why there
+// isn't one
+func comparisonText(run modelRun) string {
+	switch {
+	case run.err != nil:
+		return fmt.Sprintf("Error getting explanation from AI: %s", run.err)
+	case strings.TrimSpace(run.result.Text) == "":
+		return emptyResponseMessage
+	}
+	return run.result.Text
+}
+
+// printSequential writes the explanations one after the other, each under
+// the name of its model
+func printSequential(w io.Writer, runs []modelRun) {
+	for i, run := range runs {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "===== %s =====\n\n", run.model)
+		if run.err != nil {
+			fmt.Fprintln(w, comparisonText(run))
+			continue
+		}
+		fmt.Fprint(w, themeText(renderText(comparisonText(run))))
+		endOutput(w)
+	}
+}
+
+// printColumns writes the explanations next to each other, in columns of
+// width characters headed by the names of their models. The columns are
+// plain text, since colors can't be wrapped reliably.
+func printColumns(w io.Writer, runs []modelRun, width int) {
+	cols := make([][]string, len(runs))
+	rows := 0
+	for i, run := range runs {
+		cols[i] = append([]string{run.model, strings.Repeat("─", width)}, wrapText(plainText(comparisonText(run)), width)...)
+		rows = max(rows, len(cols[i]))
+	}
+
+	for row := 0; row < rows; row++ {
+		cells := make([]string, len(cols))
+		for i, col := range cols {
+			if row < len(col) {
+				cells[i] = col[row]
+			}
+			// The last column needs no padding
+			if i < len(cols)-1 {
+				cells[i] += strings.Repeat(" ", width-len([]rune(cells[i])))
+			}
+		}
+		fmt.Fprintln(w, strings.TrimRight(strings.Join(cells, compareSeparator), " "))
+	}
+}
+
+// wrapText breaks text into lines of at most width characters, between words
+// where it can, keeping its line breaks and the indentation of each line
+func wrapText(text string, width int) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\t", "    "), "\n") {
+		indent := line[:len(line)-len(strings.TrimLeft(line, " "))]
+		if len(indent) > width/2 {
+			indent = ""
+		}
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+
+		current := indent
+		for _, word := range words {
+			// A word longer than a line is split wherever the line is full
+			for len([]rune(indent+word)) > width {
+				if current != indent {
+					lines = append(lines, current)
+				}
+				cut := width - len([]rune(indent))
+				lines = append(lines, indent+string([]rune(word)[:cut]))
+				word = string([]rune(word)[cut:])
+				current = indent
+			}
+
+			switch {
+			case current == indent:
+				current += word
+			case len([]rune(current))+1+len([]rune(word)) <= width:
+				current += " " + word
+			default:
+				lines = append(lines, current)
+				current = indent + word
+			}
+		}
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+// reportComparison writes how long each model took, the tokens it used and
+// what they cost at the model's prices. Token counts the provider didn't
+// report are estimated from the prompt and the explanation.
+func reportComparison(w io.Writer, cfg *config.Config, prompt string, runs []modelRun) {
+	fmt.Fprintln(w)
+	for _, run := range runs {
+		if run.err != nil {
+			fmt.Fprintf(w, "%s: failed after %s\n", run.model, run.latency.Round(100*time.Millisecond))
+			continue
+		}
+
+		usage := run.result.Usage
+		if usage.InputTokens == 0 {
+			usage.InputTokens = diff.EstimateTokens(prompt)
+		}
+		if usage.OutputTokens == 0 {
+			usage.OutputTokens = diff.EstimateTokens(run.result.Text)
+		}
+		fmt.Fprintf(w, "%s: %s, %d input and %d output tokens", run.model, run.latency.Round(100*time.Millisecond), usage.InputTokens, usage.OutputTokens)
+		if input, output := cfg.Prices(run.model); input == 0 && output == 0 {
+			fmt.Fprintf(w, ", no price set (%s_input_price and %s_output_price)\n", run.model, run.model)
+		} else {
+			fmt.Fprintf(w, ", about $%.4f\n", config.RequestCost(cfg, run.model, usage.InputTokens, usage.OutputTokens))
+		}
+	}
+}
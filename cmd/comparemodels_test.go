@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tydin/difx/config"
+	"github.com/tydin/difx/diff"
+)
+
+func TestParseCompareModels(t *testing.T) {
+	got, err := parseCompareModels("claude, azure_openai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{config.ModelClaude, config.ModelAzureOpenAI}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCompareModels = %v, want %v", got, want)
+	}
+
+	for _, value := range []string{"claude", "claude,claude", "claude,gpt5", "claude,azure_openai,claude"} {
+		if _, err := parseCompareModels(value); err == nil {
+			t.Errorf("parseCompareModels(%q) succeeded", value)
+		}
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	got := wrapText("one two three four\n  indented words here\n\nabcdefghijkl", 10)
+	want := []string{
+		"one two",
+		"three four",
+		"  indented",
+		"  words",
+		"  here",
+		"",
+		"abcdefghij",
+		"kl",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrapText = %q, want %q", got, want)
+	}
+}
+
+func TestPrintColumns(t *testing.T) {
+	runs := []modelRun{
+		{model: "claude", result: diff.Result{Text: "\\033[32mAdded\\033[0m a flag"}},
+		{model: "azure_openai", err: errors.New("boom")},
+	}
+
+	var out bytes.Buffer
+	printColumns(&out, runs, 12)
+
+	want := strings.Join([]string{
+		"claude       │ azure_openai",
+		"──────────── │ ────────────",
+		"Added a flag │ Error",
+		"             │ getting",
+		"             │ explanation",
+		"             │ from AI:",
+		"             │ boom",
+	}, "\n") + "\n"
+	if out.String() != want {
+		t.Errorf("printColumns =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestReportComparison(t *testing.T) {
+	runs := []modelRun{
+		{model: config.ModelClaude, result: diff.Result{Text: "Added a flag", Usage: diff.Usage{InputTokens: 1000, OutputTokens: 200}}, latency: 1200 * time.Millisecond},
+		{model: config.ModelAzureOpenAI, result: diff.Result{Text: "Added a flag", Usage: diff.Usage{InputTokens: 1000, OutputTokens: 200}}, latency: 800 * time.Millisecond},
+	}
+
+	// input_price and output_price are those of the active model only
+	cfg := &config.Config{ActiveModel: config.ModelClaude, InputPrice: 3, OutputPrice: 15}
+	var out bytes.Buffer
+	reportComparison(&out, cfg, "", runs)
+	want := "\nclaude: 1.2s, 1000 input and 200 output tokens, about $0.0060\n" +
+		"azure_openai: 800ms, 1000 input and 200 output tokens, no price set (azure_openai_input_price and azure_openai_output_price)\n"
+	if out.String() != want {
+		t.Errorf("reportComparison =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	cfg.AzureOpenAIInputPrice, cfg.AzureOpenAIOutputPrice = 2.5, 10
+	out.Reset()
+	reportComparison(&out, cfg, "", runs)
+	if !strings.Contains(out.String(), "azure_openai: 800ms, 1000 input and 200 output tokens, about $0.0045\n") {
+		t.Errorf("reportComparison with azure_openai prices =\n%s", out.String())
+	}
+}
+
+func TestPrintComparisonRunsPostHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook is a POSIX shell command")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/v1/messages") {
+			w.Write([]byte(`{"content":[{"type":"text","text":"From Claude"}]}`))
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"From Azure"}}]}`))
+	}))
+	defer server.Close()
+
+	original := comparedModels
+	comparedModels = []string{config.ModelClaude, config.ModelAzureOpenAI}
+	t.Cleanup(func() { comparedModels = original })
+
+	hookDir := t.TempDir()
+	cfg := &config.Config{
+		ClaudeAPIKey:        "key",
+		ClaudeBaseURL:       server.URL,
+		AzureOpenAIKey:      "key",
+		AzureOpenAIEndpoint: server.URL,
+		PostHook:            "cat > " + hookDir + "/\"$DIFX_MODEL\"",
+	}
+	diffOutput := "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-a\n+b\n"
+
+	captureModelOutput(t)
+	captureOutput(t, func() { printComparison(context.Background(), cfg, diffOutput) })
+
+	entries, _ := os.ReadDir(hookDir)
+	var got []string
+	for _, entry := range entries {
+		content, _ := os.ReadFile(filepath.Join(hookDir, entry.Name()))
+		got = append(got, entry.Name()+": "+string(content))
+	}
+	sort.Strings(got)
+	if want := []string{"azure_openai: From Azure\n", "claude: From Claude\n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("post hook got %q, want %q", got, want)
+	}
+}
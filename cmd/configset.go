@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tydin/difx/config"
+)
+
+// configDryRun shows the result of config set instead of saving it
+var configDryRun bool
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Change the saved configuration",
+	Args:  cobra.NoArgs,
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Change a setting in the config file",
+	Long: `Change one setting in the config file, named as in the file, such as
+min_severity or max_hunk_lines. The whole config is checked before it is
+saved, and an invalid one is refused. Environment variables and command line
+flags aren't saved. With --dry-run, the resulting file is shown, with its keys
+hidden, instead of written.`,
+	Args: cobra.ExactArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return config.Keys(), cobra.ShellCompDirectiveNoFileComp
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		stored, err := config.LoadStored()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %s\n", err)
+			exit(1)
+		}
+
+		if err := config.Set(stored, args[0], args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			exit(1)
+		}
+
+		if configDryRun {
+			if err := config.Encode(os.Stdout, config.Masked(stored)); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				exit(1)
+			}
+			// Say whether it would have been saved
+			if err := config.Validate(stored); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: this config would be refused: %s\n", err)
+				exit(1)
+			}
+			fmt.Fprintln(os.Stderr, "Dry run, nothing was written.")
+			return
+		}
+
+		if err := config.Save(stored); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving config: %s\n", err)
+			exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Set %s in %s/%s\n", args[0], config.ConfigDir, config.ConfigFile)
+	},
+}
+
+func init() {
+	configSetCmd.Flags().BoolVar(&configDryRun, "dry-run", false, "Show the resulting config file instead of writing it")
+	configCmd.AddCommand(configSetCmd)
+	rootCmd.AddCommand(configCmd)
+}
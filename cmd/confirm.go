@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tydin/difx/config"
+	"github.com/tydin/difx/diff"
+)
+
+// confirmSend shows what is about to leave the machine and asks the user to
+// approve it. It returns true if the diff may be sent.
+func confirmSend(cfg *config.Config, diffOutput string) bool {
+	// Nothing leaves the machine in offline mode
+	if cfg.Offline {
+		return true
+	}
+
+	// Without a terminal nobody can answer, which counts as a no
+	if !config.CanPrompt() {
+		fmt.Fprintln(os.Stderr, "Error: --confirm-send can't ask without an interactive terminal; add --yes to send without asking")
+		return false
+	}
+
+	provider, host := diff.Destination(cfg)
+
+	fmt.Fprintf(os.Stderr, "About to send the diff to %s (%s)\n", provider, host)
+	fmt.Fprintf(os.Stderr, "  Size: %d bytes, about %d tokens\n", len(diffOutput), diff.EstimateTokens(diffOutput))
+	if cfg.Background != "" {
+		fmt.Fprintf(os.Stderr, "  Background: %d bytes, about %d tokens\n", len(cfg.Background), diff.EstimateTokens(cfg.Background))
+	}
+	fmt.Fprint(os.Stderr, "Continue? [y/N] ")
+
+	reader := bufio.NewReader(os.Stdin)
+	answer, err := reader.ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(os.Stderr)
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tydin/difx/telemetry"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exit codes, so scripts can tell why difx failed
+const (
+	// exitError is for usage and configuration errors, and anything else
+	exitError = 1
+	// exitGit means git failed or the diff couldn't be read
+	exitGit = 2
+	// exitAPI means the model call failed or produced no explanation
+	exitAPI = 3
+)
+
+// commandSpan is the span of the running command, none before it starts
+var commandSpan trace.Span
+
+// shutdownTracing sends the spans still buffered to the collector
+var shutdownTracing = func(context.Context) error { return nil }
+
+// startSpan starts the span of a command, which exit ends as well
+func startSpan(cmd *cobra.Command, name string) (context.Context, trace.Span) {
+	ctx, span := telemetry.Tracer().Start(cmd.Context(), name)
+	commandSpan = span
+	return ctx, span
+}
+
+// exit ends the command's span and flushes the spans before leaving with
+// code, since os.Exit skips the deferred calls that would do so
+func exit(code int) {
+	if commandSpan != nil {
+		if code != 0 {
+			commandSpan.SetStatus(codes.Error, fmt.Sprintf("exit code %d", code))
+		}
+		commandSpan.End()
+	}
+	shutdownTracing(context.Background())
+	os.Exit(code)
+}
@@ -0,0 +1,916 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/tydin/difx/cache"
+	"github.com/tydin/difx/config"
+	"github.com/tydin/difx/diff"
+)
+
+// loadConfig loads the config and applies the command line overrides to it
+func loadConfig() *config.Config {
+	// Take credentials from a project .env file, without overriding the real
+	// environment. Only a file named with --env-file may change anything else.
+	path := envFile
+	if path == "" && !noEnvFile {
+		path = config.FindEnvFile()
+	}
+	if path != "" {
+		skipped, err := config.LoadEnvFile(path, envFile != "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading %s: %s\n", path, err)
+			exit(1)
+		}
+		if len(skipped) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring %s from %s; only credentials are taken from a .env difx finds (name it with --env-file to use the rest)\n", strings.Join(skipped, ", "), path)
+		}
+	}
+
+	// Load or create config
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %s\n", err)
+		exit(1)
+	}
+
+	// Fall back to plain text on terminals without ANSI support
+	if !supportsANSI() {
+		color.NoColor = true
+		renderText = stripEscapeSequences
+		resetCode = ""
+	}
+
+	// Turn literal \n and \t from the model into real whitespace unless disabled
+	if !noNormalize {
+		render := renderText
+		renderText = func(text string) string {
+			return render(normalizeEscapes(text))
+		}
+	}
+
+	// Scripts and CI need a failing exit code, an interactive user sees the warning
+	if !failOnErrorFlag.Changed {
+		failOnError = !stdoutIsTerminal()
+	}
+
+	// Check if we're in CI mode
+	if ciMode {
+		cfg.Streaming = false
+	}
+
+	// Ask for fenced code blocks in the explanation
+	if wrapCode {
+		cfg.WrapCode = true
+	}
+
+	if maxHunkLines > 0 {
+		cfg.MaxHunkLines = maxHunkLines
+	}
+
+	if includeLockfiles {
+		cfg.IncludeLockfiles = true
+	}
+
+	if newFilesOnly {
+		cfg.NewFilesOnly = true
+	}
+
+	if dedupeImports {
+		cfg.DedupeImports = true
+	}
+
+	if includeSubmodules {
+		cfg.IncludeSubmodules = true
+	}
+
+	if projectContextFile != "" {
+		cfg.ProjectContextFile = projectContextFile
+	}
+
+	if postHook != "" {
+		cfg.PostHook = postHook
+	}
+
+	if maxLineChars > 0 {
+		cfg.MaxLineChars = maxLineChars
+	}
+
+	if persona != "" {
+		cfg.Persona = persona
+	}
+	if !diff.IsPersona(cfg.Persona) {
+		fmt.Fprintf(os.Stderr, "Unsupported persona %q (use %s, %s, %s or %s)\n", cfg.Persona, config.PersonaTeacher, config.PersonaReviewer, config.PersonaChangelog, config.PersonaELI5)
+		exit(1)
+	}
+
+	if groupByDir {
+		cfg.GroupByDir = true
+	}
+
+	if offline {
+		cfg.Offline = true
+	}
+
+	if resume {
+		cfg.Resume = true
+	}
+
+	if checkTests {
+		cfg.CheckTests = true
+	}
+
+	if intent {
+		cfg.Intent = true
+	}
+
+	if fullContext {
+		cfg.FullContext = true
+	}
+
+	if anonymizePaths {
+		cfg.AnonymizePaths = true
+	}
+	if keepExtensions {
+		cfg.KeepExtensions = true
+	}
+	// The complete files are looked up, and sent, under their real paths
+	if cfg.AnonymizePaths && cfg.FullContext {
+		fmt.Fprintln(os.Stderr, "Error: --anonymize-paths can't be combined with --full-context")
+		exit(1)
+	}
+
+	if maxInputTokens > 0 {
+		cfg.MaxInputTokens = maxInputTokens
+	}
+
+	if maxResponseTime > 0 {
+		cfg.MaxResponseTime = maxResponseTime
+	}
+
+	if concurrency > 0 {
+		cfg.Concurrency = concurrency
+	}
+
+	if budget > 0 {
+		cfg.Budget = budget
+	}
+
+	if maxRetriesFlag.Changed {
+		cfg.MaxRetries = maxRetries
+	}
+
+	// Explain the wait when a request is sent again
+	if !quiet {
+		cfg.Notify = func(message string) {
+			fmt.Fprintln(os.Stderr, message)
+		}
+	}
+
+	// Add up what each request used in the local stats file
+	cfg.RecordUsage = recordUsage(cfg)
+
+	if useCache {
+		cfg.Cache = true
+	}
+
+	if structured {
+		cfg.Structured = true
+	}
+
+	// JSON output needs the structured explanation, buffered in full
+	if jsonOutput {
+		cfg.Structured = true
+		cfg.Streaming = false
+	}
+
+	if minSeverity != "" {
+		cfg.MinSeverity = minSeverity
+	}
+	switch cfg.MinSeverity {
+	case "":
+		cfg.MinSeverity = config.SeverityAll
+	case config.SeverityAll, config.SeverityNotable, config.SeverityMajor:
+	default:
+		fmt.Fprintf(os.Stderr, "Unsupported severity %q (use %s, %s or %s)\n", cfg.MinSeverity, config.SeverityAll, config.SeverityNotable, config.SeverityMajor)
+		exit(1)
+	}
+
+	if stripNoNewline {
+		cfg.StripNoNewline = true
+	}
+
+	if confirmBeforeSend {
+		cfg.ConfirmSend = true
+	}
+
+	if colorSchemeName != "" {
+		cfg.ColorScheme = colorSchemeName
+	}
+	if err := setColorScheme(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		exit(1)
+	}
+
+	if themeName != "" {
+		cfg.Theme = themeName
+	}
+	if err := setTheme(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		exit(1)
+	}
+
+	// Release notes are Markdown, which the structured schema can't hold
+	if changelog {
+		if cfg.Structured {
+			fmt.Fprintln(os.Stderr, "Error: --changelog can't be combined with --structured or --json")
+			exit(1)
+		}
+		cfg.Changelog = true
+	}
+
+	// Review comments are plain text, and replace the explanation like release notes
+	if review {
+		if cfg.Structured || cfg.Changelog {
+			fmt.Fprintln(os.Stderr, "Error: --review can't be combined with --structured, --json or --changelog")
+			exit(1)
+		}
+		cfg.Review = true
+	}
+
+	// JSON output is printed as is, without color conversion
+	if cfg.Structured {
+		renderText = func(text string) string { return text }
+		activeTheme = nil
+		resetCode = ""
+	}
+
+	// A changelog entry, or output going to a file or pipe, is kept free of
+	// the trailing color reset
+	if cfg.Changelog || !stdoutIsTerminal() {
+		resetCode = ""
+	}
+
+	if model != "" {
+		cfg.ActiveModel = config.CanonicalModel(model)
+		if !slices.Contains(config.Models, cfg.ActiveModel) {
+			fmt.Fprintf(os.Stderr, "Unsupported model %q (use %s)\n", model, strings.Join(config.Models, " or "))
+			exit(1)
+		}
+	}
+
+	// Comparing models runs each in full, so a single model or a split diff
+	// doesn't go with it, nor what follows up on a single explanation
+	if compareFlag != "" {
+		if model != "" || chunked || jsonOutput || attachNote || strict || baselineFile != "" {
+			fmt.Fprintln(os.Stderr, "Error: --compare can't be combined with --model, --chunked, --json, --attach-note, --strict or --baseline")
+			exit(1)
+		}
+		models, err := parseCompareModels(compareFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			exit(1)
+		}
+		comparedModels = models
+	}
+
+	if seedFlag.Changed {
+		cfg.Seed = &seed
+	}
+	if cfg.Seed != nil && cfg.ActiveModel != config.ModelAzureOpenAI {
+		fmt.Fprintf(os.Stderr, "Warning: --seed is ignored by the %s model\n", cfg.ActiveModel)
+	}
+
+	// Stop the response at the closing delimiter rather than let the model go on
+	if stopAtDelimiter && !slices.Contains(cfg.StopSequences, diff.DelimiterStopSequence) {
+		cfg.StopSequences = append(cfg.StopSequences, diff.DelimiterStopSequence)
+	}
+	for _, sequence := range cfg.StopSequences {
+		// Claude rejects them, and they would end the response at any line break
+		if strings.TrimSpace(sequence) == "" {
+			fmt.Fprintln(os.Stderr, "Error: stop_sequences must not contain empty or whitespace-only sequences")
+			exit(1)
+		}
+	}
+	if cfg.ActiveModel == config.ModelAzureOpenAI && len(cfg.StopSequences) > diff.MaxAzureStopSequences {
+		fmt.Fprintf(os.Stderr, "Error: Azure OpenAI accepts at most %d stop sequences, got %d\n", diff.MaxAzureStopSequences, len(cfg.StopSequences))
+		exit(1)
+	}
+
+	if anthropicVersion != "" {
+		cfg.AnthropicVersion = anthropicVersion
+	}
+
+	// Catch a mistyped setting, such as an endpoint, now rather than with a
+	// confusing request error
+	if err := config.Validate(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config: %s\n", err)
+		exit(1)
+	}
+
+	// The budget is in dollars, which takes the prices to estimate
+	if input, output := cfg.Prices(cfg.ActiveModel); cfg.Budget > 0 && input == 0 && output == 0 && !cfg.Offline {
+		fmt.Fprintf(os.Stderr, "Error: --budget needs input_price and output_price, or %s_input_price and %s_output_price, in the config (dollars per million tokens) to estimate the spend\n", cfg.ActiveModel, cfg.ActiveModel)
+		exit(1)
+	}
+
+	// A run either records the API exchanges or replays them
+	if cfg.RecordDir != "" && cfg.ReplayDir != "" {
+		fmt.Fprintf(os.Stderr, "Error: %s and %s can't be set together\n", config.RecordEnvVar, config.ReplayEnvVar)
+		exit(1)
+	}
+
+	// Settings for git diff that only apply to this run
+	if err := diff.SetGitConfig(gitConfigFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		exit(1)
+	}
+
+	// Every request of the run shares one client and its connections
+	diff.ConfigureHTTPClient(cfg)
+
+	return cfg
+}
+
+// ensureAPIKey makes sure the active model has credentials, prompting for a
+// Claude API key if there isn't one yet
+func ensureAPIKey(cfg *config.Config) {
+	// Offline and replayed explanations don't need credentials
+	if cfg.Offline || cfg.ReplayDir != "" {
+		return
+	}
+
+	// Check if API keys are available based on active model
+	switch cfg.ActiveModel {
+	case config.ModelClaude:
+		if cfg.ClaudeAPIKey == "" {
+			apiKey, err := config.PromptForAPIKey()
+			if errors.Is(err, config.ErrNonInteractive) {
+				fmt.Fprintln(os.Stderr, "Error: no Claude API key is configured and there is no terminal to ask for one; set CLAUDE_API_KEY or run difx reconfigure")
+				exit(1)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error getting Claude API key: %s\n", err)
+				exit(1)
+			}
+			cfg.ClaudeAPIKey = apiKey
+
+			// Save the key to the stored config so command line overrides aren't persisted
+			stored, err := config.LoadOrCreate()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading config: %s\n", err)
+				exit(1)
+			}
+			stored.ClaudeAPIKey = apiKey
+			if err := config.Save(stored); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %s\n", err)
+				exit(1)
+			}
+		}
+	case config.ModelAzureOpenAI:
+		// Azure AD tokens replace the key, but the endpoint is always needed
+		if cfg.AzureAuthMode == config.AzureAuthAAD {
+			if cfg.AzureOpenAIEndpoint == "" {
+				fmt.Fprintf(os.Stderr, "Azure OpenAI endpoint must be set in config or environment variables\n")
+				exit(1)
+			}
+			return
+		}
+		if cfg.AzureOpenAIEndpoint == "" || cfg.AzureOpenAIKey == "" {
+			fmt.Fprintf(os.Stderr, "Azure OpenAI endpoint and key must be set in config or environment variables\n")
+			exit(1)
+		}
+	}
+}
+
+// addProjectContext adds the repository's context file to the config. The
+// default .difx/context.md is optional, but a file named with --project-context
+// or project_context has to be readable; like --with-log, failing to read it is
+// only a warning unless --fail-on-error is on.
+func addProjectContext(ctx context.Context, cfg *config.Config) {
+	if cfg.Offline {
+		return
+	}
+
+	path := cfg.ProjectContextFile
+	if path == "" {
+		path = diff.DefaultContextFile
+	}
+
+	text, truncated, err := diff.ReadProjectContext(ctx, path, diff.MaxProjectContextBytes)
+	if err != nil {
+		if cfg.ProjectContextFile == "" {
+			return
+		}
+		if failOnError {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
+		return
+	}
+
+	if truncated {
+		fmt.Fprintf(os.Stderr, "Warning: %s is larger than %d KB, only its beginning is sent\n", path, diff.MaxProjectContextBytes/1024)
+	}
+	// The token budget only trims the diff, so a large context comes on top of it
+	if tokens := diff.EstimateTokens(text); cfg.MaxInputTokens > 0 && tokens > cfg.MaxInputTokens/4 {
+		fmt.Fprintf(os.Stderr, "Warning: %s adds about %d tokens to every request, on top of the %d token budget\n", path, tokens, cfg.MaxInputTokens)
+	}
+	cfg.ProjectContext = text
+}
+
+// addBackground reads the background file given with --context-file into
+// the config, from stdin when it is "-". It was asked for explicitly, so
+// failing to read it is an error.
+func addBackground(cfg *config.Config) {
+	source := io.Reader(os.Stdin)
+	if contextFile == "-" {
+		// stdin can only be read once, and --confirm-send reads its answer from it
+		if diffFile == "-" {
+			fmt.Fprintln(os.Stderr, "Error: --context-file - and --diff-file - both read stdin")
+			exit(1)
+		}
+		if cfg.ConfirmSend && !assumeYes {
+			fmt.Fprintln(os.Stderr, "Error: --confirm-send can't read an answer after --context-file -; add --yes to skip it")
+			exit(1)
+		}
+	} else {
+		file, err := os.Open(contextFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: can't read the background: %s\n", err)
+			exit(1)
+		}
+		defer file.Close()
+		source = file
+	}
+
+	text, truncated, err := diff.ReadBackground(source, diff.MaxBackgroundBytes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		exit(1)
+	}
+
+	if text == "" {
+		fmt.Fprintf(os.Stderr, "Warning: --context-file %s holds no background\n", contextFile)
+	}
+	if truncated {
+		fmt.Fprintf(os.Stderr, "Warning: the background is larger than %d KB, only its beginning is sent\n", diff.MaxBackgroundBytes/1024)
+	}
+	cfg.Background = text
+}
+
+// addCommitLog adds the recent commit subjects asked for with --with-log to the
+// config. Failing to read them only costs context, so it is just a warning
+// unless --fail-on-error is on.
+func addCommitLog(ctx context.Context, cfg *config.Config) {
+	if withLog <= 0 {
+		return
+	}
+	if withLog > diff.MaxLogCommits {
+		fmt.Fprintf(os.Stderr, "Warning: --with-log is limited to %d commits\n", diff.MaxLogCommits)
+	}
+
+	commits, err := diff.RecentCommits(ctx, withLog)
+	if err != nil {
+		if failOnError {
+			fmt.Fprintf(os.Stderr, "Error reading the commit log: %s\n", err)
+			exit(exitGit)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: could not read the commit log: %s\n", err)
+		return
+	}
+	cfg.RecentCommits = commits
+}
+
+// unchangedSinceLastRun reports, on stderr, when the cache is enabled and the
+// diff is the same as the one explained last time. --force skips the check.
+func unchangedSinceLastRun(cfg *config.Config, diffOutput string) bool {
+	if !cfg.Cache || force || cfg.Resume || !cache.IsExplained(diffOutput) {
+		return false
+	}
+
+	fmt.Fprintln(os.Stderr, "No changes since last explanation (use --force to explain it again)")
+	return true
+}
+
+// markExplained lets a later --cache run skip the diff, once an explanation
+// of it came back. A failed or empty one leaves the diff to be explained again.
+func markExplained(diffOutput string, explanation string) {
+	if strings.TrimSpace(explanation) == "" {
+		return
+	}
+	if err := cache.MarkExplained(diffOutput); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not remember the explained diff: %s\n", err)
+	}
+}
+
+// explain sends the diff to the model and prints the explanation
+func explain(ctx context.Context, cfg *config.Config, diffOutput string) string {
+	// Kept as git gave it for --prepend-diff; everything else expects LF line endings
+	originalDiff := diffOutput
+	diffOutput = diff.NormalizeLineEndings(diffOutput)
+
+	// The model misreads "Subproject commit" lines, so difx describes submodule
+	// changes itself, in the diff or in a list of their own
+	var submodules []diff.SubmoduleChange
+	if cfg.IncludeSubmodules {
+		diffOutput = diff.DescribeSubmodules(ctx, diffOutput)
+	} else {
+		diffOutput, submodules = diff.SeparateSubmodules(diffOutput)
+		for i := range submodules {
+			submodules[i].Log = diff.SubmoduleLog(ctx, submodules[i])
+		}
+	}
+
+	// Nothing is left to explain when only submodules changed
+	if len(submodules) > 0 && strings.TrimSpace(diffOutput) == "" {
+		return printSubmodules(submodules)
+	}
+
+	diffOutput, noNewlineFiles := mustPrepareDiff(cfg, diffOutput)
+
+	// Make the data transfer explicit when asked to
+	if cfg.ConfirmSend && !assumeYes {
+		if !confirmSend(cfg, diffOutput) {
+			fmt.Fprintln(os.Stderr, "Aborted, nothing was sent.")
+			exit(1)
+		}
+	}
+
+	// Make the diff part of the report, ahead of its explanation
+	if prependDiff {
+		printDiff(cfg, originalDiff)
+	}
+
+	// Show how other models explain the same diff instead, each checked for
+	// the files it leaves out
+	var explanation string
+	if len(comparedModels) > 0 {
+		explanation = printComparison(ctx, cfg, diffOutput)
+	} else {
+		explanation = printExplanation(ctx, cfg, diffOutput)
+
+		// A successful call can still come back without any text
+		if failOnError && strings.TrimSpace(explanation) == "" {
+			// printModelOutput has already said so for a single explanation
+			if chunked || jsonOutput {
+				fmt.Fprintln(os.Stderr, emptyResponseMessage)
+			}
+			exit(exitAPI)
+		}
+	}
+	markExplained(originalDiff, explanation)
+
+	if len(comparedModels) == 0 {
+		explanation = checkCoverage(ctx, cfg, diffOutput, explanation)
+	}
+
+	if len(noNewlineFiles) > 0 && !jsonOutput {
+		printNoNewlineFooter(noNewlineFiles)
+	}
+
+	if len(submodules) > 0 {
+		printSubmodules(submodules)
+	}
+
+	// Show how the explanation changed compared to a saved one
+	if baselineFile != "" {
+		// Keep stdout a valid JSON document in --json mode
+		out := os.Stdout
+		if jsonOutput {
+			out = os.Stderr
+		}
+		if err := compareBaseline(out, baselineFile, explanation); err != nil {
+			fmt.Fprintf(os.Stderr, "Error comparing with baseline: %s\n", err)
+			exit(1)
+		}
+	}
+
+	// Hand the explanation to the user's own script, such as a webhook;
+	// printComparison has handed it each of its explanations
+	if cfg.PostHook != "" && len(comparedModels) == 0 && strings.TrimSpace(explanation) != "" {
+		if err := runPostHook(ctx, cfg, diffOutput, explanation); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			if failOnError {
+				exit(exitError)
+			}
+		}
+	}
+
+	return explanation
+}
+
+// checkCoverage warns about changed files the explanation leaves out. With
+// --strict it asks the model to describe them and returns the explanation
+// with the extra entries.
+func checkCoverage(ctx context.Context, cfg *config.Config, diffOutput string, explanation string) string {
+	missing := omittedFiles(cfg, diffOutput, explanation)
+	if len(missing) == 0 {
+		return explanation
+	}
+
+	// A follow-up request continues a single plain text explanation only
+	if !strict || chunked || jsonOutput || cfg.Structured {
+		fmt.Fprintf(os.Stderr, "Warning: model omitted: %s\n", strings.Join(missing, ", "))
+		return explanation
+	}
+
+	fmt.Fprintf(os.Stderr, "Model omitted %s; asking it to describe them\n", strings.Join(missing, ", "))
+	fmt.Fprintln(output)
+	extra, err := printModelOutput(cfg, func(callback func(string)) (string, error) {
+		return diff.ExplainMissingFiles(ctx, diffOutput, cfg, explanation, missing, callback)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nError getting explanation from AI: %s\n", err)
+		exit(exitAPI)
+	}
+	explanation = strings.TrimRight(explanation, "\n") + "\n\n" + extra
+
+	// Say so if the model still leaves some out
+	if missing = diff.MissingFiles(diff.ParseExplanation(explanation), diff.GetChangedFiles(diffOutput)); len(missing) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: model omitted: %s\n", strings.Join(missing, ", "))
+	}
+	return explanation
+}
+
+// omittedFiles returns the changed files the explanation doesn't describe,
+// none when it is expected to leave some out
+func omittedFiles(cfg *config.Config, diffOutput string, explanation string) []string {
+	// Release notes, review comments, --min-severity and a response cut off by the deadline
+	// leave files out on purpose; built-in descriptions never do
+	if cfg.Offline || cfg.Changelog || cfg.Review || (cfg.MinSeverity != "" && cfg.MinSeverity != config.SeverityAll) ||
+		strings.TrimSpace(explanation) == "" || strings.HasSuffix(explanation, diff.MaxResponseTimeNote) {
+		return nil
+	}
+	return diff.MissingFiles(diff.ParseExplanation(explanation), diff.GetChangedFiles(diffOutput))
+}
+
+// noNewFilesMessage is shown when --new-files-only leaves nothing to explain
+const noNewFilesMessage = "No new files found."
+
+// prepareDiff trims the diff down to what is sent to the model. It returns
+// the new diff and the files whose no-newline markers were dropped. The diff
+// is empty when --new-files-only leaves nothing to explain; what to do then,
+// or about an error, is up to the caller.
+func prepareDiff(cfg *config.Config, diffOutput string) (string, []string, error) {
+	// Narrow the diff to the files it adds before anything else looks at it
+	if cfg.NewFilesOnly {
+		var err error
+		if diffOutput, err = onlyNewFiles(diffOutput); err != nil || diffOutput == "" {
+			return "", nil, err
+		}
+	}
+
+	// Lockfiles are long and generated, so only their size is sent. Offline
+	// descriptions don't cost tokens and keep their line counts. A word diff
+	// has no changed lines to count, nor imports or hunks to trim by line.
+	if !cfg.IncludeLockfiles && !cfg.Offline && !cfg.WordDiff {
+		var lockfiles []string
+		diffOutput, lockfiles = diff.SummarizeLockfiles(diffOutput)
+		if len(lockfiles) > 0 {
+			fmt.Fprintf(os.Stderr, "Summarized lockfiles (--include-lockfiles sends them): %s\n", strings.Join(lockfiles, ", "))
+		}
+	}
+
+	// Collapse import blocks that were only reordered, before a long one is
+	// hidden behind the oversized hunk placeholder
+	if cfg.DedupeImports && !cfg.WordDiff {
+		var collapsed int
+		diffOutput, collapsed = diff.CollapseImportReorders(diffOutput)
+		if collapsed > 0 {
+			fmt.Fprintf(os.Stderr, "Collapsed reordered imports in %d files\n", collapsed)
+		}
+	}
+
+	// Leave out the bodies of oversized hunks to save tokens
+	if !cfg.WordDiff {
+		diffOutput = diff.LimitHunkSize(diffOutput, cfg.MaxHunkLines)
+	}
+
+	// Cut down pathologically long lines such as minified code
+	diffOutput, truncated := diff.TruncateLongLines(diffOutput, cfg.MaxLineChars)
+	if truncated > 0 {
+		fmt.Fprintf(os.Stderr, "Truncated %d diff lines longer than %d characters\n", truncated, cfg.MaxLineChars)
+	}
+
+	// Drop git's "\ No newline at end of file" lines and mention them in a footer instead
+	var noNewlineFiles []string
+	if cfg.StripNoNewline {
+		diffOutput, noNewlineFiles = diff.StripNoNewlineMarkers(diffOutput)
+	}
+
+	// Keep the most changed files that fit the token budget, rather than
+	// cutting the diff off. The background is sent too, so it counts toward it.
+	budget := cfg.MaxInputTokens - diff.EstimateTokens(cfg.Background)
+	if cfg.MaxInputTokens > 0 && budget <= 0 {
+		return "", nil, fmt.Errorf("the background takes about %d tokens, leaving nothing of the %d token budget for the diff; shorten it or raise --max-input-tokens", diff.EstimateTokens(cfg.Background), cfg.MaxInputTokens)
+	}
+	if cfg.MaxInputTokens > 0 && diff.EstimateTokens(diffOutput) > budget {
+		var err error
+		if diffOutput, err = trimToTokenBudget(diffOutput, budget); err != nil {
+			return "", nil, err
+		}
+	}
+
+	return diffOutput, noNewlineFiles, nil
+}
+
+// onlyNewFiles keeps the added files of the diff, saying on stderr how many
+// others were left out. It returns "" for a diff without new files.
+func onlyNewFiles(diffOutput string) (string, error) {
+	added, skipped, err := diff.OnlyNewFiles(diffOutput)
+	if err != nil {
+		return "", fmt.Errorf("can't apply --new-files-only to this diff: %w", err)
+	}
+	if summary := diff.SkippedSummary(skipped); summary != "" {
+		fmt.Fprintf(os.Stderr, "Skipped files that aren't new (--new-files-only): %s\n", summary)
+	}
+	return added, nil
+}
+
+// trimToTokenBudget leaves out the least changed files until the diff fits
+// in budget tokens, listing them on stderr
+func trimToTokenBudget(diffOutput string, budget int) (string, error) {
+	files, err := diff.Parse(diffOutput)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: can't apply --max-input-tokens to this diff: %s\n", err)
+		return diffOutput, nil
+	}
+
+	kept, omitted := diff.TrimToTokenBudget(files, budget)
+	if len(kept) == 0 {
+		return "", fmt.Errorf("no changed file fits in %d tokens", budget)
+	}
+	if len(omitted) > 0 {
+		fmt.Fprintf(os.Stderr, "Left out %d files to stay within %d tokens: %s\n", len(omitted), budget, strings.Join(omitted, ", "))
+	}
+
+	return diff.Format(kept), nil
+}
+
+// mustPrepareDiff prepares the diff for a single run, exiting on an error
+// and, having said so, when --new-files-only leaves nothing to explain
+func mustPrepareDiff(cfg *config.Config, diffOutput string) (string, []string) {
+	diffOutput, noNewlineFiles, err := prepareDiff(cfg, diffOutput)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		exit(1)
+	}
+	if diffOutput == "" {
+		fmt.Println(noNewFilesMessage)
+		exit(0)
+	}
+	return diffOutput, noNewlineFiles
+}
+
+// printExplanation gets the explanation from the model, prints it and returns the raw response
+func printExplanation(ctx context.Context, cfg *config.Config, diffOutput string) string {
+	// Explain each file separately, several at a time
+	if chunked {
+		chunks := diff.SplitFileDiffs(diffOutput)
+		progress := newChunkProgress(os.Stderr, stderrIsTerminal() && supportsANSI())
+		explanations, err := diff.ExplainChunksProgress(ctx, chunks, cfg, cfg.Concurrency, progress.start)
+		progress.done()
+
+		// Going over the budget still leaves the files explained so far
+		var budgetErr *diff.BudgetError
+		if err != nil && !errors.As(err, &budgetErr) {
+			fmt.Fprintf(os.Stderr, "\nError getting explanation from AI: %s\n", err)
+			exit(exitAPI)
+		}
+		chunks, explanations = explainedChunks(chunks, explanations)
+
+		if jsonOutput {
+			printJSON(chunks, explanations...)
+		} else {
+			for _, explanation := range explanations {
+				fmt.Fprint(output, themeText(renderText(explanation)))
+				endOutput(output)
+				fmt.Fprintln(output)
+			}
+		}
+
+		reportSpend(cfg, budgetErr)
+		if budgetErr != nil && failOnError {
+			exit(exitAPI)
+		}
+		return strings.Join(explanations, "\n\n")
+	}
+
+	// Buffer the whole response and write it as a single JSON document
+	if jsonOutput {
+		response, err := diff.GetExplanation(ctx, diffOutput, cfg, nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nError getting explanation from AI: %s\n", err)
+			exit(exitAPI)
+		}
+		printJSON([]string{diffOutput}, response)
+		return response
+	}
+
+	// Stream or print the explanation
+	response, err := printModelOutput(cfg, func(callback func(string)) (string, error) {
+		return diff.GetExplanation(ctx, diffOutput, cfg, callback)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nError getting explanation from AI: %s\n", err)
+		exit(exitAPI)
+	}
+	return response
+}
+
+// explainedChunks leaves out the chunks a budget stopped from being explained
+func explainedChunks(chunks []string, explanations []string) ([]string, []string) {
+	var keptChunks, kept []string
+	for i, explanation := range explanations {
+		if explanation != "" {
+			keptChunks = append(keptChunks, chunks[i])
+			kept = append(kept, explanation)
+		}
+	}
+	return keptChunks, kept
+}
+
+// reportSpend tells what a run with --budget cost, and which files it skipped
+// to stay within the budget
+func reportSpend(cfg *config.Config, budgetErr *diff.BudgetError) {
+	switch {
+	case budgetErr != nil:
+		fmt.Fprintf(os.Stderr, "Note: stopped at the $%.2f budget after spending about $%.2f. Not explained (%d): %s\n",
+			budgetErr.Budget, runCost(), len(budgetErr.Skipped), strings.Join(budgetErr.Skipped, ", "))
+	case cfg.Budget > 0 && !cfg.Offline:
+		fmt.Fprintf(os.Stderr, "Spent about $%.2f of the $%.2f budget.\n", runCost(), cfg.Budget)
+	}
+}
+
+// printJSON writes the responses, with the hunk classes of the diffs they
+// explain, to stdout as one JSON document
+func printJSON(diffs []string, responses ...string) {
+	if err := writeJSON(os.Stdout, diffs, responses...); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing JSON: %s\n", err)
+		exit(1)
+	}
+}
+
+// printSubmodules lists the submodule changes after the explanation, on
+// stderr in --json mode to keep stdout a single JSON document. It returns
+// the list.
+func printSubmodules(changes []diff.SubmoduleChange) string {
+	var b strings.Builder
+	b.WriteString("Submodules:\n")
+	for _, change := range changes {
+		for _, line := range change.Lines() {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+
+	out := os.Stdout
+	if jsonOutput {
+		out = os.Stderr
+	}
+	fmt.Fprint(out, b.String())
+	return b.String()
+}
+
+// printDiff writes the diff for --prepend-diff: fenced for Markdown release
+// notes, colored like git diff --color where ANSI codes work, and plain
+// otherwise
+func printDiff(cfg *config.Config, diffOutput string) {
+	diffOutput = strings.TrimRight(diffOutput, "\n") + "\n"
+
+	// Long lines are cut to fit the screen; the model still gets them whole.
+	// Release notes and piped output are documents, so they are kept whole.
+	if !cfg.Changelog && (widthOverride > 0 || stdoutIsTerminal()) {
+		diffOutput = truncateLines(diffOutput, termWidth())
+	}
+
+	switch {
+	case cfg.Changelog:
+		// A longer fence keeps backticks in the diff from closing the block
+		fence := "```"
+		for strings.Contains(diffOutput, fence) {
+			fence += "`"
+		}
+		fmt.Fprintf(output, "%sdiff\n%s%s\n\n", fence, diffOutput, fence)
+	case color.NoColor:
+		fmt.Fprintln(output, diffOutput)
+	case cfg.WordDiff:
+		fmt.Fprintln(output, diff.ColorizeWordDiff(diffOutput, sequence(activeScheme.add), sequence(activeScheme.del)))
+	default:
+		fmt.Fprintln(output, diff.Colorize(diffOutput, sequence(activeScheme.add), sequence(activeScheme.del)))
+	}
+}
+
+// printNoNewlineFooter prints a dim note listing files that don't end with a newline
+func printNoNewlineFooter(files []string) {
+	dim := color.New(color.Faint)
+	dim.Printf("Note: no newline at end of file in %s\n", strings.Join(files, ", "))
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/tydin/difx/cache"
+	"github.com/tydin/difx/config"
+)
+
+func TestPrepareDiffKeepsWordDiffHunks(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "diff", "testdata", "worddiff-list.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, _, err := prepareDiff(&config.Config{WordDiff: true, MaxHunkLines: 2, DedupeImports: true}, string(data))
+	if err != nil {
+		t.Fatalf("prepareDiff: %s", err)
+	}
+	if got != string(data) {
+		t.Errorf("prepareDiff changed a word diff:\n%s", got)
+	}
+}
+
+func TestUnchangedSinceLastRunAfterExplanation(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	cfg := &config.Config{Cache: true}
+	diffOutput := "diff --git a/a.go b/a.go\n"
+
+	// The diff is saved for difx again before the request, which then fails
+	if err := cache.SaveLastDiff(diffOutput); err != nil {
+		t.Fatal(err)
+	}
+	markExplained(diffOutput, "")
+	if unchangedSinceLastRun(cfg, diffOutput) {
+		t.Error("a diff without an explanation counts as unchanged")
+	}
+
+	markExplained(diffOutput, "Adds a.go")
+	captureOutput(t, func() {
+		if !unchangedSinceLastRun(cfg, diffOutput) {
+			t.Error("the explained diff doesn't count as unchanged")
+		}
+	})
+}
+
+func TestPrepareDiffRejectsBackgroundOverBudget(t *testing.T) {
+	cfg := &config.Config{MaxInputTokens: 10, Background: strings.Repeat("ticket text ", 40)}
+	if _, _, err := prepareDiff(cfg, "diff --git a/a.go b/a.go\n+x\n"); err == nil {
+		t.Error("prepareDiff accepted a background larger than the token budget")
+	}
+}
+
+func TestExplainComparisonFinishesTheReport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/v1/messages") {
+			w.Write([]byte(`{"content":[{"type":"text","text":"DETAILS:\n\ta.go:\n\t\t+ x\n"}]}`))
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"DETAILS:\n\ta.go:\n\t\t+ x\n\tb.go:\n\t\t+ y\n"}}]}`))
+	}))
+	defer server.Close()
+
+	original := comparedModels
+	comparedModels = []string{config.ModelClaude, config.ModelAzureOpenAI}
+	t.Cleanup(func() { comparedModels = original })
+
+	cfg := &config.Config{
+		ClaudeAPIKey:        "key",
+		ClaudeBaseURL:       server.URL,
+		AzureOpenAIKey:      "key",
+		AzureOpenAIEndpoint: server.URL,
+		StripNoNewline:      true,
+	}
+	diffOutput := "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-a\n+b\n\\ No newline at end of file\n" +
+		"diff --git a/b.go b/b.go\n--- a/b.go\n+++ b/b.go\n@@ -1 +1 @@\n-a\n+b\n" +
+		"diff --git a/vendor/lib b/vendor/lib\nindex 1a2b3c4..5d6e7f8 160000\n--- a/vendor/lib\n+++ b/vendor/lib\n@@ -1 +1 @@\n" +
+		"-Subproject commit 1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d\n+Subproject commit 5d6e7f8091a2b3c4d1a2b3c4d5e6f708192a3b4c\n"
+
+	captureModelOutput(t)
+	var footer strings.Builder
+	colorOutput := color.Output
+	color.Output = &footer
+	t.Cleanup(func() { color.Output = colorOutput })
+	stdout, stderr := captureOutput(t, func() { explain(context.Background(), cfg, diffOutput) })
+
+	// The submodules and the no-newline footer follow the explanations, and
+	// each model is checked for the files it left out
+	if !strings.Contains(stdout, "Submodules:") || !strings.Contains(stdout, "vendor/lib") {
+		t.Errorf("no submodule list after the comparison:\n%s", stdout)
+	}
+	if !strings.Contains(footer.String(), "no newline at end of file in a.go") {
+		t.Errorf("no no-newline footer after the comparison: %q", footer.String())
+	}
+	if !strings.Contains(stderr, "Warning: claude omitted: b.go") || strings.Contains(stderr, "azure_openai omitted") {
+		t.Errorf("omitted files after the comparison:\n%s", stderr)
+	}
+}
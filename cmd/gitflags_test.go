@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func TestGitFlagArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{name: "defaults", args: nil, want: nil},
+		{name: "explicit patch", args: []string{"--patch"}, want: []string{"-p"}},
+		{name: "patch disabled", args: []string{"--patch=false"}, want: nil},
+		{name: "stat", args: []string{"--stat"}, want: []string{"--stat"}},
+		{name: "name-only", args: []string{"--name-only"}, want: []string{"--name-only"}},
+		{name: "name-status", args: []string{"--name-status"}, want: []string{"--name-status"}},
+		{name: "filter and context", args: []string{"--diff-filter=AM", "-U", "5"}, want: []string{"--diff-filter=AM", "-U5"}},
+		{name: "reverse", args: []string{"--reverse"}, want: []string{"-R"}},
+		{name: "reverse shorthand with stat", args: []string{"-R", "--stat"}, want: []string{"--stat", "-R"}},
+		{name: "word diff", args: []string{"--word-diff"}, want: []string{"--word-diff=plain"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := pflag.NewFlagSet("difx", pflag.ContinueOnError)
+			addGitFlags(flags)
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatalf("Parse(%v): %s", tt.args, err)
+			}
+
+			if got := gitFlagArgs(flags); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("gitFlagArgs(%v) = %v, want %v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitArgsAfterDash(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		wantGit   []string
+		wantModel string
+	}{
+		{
+			name:      "git flags after dash",
+			args:      []string{"--model", "claude", "--", "--stat", "HEAD~1"},
+			wantGit:   []string{"--stat", "HEAD~1"},
+			wantModel: "claude",
+		},
+		{
+			name:      "difx flag after dash goes to git",
+			args:      []string{"--", "--model", "claude"},
+			wantGit:   []string{"--model", "claude"},
+			wantModel: "",
+		},
+		{
+			name:      "revision before dash",
+			args:      []string{"HEAD~1", "--name-only", "--", "--", "main.go"},
+			wantGit:   []string{"--name-only", "HEAD~1", "--", "main.go"},
+			wantModel: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			var model string
+			cmd := &cobra.Command{
+				Use:  "difx",
+				Args: cobra.ArbitraryArgs,
+				Run: func(cmd *cobra.Command, args []string) {
+					got = gitArgs(cmd, args)
+				},
+			}
+			addGitFlags(cmd.Flags())
+			cmd.Flags().StringVar(&model, "model", "", "")
+			cmd.SetArgs(tt.args)
+
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute(%v): %s", tt.args, err)
+			}
+			if !reflect.DeepEqual(got, tt.wantGit) {
+				t.Errorf("git args = %v, want %v", got, tt.wantGit)
+			}
+			if model != tt.wantModel {
+				t.Errorf("model = %q, want %q", model, tt.wantModel)
+			}
+		})
+	}
+}
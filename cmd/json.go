@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/tydin/difx/diff"
+)
+
+// jsonFallback wraps model output that isn't valid JSON
+type jsonFallback struct {
+	Raw        string           `json:"raw"`
+	ParseError string           `json:"parse_error"`
+	Hunks      []diff.HunkLabel `json:"hunks,omitempty"`
+}
+
+// jsonDocument returns the model response as a JSON value, or a fallback
+// object holding the raw text and the parse error if it isn't valid JSON.
+// The classes of the explained diff's hunks are added as a hunks field.
+func jsonDocument(response string, diffOutput string) interface{} {
+	var hunks []diff.HunkLabel
+	if files, err := diff.Parse(diffOutput); err == nil {
+		hunks = diff.ClassifyHunks(files)
+	}
+
+	var value json.RawMessage
+	if err := json.Unmarshal([]byte(response), &value); err != nil {
+		return jsonFallback{Raw: response, ParseError: err.Error(), Hunks: hunks}
+	}
+	return withHunks(value, hunks)
+}
+
+// withHunks adds the hunk classes as the last field of a JSON object, keeping
+// the model's fields in their order. Other JSON values are left as they are.
+func withHunks(value json.RawMessage, hunks []diff.HunkLabel) json.RawMessage {
+	trimmed := bytes.TrimSpace(value)
+	if len(hunks) == 0 || len(trimmed) < 2 || trimmed[0] != '{' {
+		return value
+	}
+
+	encoded, err := json.Marshal(hunks)
+	if err != nil {
+		return value
+	}
+
+	body := bytes.TrimSpace(trimmed[1 : len(trimmed)-1])
+	var out bytes.Buffer
+	out.WriteByte('{')
+	if len(body) > 0 {
+		out.Write(body)
+		out.WriteByte(',')
+	}
+	out.WriteString(`"hunks":`)
+	out.Write(encoded)
+	out.WriteByte('}')
+	return out.Bytes()
+}
+
+// writeJSON encodes the responses as one indented JSON document and writes it
+// in a single call, so a reader never sees partial output. diffs holds the
+// diff each response explains, or is nil to leave out the hunk classes. One
+// response is written as is; several become an array.
+func writeJSON(w io.Writer, diffs []string, responses ...string) error {
+	diffOf := func(i int) string {
+		if i < len(diffs) {
+			return diffs[i]
+		}
+		return ""
+	}
+
+	var doc interface{}
+	if len(responses) == 1 {
+		doc = jsonDocument(responses[0], diffOf(0))
+	} else {
+		docs := make([]interface{}, len(responses))
+		for i, response := range responses {
+			docs[i] = jsonDocument(response, diffOf(i))
+		}
+		doc = docs
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriteJSONValid(t *testing.T) {
+	var out strings.Builder
+	if err := writeJSON(&out, nil, `{"summary":"Bump version","files":[]}`); err != nil {
+		t.Fatalf("writeJSON: %s", err)
+	}
+
+	want := "{\n  \"summary\": \"Bump version\",\n  \"files\": []\n}\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestWriteJSONFallback(t *testing.T) {
+	var out strings.Builder
+	if err := writeJSON(&out, nil, "SUMMARY:\n  - not json"); err != nil {
+		t.Fatalf("writeJSON: %s", err)
+	}
+
+	var doc jsonFallback
+	if err := json.Unmarshal([]byte(out.String()), &doc); err != nil {
+		t.Fatalf("output is not valid JSON: %s\n%s", err, out.String())
+	}
+	if doc.Raw != "SUMMARY:\n  - not json" {
+		t.Errorf("raw = %q", doc.Raw)
+	}
+	if doc.ParseError == "" {
+		t.Error("parse_error is empty")
+	}
+}
+
+func TestWriteJSONMultiple(t *testing.T) {
+	var out strings.Builder
+	if err := writeJSON(&out, nil, `{"summary":"a"}`, "not json"); err != nil {
+		t.Fatalf("writeJSON: %s", err)
+	}
+
+	var docs []map[string]interface{}
+	if err := json.Unmarshal([]byte(out.String()), &docs); err != nil {
+		t.Fatalf("output is not a valid JSON array: %s\n%s", err, out.String())
+	}
+	if len(docs) != 2 || docs[0]["summary"] != "a" || docs[1]["raw"] != "not json" {
+		t.Errorf("unexpected documents: %v", docs)
+	}
+}
+
+func TestWriteJSONHunks(t *testing.T) {
+	diffOutput := "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1,2 @@\n x()\n+y()\n"
+
+	var out strings.Builder
+	if err := writeJSON(&out, []string{diffOutput}, `{"summary":"Call y"}`); err != nil {
+		t.Fatalf("writeJSON: %s", err)
+	}
+
+	want := "{\n  \"summary\": \"Call y\",\n  \"hunks\": [\n    {\n      \"path\": \"a.go\",\n      \"header\": \"@@ -1 +1,2 @@\",\n      \"class\": \"addition\"\n    }\n  ]\n}\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/tydin/difx/diff"
+)
+
+// resolveNoteCommit finds the commit whose explanation --attach-note saves.
+// It fails early, before any API call, when there is no single commit or it
+// already has a note that --force doesn't allow replacing.
+func resolveNoteCommit(ctx context.Context, args []string) string {
+	if diffFile != "" || fromClipboard {
+		fmt.Fprintln(os.Stderr, "Error: --attach-note needs the diff to come from git, not --diff-file or --from-clipboard")
+		exit(1)
+	}
+
+	commit, err := diff.NoteCommit(ctx, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --attach-note: %s\n", err)
+		exit(exitGit)
+	}
+
+	if !force && diff.HasNote(ctx, commit) {
+		fmt.Fprintf(os.Stderr, "Error: commit %s already has a note (use --force to overwrite it)\n", commit)
+		exit(1)
+	}
+
+	return commit
+}
+
+// attachExplanationNote saves the explanation as the commit's note, without colors
+func attachExplanationNote(ctx context.Context, commit string, explanation string) {
+	if err := diff.AddNote(ctx, commit, plainText(explanation)+"\n", force); err != nil {
+		fmt.Fprintf(os.Stderr, "Error attaching the note: %s\n", err)
+		exit(exitGit)
+	}
+	fmt.Fprintf(os.Stderr, "Attached the explanation as a note to %s\n", commit)
+}
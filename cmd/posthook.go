@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/tydin/difx/config"
+	"github.com/tydin/difx/diff"
+)
+
+// Environment variables that tell a --post-hook command about the explanation
+const (
+	hookModelEnvVar = "DIFX_MODEL"
+	hookFilesEnvVar = "DIFX_FILES"
+	hookCountEnvVar = "DIFX_FILE_COUNT"
+)
+
+// runPostHook runs the --post-hook command through the shell with the
+// explanation, as plain text, on its stdin. Its output goes to stderr, so it
+// can't mix with the explanation on stdout. A hook that fails or exits with a
+// non-zero status is returned as an error.
+func runPostHook(ctx context.Context, cfg *config.Config, diffOutput string, explanation string) error {
+	name, args := shellCommand(cfg.PostHook)
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = strings.NewReader(plainText(explanation) + "\n")
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), hookEnv(cfg, diffOutput)...)
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("post hook %q exited with status %d", cfg.PostHook, exitErr.ExitCode())
+	}
+	if err != nil {
+		return fmt.Errorf("error running post hook %q: %w", cfg.PostHook, err)
+	}
+	return nil
+}
+
+// hookEnv returns the metadata a hook gets: the model, or "offline" for
+// built-in descriptions, and the changed files, one per line
+func hookEnv(cfg *config.Config, diffOutput string) []string {
+	model := cfg.ActiveModel
+	if cfg.Offline {
+		model = "offline"
+	}
+	files := diff.GetChangedFiles(diffOutput)
+	return []string{
+		hookModelEnvVar + "=" + model,
+		hookFilesEnvVar + "=" + strings.Join(files, "\n"),
+		hookCountEnvVar + "=" + strconv.Itoa(len(files)),
+	}
+}
+
+// shellCommand returns the shell invocation that runs a command line
+func shellCommand(command string) (string, []string) {
+	if runtime.GOOS == "windows" {
+		return "cmd", []string{"/C", command}
+	}
+	return "sh", []string{"-c", command}
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/tydin/difx/config"
+)
+
+func TestRunPostHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook is a POSIX shell command")
+	}
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input")
+	env := filepath.Join(dir, "env")
+	cfg := &config.Config{
+		ActiveModel: config.ModelClaude,
+		PostHook:    "cat > " + input + "; printf '%s|%s' \"$DIFX_MODEL\" \"$DIFX_FILE_COUNT\" > " + env,
+	}
+	diffOutput := "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-a\n+b\n"
+
+	if err := runPostHook(context.Background(), cfg, diffOutput, "\033[32;1mAdded\033[0m b\n"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(input); string(got) != "Added b\n" {
+		t.Errorf("hook stdin = %q, want the plain text explanation", got)
+	}
+	if got, _ := os.ReadFile(env); string(got) != "claude|1" {
+		t.Errorf("hook environment = %q", got)
+	}
+
+	cfg.PostHook = "exit 4"
+	if err := runPostHook(context.Background(), cfg, diffOutput, "text"); err == nil || !strings.Contains(err.Error(), "exited with status 4") {
+		t.Errorf("err = %v", err)
+	}
+}
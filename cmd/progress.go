@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/tydin/difx/diff"
+)
+
+// clearLine moves to the start of the line and erases it
+const clearLine = "\r\x1b[K"
+
+// chunkProgress prints which file a chunked run is explaining. On a terminal
+// the line is updated in place, otherwise each file gets its own line.
+type chunkProgress struct {
+	out     io.Writer
+	inPlace bool
+	shown   bool
+}
+
+// newChunkProgress returns the progress printer for a chunked run, or nil
+// with --quiet
+func newChunkProgress(out io.Writer, inPlace bool) *chunkProgress {
+	if quiet {
+		return nil
+	}
+	return &chunkProgress{out: out, inPlace: inPlace}
+}
+
+// start shows that a chunk's explanation has begun
+func (p *chunkProgress) start(progress diff.ChunkProgress) {
+	if p == nil {
+		return
+	}
+
+	line := fmt.Sprintf("Explaining file %d/%d", progress.Started, progress.Total)
+	if progress.Path != "" {
+		line += ": " + progress.Path
+	}
+
+	// A line that wraps can't be cleared, so it has to fit the terminal
+	if p.inPlace {
+		fmt.Fprint(p.out, clearLine+fitWidth(line, termWidth()-1))
+	} else {
+		fmt.Fprintln(p.out, line)
+	}
+	p.shown = true
+}
+
+// done removes the in-place line before the explanations are printed
+func (p *chunkProgress) done() {
+	if p == nil || !p.inPlace || !p.shown {
+		return
+	}
+	fmt.Fprint(p.out, clearLine)
+}
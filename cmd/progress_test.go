@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tydin/difx/diff"
+)
+
+func TestChunkProgress(t *testing.T) {
+	var out strings.Builder
+	progress := newChunkProgress(&out, false)
+	progress.start(diff.ChunkProgress{Started: 1, Total: 2, Path: "a.go"})
+	progress.start(diff.ChunkProgress{Started: 2, Total: 2})
+	progress.done()
+
+	if want := "Explaining file 1/2: a.go\nExplaining file 2/2\n"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestChunkProgressInPlace(t *testing.T) {
+	var out strings.Builder
+	progress := newChunkProgress(&out, true)
+	progress.start(diff.ChunkProgress{Started: 1, Total: 2, Path: "a.go"})
+	progress.start(diff.ChunkProgress{Started: 2, Total: 2, Path: "b.go"})
+	progress.done()
+
+	want := clearLine + "Explaining file 1/2: a.go" + clearLine + "Explaining file 2/2: b.go" + clearLine
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestChunkProgressFitsWidth(t *testing.T) {
+	widthOverride = 20
+	defer func() { widthOverride = 0 }()
+
+	var out strings.Builder
+	progress := newChunkProgress(&out, true)
+	progress.start(diff.ChunkProgress{Started: 1, Total: 1, Path: "internal/parser/a.go"})
+
+	// One column is left free, so the cursor doesn't wrap
+	if want := clearLine + "…ternal/parser/a.go"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestChunkProgressQuiet(t *testing.T) {
+	quiet = true
+	defer func() { quiet = false }()
+
+	var out strings.Builder
+	progress := newChunkProgress(&out, false)
+	progress.start(diff.ChunkProgress{Started: 1, Total: 1, Path: "a.go"})
+	progress.done()
+
+	if out.String() != "" {
+		t.Errorf("--quiet still printed %q", out.String())
+	}
+}
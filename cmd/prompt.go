@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tydin/difx/config"
+	"github.com/tydin/difx/diff"
+)
+
+var promptCmd = &cobra.Command{
+	Use:   "prompt [<commit>...] [--] [<git diff args>...]",
+	Short: "Print the prompt difx would send, without sending it",
+	Long: `Build the prompt for a diff exactly as difx would send it and print it, for
+tuning prompts or pasting into a model playground. It takes the same git diff
+arguments as difx and honors the options that change the prompt, such as
+--persona, --with-log, --project-context, --full-context, --review and
+--changelog. Nothing is sent and no API key is needed. With --chunked, the
+prompt of every file is printed under its path.`,
+	Args: cobra.ArbitraryArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, span := startSpan(cmd, "difx prompt")
+		defer span.End()
+
+		cfg := loadConfig()
+		cfg.Reverse = reversed(cmd)
+		cfg.WordDiff = wordDiffed(cmd, cfg)
+
+		diffOutput, err := readDiff(ctx, gitArgs(cmd, compareArgs(cmd, cfg, args)))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading diff: %s\n", err)
+			exit(exitGit)
+		}
+		if diffOutput == "" {
+			fmt.Println("No differences found.")
+			return
+		}
+
+		// The same preparation as an explanation, so the prompt is the one sent
+		diffOutput = diff.NormalizeLineEndings(diffOutput)
+		if cfg.IncludeSubmodules {
+			diffOutput = diff.DescribeSubmodules(ctx, diffOutput)
+		} else {
+			diffOutput, _ = diff.SeparateSubmodules(diffOutput)
+		}
+		diffOutput, _ = mustPrepareDiff(cfg, diffOutput)
+		addCommitLog(ctx, cfg)
+		addProjectContext(ctx, cfg)
+
+		chunks := []string{diffOutput}
+		if chunked {
+			chunks = diff.SplitFileDiffs(diffOutput)
+		}
+		printPrompts(ctx, os.Stdout, cfg, chunks)
+	},
+}
+
+// printPrompts writes the prompt of each chunk as plain text, under the
+// path of its file when there are several, and says how big they are on
+// stderr
+func printPrompts(ctx context.Context, w io.Writer, cfg *config.Config, chunks []string) {
+	tokens := 0
+	for i, chunk := range chunks {
+		prompt := diff.BuildPrompt(ctx, chunk, cfg)
+		tokens += diff.EstimateTokens(prompt)
+
+		if len(chunks) > 1 {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "===== %s =====\n\n", strings.Join(diff.GetChangedFiles(chunk), ", "))
+		}
+		fmt.Fprintln(w, strings.TrimRight(prompt, "\n"))
+	}
+	noun := "prompt"
+	if len(chunks) > 1 {
+		noun = "prompts"
+	}
+	fmt.Fprintf(os.Stderr, "About %d tokens in %d %s\n", tokens, len(chunks), noun)
+}
+
+func init() {
+	addGitFlags(promptCmd.Flags())
+	rootCmd.AddCommand(promptCmd)
+}
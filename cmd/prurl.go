@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tydin/difx/cache"
+	"github.com/tydin/difx/diff"
+)
+
+var prURLCmd = &cobra.Command{
+	Use:   "pr-url <url>",
+	Short: "Explain a GitHub pull request without cloning it",
+	Long: `Download the diff of a GitHub pull request and explain it.
+Set GITHUB_TOKEN to explain pull requests in private repositories.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, span := startSpan(cmd, "difx pr-url")
+		defer span.End()
+
+		cfg := loadConfig()
+
+		// Downloading the pull request would call GitHub
+		if cfg.Offline {
+			fmt.Fprintln(os.Stderr, "Error: difx pr-url needs the network and can't run in offline mode")
+			exit(1)
+		}
+
+		pr, err := diff.ParsePRURL(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			exit(1)
+		}
+
+		diffOutput, err := diff.FetchPRDiff(ctx, pr, os.Getenv(diff.GitHubTokenEnvVar))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error fetching pull request: %s\n", err)
+			exit(exitGit)
+		}
+
+		if diffOutput == "" {
+			fmt.Println("No differences found.")
+			return
+		}
+
+		// Don't pay for the same explanation twice in a row
+		if unchangedSinceLastRun(cfg, diffOutput) {
+			return
+		}
+
+		// Remember the diff so it can be explained again later
+		if err := cache.SaveLastDiff(diffOutput); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not save the diff for difx again: %s\n", err)
+		}
+
+		ensureAPIKey(cfg)
+		explain(ctx, cfg, diffOutput)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(prURLCmd)
+}
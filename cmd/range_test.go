@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestWithRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "no range", args: []string{"main.go"}, want: []string{"main.go"}},
+		{name: "from only", from: "v1.0.0", want: []string{"v1.0.0..HEAD"}},
+		{name: "from and to", from: "v1.0.0", to: "v1.1.0", args: []string{"--", "cmd"}, want: []string{"v1.0.0..v1.1.0", "--", "cmd"}},
+		{name: "to only", to: "v1.1.0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := withRange(tt.from, tt.to, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %t", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("withRange = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithCompareBase(t *testing.T) {
+	tests := []struct {
+		name        string
+		base        string
+		defaultBase string
+		args        []string
+		want        []string
+		wantErr     bool
+	}{
+		{name: "no base", args: []string{"--stat"}, want: []string{"--stat"}},
+		{name: "head", base: "head", args: []string{"--", "cmd"}, want: []string{"HEAD", "--", "cmd"}},
+		{name: "index", base: "index", want: []string{"--cached"}},
+		{name: "working", base: "working", args: []string{"--stat"}, want: []string{"--stat"}},
+		{name: "default", defaultBase: "head", want: []string{"HEAD"}},
+		{name: "flag over default", base: "index", defaultBase: "head", want: []string{"--cached"}},
+		// root.go exists in the package directory, so it is a path
+		{name: "path before dash", defaultBase: "index", args: []string{"root.go"}, want: []string{"--cached", "root.go"}},
+		{name: "default skipped for a commit", defaultBase: "head", args: []string{"HEAD~2"}, want: []string{"HEAD~2"}},
+		{name: "default skipped for cached", defaultBase: "head", args: []string{"--staged"}, want: []string{"--staged"}},
+		{name: "flag with a range", base: "head", args: []string{"v1.0.0..HEAD"}, wantErr: true},
+		{name: "flag with cached", base: "index", args: []string{"--cached"}, wantErr: true},
+		{name: "unknown base", base: "stash", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := withCompareBase(tt.base, tt.defaultBase, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %t", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("withCompareBase = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOnlyStagedSkipsOtherDiffs(t *testing.T) {
+	// None of these compare the working tree, so git isn't asked about the index
+	for _, args := range [][]string{{"--cached"}, {"--staged"}, {"HEAD~1"}, {"main..feature", "--", "cmd"}} {
+		if onlyStaged(context.Background(), args) {
+			t.Errorf("onlyStaged(%q) = true", args)
+		}
+	}
+
+	diffFile = "changes.patch"
+	t.Cleanup(func() { diffFile = "" })
+	if onlyStaged(context.Background(), nil) {
+		t.Error("onlyStaged = true for --diff-file")
+	}
+}
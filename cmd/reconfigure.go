@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tydin/difx/config"
+	"github.com/tydin/difx/diff"
+)
+
+// reconfigureProvider is the provider whose credentials are replaced
+var reconfigureProvider string
+
+var reconfigureCmd = &cobra.Command{
+	Use:   "reconfigure",
+	Short: "Replace the credentials of a provider",
+	Long: `Ask for new credentials for a provider, such as a rotated API key, check
+them with a small request and save them to the config file. Keys are read
+without echo, and the settings of other providers are left as they are.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, span := startSpan(cmd, "difx reconfigure")
+		defer span.End()
+
+		// The settings in effect, for the endpoint and headers the check uses
+		cfg := loadConfig()
+		if cfg.Offline {
+			fmt.Fprintln(os.Stderr, "Error: difx reconfigure checks the credentials with the API and can't run in offline mode")
+			exit(1)
+		}
+
+		// The new credentials are typed in, so there must be someone to type them
+		if !config.CanPrompt() {
+			fmt.Fprintln(os.Stderr, "Error: difx reconfigure asks for the new credentials and needs an interactive terminal; in CI set CLAUDE_API_KEY or AZURE_OPENAI_KEY instead")
+			exit(1)
+		}
+
+		provider := reconfigureProvider
+		if provider == "" {
+			provider = cfg.ActiveModel
+		}
+
+		// Only the file as saved is changed, so environment overrides aren't persisted
+		stored, err := config.LoadStored()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %s\n", err)
+			exit(1)
+		}
+
+		check := *cfg
+		check.ActiveModel = provider
+		switch provider {
+		case config.ModelClaude:
+			key := promptForKey("New Claude API key: ")
+			check.ClaudeAPIKey = key
+			pingProvider(ctx, &check)
+			stored.ClaudeAPIKey = key
+			warnOverride("CLAUDE_API_KEY")
+		case config.ModelAzureOpenAI:
+			endpoint, err := config.PromptForValue("Azure OpenAI endpoint", stored.AzureOpenAIEndpoint)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading endpoint: %s\n", err)
+				exit(1)
+			}
+			if err := diff.ValidateBaseURL(endpoint); err != nil {
+				fmt.Fprintf(os.Stderr, "Error in endpoint: %s\n", err)
+				exit(1)
+			}
+			check.AzureOpenAIEndpoint = endpoint
+
+			// Azure AD signs in without a key
+			var key string
+			if stored.AzureAuthMode != config.AzureAuthAAD {
+				key = promptForKey("New Azure OpenAI key: ")
+				check.AzureOpenAIKey = key
+			}
+			pingProvider(ctx, &check)
+
+			stored.AzureOpenAIEndpoint = endpoint
+			if stored.AzureAuthMode != config.AzureAuthAAD {
+				stored.AzureOpenAIKey = key
+			}
+			warnOverride("AZURE_OPENAI_ENDPOINT")
+			warnOverride("AZURE_OPENAI_KEY")
+		default:
+			fmt.Fprintf(os.Stderr, "Error: unknown provider %q (use %s or %s)\n", provider, config.ModelClaude, config.ModelAzureOpenAI)
+			exit(1)
+		}
+
+		if err := config.Save(stored); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving config: %s\n", err)
+			exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Saved the new %s credentials to %s/%s\n", provider, config.ConfigDir, config.ConfigFile)
+	},
+}
+
+// promptForKey reads a new key without echo. An empty answer changes nothing.
+func promptForKey(prompt string) string {
+	key, err := config.PromptForSecret(prompt)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading key: %s\n", err)
+		exit(1)
+	}
+	if key == "" {
+		fmt.Fprintln(os.Stderr, "No key entered, nothing was changed.")
+		exit(1)
+	}
+	return key
+}
+
+// pingProvider checks the new credentials before they replace the saved ones
+func pingProvider(ctx context.Context, cfg *config.Config) {
+	fmt.Fprintf(os.Stderr, "Checking the credentials with %s...\n", cfg.ActiveModel)
+	if err := diff.Ping(ctx, cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\nThe saved credentials were not changed.\n", err)
+		exit(exitAPI)
+	}
+}
+
+// warnOverride points out an environment variable that takes precedence
+// over the setting that was just saved
+func warnOverride(name string) {
+	if os.Getenv(name) != "" {
+		fmt.Fprintf(os.Stderr, "Note: %s is set and overrides the saved setting\n", name)
+	}
+}
+
+func init() {
+	reconfigureCmd.Flags().StringVar(&reconfigureProvider, "provider", "", "Provider whose credentials to replace: claude or azure_openai (default: the active model)")
+	reconfigureCmd.RegisterFlagCompletionFunc("provider", fixedCompletion(config.ModelClaude, config.ModelAzureOpenAI))
+	rootCmd.AddCommand(reconfigureCmd)
+}
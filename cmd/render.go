@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/tydin/difx/config"
+)
+
+// legacyOpeners are the old color markers whose closing marker may arrive in a later chunk
+var legacyOpeners = []string{"[ADD]", "[DEL]", "GREEN_START", "RED_START"}
+
+// streamRenderer renders streamed model output incrementally. Text is only
+// converted once: completed content is rendered and written as soon as it is
+// safe, and just the unfinished tail is kept back for the next chunk.
+type streamRenderer struct {
+	w       io.Writer
+	render  func(string) string
+	pending string
+
+	// midLine is set while the last text written didn't end a line
+	midLine bool
+}
+
+// newStreamRenderer returns a renderer that writes to w, converting text with render
+func newStreamRenderer(w io.Writer, render func(string) string) *streamRenderer {
+	return &streamRenderer{w: w, render: render}
+}
+
+// Write adds a chunk of model output and writes whatever part of it is complete
+func (r *streamRenderer) Write(chunk string) {
+	r.pending += chunk
+
+	// Markers and escape sequences never span lines, so every complete line is safe to render
+	if i := strings.LastIndexByte(r.pending, '\n'); i >= 0 {
+		r.emit(r.pending[:i+1])
+		r.pending = r.pending[i+1:]
+	}
+
+	// A line that may become a header or delimiter is themed once it's whole
+	if !r.midLine && mayBeThemed(r.pending) {
+		return
+	}
+
+	// Wait for the rest of the line if a legacy marker still needs its closing tag
+	for _, opener := range legacyOpeners {
+		if strings.Contains(r.pending, opener) {
+			return
+		}
+	}
+
+	// Hold back anything that could be the start of an escape sequence or
+	// marker, and a character whose remaining bytes are in the next chunk
+	ready := completeRunes(cleanIncompleteEscapeSequences(r.pending))
+	if ready != "" {
+		r.emit(ready)
+		r.pending = r.pending[len(ready):]
+	}
+}
+
+// completeRunes returns text without a trailing UTF-8 sequence that was cut
+// off, which would be written as a replacement character. Only the last few
+// bytes can belong to it, since a rune is at most utf8.UTFMax bytes.
+func completeRunes(text string) string {
+	for i := 1; i <= utf8.UTFMax && i <= len(text); i++ {
+		start := len(text) - i
+		if utf8.RuneStart(text[start]) {
+			if utf8.FullRuneInString(text[start:]) {
+				return text
+			}
+			return text[:start]
+		}
+	}
+	return text
+}
+
+// Flush writes everything still held back. Call it once the stream is done.
+func (r *streamRenderer) Flush() {
+	if r.pending != "" {
+		r.emit(r.pending)
+		r.pending = ""
+	}
+}
+
+// emit renders text and writes it, styling it with the active theme
+func (r *streamRenderer) emit(text string) {
+	io.WriteString(r.w, applyTheme(r.render(text), r.midLine))
+	r.midLine = !strings.HasSuffix(text, "\n")
+}
+
+// output is where model output is written. It is stdout, and tests or a
+// command that keeps the output elsewhere can swap it.
+var output io.Writer = os.Stdout
+
+// resetCode is written at the end of model output to close any color the
+// model left open, so it doesn't carry over to the shell prompt. It is empty
+// where colors aren't rendered.
+var resetCode = "\033[0m"
+
+// endOutput ends a piece of model output with the color reset and a newline
+func endOutput(w io.Writer) {
+	fmt.Fprint(w, resetCode+"\n")
+}
+
+// emptyResponseMessage is shown instead of an explanation when the model
+// answers without any text
+const emptyResponseMessage = "The model returned an empty explanation."
+
+// printModelOutput runs a model call and prints its output to output. When
+// streaming is enabled, chunks are rendered as they arrive; otherwise the full
+// response is rendered once the call returns. Every command that shows model
+// output goes through here so they all stream the same way.
+func printModelOutput(cfg *config.Config, call func(callback func(string)) (string, error)) (string, error) {
+	// Non-streaming mode (CI mode)
+	if !cfg.Streaming {
+		// Simple callback that does nothing since we'll print the full response at the end
+		response, err := call(func(chunk string) {})
+		if err != nil {
+			return "", err
+		}
+
+		// Process and print the full response
+		if strings.TrimSpace(response) == "" {
+			fmt.Fprintln(os.Stderr, emptyResponseMessage)
+		} else {
+			fmt.Fprint(output, themeText(renderText(response)))
+			endOutput(output)
+		}
+		return response, nil
+	}
+
+	// Create a channel for streaming output
+	outputChan := make(chan string)
+	done := make(chan struct{})
+
+	// Start a goroutine to handle the display of streaming output
+	go func() {
+		defer close(done)
+
+		renderer := newStreamRenderer(output, renderText)
+		wrote := false
+		for chunk := range outputChan {
+			renderer.Write(chunk)
+			wrote = wrote || chunk != ""
+		}
+		renderer.Flush()
+
+		// Reset the colors and end the line when done
+		if wrote {
+			endOutput(output)
+		}
+	}()
+
+	// Call the API with a callback that forwards each chunk to the display.
+	// A chunk arriving after the call has returned is dropped rather than
+	// sent on the closed channel.
+	var mu sync.Mutex
+	closed := false
+	response, err := call(func(chunk string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !closed {
+			outputChan <- chunk
+		}
+	})
+
+	// Close the output channel and wait for everything to be printed
+	mu.Lock()
+	closed = true
+	close(outputChan)
+	mu.Unlock()
+	<-done
+
+	if err == nil && strings.TrimSpace(response) == "" {
+		fmt.Fprintln(os.Stderr, emptyResponseMessage)
+	}
+
+	return response, err
+}
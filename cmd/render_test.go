@@ -0,0 +1,270 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"github.com/tydin/difx/config"
+)
+
+func TestStreamRendererStripMode(t *testing.T) {
+	in := "main.go:\n\t\\033[32;1m+ foo\\033[0m and [DEL]bar[/DEL]\n"
+	want := stripEscapeSequences(in)
+
+	for i := 1; i < len(in); i++ {
+		if got := simulateStream([]string{in[:i], in[i:]}, stripEscapeSequences); got != want {
+			t.Errorf("split at %d: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestStreamRendererFlushesHeldBackText(t *testing.T) {
+	// The trailing R could start a RED_START marker, so it's only written on Flush
+	var out strings.Builder
+	renderer := newStreamRenderer(&out, convertEscapeSequences)
+	renderer.Write("no ERROR")
+	if got := out.String(); got != "no ERRO" {
+		t.Errorf("before Flush got %q, want %q", got, "no ERRO")
+	}
+
+	renderer.Flush()
+	if got := out.String(); got != "no ERROR" {
+		t.Errorf("after Flush got %q, want %q", got, "no ERROR")
+	}
+}
+
+func TestStreamRendererSplitRunes(t *testing.T) {
+	in := "Änderung an 変更, café 😀 ok"
+	for i := 1; i < len(in); i++ {
+		var out strings.Builder
+		renderer := newStreamRenderer(&out, func(text string) string { return text })
+		renderer.Write(in[:i])
+		if !utf8.ValidString(out.String()) {
+			t.Errorf("split at %d: wrote part of a character: %q", i, out.String())
+		}
+		renderer.Write(in[i:])
+		renderer.Flush()
+		if out.String() != in {
+			t.Errorf("split at %d: got %q, want %q", i, out.String(), in)
+		}
+	}
+}
+
+// benchmarkResponse builds a model response of roughly size bytes
+func benchmarkResponse(size int) string {
+	var b strings.Builder
+	for i := 0; b.Len() < size; i++ {
+		fmt.Fprintf(&b, "\tfile%d.go:\n\t\t\\033[32;1m+ added line %d\\033[0m\n\t\t\\033[31;1m- removed line %d\\033[0m\n", i, i, i)
+	}
+	return b.String()
+}
+
+// benchmarkStream renders the response in small chunks like a streamed API reply
+func benchmarkStream(b *testing.B, size int) {
+	response := benchmarkResponse(size)
+	var chunks []string
+	for i := 0; i < len(response); i += 16 {
+		end := i + 16
+		if end > len(response) {
+			end = len(response)
+		}
+		chunks = append(chunks, response[i:end])
+	}
+
+	b.SetBytes(int64(len(response)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		renderer := newStreamRenderer(io.Discard, convertEscapeSequences)
+		for _, chunk := range chunks {
+			renderer.Write(chunk)
+		}
+		renderer.Flush()
+	}
+}
+
+// Time per byte should stay flat as the response grows
+func BenchmarkStreamRenderer50KB(b *testing.B)  { benchmarkStream(b, 50*1024) }
+func BenchmarkStreamRenderer200KB(b *testing.B) { benchmarkStream(b, 200*1024) }
+
+// captureOutput runs f with stdout and stderr redirected and returns what was written
+func captureOutput(t *testing.T, f func()) (string, string) {
+	t.Helper()
+
+	read := func(target **os.File) (func() string, error) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, err
+		}
+		original := *target
+		*target = w
+		out := make(chan string)
+		go func() {
+			b, _ := io.ReadAll(r)
+			out <- string(b)
+		}()
+		return func() string {
+			w.Close()
+			*target = original
+			return <-out
+		}, nil
+	}
+
+	stdout, err := read(&os.Stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Model output goes to the new stdout too
+	original := output
+	output = os.Stdout
+	defer func() { output = original }()
+	stderr, err := read(&os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f()
+	return stdout(), stderr()
+}
+
+func TestPrintModelOutputEmptyResponse(t *testing.T) {
+	for _, streaming := range []bool{true, false} {
+		stdout, stderr := captureOutput(t, func() {
+			printModelOutput(&config.Config{Streaming: streaming}, func(callback func(string)) (string, error) {
+				callback("  ")
+				return "", nil
+			})
+		})
+		if strings.TrimSpace(strings.ReplaceAll(stdout, resetCode, "")) != "" || !strings.Contains(stderr, emptyResponseMessage) {
+			t.Errorf("streaming=%v: stdout = %q, stderr = %q", streaming, stdout, stderr)
+		}
+	}
+}
+
+func TestPrintModelOutputLateChunk(t *testing.T) {
+	var late func(string)
+	stdout, _ := captureOutput(t, func() {
+		_, err := printModelOutput(&config.Config{Streaming: true}, func(callback func(string)) (string, error) {
+			callback("partial")
+			late = callback
+			return "partial", errors.New("stream broke")
+		})
+		if err == nil {
+			t.Error("expected the call's error")
+		}
+	})
+
+	// A chunk sent after the call returned must not panic
+	late("more")
+
+	if !strings.Contains(stdout, "partial") || strings.Contains(stdout, "more") {
+		t.Errorf("stdout = %q", stdout)
+	}
+}
+
+// captureModelOutput points output at a buffer for the rest of the test
+func captureModelOutput(t *testing.T) *strings.Builder {
+	t.Helper()
+	var out strings.Builder
+	original := output
+	output = &out
+	t.Cleanup(func() { output = original })
+	return &out
+}
+
+func TestPrintModelOutputWriter(t *testing.T) {
+	chunks := []string{"main.go:\n\t\\033[32", ";1m+ foo\\033[0m\n", "done"}
+	want := "main.go:\n\t\033[32;1m+ foo\033[0m\ndone\033[0m\n"
+
+	for _, streaming := range []bool{true, false} {
+		out := captureModelOutput(t)
+		response, err := printModelOutput(&config.Config{Streaming: streaming}, func(callback func(string)) (string, error) {
+			for _, chunk := range chunks {
+				callback(chunk)
+			}
+			return strings.Join(chunks, ""), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := out.String(); got != want {
+			t.Errorf("streaming=%v: wrote %q, want %q", streaming, got, want)
+		}
+		if response != strings.Join(chunks, "") {
+			t.Errorf("streaming=%v: response = %q", streaming, response)
+		}
+	}
+}
+
+func TestPrintModelOutputWriterNoText(t *testing.T) {
+	stdout, stderr := captureOutput(t, func() {
+		printModelOutput(&config.Config{Streaming: true}, func(callback func(string)) (string, error) {
+			return "", nil
+		})
+	})
+
+	// Not even the final newline is written without any text
+	if stdout != "" {
+		t.Errorf("wrote %q", stdout)
+	}
+	if !strings.Contains(stderr, emptyResponseMessage) {
+		t.Errorf("stderr = %q", stderr)
+	}
+}
+
+func TestPrintDiff(t *testing.T) {
+	diffOutput := "diff --git a/README.md b/README.md\n@@ -1 +1 @@\n-```go\n+```bash\n"
+
+	out := captureModelOutput(t)
+	printDiff(&config.Config{Changelog: true}, diffOutput)
+	if want := "````diff\n" + diffOutput + "````\n\n"; out.String() != want {
+		t.Errorf("Markdown diff = %q, want %q", out.String(), want)
+	}
+
+	noColor := color.NoColor
+	t.Cleanup(func() { color.NoColor = noColor })
+
+	color.NoColor = true
+	out = captureModelOutput(t)
+	printDiff(&config.Config{}, diffOutput)
+	if want := diffOutput + "\n"; out.String() != want {
+		t.Errorf("plain diff = %q, want %q", out.String(), want)
+	}
+
+	color.NoColor = false
+	out = captureModelOutput(t)
+	printDiff(&config.Config{}, diffOutput)
+	if !strings.Contains(out.String(), sequence(activeScheme.add)+"+```bash\033[0m\n") {
+		t.Errorf("colored diff = %q", out.String())
+	}
+	// With --width, long lines are cut and keep their marker and color
+	widthOverride = 10
+	t.Cleanup(func() { widthOverride = 0 })
+	out = captureModelOutput(t)
+	printDiff(&config.Config{}, diffOutput)
+	if !strings.Contains(out.String(), sequence(activeScheme.add)+"+```bash\033[0m\n") || !strings.Contains(out.String(), "diff --gi…") {
+		t.Errorf("truncated diff = %q", out.String())
+	}
+}
+
+func TestPrintModelOutputResetsOpenColor(t *testing.T) {
+	// The model never closes the yellow it opened
+	want := "\033[33mstill yellow\033[0m\n"
+
+	for _, streaming := range []bool{true, false} {
+		out := captureModelOutput(t)
+		printModelOutput(&config.Config{Streaming: streaming}, func(callback func(string)) (string, error) {
+			callback("\\033[33mstill yellow")
+			return "\\033[33mstill yellow", nil
+		})
+		if got := out.String(); got != want {
+			t.Errorf("streaming=%v: wrote %q, want %q", streaming, got, want)
+		}
+	}
+}
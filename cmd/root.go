@@ -1,157 +1,433 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/tydin/difx/cache"
 	"github.com/tydin/difx/config"
 	"github.com/tydin/difx/diff"
+	"github.com/tydin/difx/telemetry"
 )
 
 // Command line flags
+var model string
 var ciMode bool
+var wrapCode bool
+var maxHunkLines int
+var dedupeImports bool
+var includeLockfiles bool
+var newFilesOnly bool
+var anonymizePaths bool
+var keepExtensions bool
+var quiet bool
+var includeSubmodules bool
+var contextFile string
+var projectContextFile string
+var postHook string
+var maxLineChars int
+var stripNoNewline bool
+var diffFile string
+var noNormalize bool
+var chunked bool
+var concurrency int
+var useCache bool
+var structured bool
+var jsonOutput bool
+var baselineFile string
+var anthropicVersion string
+var force bool
+var minSeverity string
+var envFile string
+var noEnvFile bool
+var maxResponseTime int
+var stopAtDelimiter bool
+var maxInputTokens int
+var fullContext bool
+var checkTests bool
+var intent bool
+var strict bool
+var symbol string
+var fromClipboard bool
+var mergeBase string
+var prependDiff bool
+var budget float64
+var compareFlag string
+var gitConfigFlag []string
+
+// fromRev and toRev select a range of commits, like <from>..<to>
+var fromRev string
+var toRev string
+
+// sinceTag and sinceLatestTag start the range at a release tag
+var sinceTag string
+var sinceLatestTag bool
+
+// upstream diffs against the branch the current branch tracks
+var upstream bool
+var changelog bool
+var review bool
+var persona string
+var groupByDir bool
+var offline bool
+var resume bool
+var withLog int
+var seed int
+
+// maxRetriesFlag tells whether --max-retries was given, since 0 turns retries off
+var maxRetries int
+var maxRetriesFlag *pflag.Flag
+
+// seedFlag tells whether --seed was given, since 0 is a valid seed
+var seedFlag *pflag.Flag
+var colorSchemeName string
+var themeName string
+var confirmBeforeSend bool
+var attachNote bool
+
+// failOnError turns failures that are otherwise only warnings into errors;
+// failOnErrorFlag tells whether it was given, since it defaults to on outside a terminal
+var failOnError bool
+var failOnErrorFlag *pflag.Flag
+var assumeYes bool
+
+// renderText converts the model's escape sequences for display, or strips
+// them when the terminal can't render ANSI codes
+var renderText = convertEscapeSequences
 
 var rootCmd = &cobra.Command{
-	Use:   "difx [options] [--] [<path>...]",
+	Use:   "difx [options] [<commit>...] [--] [<git diff args>...]",
 	Short: "A tool that uses AI to explain git diffs",
 	Long: `difx is a command-line tool that uses AI to explain git diffs.
 It accepts the same syntax as the git diff command and provides AI-powered explanations.`,
+	// Arguments are passed to git diff, so they must not be treated as subcommands
+	Args: cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		// Load or create config
-		cfg, err := config.LoadOrCreate()
+		ctx, span := startSpan(cmd, "difx")
+		defer span.End()
+
+		cfg := loadConfig()
+		cfg.Reverse = reversed(cmd)
+		cfg.WordDiff = wordDiffed(cmd, cfg)
+
+		// Only git can swap the sides of a diff
+		if cfg.Reverse && (diffFile != "" || fromClipboard || symbol != "") {
+			fmt.Fprintln(os.Stderr, "Error: --reverse needs the diff to come from git diff, not --diff-file, --from-clipboard or --symbol")
+			exit(1)
+		}
+
+		// A release tag is the start of the range
+		from := fromRev
+		if sinceTag != "" || sinceLatestTag {
+			from = resolveSinceTag(ctx)
+		}
+
+		// --from and --to are a shorthand for the range argument
+		args, err := withRange(from, toRev, args)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error loading config: %s\n", err)
-			os.Exit(1)
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			exit(1)
 		}
 
-		// Check if we're in CI mode
-		if ciMode {
-			cfg.Streaming = false
+		// --upstream compares with the branch the current one tracks
+		if upstream {
+			args = withUpstream(ctx, args)
 		}
 
-		// Check if API keys are available based on active model
-		switch cfg.ActiveModel {
-		case config.ModelClaude:
-			if cfg.ClaudeAPIKey == "" {
-				apiKey, err := config.PromptForAPIKey()
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Error getting Claude API key: %s\n", err)
-					os.Exit(1)
-				}
-				cfg.ClaudeAPIKey = apiKey
-				if err := config.Save(cfg); err != nil {
-					fmt.Fprintf(os.Stderr, "Error saving config: %s\n", err)
-					os.Exit(1)
-				}
-			}
-		case config.ModelAzureOpenAI:
-			if cfg.AzureOpenAIEndpoint == "" || cfg.AzureOpenAIKey == "" {
-				fmt.Fprintf(os.Stderr, "Azure OpenAI endpoint and key must be set in config or environment variables\n")
-				os.Exit(1)
-			}
+		// --merge-base compares with a given branch the same way
+		if mergeBase != "" {
+			args = append([]string{diff.UpstreamRange(mergeBase)}, args...)
+		}
+
+		// Find the commit to annotate before paying for the explanation
+		var noteCommit string
+		if attachNote {
+			noteCommit = resolveNoteCommit(ctx, args)
 		}
 
-		// Process git diff and get explanation
-		diffOutput, err := diff.RunGitDiff(args)
+		// Read the background first, so stdin isn't taken for a piped diff
+		if contextFile != "" {
+			addBackground(cfg)
+		}
+
+		// Get the diff from a file, piped stdin, or git diff, or trace a single function
+		var diffOutput string
+		var diffArgs []string
+		if symbol != "" {
+			diffOutput, err = diff.RunGitSymbolDiff(ctx, symbol, args)
+		} else {
+			diffArgs = gitArgs(cmd, compareArgs(cmd, cfg, args))
+			diffOutput, err = readDiff(ctx, diffArgs)
+		}
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error running git diff: %s\n", err)
-			os.Exit(1)
+			fmt.Fprintf(os.Stderr, "Error reading diff: %s\n", err)
+			exit(exitGit)
 		}
 
 		if diffOutput == "" {
 			fmt.Println("No differences found.")
+			if symbol == "" && onlyStaged(ctx, diffArgs) {
+				fmt.Fprintln(os.Stderr, "No unstaged changes; did you mean --staged? (--against head shows staged and unstaged changes together)")
+			}
 			return
 		}
 
-		// Handle streaming vs non-streaming mode differently
-		if cfg.Streaming {
-			// Create a channel for streaming output
-			outputChan := make(chan string)
+		// Don't pay for the same explanation twice in a row
+		if unchangedSinceLastRun(cfg, diffOutput) {
+			return
+		}
 
-			// Start a goroutine to handle the display of streaming output
-			go func() {
-				var buffer strings.Builder
-				var lastProcessed string
+		// Remember the diff so it can be explained again later
+		if err := cache.SaveLastDiff(diffOutput); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not save the diff for difx again: %s\n", err)
+		}
 
-				for chunk := range outputChan {
-					// Add the new chunk to the buffer
-					buffer.WriteString(chunk)
+		ensureAPIKey(cfg)
+		addCommitLog(ctx, cfg)
+		addProjectContext(ctx, cfg)
+		explanation := explain(ctx, cfg, diffOutput)
 
-					// Get the current full text
-					currentText := buffer.String()
+		if attachNote {
+			attachExplanationNote(ctx, noteCommit, explanation)
+		}
+	},
+}
 
-					// Clean up any incomplete escape sequences at the end of the text
-					currentText = cleanIncompleteEscapeSequences(currentText)
+// gitOutputModes are the git diff flags that select what kind of output is produced
+var gitOutputModes = []string{"patch", "stat", "name-only", "name-status"}
+
+// addGitFlags declares the git diff flags that difx understands
+func addGitFlags(flags *pflag.FlagSet) {
+	flags.BoolP("patch", "p", true, "Generate patch")
+	flags.BoolP("stat", "", false, "Generate diffstat")
+	flags.BoolP("name-only", "", false, "Show only names of changed files")
+	flags.BoolP("name-status", "", false, "Show only names and status of changed files")
+	flags.StringP("diff-filter", "", "", "Filter by added/modified/deleted")
+	flags.StringP("unified", "U", "", "Show n lines of context")
+	flags.BoolP("reverse", "R", false, "Swap the two sides of the diff and explain it as undoing the changes")
+	flags.Bool("word-diff", false, "Diff by words instead of lines, marking [-removed-] and {+added+} words, for prose and docs")
+	flags.String("against", "", "Compare the working tree with HEAD (head), the index with HEAD (index) or the working tree with the index (working, git's default)")
+}
 
-					// Convert \033 escape sequences to actual escape characters
-					processedText := convertEscapeSequences(currentText)
+// gitFlagArgs turns the git diff flags that were set into git diff arguments.
+// -p is only forwarded when it was given explicitly and no other output mode
+// was chosen, since its default is true and patch output is git's default anyway.
+func gitFlagArgs(flags *pflag.FlagSet) []string {
+	var gitArgs []string
+
+	outputMode := ""
+	for _, name := range gitOutputModes[1:] {
+		if on, _ := flags.GetBool(name); on {
+			outputMode = name
+			gitArgs = append(gitArgs, "--"+name)
+		}
+	}
+	if patch, _ := flags.GetBool("patch"); patch && flags.Changed("patch") && outputMode == "" {
+		gitArgs = append(gitArgs, "-p")
+	}
 
-					// Only print the new part (what's been added since last time)
-					if len(lastProcessed) < len(processedText) {
-						newPart := processedText[len(lastProcessed):]
-						fmt.Printf("%s", newPart) // Use Printf for better handling of escape sequences
-						lastProcessed = processedText
-					}
-				}
+	if filter, _ := flags.GetString("diff-filter"); filter != "" {
+		gitArgs = append(gitArgs, "--diff-filter="+filter)
+	}
+	if unified, _ := flags.GetString("unified"); unified != "" {
+		gitArgs = append(gitArgs, "-U"+unified)
+	}
+	if reverse, _ := flags.GetBool("reverse"); reverse {
+		gitArgs = append(gitArgs, "-R")
+	}
+	if wordDiff, _ := flags.GetBool("word-diff"); wordDiff {
+		gitArgs = append(gitArgs, "--word-diff=plain")
+	}
 
-				// Print a final newline when done
-				fmt.Println()
-			}()
+	return gitArgs
+}
 
-			// Create a callback function to process streaming output
-			streamCallback := func(chunk string) {
-				outputChan <- chunk
-			}
+// reversed tells whether the diff is taken with -R, so the prompt asks for
+// the changes to be explained as being undone
+func reversed(cmd *cobra.Command) bool {
+	reverse, _ := cmd.Flags().GetBool("reverse")
+	return reverse
+}
 
-			// Call the API with streaming callback
-			_, err = diff.GetExplanation(diffOutput, cfg, streamCallback)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "\nError getting explanation from AI: %s\n", err)
-				os.Exit(1)
-			}
+// wordDiffed tells whether the diff is taken with --word-diff, so the prompt
+// explains its markers. Word diff lines have no + or - prefix, so the
+// offline descriptions and the structured explanation, which count added and
+// removed lines, are refused with it.
+func wordDiffed(cmd *cobra.Command, cfg *config.Config) bool {
+	wordDiff, _ := cmd.Flags().GetBool("word-diff")
+	if wordDiff && (cfg.Offline || cfg.Structured) {
+		fmt.Fprintln(os.Stderr, "Error: --word-diff can't be combined with --offline, --structured or --json")
+		exit(1)
+	}
+	return wordDiff
+}
 
-			// Close the output channel to signal completion
-			close(outputChan)
-		} else {
-			// Non-streaming mode (CI mode)
-			// Simple callback that does nothing since we'll print the full response at the end
-			streamCallback := func(chunk string) {}
-
-			// Call the API
-			response, err := diff.GetExplanation(diffOutput, cfg, streamCallback)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "\nError getting explanation from AI: %s\n", err)
-				os.Exit(1)
-			}
+// gitArgs builds the git diff arguments for a run: the git flags difx parsed,
+// then the positional arguments. Everything after -- is in args untouched, so
+// it reaches git verbatim even if it looks like a flag.
+func gitArgs(cmd *cobra.Command, args []string) []string {
+	return append(gitFlagArgs(cmd.Flags()), args...)
+}
 
-			// Process and print the full response
-			processedText := convertEscapeSequences(response)
-			fmt.Println(processedText)
+// withRange puts the <from>..<to> range of --from and --to before the git
+// diff arguments. --to defaults to HEAD.
+func withRange(from string, to string, args []string) ([]string, error) {
+	if from == "" {
+		if to != "" {
+			return nil, fmt.Errorf("--to needs --from")
 		}
-	},
+		return args, nil
+	}
+	if to == "" {
+		to = "HEAD"
+	}
+	return append([]string{from + ".." + to}, args...), nil
+}
+
+// resolveSinceTag returns the tag --since-tag or --since-latest-tag starts
+// the range at, after checking that it exists
+func resolveSinceTag(ctx context.Context) string {
+	tag := sinceTag
+	if sinceLatestTag {
+		var err error
+		if tag, err = diff.LatestTag(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			exit(exitGit)
+		}
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "Explaining the changes since %s\n", tag)
+		}
+	}
+
+	if err := diff.ValidateTag(ctx, tag); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		exit(exitGit)
+	}
+	return tag
+}
+
+// withUpstream puts the range from the upstream branch to HEAD before the git
+// diff arguments, so pathspecs still apply
+func withUpstream(ctx context.Context, args []string) []string {
+	branch, err := diff.Upstream(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		exit(exitGit)
+	}
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "Comparing with %s\n", branch)
+	}
+	return append([]string{diff.UpstreamRange(branch)}, args...)
+}
+
+// stdinWaitNotice is how long a piped stdin can stay silent before difx says
+// it is waiting for a diff on it
+var stdinWaitNotice = 2 * time.Second
+
+// readDiff returns the diff to explain. An explicit --diff-file or
+// --from-clipboard wins, then a diff piped on stdin unless it held the
+// --context-file - background, and otherwise git diff is run with the given
+// arguments. Stdin is only read without git diff arguments: in CI, hooks or
+// a shell loop it can be a pipe that never closes, and reading it would hang.
+// Git diff arguments given with a piped stdin are preferred with a warning.
+func readDiff(ctx context.Context, args []string) (string, error) {
+	if diffFile != "" {
+		return diff.ReadDiffFile(diffFile)
+	}
+
+	if fromClipboard {
+		text, isDiff, err := diff.ReadClipboardDiff()
+		if err != nil {
+			return "", err
+		}
+		// Explain it anyway; the model can still make sense of a partial paste
+		if !isDiff && strings.TrimSpace(text) != "" {
+			fmt.Fprintln(os.Stderr, "Warning: the clipboard doesn't look like a diff (no diff --git or ---/+++ header)")
+		}
+		return text, nil
+	}
+
+	if contextFile != "-" && diff.StdinIsPipe() {
+		if len(args) > 0 {
+			fmt.Fprintln(os.Stderr, "Warning: ignoring stdin because git diff arguments were given (use --diff-file - to explain a piped diff)")
+			return diff.RunGitDiff(ctx, args)
+		}
+
+		// Say why nothing happens while stdin stays open
+		notice := time.AfterFunc(stdinWaitNotice, func() {
+			fmt.Fprintln(os.Stderr, "Waiting for a diff on stdin; give git diff arguments, or close stdin (< /dev/null), to run git diff instead")
+		})
+		piped, isDiff, err := diff.ReadPipedDiff()
+		notice.Stop()
+		if err != nil {
+			return "", err
+		}
+		if isDiff {
+			return piped, nil
+		}
+	}
+
+	return diff.RunGitDiff(ctx, args)
+}
+
+// onlyStaged tells whether an empty git diff of the working tree, with
+// these arguments, hides changes that are staged. Diffs from a file, the
+// clipboard, or between commits aren't the working tree.
+func onlyStaged(ctx context.Context, args []string) bool {
+	if diffFile != "" || fromClipboard || choosesComparison(args) {
+		return false
+	}
+	staged, err := diff.RunGitDiff(ctx, append([]string{"--cached"}, args...))
+	return err == nil && staged != ""
 }
 
 // Execute executes the root command.
 func Execute() error {
-	return rootCmd.Execute()
+	// Cancel in-flight git and API calls on Ctrl+C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Set up tracing (a no-op unless OTEL_EXPORTER_OTLP_ENDPOINT is set)
+	shutdown, err := telemetry.Setup(ctx)
+	if err != nil {
+		return err
+	}
+	shutdownTracing = shutdown
+	defer shutdown(context.Background())
+
+	return rootCmd.ExecuteContext(ctx)
 }
 
+// Legacy color markers, compiled once since conversion runs on every streamed chunk
+var (
+	addRegex   = regexp.MustCompile(`\[ADD\](.*?)\[/ADD\]`)
+	delRegex   = regexp.MustCompile(`\[DEL\](.*?)\[/DEL\]`)
+	greenRegex = regexp.MustCompile(`GREEN_START(.*?)GREEN_END`)
+	redRegex   = regexp.MustCompile(`RED_START(.*?)RED_END`)
+)
+
 // convertEscapeSequences converts \033 escape sequences to actual escape characters
 func convertEscapeSequences(text string) string {
+	// Map the model's add/delete colors to the active color scheme
+	result := schemeReplacer.Replace(text)
+
 	// Replace \033 with the actual escape character
-	result := strings.ReplaceAll(text, "\\033", "\033")
+	result = strings.ReplaceAll(result, "\\033", "\033")
 
 	// For backward compatibility, also handle the old markers
 	// Create color objects
-	red := color.New(color.FgRed, color.Bold)
-	green := color.New(color.FgGreen, color.Bold)
+	red := color.New(activeScheme.del...)
+	green := color.New(activeScheme.add...)
 
 	// Find and replace additions (green text) with [ADD] markers
-	addRegex := regexp.MustCompile(`\[ADD\](.*?)\[/ADD\]`)
 	result = addRegex.ReplaceAllStringFunc(result, func(match string) string {
 		submatches := addRegex.FindStringSubmatch(match)
 		if len(submatches) > 1 {
@@ -161,7 +437,6 @@ func convertEscapeSequences(text string) string {
 	})
 
 	// Find and replace deletions (red text) with [DEL] markers
-	delRegex := regexp.MustCompile(`\[DEL\](.*?)\[/DEL\]`)
 	result = delRegex.ReplaceAllStringFunc(result, func(match string) string {
 		submatches := delRegex.FindStringSubmatch(match)
 		if len(submatches) > 1 {
@@ -171,7 +446,6 @@ func convertEscapeSequences(text string) string {
 	})
 
 	// Also handle the GREEN_START/GREEN_END and RED_START/RED_END markers for backward compatibility
-	greenRegex := regexp.MustCompile(`GREEN_START(.*?)GREEN_END`)
 	result = greenRegex.ReplaceAllStringFunc(result, func(match string) string {
 		submatches := greenRegex.FindStringSubmatch(match)
 		if len(submatches) > 1 {
@@ -180,7 +454,6 @@ func convertEscapeSequences(text string) string {
 		return match
 	})
 
-	redRegex := regexp.MustCompile(`RED_START(.*?)RED_END`)
 	result = redRegex.ReplaceAllStringFunc(result, func(match string) string {
 		submatches := redRegex.FindStringSubmatch(match)
 		if len(submatches) > 1 {
@@ -192,92 +465,40 @@ func convertEscapeSequences(text string) string {
 	return result
 }
 
+// escapeReplacer turns literal \n and \t into whitespace. An escaped
+// backslash is matched first so sequences like \\n are left alone.
+var escapeReplacer = strings.NewReplacer(`\\`, `\\`, `\n`, "\n", `\t`, "\t")
+
+// normalizeEscapes converts stray literal \n and \t sequences that the model
+// copies from the prompt into real newlines and tabs. \033 is untouched and
+// left for convertEscapeSequences.
+func normalizeEscapes(text string) string {
+	return escapeReplacer.Replace(text)
+}
+
+// incompleteEscapeRegex matches a \033 color sequence cut off at the end of the text
+var incompleteEscapeRegex = regexp.MustCompile(`\\(0(3(3(\[[0-9;]*)?)?)?)?$`)
+
 // cleanIncompleteEscapeSequences removes incomplete escape sequences at the end of text
 // This helps when an escape sequence is split across multiple chunks
 func cleanIncompleteEscapeSequences(text string) string {
-	// Check for incomplete \033 escape sequence at the end
-	if strings.HasSuffix(text, "\\") {
-		return text[:len(text)-1]
-	}
-	if strings.HasSuffix(text, "\\0") {
-		return text[:len(text)-2]
-	}
-	if strings.HasSuffix(text, "\\03") {
-		return text[:len(text)-3]
-	}
-	if strings.HasSuffix(text, "\\033") {
-		return text[:len(text)-4]
-	}
-	if strings.HasSuffix(text, "\\033[") {
-		return text[:len(text)-5]
-	}
-	if strings.HasSuffix(text, "\\033[3") {
-		return text[:len(text)-6]
-	}
-	if strings.HasSuffix(text, "\\033[32") {
-		return text[:len(text)-7]
-	}
-	if strings.HasSuffix(text, "\\033[32;") {
-		return text[:len(text)-8]
-	}
-	if strings.HasSuffix(text, "\\033[32;1") {
-		return text[:len(text)-9]
-	}
-	if strings.HasSuffix(text, "\\033[31") {
-		return text[:len(text)-7]
-	}
-	if strings.HasSuffix(text, "\\033[31;") {
-		return text[:len(text)-8]
-	}
-	if strings.HasSuffix(text, "\\033[31;1") {
-		return text[:len(text)-9]
-	}
-
-	// For backward compatibility, also check for incomplete markers
-	// Check for incomplete [ADD]/[DEL] markers
-	if strings.HasSuffix(text, "[") {
-		return text[:len(text)-1]
-	}
-	if strings.HasSuffix(text, "[A") {
-		return text[:len(text)-2]
-	}
-	if strings.HasSuffix(text, "[AD") {
-		return text[:len(text)-3]
-	}
-	if strings.HasSuffix(text, "[ADD") {
-		return text[:len(text)-4]
-	}
-	if strings.HasSuffix(text, "[D") {
-		return text[:len(text)-2]
-	}
-	if strings.HasSuffix(text, "[DE") {
-		return text[:len(text)-3]
-	}
-	if strings.HasSuffix(text, "[DEL") {
-		return text[:len(text)-4]
+	// For backward compatibility, also hold back anything that could be the start of a marker
+	longest := 0
+	for _, opener := range legacyOpeners {
+		for l := len(opener); l > longest; l-- {
+			if strings.HasSuffix(text, opener[:l]) {
+				longest = l
+				break
+			}
+		}
 	}
+	text = text[:len(text)-longest]
 
-	// Check for incomplete GREEN_START/RED_START markers
-	if strings.HasSuffix(text, "G") {
-		return text[:len(text)-1]
-	}
-	if strings.HasSuffix(text, "GR") {
-		return text[:len(text)-2]
-	}
-	if strings.HasSuffix(text, "GREEN_START") {
-		return text[:len(text)-11]
-	}
-	if strings.HasSuffix(text, "R") {
-		return text[:len(text)-1]
-	}
-	if strings.HasSuffix(text, "RE") {
-		return text[:len(text)-2]
-	}
-	if strings.HasSuffix(text, "RED_START") {
-		return text[:len(text)-9]
-	}
+	// Check for incomplete \033 escape sequence at the end
+	text = incompleteEscapeRegex.ReplaceAllString(text, "")
 
-	return text
+	// A run of backslashes could pair up differently once the next character arrives
+	return strings.TrimRight(text, `\`)
 }
 
 func init() {
@@ -285,16 +506,100 @@ func init() {
 	color.NoColor = false
 
 	// Add flags that git diff supports
-	rootCmd.Flags().BoolP("patch", "p", true, "Generate patch")
-	rootCmd.Flags().BoolP("stat", "", false, "Generate diffstat")
+	addGitFlags(rootCmd.Flags())
+
+	// Only one git output mode can be used at a time
+	rootCmd.MarkFlagsMutuallyExclusive(gitOutputModes...)
 	
 	// Add the --ci flag
-	rootCmd.Flags().BoolVar(&ciMode, "ci", false, "Run in CI mode (disables streaming)")
-	rootCmd.Flags().BoolP("name-only", "", false, "Show only names of changed files")
-	rootCmd.Flags().BoolP("name-status", "", false, "Show only names and status of changed files")
-	rootCmd.Flags().StringP("diff-filter", "", "", "Filter by added/modified/deleted")
-	rootCmd.Flags().StringP("unified", "U", "", "Show n lines of context")
+	rootCmd.PersistentFlags().BoolVar(&ciMode, "ci", false, "Run in CI mode (disables streaming)")
 
 	// Add difx specific flags
 	rootCmd.Flags().BoolP("verbose", "v", false, "Show detailed output including the diff")
+	rootCmd.PersistentFlags().StringVar(&envFile, "env-file", "", "Read CLAUDE_*, AZURE_* and DIFX_* variables from this file (default: .env in the current directory or repository root)")
+	rootCmd.PersistentFlags().BoolVar(&noEnvFile, "no-env-file", false, "Don't look for a .env file")
+	rootCmd.PersistentFlags().StringVar(&model, "model", "", "Model to use for this run (claude or azure_openai)")
+	rootCmd.PersistentFlags().StringVar(&compareFlag, "compare", "", "Explain with two models at once, such as claude,azure_openai, and show their explanations side by side with what each took")
+	rootCmd.PersistentFlags().BoolVar(&wrapCode, "wrap-code", false, "Wrap code snippets in fenced code blocks with language hints")
+	rootCmd.Flags().BoolVar(&attachNote, "attach-note", false, "Save the explanation of a single commit (<commit>^!) as its git note; --force replaces an existing note")
+	rootCmd.Flags().StringVar(&symbol, "symbol", "", "Explain only the changes to a function, given as <function>:<file> (uses git log -L; the last change, or every change in the given commit range)")
+	rootCmd.Flags().StringVar(&diffFile, "diff-file", "", "Explain the diff in this file instead of running git diff (- reads stdin)")
+	rootCmd.MarkFlagsMutuallyExclusive("symbol", "diff-file")
+	rootCmd.Flags().BoolVar(&fromClipboard, "from-clipboard", false, "Explain a diff pasted to the system clipboard instead of running git diff")
+	rootCmd.MarkFlagsMutuallyExclusive("from-clipboard", "diff-file", "symbol")
+	rootCmd.Flags().StringVar(&fromRev, "from", "", "Explain the changes since this commit, tag or branch (same as <from>..<to>)")
+	rootCmd.Flags().StringVar(&toRev, "to", "", "End of the --from range (default HEAD)")
+	rootCmd.MarkFlagsMutuallyExclusive("from", "diff-file")
+	rootCmd.Flags().BoolVar(&upstream, "upstream", false, "Explain what the current branch has that its upstream branch doesn't (@{u}...HEAD)")
+	rootCmd.MarkFlagsMutuallyExclusive("upstream", "from")
+	rootCmd.MarkFlagsMutuallyExclusive("upstream", "diff-file")
+	rootCmd.Flags().StringVar(&sinceTag, "since-tag", "", "Explain the changes since this tag (same as --from <tag>, but the tag must exist)")
+	rootCmd.Flags().BoolVar(&sinceLatestTag, "since-latest-tag", false, "Explain the changes since the most recent tag (git describe --tags --abbrev=0)")
+	rootCmd.MarkFlagsMutuallyExclusive("since-tag", "since-latest-tag", "from", "upstream", "diff-file")
+	rootCmd.MarkFlagsMutuallyExclusive("from-clipboard", "from", "upstream", "since-tag", "since-latest-tag")
+	rootCmd.Flags().StringVar(&mergeBase, "merge-base", "", "Explain what the current branch has that this branch doesn't, from where they split (<branch>...HEAD)")
+	rootCmd.MarkFlagsMutuallyExclusive("merge-base", "from", "upstream", "since-tag", "since-latest-tag", "diff-file", "from-clipboard")
+	rootCmd.MarkFlagsMutuallyExclusive("against", "diff-file", "from-clipboard", "symbol")
+	rootCmd.Flags().StringVar(&contextFile, "context-file", "", fmt.Sprintf("Send this file as background for the changes, such as a ticket description, or - for stdin (at most %d KB)", diff.MaxBackgroundBytes/1024))
+	rootCmd.PersistentFlags().BoolVar(&review, "review", false, "Write review comments (bugs, risks, style, suggestions) with file:line locations instead of an explanation")
+	rootCmd.PersistentFlags().BoolVar(&changelog, "changelog", false, "Write Conventional Changelog release notes (Features, Bug Fixes, Breaking Changes, Chores) instead of an explanation")
+	rootCmd.PersistentFlags().BoolVar(&noNormalize, "no-normalize", false, "Keep literal \\n and \\t in the explanation instead of converting them to whitespace")
+	rootCmd.PersistentFlags().BoolVar(&chunked, "chunked", false, "Explain each changed file with a separate API call")
+	rootCmd.PersistentFlags().IntVar(&concurrency, "concurrency", 0, "Number of API calls to run at once with --chunked (default from config, 3)")
+	rootCmd.PersistentFlags().Float64Var(&budget, "budget", 0, "Stop sending --chunked requests before the estimated spend could go over this many dollars (default from config, unlimited)")
+	rootCmd.PersistentFlags().BoolVar(&useCache, "cache", false, "Reuse cached explanations for identical prompts and models")
+	rootCmd.PersistentFlags().BoolVar(&force, "force", false, "Explain the diff even if it hasn't changed since the last run with --cache")
+	rootCmd.PersistentFlags().BoolVar(&failOnError, "fail-on-error", false, "Exit non-zero whenever no complete explanation was produced (default on when stdout isn't a terminal)")
+	failOnErrorFlag = rootCmd.PersistentFlags().Lookup("fail-on-error")
+	rootCmd.PersistentFlags().BoolVar(&confirmBeforeSend, "confirm-send", false, "Show what will be sent and ask before calling the API")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "Skip the --confirm-send prompt")
+	rootCmd.PersistentFlags().BoolVar(&config.NonInteractive, "non-interactive", false, "Never prompt, failing instead of waiting for an answer (the default when stdin isn't a terminal)")
+	rootCmd.PersistentFlags().StringVar(&colorSchemeName, "color-scheme", "", "Colors for additions and deletions: default, light, colorblind or custom")
+	rootCmd.PersistentFlags().StringVar(&themeName, "theme", "", "Style the section headers and delimiters: dark, light or mono")
+	rootCmd.PersistentFlags().BoolVar(&structured, "structured", false, "Return the explanation as JSON using Claude tool use")
+	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Print the explanation as a single JSON document (implies --structured, disables streaming)")
+	rootCmd.PersistentFlags().BoolVar(&prependDiff, "prepend-diff", false, "Print the diff, colored like git diff --color, before the explanation (in a diff code block with --changelog)")
+	rootCmd.MarkFlagsMutuallyExclusive("prepend-diff", "json")
+	rootCmd.PersistentFlags().StringVar(&anthropicVersion, "anthropic-version", "", "anthropic-version header for the Claude API (default from config, "+config.DefaultAnthropicVersion+")")
+	rootCmd.PersistentFlags().StringVar(&baselineFile, "baseline", "", "Show how the explanation differs from one saved in this file")
+	rootCmd.PersistentFlags().StringVar(&minSeverity, "min-severity", "", "Changes to describe in DETAILS: all, notable or major (default from config, all)")
+	rootCmd.PersistentFlags().IntVar(&maxResponseTime, "max-response-time", 0, "Stop the response after this many seconds and keep what has arrived (0 means no limit)")
+	rootCmd.PersistentFlags().BoolVar(&stopAtDelimiter, "stop-at-delimiter", false, "End the response at the closing dash line, so the model can't ramble past it")
+	rootCmd.PersistentFlags().IntVar(&withLog, "with-log", 0, fmt.Sprintf("Add the last n commit subjects to the prompt as context (at most %d)", diff.MaxLogCommits))
+	rootCmd.PersistentFlags().IntVar(&seed, "seed", 0, "Seed for more repeatable explanations (azure_openai only, sets temperature to 0)")
+	seedFlag = rootCmd.PersistentFlags().Lookup("seed")
+	rootCmd.PersistentFlags().IntVar(&maxRetries, "max-retries", 0, fmt.Sprintf("Times to resend a request that failed with a temporary error, such as an overloaded API (default from config, %d)", config.DefaultMaxRetries))
+	maxRetriesFlag = rootCmd.PersistentFlags().Lookup("max-retries")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Don't show progress or retry notes on stderr")
+	rootCmd.PersistentFlags().IntVar(&widthOverride, "width", 0, fmt.Sprintf("Terminal width to lay output out for (default: COLUMNS, the detected width, or %d)", defaultWidth))
+	rootCmd.PersistentFlags().StringVar(&postHook, "post-hook", "", "Run this shell command with the finished explanation, as plain text, on its stdin (default from config)")
+	rootCmd.PersistentFlags().StringVar(&projectContextFile, "project-context", "", "Send this file as background about the project (default: "+diff.DefaultContextFile+" in the repository, if it exists)")
+	rootCmd.PersistentFlags().BoolVar(&includeSubmodules, "include-submodules", false, "Send submodule changes to the model instead of only listing them")
+	rootCmd.PersistentFlags().BoolVar(&dedupeImports, "dedupe-imports", false, "Replace hunks that only reorder imports with a short note")
+	rootCmd.PersistentFlags().BoolVar(&anonymizePaths, "anonymize-paths", false, "Send file1, file2, ... instead of the file paths and put the real paths back in the explanation")
+	rootCmd.PersistentFlags().BoolVar(&keepExtensions, "keep-extensions", false, "With --anonymize-paths, keep the file extensions as a hint about the language")
+	rootCmd.PersistentFlags().BoolVar(&newFilesOnly, "new-files-only", false, "Only explain the files the change adds, leaving out modified, deleted and renamed files")
+	rootCmd.PersistentFlags().BoolVar(&includeLockfiles, "include-lockfiles", false, "Send dependency lockfiles such as go.sum in full instead of a line count")
+	rootCmd.PersistentFlags().IntVar(&maxHunkLines, "max-hunk-lines", 0, "Omit the body of hunks longer than n lines (0 sends everything)")
+	rootCmd.PersistentFlags().BoolVar(&stripNoNewline, "strip-no-newline", false, "Don't send git's \"No newline at end of file\" lines; list the files in a footer instead")
+	rootCmd.PersistentFlags().IntVar(&maxInputTokens, "max-input-tokens", 0, "Only send the most changed files that fit in about n tokens (0 sends every file)")
+	rootCmd.PersistentFlags().BoolVar(&fullContext, "full-context", false, fmt.Sprintf("Also send the complete changed files, before and after, when they are under %d KB", diff.MaxFullContextBytes/1024))
+	rootCmd.PersistentFlags().StringVar(&persona, "persona", "", "Tone of the explanation: teacher, reviewer, changelog or eli5 (default neutral)")
+	rootCmd.PersistentFlags().BoolVar(&groupByDir, "group-by-dir", false, "Organize DETAILS under a heading for each top-level directory")
+	rootCmd.PersistentFlags().BoolVar(&resume, "resume", false, "Continue the explanation of the same diff where an interrupted run stopped")
+	rootCmd.PersistentFlags().StringArrayVar(&gitConfigFlag, "git-config", nil, "Run git diff with this key=value setting, such as diff.algorithm=histogram (repeatable)")
+	rootCmd.PersistentFlags().BoolVar(&offline, "offline", false, "Never call a model; describe the diff with built-in rules instead (or set DIFX_OFFLINE=1)")
+	rootCmd.PersistentFlags().BoolVar(&strict, "strict", false, "Ask the model to describe the changed files its explanation left out, instead of only warning")
+	rootCmd.PersistentFlags().BoolVar(&checkTests, "check-tests", false, "Add a TEST COVERAGE section noting which changed source files have no test changes")
+	rootCmd.PersistentFlags().BoolVar(&intent, "intent", false, "Add a LIKELY INTENT section with the model's best guess at why the change was made and what it risks (pairs well with --with-log)")
+	rootCmd.PersistentFlags().IntVar(&maxLineChars, "max-line-chars", 0, "Truncate diff lines longer than n characters (0 sends them whole)")
+
+	// Complete flag values that come from a known list
+	rootCmd.RegisterFlagCompletionFunc("model", fixedCompletion(config.ModelClaude, config.ModelAzureOpenAI))
+	rootCmd.RegisterFlagCompletionFunc("min-severity", fixedCompletion(config.SeverityAll, config.SeverityNotable, config.SeverityMajor))
+	rootCmd.RegisterFlagCompletionFunc("color-scheme", fixedCompletion(
+		config.ColorSchemeDefault, config.ColorSchemeLight, config.ColorSchemeColorblind, config.ColorSchemeCustom))
+	rootCmd.RegisterFlagCompletionFunc("persona", fixedCompletion(config.PersonaTeacher, config.PersonaReviewer, config.PersonaChangelog, config.PersonaELI5))
+	rootCmd.RegisterFlagCompletionFunc("theme", fixedCompletion(config.ThemeDark, config.ThemeLight, config.ThemeMono))
+	rootCmd.RegisterFlagCompletionFunc("against", compareCompletion)
 }
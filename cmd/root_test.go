@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	ansiGreen = "\x1b[32;1m"
+	ansiRed   = "\x1b[31;1m"
+	ansiReset = "\x1b[0m"
+
+	// colorReset is what fatih/color emits to end the legacy marker colors
+	colorReset = "\x1b[0;22m"
+)
+
+func TestConvertEscapeSequences(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "plain text",
+			in:   "SUMMARY:\n  - Files modified: 1",
+			want: "SUMMARY:\n  - Files modified: 1",
+		},
+		{
+			name: "complete addition",
+			in:   `\033[32;1m+ added line\033[0m`,
+			want: ansiGreen + "+ added line" + ansiReset,
+		},
+		{
+			name: "complete deletion",
+			in:   `\033[31;1m- removed line\033[0m`,
+			want: ansiRed + "- removed line" + ansiReset,
+		},
+		{
+			name: "legacy ADD marker",
+			in:   "[ADD]new code[/ADD]",
+			want: ansiGreen + "new code" + colorReset,
+		},
+		{
+			name: "legacy DEL marker",
+			in:   "[DEL]old code[/DEL]",
+			want: ansiRed + "old code" + colorReset,
+		},
+		{
+			name: "legacy GREEN_START marker",
+			in:   "GREEN_STARTnew codeGREEN_END",
+			want: ansiGreen + "new code" + colorReset,
+		},
+		{
+			name: "legacy RED_START marker",
+			in:   "RED_STARTold codeRED_END",
+			want: ansiRed + "old code" + colorReset,
+		},
+		{
+			name: "legacy markers do not span lines",
+			in:   "[ADD]first\nsecond[/ADD]",
+			want: "[ADD]first\nsecond[/ADD]",
+		},
+		{
+			name: "mixed content",
+			in:   "main.go:\n\t\\033[32;1m+ foo\\033[0m\n\t[DEL]bar[/DEL]\n\tRED_STARTbazRED_END",
+			want: "main.go:\n\t" + ansiGreen + "+ foo" + ansiReset + "\n\t" + ansiRed + "bar" + colorReset + "\n\t" + ansiRed + "baz" + colorReset,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := convertEscapeSequences(tt.in); got != tt.want {
+				t.Errorf("convertEscapeSequences(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanIncompleteEscapeSequences(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "complete text", in: "done.", want: "done."},
+		{name: "complete sequence", in: `text\033[32;1m`, want: `text\033[32;1m`},
+		{name: "backslash", in: `text\`, want: "text"},
+		{name: "escape prefix", in: `text\03`, want: "text"},
+		{name: "escape without bracket", in: `text\033`, want: "text"},
+		{name: "escape with bracket", in: `text\033[`, want: "text"},
+		{name: "partial green", in: `text\033[32;1`, want: "text"},
+		{name: "partial red", in: `text\033[31;`, want: "text"},
+		{name: "partial reset", in: `text\033[0`, want: "text"},
+		{name: "backslash run", in: `text\\\`, want: "text"},
+		{name: "partial ADD marker", in: "text[AD", want: "text"},
+		{name: "partial DEL marker", in: "text[DEL", want: "text"},
+		{name: "partial GREEN_START marker", in: "textGR", want: "text"},
+		{name: "complete RED_START marker", in: "textRED_START", want: "text"},
+		{name: "longer GREEN_START prefix", in: "textGREEN", want: "text"},
+		{name: "longer RED_START prefix", in: "textRED_", want: "text"},
+		// Trailing G and R are held back even when they end a real word
+		{name: "word ending in R", in: "ERROR", want: "ERRO"},
+		{name: "word ending in G", in: "logging", want: "logging"},
+		{name: "capital G", in: "LOG", want: "LO"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanIncompleteEscapeSequences(tt.in); got != tt.want {
+				t.Errorf("cleanIncompleteEscapeSequences(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// simulateStream feeds chunks through the streaming renderer and returns
+// everything that would be printed
+func simulateStream(chunks []string, render func(string) string) string {
+	var out strings.Builder
+	renderer := newStreamRenderer(&out, render)
+	for _, chunk := range chunks {
+		renderer.Write(chunk)
+	}
+	renderer.Flush()
+
+	return out.String()
+}
+
+func TestStreamingSplitAtEveryByte(t *testing.T) {
+	inputs := []string{
+		`\033[32;1m+ added line\033[0m`,
+		`\033[31;1m- removed line\033[0m`,
+		"main.go:\n\t\\033[32;1m+ foo\\033[0m\n\t\\033[31;1m- bar\\033[0m\n",
+		"main.go:\n\t[ADD]foo[/ADD]\n\tRED_STARTbarRED_END\n",
+		"ends with an ERROR",
+	}
+
+	for _, in := range inputs {
+		want := convertEscapeSequences(in)
+		for i := 1; i < len(in); i++ {
+			if got := simulateStream([]string{in[:i], in[i:]}, convertEscapeSequences); got != want {
+				t.Errorf("split %q at %d: got %q, want %q", in, i, got, want)
+			}
+		}
+
+		// Also feed the input one byte at a time
+		var chunks []string
+		for i := 0; i < len(in); i++ {
+			chunks = append(chunks, in[i:i+1])
+		}
+		if got := simulateStream(chunks, convertEscapeSequences); got != want {
+			t.Errorf("byte-by-byte %q: got %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNormalizeEscapes(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain text", in: "no escapes", want: "no escapes"},
+		{name: "literal newline", in: `SUMMARY:\n  - one`, want: "SUMMARY:\n  - one"},
+		{name: "literal tab", in: `DETAILS:\n\tmain.go:`, want: "DETAILS:\n\tmain.go:"},
+		{name: "real whitespace untouched", in: "a\nb\tc", want: "a\nb\tc"},
+		{name: "color escape untouched", in: `\033[32;1m+ added\033[0m\n`, want: "\\033[32;1m+ added\\033[0m\n"},
+		{name: "escaped backslash", in: `fmt.Print("\\n")`, want: `fmt.Print("\\n")`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeEscapes(tt.in); got != tt.want {
+				t.Errorf("normalizeEscapes(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizedStreamingSplitAtEveryByte(t *testing.T) {
+	in := `SUMMARY:\n\t\033[32;1m+ foo\033[0m\n`
+	want := convertEscapeSequences(normalizeEscapes(in))
+
+	render := func(text string) string {
+		return convertEscapeSequences(normalizeEscapes(text))
+	}
+
+	for i := 1; i < len(in); i++ {
+		if got := simulateStream([]string{in[:i], in[i:]}, render); got != want {
+			t.Errorf("split at %d: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestReadDiffLeavesStdinWithGitArgs(t *testing.T) {
+	// A pipe that is never written to or closed, as stdin often is in CI
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	original := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() { os.Stdin = original; r.Close() })
+
+	_, stderr := captureOutput(t, func() {
+		done := make(chan error, 1)
+		go func() {
+			_, err := readDiff(context.Background(), []string{"HEAD", "--", "no-such-path"})
+			done <- err
+		}()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("readDiff: %s", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("readDiff waited for stdin although git diff arguments were given")
+		}
+	})
+	if !strings.Contains(stderr, "Warning: ignoring stdin because git diff arguments were given") {
+		t.Errorf("no warning about the ignored stdin: %q", stderr)
+	}
+}
+
+func TestReadDiffSaysItWaitsForStdin(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := os.Stdin
+	os.Stdin = r
+	originalNotice := stdinWaitNotice
+	stdinWaitNotice = 10 * time.Millisecond
+	t.Cleanup(func() { os.Stdin = original; stdinWaitNotice = originalNotice; r.Close() })
+
+	piped := "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-a\n+b\n"
+	var got string
+	_, stderr := captureOutput(t, func() {
+		// The diff arrives well after the notice is due
+		go func() {
+			time.Sleep(200 * time.Millisecond)
+			w.WriteString(piped)
+			w.Close()
+		}()
+		got, err = readDiff(context.Background(), nil)
+	})
+	if err != nil || got != piped {
+		t.Errorf("readDiff = %q, %v, want the piped diff", got, err)
+	}
+	if !strings.Contains(stderr, "Waiting for a diff on stdin") {
+		t.Errorf("no notice while waiting for stdin: %q", stderr)
+	}
+}
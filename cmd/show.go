@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/tydin/difx/cache"
+	"github.com/tydin/difx/diff"
+)
+
+var showCmd = &cobra.Command{
+	Use:   "show <commit>",
+	Short: "Explain the changes of a single commit",
+	Long: `Explain what a single commit changed, like git show. The commit is given by
+hash, branch, tag or any other revision. Its message is sent with the diff, so
+the explanation can say why the changes were made, and its author and date are
+printed above the explanation. A merge is explained against its first parent.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, span := startSpan(cmd, "difx show")
+		defer span.End()
+
+		cfg := loadConfig()
+
+		commit, diffOutput, err := diff.ShowCommit(ctx, args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading commit: %s\n", err)
+			exit(exitGit)
+		}
+
+		if diffOutput == "" {
+			fmt.Printf("Commit %s changes no files.\n", commit.ShortHash())
+			return
+		}
+
+		// Don't pay for the same explanation twice in a row
+		if unchangedSinceLastRun(cfg, diffOutput) {
+			return
+		}
+
+		// Remember the diff so it can be explained again later
+		if err := cache.SaveLastDiff(diffOutput); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not save the diff for difx again: %s\n", err)
+		}
+
+		// JSON output is a single document, without the header
+		if !cfg.Structured {
+			printCommitHeader(os.Stdout, commit)
+		}
+
+		ensureAPIKey(cfg)
+		cfg.CommitMessage = commit.Message
+		addCommitLog(ctx, cfg)
+		addProjectContext(ctx, cfg)
+		explain(ctx, cfg, diffOutput)
+	},
+}
+
+// printCommitHeader writes which commit is explained, by whom and when, and
+// its subject
+func printCommitHeader(w io.Writer, commit diff.Commit) {
+	hash := color.New(color.FgYellow).Sprint(commit.ShortHash())
+	fmt.Fprintf(w, "commit %s by %s <%s> on %s\n", hash, commit.Author, commit.Email, commit.Date.Format("2006-01-02 15:04 -0700"))
+	fmt.Fprintf(w, "    %s\n\n", commit.Subject())
+}
+
+func init() {
+	rootCmd.AddCommand(showCmd)
+}
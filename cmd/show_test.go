@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/tydin/difx/diff"
+)
+
+func TestPrintCommitHeader(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	t.Cleanup(func() { color.NoColor = noColor })
+
+	commit := diff.Commit{
+		Hash:    "1a2b3c4d5e6f708192a3b4c5d6e7f80912a3b4c5",
+		Author:  "Jane Doe",
+		Email:   "jane@example.com",
+		Date:    time.Date(2026, 10, 1, 9, 30, 0, 0, time.FixedZone("", 2*60*60)),
+		Message: "Add parser\n\nHandles empty files too.",
+	}
+
+	var out strings.Builder
+	printCommitHeader(&out, commit)
+
+	want := "commit 1a2b3c4 by Jane Doe <jane@example.com> on 2026-10-01 09:30 +0200\n    Add parser\n\n"
+	if out.String() != want {
+		t.Errorf("printCommitHeader = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/tydin/difx/config"
+)
+
+// resetStats clears the usage counts instead of showing them
+var resetStats bool
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show how much difx has used each model",
+	Long: `Show the runs, requests, tokens and cost of each model since counting
+started. The counts are kept in stats.json in the config directory and never
+leave the machine. Tokens are estimated when the provider doesn't report them,
+and costs are counted with input_price and output_price from the config.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if resetStats {
+			if err := config.ResetStats(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error resetting stats: %s\n", err)
+				exit(1)
+			}
+			fmt.Println("Usage stats reset.")
+			return
+		}
+
+		cfg := loadConfig()
+		stats, err := config.LoadStats()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading stats: %s\n", err)
+			exit(1)
+		}
+		printStats(os.Stdout, stats, cfg)
+	},
+}
+
+// printStats writes the usage counts as a table with a row for each model
+func printStats(w io.Writer, stats *config.Stats, cfg *config.Config) {
+	if len(stats.Models) == 0 {
+		fmt.Fprintln(w, "No requests counted yet.")
+		return
+	}
+
+	models := make([]string, 0, len(stats.Models))
+	for model := range stats.Models {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+
+	fmt.Fprintf(w, "Since %s\n\n", stats.Since.Format("2006-01-02"))
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "MODEL\tRUNS\tREQUESTS\tINPUT TOKENS\tOUTPUT TOKENS\tCOST\t")
+	var total config.ModelStats
+	for _, model := range models {
+		counts := stats.Models[model]
+		fmt.Fprintf(table, "%s\t%d\t%d\t%d\t%d\t$%.2f\t\n", model, counts.Runs, counts.Requests, counts.InputTokens, counts.OutputTokens, counts.Cost)
+		total.Runs += counts.Runs
+		total.Requests += counts.Requests
+		total.InputTokens += counts.InputTokens
+		total.OutputTokens += counts.OutputTokens
+		total.Cost += counts.Cost
+	}
+	if len(models) > 1 {
+		fmt.Fprintf(table, "total\t%d\t%d\t%d\t%d\t$%.2f\t\n", total.Runs, total.Requests, total.InputTokens, total.OutputTokens, total.Cost)
+	}
+	table.Flush()
+
+	if !hasPrices(cfg) {
+		fmt.Fprintln(w, "\nSet input_price and output_price (dollars per million tokens) in the config to count costs.")
+	}
+}
+
+// hasPrices tells whether any model has a price set to count costs with
+func hasPrices(cfg *config.Config) bool {
+	for _, model := range config.Models {
+		if input, output := cfg.Prices(model); input != 0 || output != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// usageMu keeps the requests of a --chunked run from writing the stats file
+// at the same time
+var usageMu sync.Mutex
+
+// countedRuns holds the models this run has been counted for
+var countedRuns = map[string]bool{}
+
+// spent is the estimated cost of the requests of this run
+var spent float64
+
+// runCost returns what the requests of this run have cost so far
+func runCost() float64 {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	return spent
+}
+
+// recordUsage returns a function that adds a request to the local stats.
+// Failing to save them only loses a count, so it is just a warning.
+func recordUsage(cfg *config.Config) func(model string, inputTokens, outputTokens int) {
+	return func(model string, inputTokens, outputTokens int) {
+		usageMu.Lock()
+		defer usageMu.Unlock()
+
+		cost := config.RequestCost(cfg, model, inputTokens, outputTokens)
+		spent += cost
+
+		stats, err := config.LoadStats()
+		if err == nil {
+			stats.Add(model, !countedRuns[model], inputTokens, outputTokens, cost)
+			err = config.SaveStats(stats)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not update usage stats: %s\n", err)
+			return
+		}
+		countedRuns[model] = true
+	}
+}
+
+func init() {
+	statsCmd.Flags().BoolVar(&resetStats, "reset", false, "Delete the usage counts and start counting again")
+	rootCmd.AddCommand(statsCmd)
+}
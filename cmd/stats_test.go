@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tydin/difx/config"
+)
+
+func TestRecordUsage(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	countedRuns = map[string]bool{}
+	t.Cleanup(func() { countedRuns = map[string]bool{} })
+
+	// A chunked run counts once, with all of its requests
+	record := recordUsage(&config.Config{ActiveModel: config.ModelClaude, InputPrice: 3, OutputPrice: 15})
+	record(config.ModelClaude, 1000, 100)
+	record(config.ModelClaude, 3000, 300)
+	record(config.ModelAzureOpenAI, 50, 10)
+
+	stats, err := config.LoadStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	printStats(&out, stats, &config.Config{})
+
+	lines := strings.Split(out.String(), "\n")
+	if len(lines) < 6 || !strings.HasPrefix(lines[0], "Since ") {
+		t.Fatalf("printStats =\n%s", out.String())
+	}
+	for i, want := range []string{
+		"azure_openai  1  1  50  10  $0.00",
+		"claude  1  2  4000  400  $0.02",
+		"total  2  3  4050  410  $0.02",
+	} {
+		if got := strings.Join(strings.Fields(lines[i+3]), "  "); got != want {
+			t.Errorf("row %d = %q, want %q", i, got, want)
+		}
+	}
+	if !strings.Contains(out.String(), "Set input_price and output_price") {
+		t.Errorf("no hint about prices:\n%s", out.String())
+	}
+}
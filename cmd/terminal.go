@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"os"
+	"regexp"
+)
+
+// ansiCodeRegex matches ANSI color codes, including one cut off at the end of the text
+var ansiCodeRegex = regexp.MustCompile(`\x1b\[[0-9;]*(m|$)`)
+
+// supportsANSI reports whether the terminal can render ANSI color codes.
+// This is about capability, not preference: output piped to another program
+// keeps its colors, but a dumb terminal or a legacy Windows console does not.
+func supportsANSI() bool {
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	return enableVirtualTerminal()
+}
+
+// stdoutIsTerminal reports whether stdout is a terminal rather than a pipe or file
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// stderrIsTerminal reports whether stderr is a terminal rather than a pipe or file
+func stderrIsTerminal() bool {
+	info, err := os.Stderr.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// stripEscapeSequences converts the model's escape sequences and markers like
+// convertEscapeSequences does, then removes the resulting color codes entirely
+func stripEscapeSequences(text string) string {
+	return ansiCodeRegex.ReplaceAllString(convertEscapeSequences(text), "")
+}
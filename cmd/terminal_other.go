@@ -0,0 +1,8 @@
+//go:build !windows
+
+package cmd
+
+// enableVirtualTerminal is a no-op outside Windows, where terminals handle ANSI codes natively
+func enableVirtualTerminal() bool {
+	return true
+}
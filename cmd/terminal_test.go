@@ -0,0 +1,31 @@
+package cmd
+
+import "testing"
+
+func TestStripEscapeSequences(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain text", in: "no colors here", want: "no colors here"},
+		{name: "model escapes", in: `\033[32;1m+ added\033[0m and \033[31;1m- removed\033[0m`, want: "+ added and - removed"},
+		{name: "legacy markers", in: "[ADD]new[/ADD] GREEN_STARTmoreGREEN_END", want: "new more"},
+		{name: "cut off code", in: `text\033[0`, want: "text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripEscapeSequences(tt.in); got != tt.want {
+				t.Errorf("stripEscapeSequences(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSupportsANSIDumbTerminal(t *testing.T) {
+	t.Setenv("TERM", "dumb")
+	if supportsANSI() {
+		t.Error("supportsANSI() = true with TERM=dumb")
+	}
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/tydin/difx/config"
+)
+
+// theme holds the styles for the parts of the explanation's layout, on top
+// of whatever colors the model used
+type theme struct {
+	header    *color.Color
+	delimiter *color.Color
+}
+
+// themes are the presets for --theme
+var themes = map[string]*theme{
+	config.ThemeDark: {
+		header:    color.New(color.FgHiCyan, color.Bold),
+		delimiter: color.New(color.FgHiBlack),
+	},
+	config.ThemeLight: {
+		header:    color.New(color.FgBlue, color.Bold),
+		delimiter: color.New(color.FgWhite),
+	},
+	config.ThemeMono: {
+		header:    color.New(color.Bold, color.Underline),
+		delimiter: color.New(color.Faint),
+	},
+}
+
+// sectionHeaders are the section markers the model is asked to use
+var sectionHeaders = []string{"SUMMARY:", "FILE CHANGES:", "DETAILS:", "TEST COVERAGE:", "LIKELY INTENT:"}
+
+// activeTheme styles the explanation; nil leaves it as the model wrote it
+var activeTheme *theme
+
+// setTheme selects the theme named in the config
+func setTheme(cfg *config.Config) error {
+	if cfg.Theme == "" {
+		activeTheme = nil
+		return nil
+	}
+
+	t, ok := themes[cfg.Theme]
+	if !ok {
+		return fmt.Errorf("unknown theme %q (use %s, %s or %s)", cfg.Theme, config.ThemeDark, config.ThemeLight, config.ThemeMono)
+	}
+	activeTheme = t
+	return nil
+}
+
+// applyTheme styles the section headers and delimiter lines of rendered text.
+// midLine tells that the text continues a line started earlier, so its first
+// line isn't a whole line and is left alone.
+func applyTheme(text string, midLine bool) string {
+	if activeTheme == nil {
+		return text
+	}
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if i == 0 && midLine {
+			continue
+		}
+
+		trimmed := strings.TrimLeft(line, " \t")
+		indent := line[:len(line)-len(trimmed)]
+		if isDelimiter(trimmed) {
+			lines[i] = indent + activeTheme.delimiter.Sprint(trimmed)
+			continue
+		}
+		for _, header := range sectionHeaders {
+			if strings.HasPrefix(trimmed, header) {
+				lines[i] = indent + activeTheme.header.Sprint(header) + trimmed[len(header):]
+				break
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// themeText styles a complete explanation
+func themeText(text string) string {
+	return applyTheme(text, false)
+}
+
+// isDelimiter tells whether a line is one of the dash lines around the explanation
+func isDelimiter(line string) bool {
+	line = strings.TrimRight(line, " \t\r")
+	return len(line) >= 3 && strings.Trim(line, "-") == ""
+}
+
+// mayBeThemed tells whether the start of a line could still turn out to be
+// a header or delimiter, so a streamed line should wait until it's complete
+func mayBeThemed(start string) bool {
+	if activeTheme == nil {
+		return false
+	}
+
+	trimmed := strings.TrimLeft(start, " \t")
+	if trimmed == "" || strings.Trim(trimmed, "-") == "" {
+		return true
+	}
+	for _, header := range sectionHeaders {
+		if strings.HasPrefix(header, trimmed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/tydin/difx/config"
+)
+
+func TestApplyTheme(t *testing.T) {
+	color.NoColor = false
+	if err := setTheme(&config.Config{Theme: config.ThemeMono}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { activeTheme = nil })
+
+	in := "-----\nSUMMARY:\n  - one DETAILS: inline\n  DETAILS:\n"
+	want := "\x1b[2m-----\x1b[22m\n\x1b[1;4mSUMMARY:\x1b[22;24m\n  - one DETAILS: inline\n  \x1b[1;4mDETAILS:\x1b[22;24m\n"
+	if got := themeText(in); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// A continued line is left alone
+	if got := applyTheme("SUMMARY: x", true); got != "SUMMARY: x" {
+		t.Errorf("mid-line text was styled: %q", got)
+	}
+}
+
+func TestStreamRendererTheme(t *testing.T) {
+	color.NoColor = false
+	if err := setTheme(&config.Config{Theme: config.ThemeDark}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { activeTheme = nil })
+
+	in := "-----\nSUMMARY:\n  - see FILE CHANGES: below\nFILE CHANGES:\n  a.go\n-----"
+	want := themeText(convertEscapeSequences(in))
+
+	for i := 1; i < len(in); i++ {
+		if got := simulateStream([]string{in[:i], in[i:]}, convertEscapeSequences); got != want {
+			t.Errorf("split at %d: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestUnknownTheme(t *testing.T) {
+	if err := setTheme(&config.Config{Theme: "neon"}); err == nil {
+		t.Error("expected an error for an unknown theme")
+	}
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/tydin/difx/diff"
+)
+
+// treeDiffExplain adds the model's explanation of the changes to the listing
+var treeDiffExplain bool
+
+var treeDiffCmd = &cobra.Command{
+	Use:   "tree-diff <a> <b>",
+	Short: "List the files that differ between two branches or commits",
+	Long: `List the files that were added, modified, deleted, renamed or copied between
+two branches, commits or tags, from git diff --name-status. With --explain the
+diff between them is also sent to the model, which explains why each file
+changed.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, span := startSpan(cmd, "difx tree-diff")
+		defer span.End()
+
+		cfg := loadConfig()
+		a, b := args[0], args[1]
+
+		files, err := diff.TreeDiff(ctx, a, b)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing the changed files: %s\n", err)
+			exit(exitGit)
+		}
+		if len(files) == 0 {
+			fmt.Println("No differences found.")
+			return
+		}
+
+		printTreeDiff(os.Stdout, a, b, files)
+		if !treeDiffExplain {
+			return
+		}
+
+		diffOutput, err := diff.RunGitDiff(ctx, []string{a, b})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading diff: %s\n", err)
+			exit(exitGit)
+		}
+		fmt.Println()
+
+		ensureAPIKey(cfg)
+		addCommitLog(ctx, cfg)
+		addProjectContext(ctx, cfg)
+		explain(ctx, cfg, diffOutput)
+	},
+}
+
+// treeStatusOrder is the order of the counts in the summary line
+var treeStatusOrder = []diff.FileStatus{diff.StatusAdded, diff.StatusModified, diff.StatusDeleted, diff.StatusRenamed, diff.StatusCopied}
+
+// printTreeDiff writes a line for each changed file, with its status letter
+// in the color of the change, and a summary line with the counts
+func printTreeDiff(w io.Writer, a, b string, files []diff.NameStatus) {
+	counts := map[diff.FileStatus]int{}
+	for _, file := range files {
+		counts[file.Status]++
+
+		letter := strings.ToUpper(string(file.Status[0]))
+		line := file.Path
+		if file.OldPath != "" {
+			line = fmt.Sprintf("%s -> %s (%d%%)", file.OldPath, file.Path, file.Similarity)
+		}
+		fmt.Fprintf(w, "%s  %s\n", treeStatusColor(file.Status).Sprint(letter), line)
+	}
+
+	var parts []string
+	for _, status := range treeStatusOrder {
+		if counts[status] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[status], status))
+		}
+	}
+	noun := "files differ"
+	if len(files) == 1 {
+		noun = "file differs"
+	}
+	fmt.Fprintf(w, "\n%d %s between %s and %s: %s\n", len(files), noun, a, b, strings.Join(parts, ", "))
+}
+
+// treeStatusColor colors added and deleted files like added and deleted
+// lines in the active color scheme
+func treeStatusColor(status diff.FileStatus) *color.Color {
+	switch status {
+	case diff.StatusAdded:
+		return color.New(activeScheme.add...)
+	case diff.StatusDeleted:
+		return color.New(activeScheme.del...)
+	case diff.StatusRenamed, diff.StatusCopied:
+		return color.New(color.FgCyan)
+	default:
+		return color.New(color.FgYellow)
+	}
+}
+
+func init() {
+	treeDiffCmd.Flags().BoolVar(&treeDiffExplain, "explain", false, "Also explain the changes with the model")
+	rootCmd.AddCommand(treeDiffCmd)
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/tydin/difx/diff"
+)
+
+func TestPrintTreeDiff(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	t.Cleanup(func() { color.NoColor = noColor })
+
+	files := []diff.NameStatus{
+		{Status: diff.StatusModified, Path: "main.go"},
+		{Status: diff.StatusAdded, Path: "docs/new.md"},
+		{Status: diff.StatusRenamed, OldPath: "pkg/a.go", Path: "pkg/b.go", Similarity: 95},
+		{Status: diff.StatusDeleted, Path: "old.go"},
+		{Status: diff.StatusModified, Path: "go.mod"},
+	}
+
+	var out strings.Builder
+	printTreeDiff(&out, "main", "feature", files)
+
+	want := `M  main.go
+A  docs/new.md
+R  pkg/a.go -> pkg/b.go (95%)
+D  old.go
+M  go.mod
+
+5 files differ between main and feature: 1 added, 2 modified, 1 deleted, 1 renamed
+`
+	if out.String() != want {
+		t.Errorf("printTreeDiff =\n%s\nwant\n%s", out.String(), want)
+	}
+}
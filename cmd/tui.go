@@ -0,0 +1,350 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+	"github.com/tydin/difx/cache"
+	"github.com/tydin/difx/config"
+	"github.com/tydin/difx/diff"
+)
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui [<commit>...] [--] [<git diff args>...]",
+	Short: "Browse the changed files and their explanations interactively",
+	Long: `Open a terminal UI listing the changed files. Each file is explained with its
+own API call when it is selected, and the explanation streams into the pane.`,
+	Args: cobra.ArbitraryArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, span := startSpan(cmd, "difx tui")
+		defer span.End()
+
+		cfg := loadConfig()
+		cfg.Reverse = reversed(cmd)
+		cfg.WordDiff = wordDiffed(cmd, cfg)
+
+		diffOutput, err := readDiff(ctx, gitArgs(cmd, compareArgs(cmd, cfg, args)))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading diff: %s\n", err)
+			exit(exitGit)
+		}
+
+		if diffOutput == "" {
+			fmt.Println("No differences found.")
+			return
+		}
+
+		// Remember the diff so it can be explained again later
+		if err := cache.SaveLastDiff(diffOutput); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not save the diff for difx again: %s\n", err)
+		}
+
+		ensureAPIKey(cfg)
+		addCommitLog(ctx, cfg)
+		addProjectContext(ctx, cfg)
+
+		diffOutput, _ = mustPrepareDiff(cfg, diff.NormalizeLineEndings(diffOutput))
+		if cfg.ConfirmSend && !assumeYes {
+			if !confirmSend(cfg, diffOutput) {
+				fmt.Fprintln(os.Stderr, "Aborted, nothing was sent.")
+				exit(1)
+			}
+		}
+
+		// Stop any running requests when the UI exits
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		m := newTUIModel(ctx, cfg, diff.SplitFileDiffs(diffOutput))
+		program := tea.NewProgram(m, tea.WithAltScreen(), tea.WithContext(ctx))
+		m.send = program.Send
+
+		if _, err := program.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running the TUI: %s\n", err)
+			exit(1)
+		}
+
+		if failed := m.failedFiles(); failOnError && len(failed) > 0 {
+			fmt.Fprintf(os.Stderr, "Error: no explanation for %s\n", strings.Join(failed, ", "))
+			exit(exitAPI)
+		}
+	},
+}
+
+// fileState is how far the explanation of one file has got
+type fileState int
+
+const (
+	fileIdle fileState = iota
+	fileLoading
+	fileDone
+	fileFailed
+)
+
+// stateIcons mark each file's state in the sidebar
+var stateIcons = map[fileState]string{
+	fileIdle:    " ",
+	fileLoading: "…",
+	fileDone:    "✓",
+	fileFailed:  "✗",
+}
+
+// tuiFile is one changed file with its explanation so far
+type tuiFile struct {
+	name        string
+	diff        string
+	state       fileState
+	explanation strings.Builder
+	err         error
+}
+
+// Messages sent from the request goroutines to the UI
+type (
+	chunkMsg struct {
+		index int
+		text  string
+	}
+	doneMsg struct {
+		index int
+		err   error
+	}
+)
+
+// tuiModel is the bubbletea model of the TUI
+type tuiModel struct {
+	ctx    context.Context
+	cfg    *config.Config
+	files  []*tuiFile
+	cursor int
+	pane   viewport.Model
+	width  int
+	height int
+
+	// sem limits how many files are explained at once
+	sem chan struct{}
+
+	// send delivers messages to the running program; set once it's created
+	send func(tea.Msg)
+}
+
+// Sidebar and pane styles
+var (
+	sidebarStyle  = lipgloss.NewStyle().BorderStyle(lipgloss.NormalBorder()).BorderRight(true).PaddingRight(1)
+	selectedStyle = lipgloss.NewStyle().Reverse(true)
+	helpStyle     = lipgloss.NewStyle().Faint(true)
+)
+
+// newTUIModel creates the model for the given per-file diffs
+func newTUIModel(ctx context.Context, cfg *config.Config, sections []string) *tuiModel {
+	m := &tuiModel{
+		ctx: ctx,
+		cfg: cfg,
+		sem: make(chan struct{}, max(cfg.Concurrency, 1)),
+	}
+
+	for _, section := range sections {
+		name := section
+		if files := diff.GetChangedFiles(section); len(files) > 0 {
+			name = files[0]
+		}
+		m.files = append(m.files, &tuiFile{name: name, diff: section})
+	}
+
+	// Up and down move through the files, so the pane only scrolls by page
+	m.pane = viewport.New(0, 0)
+	m.pane.KeyMap.Up.SetEnabled(false)
+	m.pane.KeyMap.Down.SetEnabled(false)
+
+	// Lay out for the likely size until the terminal reports its own, which
+	// it may never do
+	m.resize(termWidth(), defaultHeight)
+
+	return m
+}
+
+func (m *tuiModel) Init() tea.Cmd {
+	return m.fetch(m.cursor)
+}
+
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.resize(msg.Width, msg.Height)
+		m.refreshPane()
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c", "esc":
+			return m, tea.Quit
+		case "up", "k":
+			return m, m.selectFile(m.cursor - 1)
+		case "down", "j":
+			return m, m.selectFile(m.cursor + 1)
+		case "r":
+			// Retry a file whose explanation failed
+			if m.files[m.cursor].state == fileFailed {
+				m.files[m.cursor].state = fileIdle
+				return m, m.fetch(m.cursor)
+			}
+		}
+
+	case chunkMsg:
+		m.files[msg.index].explanation.WriteString(msg.text)
+		if msg.index == m.cursor {
+			m.refreshPane()
+		}
+		return m, nil
+
+	case doneMsg:
+		file := m.files[msg.index]
+		if msg.err != nil {
+			file.state = fileFailed
+			file.err = msg.err
+		} else {
+			file.state = fileDone
+		}
+		if msg.index == m.cursor {
+			m.refreshPane()
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.pane, cmd = m.pane.Update(msg)
+	return m, cmd
+}
+
+// selectFile moves the cursor and starts explaining the newly selected file
+func (m *tuiModel) selectFile(index int) tea.Cmd {
+	if index < 0 || index >= len(m.files) || index == m.cursor {
+		return nil
+	}
+	m.cursor = index
+	m.refreshPane()
+	m.pane.GotoTop()
+	return m.fetch(index)
+}
+
+// fetch explains a file in the background unless it already has an explanation
+func (m *tuiModel) fetch(index int) tea.Cmd {
+	if len(m.files) == 0 || m.files[index].state != fileIdle {
+		return nil
+	}
+
+	file := m.files[index]
+	file.state = fileLoading
+	file.explanation.Reset()
+	file.err = nil
+
+	return func() tea.Msg {
+		select {
+		case m.sem <- struct{}{}:
+			defer func() { <-m.sem }()
+		case <-m.ctx.Done():
+			return doneMsg{index: index, err: m.ctx.Err()}
+		}
+
+		// Without streaming the callback gets the whole response at once
+		_, err := diff.GetExplanation(m.ctx, file.diff, m.cfg, func(text string) {
+			m.send(chunkMsg{index: index, text: text})
+		})
+		return doneMsg{index: index, err: err}
+	}
+}
+
+// refreshPane shows the selected file's explanation in the pane
+func (m *tuiModel) refreshPane() {
+	if len(m.files) == 0 {
+		return
+	}
+
+	file := m.files[m.cursor]
+	var content string
+	switch file.state {
+	case fileFailed:
+		content = fmt.Sprintf("Error getting explanation from AI: %s\n\nPress r to retry.", file.err)
+	case fileLoading:
+		// Hold back an escape sequence that hasn't fully arrived yet
+		content = themeText(renderText(cleanIncompleteEscapeSequences(file.explanation.String())))
+		if content == "" {
+			content = "Explaining " + file.name + "…"
+		}
+	default:
+		content = themeText(renderText(file.explanation.String()))
+	}
+
+	m.pane.SetContent(lipgloss.NewStyle().Width(m.pane.Width).Render(content))
+}
+
+// failedFiles returns the names of the files whose explanation failed
+func (m *tuiModel) failedFiles() []string {
+	var failed []string
+	for _, file := range m.files {
+		if file.state == fileFailed {
+			failed = append(failed, file.name)
+		}
+	}
+	return failed
+}
+
+// resize lays the sidebar and pane out for the given screen size
+func (m *tuiModel) resize(width, height int) {
+	m.width, m.height = width, height
+	m.pane.Width = m.width - m.sidebarWidth() - 3
+	m.pane.Height = m.height - 1
+}
+
+// sidebarWidth fits the longest file name, up to a third of the screen
+func (m *tuiModel) sidebarWidth() int {
+	width := 10
+	for _, file := range m.files {
+		width = max(width, lipgloss.Width(file.name)+2)
+	}
+	return min(width, m.width/3)
+}
+
+func (m *tuiModel) View() string {
+	if m.width == 0 {
+		return ""
+	}
+
+	width := m.sidebarWidth()
+	var items []string
+	for i, file := range m.files {
+		name := file.name
+		if width > 3 && lipgloss.Width(name) > width-2 {
+			// Keep the end of long paths, which is the most telling part. Wide
+			// characters take two columns, so the name is cut by its width.
+			runes := []rune(name)
+			for len(runes) > 0 && lipgloss.Width(string(runes)) > width-3 {
+				runes = runes[1:]
+			}
+			name = "…" + string(runes)
+		}
+		item := stateIcons[file.state] + " " + name
+		if i == m.cursor {
+			item = selectedStyle.Render(item)
+		}
+		items = append(items, item)
+	}
+
+	sidebar := sidebarStyle.Width(width).Height(m.height - 1).Render(strings.Join(items, "\n"))
+	body := lipgloss.JoinHorizontal(lipgloss.Top, sidebar, " ", m.pane.View())
+	help := helpStyle.Render("↑/↓ select file • pgup/pgdn scroll • r retry • q quit")
+
+	return lipgloss.JoinVertical(lipgloss.Left, body, help)
+}
+
+func init() {
+	addGitFlags(tuiCmd.Flags())
+	tuiCmd.MarkFlagsMutuallyExclusive(gitOutputModes...)
+	tuiCmd.RegisterFlagCompletionFunc("against", compareCompletion)
+	rootCmd.AddCommand(tuiCmd)
+}
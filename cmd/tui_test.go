@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tydin/difx/config"
+)
+
+// newTestTUI creates a TUI model for two files without fetching anything
+func newTestTUI() *tuiModel {
+	sections := []string{
+		"diff --git a/main.go b/main.go\n@@ -1 +1 @@\n-a\n+b",
+		"diff --git a/cmd/root.go b/cmd/root.go\n@@ -1 +1 @@\n-c\n+d",
+	}
+	m := newTUIModel(context.Background(), &config.Config{Concurrency: 1}, sections)
+	m.Update(tea.WindowSizeMsg{Width: 80, Height: 20})
+	return m
+}
+
+func TestTUIFileNames(t *testing.T) {
+	m := newTestTUI()
+	if len(m.files) != 2 || m.files[0].name != "main.go" || m.files[1].name != "cmd/root.go" {
+		t.Fatalf("unexpected files: %+v", m.files)
+	}
+}
+
+func TestTUIStreamsIntoPane(t *testing.T) {
+	m := newTestTUI()
+	m.files[0].state = fileLoading
+
+	m.Update(chunkMsg{index: 0, text: "Changes the "})
+	m.Update(chunkMsg{index: 0, text: "greeting"})
+	m.Update(doneMsg{index: 0})
+
+	if m.files[0].state != fileDone {
+		t.Errorf("state = %v, want done", m.files[0].state)
+	}
+	if view := m.pane.View(); !strings.Contains(view, "Changes the greeting") {
+		t.Errorf("pane does not show the explanation:\n%s", view)
+	}
+
+	// Chunks for another file don't show up in the selected pane
+	m.files[1].state = fileLoading
+	m.Update(chunkMsg{index: 1, text: "other file"})
+	if strings.Contains(m.pane.View(), "other file") {
+		t.Error("pane shows the explanation of a file that isn't selected")
+	}
+}
+
+func TestTUIFailureCanBeRetried(t *testing.T) {
+	m := newTestTUI()
+	m.files[0].state = fileLoading
+	m.Update(doneMsg{index: 0, err: errors.New("rate limited")})
+
+	if !strings.Contains(m.pane.View(), "rate limited") {
+		t.Errorf("pane does not show the error:\n%s", m.pane.View())
+	}
+
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	if cmd == nil || m.files[0].state != fileLoading {
+		t.Errorf("r did not restart the request, state = %v", m.files[0].state)
+	}
+}
+
+func TestTUIFailedFiles(t *testing.T) {
+	m := newTestTUI()
+	m.files[0].state = fileLoading
+	m.files[1].state = fileLoading
+	m.Update(doneMsg{index: 0})
+	m.Update(doneMsg{index: 1, err: errors.New("unauthorized")})
+
+	if got := m.failedFiles(); len(got) != 1 || got[0] != "cmd/root.go" {
+		t.Errorf("failed files = %v, want [cmd/root.go]", got)
+	}
+}
+
+func TestTUITruncatesWideNames(t *testing.T) {
+	sections := []string{"diff --git a/日本語のファイル名です.go b/日本語のファイル名です.go\n@@ -1 +1 @@\n-a\n+b"}
+	m := newTUIModel(context.Background(), &config.Config{Concurrency: 1}, sections)
+	m.Update(tea.WindowSizeMsg{Width: 60, Height: 20})
+
+	// The name is wider than the sidebar in columns, not in characters
+	view := m.View()
+	if !strings.Contains(view, "…ファイル名です.go") || strings.Contains(view, "のファイル") {
+		t.Errorf("the wide name isn't cut to the sidebar:\n%s", view)
+	}
+}
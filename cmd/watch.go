@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+	"github.com/tydin/difx/config"
+	"github.com/tydin/difx/diff"
+)
+
+var watchInterval time.Duration
+var watchPaths []string
+
+var watchCmd = &cobra.Command{
+	Use:   "watch [<commit>...] [--] [<git diff args>...]",
+	Short: "Explain the working tree again whenever files change",
+	Long: `Watch the repository and explain the diff again once file changes settle.
+The screen is cleared before each new explanation. A diff that is the same as
+the last one explained is not sent again.`,
+	Args: cobra.ArbitraryArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, span := startSpan(cmd, "difx watch")
+		defer span.End()
+
+		cfg := loadConfig()
+		cfg.Reverse = reversed(cmd)
+		cfg.WordDiff = wordDiffed(cmd, cfg)
+		ensureAPIKey(cfg)
+		addProjectContext(ctx, cfg)
+
+		root, err := diff.RepoRoot(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error finding the repository: %s\n", err)
+			exit(exitGit)
+		}
+
+		// Build output and dependencies change often and explain nothing
+		ignored, err := diff.IgnoredDirs(ctx, root)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not list the directories git ignores: %s\n", err)
+		}
+
+		dirs, err := watchDirs(root, watchPaths, ignored)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			exit(1)
+		}
+
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error starting the file watcher: %s\n", err)
+			exit(1)
+		}
+		defer watcher.Close()
+
+		for _, dir := range dirs {
+			if err := watcher.Add(dir); err != nil {
+				fmt.Fprintf(os.Stderr, "Error watching %s: %s\n", dir, err)
+				exit(1)
+			}
+		}
+
+		watch(ctx, cfg, watcher, gitArgs(cmd, compareArgs(cmd, cfg, args)))
+	},
+}
+
+// watch explains the diff once, then again after every burst of file changes
+// has been quiet for watchInterval, until the context is done
+func watch(ctx context.Context, cfg *config.Config, watcher *fsnotify.Watcher, args []string) {
+	var lastDiff string
+	first := true
+	explainChanges := func() {
+		diffOutput, err := diff.RunGitDiff(ctx, args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading diff: %s\n", err)
+			return
+		}
+
+		// Saving a file without changing it, or undoing a change, needs no new explanation
+		if diffOutput == lastDiff && !first {
+			return
+		}
+		lastDiff, first = diffOutput, false
+
+		clearScreen()
+		if diffOutput == "" {
+			fmt.Println("No differences found.")
+		} else {
+			explainOnce(ctx, cfg, diffOutput)
+		}
+		fmt.Fprintf(os.Stderr, "\nWatching for changes (Ctrl+C to stop)...\n")
+	}
+
+	explainChanges()
+
+	// The timer only runs while there are changes waiting to settle
+	debounce := time.NewTimer(watchInterval)
+	debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			// New directories are watched too, since fsnotify isn't recursive
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() && !ignoredDir(info.Name()) && !diff.IsIgnored(ctx, event.Name) {
+					watcher.Add(event.Name)
+				}
+			}
+			debounce.Reset(watchInterval)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "Warning: file watcher: %s\n", err)
+
+		case <-debounce.C:
+			explainChanges()
+		}
+	}
+}
+
+// explainOnce explains a diff like a normal run, but reports failures
+// instead of exiting so the watch keeps going
+func explainOnce(ctx context.Context, cfg *config.Config, diffOutput string) {
+	diffOutput, _, err := prepareDiff(cfg, diffOutput)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return
+	}
+	if diffOutput == "" {
+		fmt.Println(noNewFilesMessage)
+		return
+	}
+	if cfg.ConfirmSend && !assumeYes && !confirmSend(cfg, diffOutput) {
+		fmt.Fprintln(os.Stderr, "Skipped, nothing was sent.")
+		return
+	}
+
+	_, err = printModelOutput(cfg, func(callback func(string)) (string, error) {
+		return diff.GetExplanation(ctx, diffOutput, cfg, callback)
+	})
+	if err != nil && ctx.Err() == nil {
+		fmt.Fprintf(os.Stderr, "\nError getting explanation from AI: %s\n", err)
+	}
+}
+
+// clearScreen clears the terminal before a new explanation
+func clearScreen() {
+	if supportsANSI() && stdoutIsTerminal() {
+		fmt.Print("\033[H\033[2J")
+	}
+}
+
+// watchDirs lists the directories to watch: every directory under the given
+// paths, or under the repository root when there are none. Git's own
+// directory and other hidden directories are skipped, and so are the
+// ignored ones, given relative to root.
+func watchDirs(root string, paths []string, ignored []string) ([]string, error) {
+	if len(paths) == 0 {
+		paths = []string{root}
+	}
+
+	skip := make(map[string]bool, len(ignored))
+	for _, dir := range ignored {
+		skip[filepath.Join(root, dir)] = true
+	}
+
+	var dirs []string
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(dir string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() {
+				return nil
+			}
+			// --paths may be relative, the ignored directories are not
+			abs, _ := filepath.Abs(dir)
+			if dir != path && (ignoredDir(entry.Name()) || skip[abs]) {
+				return filepath.SkipDir
+			}
+			dirs = append(dirs, dir)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the directories to watch: %w", err)
+		}
+	}
+	return dirs, nil
+}
+
+// ignoredDir tells whether a directory is never watched, such as .git
+func ignoredDir(name string) bool {
+	return len(name) > 1 && name[0] == '.'
+}
+
+func init() {
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", 500*time.Millisecond, "How long files must be unchanged before explaining again")
+	watchCmd.Flags().StringSliceVar(&watchPaths, "paths", nil, "Only watch these directories (default: the whole repository)")
+	addGitFlags(watchCmd.Flags())
+	watchCmd.MarkFlagsMutuallyExclusive(gitOutputModes...)
+	watchCmd.RegisterFlagCompletionFunc("against", compareCompletion)
+	rootCmd.AddCommand(watchCmd)
+}
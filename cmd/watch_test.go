@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tydin/difx/config"
+)
+
+func TestWatchDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{".git/objects", "cmd/sub", "diff", ".cache", "node_modules/pkg", "cmd/build"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Directories git ignores are skipped with everything in them
+	ignored := []string{"node_modules", filepath.Join("cmd", "build")}
+	got, err := watchDirs(root, nil, ignored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{root, filepath.Join(root, "cmd"), filepath.Join(root, "cmd/sub"), filepath.Join(root, "diff")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dirs = %v, want %v", got, want)
+	}
+
+	// --paths limits the watch to those directories
+	got, err = watchDirs(root, []string{filepath.Join(root, "cmd")}, ignored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{filepath.Join(root, "cmd"), filepath.Join(root, "cmd/sub")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dirs = %v, want %v", got, want)
+	}
+
+	if _, err := watchDirs(root, []string{filepath.Join(root, "missing")}, nil); err == nil {
+		t.Error("expected an error for a missing path")
+	}
+}
+
+func TestExplainOnceWithoutNewFiles(t *testing.T) {
+	modified := "diff --git a/a.go b/a.go\nindex 1111111..2222222 100644\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-old\n+new\n"
+
+	// The watch goes on, rather than exiting, when a save adds no file
+	stdout, _ := captureOutput(t, func() {
+		explainOnce(context.Background(), &config.Config{NewFilesOnly: true, Offline: true}, modified)
+	})
+	if !strings.Contains(stdout, noNewFilesMessage) {
+		t.Errorf("stdout = %q, want %q", stdout, noNewFilesMessage)
+	}
+}
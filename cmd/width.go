@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// defaultWidth is used when the terminal width can't be detected, such as
+// when output is piped or in CI
+const defaultWidth = 80
+
+// defaultHeight is the number of rows the TUI assumes until the terminal
+// reports its size
+const defaultHeight = 24
+
+// widthOverride is the --width flag; 0 detects the width
+var widthOverride int
+
+// termWidth returns the number of columns to lay output out in: --width,
+// then COLUMNS, then the width of the terminal on stdout or stderr, and
+// otherwise defaultWidth. Everything that depends on the width gets it here.
+func termWidth() int {
+	if widthOverride > 0 {
+		return widthOverride
+	}
+
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+
+	for _, f := range []*os.File{os.Stdout, os.Stderr} {
+		if width, _, err := term.GetSize(int(f.Fd())); err == nil && width > 0 {
+			return width
+		}
+	}
+
+	return defaultWidth
+}
+
+// fitWidth shortens a line to at most width characters by dropping the start
+// of it, which keeps the end of a long path visible
+func fitWidth(line string, width int) string {
+	runes := []rune(line)
+	if width < 2 || len(runes) <= width {
+		return line
+	}
+	return "…" + string(runes[len(runes)-(width-1):])
+}
+
+// tabStop is the column multiple a terminal moves a tab to
+const tabStop = 8
+
+// truncateLines cuts every line wider than width columns, ending it with an
+// ellipsis, so a wide diff doesn't wrap on screen. Lines keep their start,
+// which holds the +, - or @@ that marks them, and their line ending.
+func truncateLines(text string, width int) string {
+	if width < 2 {
+		return text
+	}
+
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(text, "\n") {
+		content := strings.TrimRight(line, "\r\n")
+		b.WriteString(truncateLine(content, width))
+		b.WriteString(line[len(content):])
+	}
+	return b.String()
+}
+
+// truncateLine shortens one line to width columns, counting a tab to the
+// next tab stop as a terminal shows it
+func truncateLine(line string, width int) string {
+	if columns(line) <= width {
+		return line
+	}
+
+	// Keep what fits before the ellipsis, which takes the last column
+	column := 0
+	for i, r := range line {
+		column = advance(column, r)
+		if column > width-1 {
+			return line[:i] + "…"
+		}
+	}
+	return line
+}
+
+// columns returns how many columns a line takes on screen
+func columns(line string) int {
+	column := 0
+	for _, r := range line {
+		column = advance(column, r)
+	}
+	return column
+}
+
+// advance returns the column after printing r at column
+func advance(column int, r rune) int {
+	if r == '\t' {
+		return (column/tabStop + 1) * tabStop
+	}
+	return column + 1
+}
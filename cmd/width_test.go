@@ -0,0 +1,52 @@
+package cmd
+
+import "testing"
+
+func TestTermWidth(t *testing.T) {
+	// Tests don't run on a terminal, so the default applies
+	t.Setenv("COLUMNS", "")
+	if got := termWidth(); got != defaultWidth {
+		t.Errorf("termWidth without a terminal = %d, want %d", got, defaultWidth)
+	}
+
+	t.Setenv("COLUMNS", "132")
+	if got := termWidth(); got != 132 {
+		t.Errorf("termWidth with COLUMNS = %d, want 132", got)
+	}
+
+	// --width wins over COLUMNS
+	widthOverride = 40
+	defer func() { widthOverride = 0 }()
+	if got := termWidth(); got != 40 {
+		t.Errorf("termWidth with --width = %d, want 40", got)
+	}
+}
+
+func TestFitWidth(t *testing.T) {
+	if got := fitWidth("Explaining file 1/2: a.go", 80); got != "Explaining file 1/2: a.go" {
+		t.Errorf("a short line was changed to %q", got)
+	}
+	if got := fitWidth("Explaining file 1/2: src/pkg/a.go", 10); got != "…/pkg/a.go" {
+		t.Errorf("fitWidth = %q", got)
+	}
+}
+
+func TestTruncateLines(t *testing.T) {
+	tests := []struct {
+		name, text string
+		width      int
+		want       string
+	}{
+		{"fits", "+short\n", 10, "+short\n"},
+		{"exactly", "+123456789\n", 10, "+123456789\n"},
+		{"too long", "+1234567890\n", 10, "+12345678…\n"},
+		{"crlf kept", "-1234567890\r\n", 10, "-12345678…\r\n"},
+		{"tab to the next stop", "+\tindented line\n", 12, "+\tind…\n"},
+		{"several lines", "@@ -1 +1 @@ func main() {\n context\n", 12, "@@ -1 +1 @@…\n context\n"},
+	}
+	for _, tt := range tests {
+		if got := truncateLines(tt.text, tt.width); got != tt.want {
+			t.Errorf("%s: truncateLines(%q, %d) = %q, want %q", tt.name, tt.text, tt.width, got, tt.want)
+		}
+	}
+}
@@ -1,11 +1,12 @@
 package config
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -15,18 +16,216 @@ const (
 	ModelAzureOpenAI = "azure_openai"
 )
 
+// Models lists the supported models, in the order they are suggested
+var Models = []string{ModelClaude, ModelAzureOpenAI}
+
+// modelAliases maps other names for a model, such as its provider, to the
+// supported one. A model that is renamed gets its old name added here, so a
+// config that saved it keeps working. Only names that can't mean anything
+// else belong here: "openai" would send requests for OpenAI to Azure.
+var modelAliases = map[string]string{
+	"anthropic":    ModelClaude,
+	"azure":        ModelAzureOpenAI,
+	"azure-openai": ModelAzureOpenAI,
+	"azureopenai":  ModelAzureOpenAI,
+}
+
+// CanonicalModel returns the supported name for a model name or alias, and
+// the name unchanged if it isn't known
+func CanonicalModel(name string) string {
+	if model, ok := modelAliases[strings.ToLower(name)]; ok {
+		return model
+	}
+	return name
+}
+
+// UnsupportedModelError explains that a model isn't supported, with the
+// models that are and how to switch to one
+func UnsupportedModelError(name string) error {
+	return fmt.Errorf("unsupported model %q (supported: %s); switch with difx config set active_model <model>", name, strings.Join(Models, ", "))
+}
+
+// Color schemes for added and deleted text
+const (
+	ColorSchemeDefault    = "default"
+	ColorSchemeLight      = "light"
+	ColorSchemeColorblind = "colorblind"
+	ColorSchemeCustom     = "custom"
+)
+
+// Themes for the section headers and delimiters of the explanation
+const (
+	ThemeDark  = "dark"
+	ThemeLight = "light"
+	ThemeMono  = "mono"
+)
+
+// Comparison bases for a plain difx, without commits: git diff HEAD,
+// git diff --cached or git diff
+const (
+	CompareHead    = "head"
+	CompareIndex   = "index"
+	CompareWorking = "working"
+)
+
+// How difx authenticates to Azure OpenAI
+const (
+	AzureAuthKey = "key"
+	AzureAuthAAD = "aad"
+)
+
+// Personas that set the tone of the explanation
+const (
+	PersonaTeacher   = "teacher"
+	PersonaReviewer  = "reviewer"
+	PersonaChangelog = "changelog"
+	PersonaELI5      = "eli5"
+)
+
+// Minimum severity of the changes listed in DETAILS
+const (
+	SeverityAll     = "all"
+	SeverityNotable = "notable"
+	SeverityMajor   = "major"
+)
+
 // Config holds the application configuration
 type Config struct {
-	ActiveModel        string `json:"active_model"`
-	ClaudeAPIKey       string `json:"claude_api_key"`
-	AzureOpenAIEndpoint string `json:"azure_openai_endpoint"`
-	AzureOpenAIKey     string `json:"azure_openai_key"`
-	Streaming          bool   `json:"streaming"`
+	ActiveModel         string   `json:"active_model"`
+	ClaudeAPIKey        string   `json:"claude_api_key"`
+	ClaudeBaseURL       string   `json:"claude_base_url,omitempty"`
+	AzureOpenAIEndpoint string   `json:"azure_openai_endpoint"`
+	AzureOpenAIKey      string   `json:"azure_openai_key"`
+	AzureAuthMode       string   `json:"azure_auth_mode,omitempty"`
+	ProxyURL            string   `json:"proxy_url,omitempty"`
+	RequestTimeout      int      `json:"request_timeout"`
+	Streaming           bool     `json:"streaming"`
+	WrapCode            bool     `json:"wrap_code"`
+	MaxHunkLines        int      `json:"max_hunk_lines"`
+	DedupeImports       bool     `json:"dedupe_imports"`
+	IncludeLockfiles    bool     `json:"include_lockfiles"`
+	IncludeSubmodules   bool     `json:"include_submodules"`
+	ProjectContextFile  string   `json:"project_context,omitempty"`
+	MaxLineChars        int      `json:"max_line_chars"`
+	MaxInputTokens      int      `json:"max_input_tokens"`
+	MaxRequestBytes     int      `json:"max_request_bytes"`
+	FullContext         bool     `json:"full_context"`
+	CheckTests          bool     `json:"check_tests"`
+	Intent              bool     `json:"intent"`
+	Persona             string   `json:"persona,omitempty"`
+	GroupByDir          bool     `json:"group_by_dir"`
+	Offline             bool     `json:"offline"`
+	StripNoNewline      bool     `json:"strip_no_newline"`
+	Concurrency         int      `json:"concurrency"`
+	Cache               bool     `json:"cache"`
+	Structured          bool     `json:"structured"`
+	ConfirmSend         bool     `json:"confirm_send"`
+	AnonymizePaths      bool     `json:"anonymize_paths"`
+	KeepExtensions      bool     `json:"keep_extensions"`
+	ColorScheme         string   `json:"color_scheme"`
+	CustomAddColor      string   `json:"custom_add_color,omitempty"`
+	CustomDeleteColor   string   `json:"custom_delete_color,omitempty"`
+	Theme               string   `json:"theme,omitempty"`
+	DefaultCompare      string   `json:"default_compare,omitempty"`
+	AnthropicVersion    string   `json:"anthropic_version"`
+	MinSeverity         string   `json:"min_severity"`
+	MaxResponseTime     int      `json:"max_response_time"`
+	MaxRetries          int      `json:"max_retries"`
+	StopSequences       []string `json:"stop_sequences,omitempty"`
+	InputPrice          float64  `json:"input_price,omitempty"`
+	OutputPrice         float64  `json:"output_price,omitempty"`
+	Budget              float64  `json:"budget,omitempty"`
+	PostHook            string   `json:"post_hook,omitempty"`
+	Seed                *int     `json:"seed,omitempty"`
+
+	// The prices of a model, over input_price and output_price, which are
+	// those of the active model
+	ClaudeInputPrice       float64 `json:"claude_input_price,omitempty"`
+	ClaudeOutputPrice      float64 `json:"claude_output_price,omitempty"`
+	AzureOpenAIInputPrice  float64 `json:"azure_openai_input_price,omitempty"`
+	AzureOpenAIOutputPrice float64 `json:"azure_openai_output_price,omitempty"`
+
+	// RecentCommits are added to the prompt as context; set per run, never saved
+	RecentCommits []string `json:"-"`
+
+	// FileVersions holds the complete changed files added to the prompt with
+	// FullContext; set per request, never saved
+	FileVersions string `json:"-"`
+
+	// Resume continues a streamed explanation that was cut off; set per run, never saved
+	Resume bool `json:"-"`
+
+	// Changelog asks for release notes instead of an explanation; set per run, never saved
+	Changelog bool `json:"-"`
+
+	// Reverse means the diff was taken with its sides swapped, to be explained
+	// as undoing the changes; set per run, never saved
+	Reverse bool `json:"-"`
+
+	// WordDiff means the diff was taken with git diff --word-diff, marking
+	// changed words rather than lines; set per run, never saved
+	WordDiff bool `json:"-"`
+
+	// NewFilesOnly means the diff was narrowed to the files it adds; set per
+	// run, never saved
+	NewFilesOnly bool `json:"-"`
+
+	// Review asks for review comments instead of an explanation; set per run, never saved
+	Review bool `json:"-"`
+
+	// ProjectContext is the repository's background from ProjectContextFile; set per run, never saved
+	ProjectContext string `json:"-"`
+
+	// Background is reference material about the changes, such as a ticket
+	// description; set per run, never saved
+	Background string `json:"-"`
+
+	// CommitMessage is the message of the single commit being explained, as
+	// with difx show; set per run, never saved
+	CommitMessage string `json:"-"`
+
+	// Notify receives notes for the user about a request, such as why it is
+	// being retried; set per run, never saved
+	Notify func(message string) `json:"-"`
+
+	// RecordDir and ReplayDir hold recordings of the API exchanges, from
+	// RecordEnvVar and ReplayEnvVar; set per run, never saved
+	RecordDir string `json:"-"`
+	ReplayDir string `json:"-"`
+
+	// RecordUsage receives the token counts of every request sent to a model,
+	// estimated when the provider doesn't report them; set per run, never saved
+	RecordUsage func(model string, inputTokens, outputTokens int) `json:"-"`
 }
 
+// DefaultAnthropicVersion is the anthropic-version header sent to the Claude API by default
+const DefaultAnthropicVersion = "2023-06-01"
+
+// DefaultConcurrency is the number of chunked API calls run at once by default
+const DefaultConcurrency = 3
+
+// DefaultMaxRetries is how many times a request that failed with a temporary
+// error is sent again by default
+const DefaultMaxRetries = 2
+
+// DefaultMaxRequestBytes caps the prompt of a request by default. It is far
+// beyond what any model accepts, so only a runaway diff, such as a whole
+// repository piped in by mistake, reaches it.
+const DefaultMaxRequestBytes = 4 << 20
+
 // ConfigDir is the directory where config is stored
 const ConfigDir = "~/.config/difx"
 
+// OfflineEnvVar turns on offline mode when set to 1 or true
+const OfflineEnvVar = "DIFX_OFFLINE"
+
+// RecordEnvVar names a directory to save every API request and response to,
+// and ReplayEnvVar one to answer them from instead of the network
+const (
+	RecordEnvVar = "DIFX_RECORD"
+	ReplayEnvVar = "DIFX_REPLAY"
+)
+
 // ConfigFile is the path to the config file
 const ConfigFile = "config.json"
 
@@ -51,8 +250,49 @@ func getConfigPath() (string, error) {
 	return filepath.Join(expandedDir, ConfigFile), nil
 }
 
-// LoadOrCreate loads the config file if it exists, or creates a new one if it doesn't
+// LoadOrCreate loads the config file if it exists, or creates a new one if it
+// doesn't, and applies the environment variables that override it. From
+// lowest to highest precedence: built-in defaults, the system config file,
+// the user's config file, then the environment; flags come on top.
 func LoadOrCreate() (*Config, error) {
+	config, err := LoadStored()
+	if err != nil {
+		return nil, err
+	}
+
+	// Override with environment variables if they exist
+	if envKey := os.Getenv("CLAUDE_API_KEY"); envKey != "" {
+		config.ClaudeAPIKey = envKey
+	}
+	
+	if envURL := os.Getenv("CLAUDE_BASE_URL"); envURL != "" {
+		config.ClaudeBaseURL = envURL
+	}
+
+	if envEndpoint := os.Getenv("AZURE_OPENAI_ENDPOINT"); envEndpoint != "" {
+		config.AzureOpenAIEndpoint = envEndpoint
+	}
+	
+	if envKey := os.Getenv("AZURE_OPENAI_KEY"); envKey != "" {
+		config.AzureOpenAIKey = envKey
+	}
+
+	// DIFX_OFFLINE=1 keeps everything on the machine, whatever the file says
+	if offline, err := strconv.ParseBool(os.Getenv(OfflineEnvVar)); err == nil && offline {
+		config.Offline = true
+	}
+
+	config.RecordDir = os.Getenv(RecordEnvVar)
+	config.ReplayDir = os.Getenv(ReplayEnvVar)
+
+	return config, nil
+}
+
+// LoadStored loads the config file as it is saved, with the system config
+// file's settings and then the built-in defaults for what it doesn't set, and
+// without environment overrides, so it can be changed and saved again. The
+// config directory is created if it doesn't exist.
+func LoadStored() (*Config, error) {
 	expandedDir, err := expandPath(ConfigDir)
 	if err != nil {
 		return nil, err
@@ -73,6 +313,20 @@ func LoadOrCreate() (*Config, error) {
 	// Set default values
 	config.ActiveModel = ModelClaude
 	config.Streaming = true
+	config.Concurrency = DefaultConcurrency
+	config.MaxRetries = DefaultMaxRetries
+	config.MaxRequestBytes = DefaultMaxRequestBytes
+	config.AnthropicVersion = DefaultAnthropicVersion
+	config.MinSeverity = SeverityAll
+
+	// An organization's defaults come before the user's own settings
+	system, err := readSystemConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := applySystemConfig(&config, system); err != nil {
+		return nil, err
+	}
 
 	// Check if config file exists
 	fileExists := true
@@ -91,26 +345,22 @@ func LoadOrCreate() (*Config, error) {
 		if err := json.NewDecoder(file).Decode(&config); err != nil {
 			return nil, fmt.Errorf("failed to decode config file: %w", err)
 		}
-	}
 
-	// Override with environment variables if they exist
-	if envKey := os.Getenv("CLAUDE_API_KEY"); envKey != "" {
-		config.ClaudeAPIKey = envKey
-	}
-	
-	if envEndpoint := os.Getenv("AZURE_OPENAI_ENDPOINT"); envEndpoint != "" {
-		config.AzureOpenAIEndpoint = envEndpoint
-	}
-	
-	if envKey := os.Getenv("AZURE_OPENAI_KEY"); envKey != "" {
-		config.AzureOpenAIKey = envKey
 	}
 
+	// A model saved under an older name is moved to the current one
+	config.ActiveModel = CanonicalModel(config.ActiveModel)
+
 	return &config, nil
 }
 
-// Save saves the config to disk
+// Save saves the config to disk. An invalid config is refused, so a broken
+// file can't stop the next run.
 func Save(config *Config) error {
+	if err := Validate(config); err != nil {
+		return fmt.Errorf("refusing to save an invalid config: %w", err)
+	}
+
 	configPath, err := getConfigPath()
 	if err != nil {
 		return err
@@ -122,26 +372,37 @@ func Save(config *Config) error {
 	}
 	defer file.Close()
 
-	encoder := json.NewEncoder(file)
+	return Encode(file, config)
+}
+
+// Encode writes the config as it is saved, as indented JSON. Settings with
+// the value the system config file gives them are left out, so they keep
+// following it.
+func Encode(w io.Writer, config *Config) error {
+	system, err := readSystemConfig()
+	if err != nil {
+		return err
+	}
+	var value interface{} = config
+	if len(system) > 0 {
+		if value, err = withoutSystemSettings(config, system); err != nil {
+			return fmt.Errorf("failed to encode config: %w", err)
+		}
+	}
+
+	encoder := json.NewEncoder(w)
 	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(config); err != nil {
+	if err := encoder.Encode(value); err != nil {
 		return fmt.Errorf("failed to encode config: %w", err)
 	}
-
 	return nil
 }
 
 // PromptForAPIKey prompts the user to enter their Claude API key
 func PromptForAPIKey() (string, error) {
-	fmt.Print("Please enter your Claude API key: ")
-	reader := bufio.NewReader(os.Stdin)
-	apiKey, err := reader.ReadString('\n')
+	apiKey, err := PromptForSecret("Please enter your Claude API key: ")
 	if err != nil {
 		return "", fmt.Errorf("failed to read API key: %w", err)
 	}
-
-	// Trim whitespace and newlines
-	apiKey = strings.TrimSpace(apiKey)
-
 	return apiKey, nil
 }
@@ -0,0 +1,224 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadStored(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CLAUDE_API_KEY", "from-env")
+
+	stored, err := LoadStored()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored.ClaudeAPIKey = "saved"
+	stored.AzureOpenAIKey = "azure"
+	if err := Save(stored); err != nil {
+		t.Fatal(err)
+	}
+
+	// The environment overrides the saved key, but not the stored config
+	cfg, err := LoadOrCreate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ClaudeAPIKey != "from-env" {
+		t.Errorf("LoadOrCreate key = %q, want the environment's", cfg.ClaudeAPIKey)
+	}
+	stored, err = LoadStored()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ClaudeAPIKey != "saved" || stored.AzureOpenAIKey != "azure" || !stored.Streaming {
+		t.Errorf("LoadStored = %+v", stored)
+	}
+}
+
+func TestLoadStoredModelAlias(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, ".config", "difx"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".config", "difx", ConfigFile), []byte(`{"active_model": "azure"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := LoadStored()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ActiveModel != ModelAzureOpenAI {
+		t.Errorf("ActiveModel = %q, want %q", stored.ActiveModel, ModelAzureOpenAI)
+	}
+
+	// OpenAI itself isn't a model difx has
+	if got := CanonicalModel("openai"); got != "openai" {
+		t.Errorf("CanonicalModel(\"openai\") = %q", got)
+	}
+}
+
+func TestUnsupportedModelError(t *testing.T) {
+	err := Validate(&Config{ActiveModel: "gpt"})
+	for _, want := range []string{`"gpt"`, ModelClaude, ModelAzureOpenAI, "difx config set active_model"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate = %v, want it to mention %s", err, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		return &Config{ActiveModel: ModelClaude, AnthropicVersion: DefaultAnthropicVersion, MinSeverity: SeverityAll}
+	}
+	if err := Validate(valid()); err != nil {
+		t.Fatalf("Validate = %v", err)
+	}
+
+	tests := []struct {
+		field  string
+		change func(*Config)
+	}{
+		{"active_model", func(c *Config) { c.ActiveModel = "gpt" }},
+		{"claude_base_url", func(c *Config) { c.ClaudeBaseURL = "api.example.com" }},
+		{"azure_auth_mode", func(c *Config) { c.AzureAuthMode = "token" }},
+		{"theme", func(c *Config) { c.Theme = "neon" }},
+		{"default_compare", func(c *Config) { c.DefaultCompare = "stash" }},
+		{"min_severity", func(c *Config) { c.MinSeverity = "some" }},
+		{"anthropic_version", func(c *Config) { c.AnthropicVersion = " " }},
+		{"concurrency", func(c *Config) { c.Concurrency = -1 }},
+		{"proxy_url", func(c *Config) { c.ProxyURL = "proxy.example.com:3128" }},
+		{"output_price", func(c *Config) { c.OutputPrice = -3 }},
+		{"request_timeout", func(c *Config) { c.RequestTimeout = -5 }},
+	}
+	for _, tt := range tests {
+		cfg := valid()
+		tt.change(cfg)
+
+		var fieldErr *FieldError
+		if err := Validate(cfg); !errors.As(err, &fieldErr) || fieldErr.Field != tt.field {
+			t.Errorf("%s: Validate = %v", tt.field, err)
+		}
+	}
+}
+
+func TestSaveRefusesInvalid(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	cfg, err := LoadStored()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ActiveModel = "gpt"
+	if err := Save(cfg); err == nil || !strings.Contains(err.Error(), "active_model") {
+		t.Errorf("Save = %v, want an active_model error", err)
+	}
+	if path, _ := getConfigPath(); fileExists(path) {
+		t.Error("the invalid config was written")
+	}
+}
+
+func TestSet(t *testing.T) {
+	cfg := &Config{}
+	for key, value := range map[string]string{
+		"min_severity":   "major",
+		"streaming":      "false",
+		"max_hunk_lines": "200",
+		"seed":           "7",
+		"stop_sequences": `["END", "STOP"]`,
+		"input_price":    "2.5",
+	} {
+		if err := Set(cfg, key, value); err != nil {
+			t.Errorf("Set(%s) = %v", key, err)
+		}
+	}
+	if cfg.MinSeverity != SeverityMajor || cfg.Streaming || cfg.MaxHunkLines != 200 || cfg.Seed == nil || *cfg.Seed != 7 || len(cfg.StopSequences) != 2 || cfg.InputPrice != 2.5 {
+		t.Errorf("cfg = %+v", cfg)
+	}
+
+	// Another name for a model is saved as the supported one
+	if err := Set(cfg, "active_model", "Anthropic"); err != nil || cfg.ActiveModel != ModelClaude {
+		t.Errorf("Set(active_model) = %v, %q", err, cfg.ActiveModel)
+	}
+
+	// An empty value clears an optional number
+	if err := Set(cfg, "seed", ""); err != nil || cfg.Seed != nil {
+		t.Errorf("clearing seed: %v, %v", err, cfg.Seed)
+	}
+
+	var fieldErr *FieldError
+	if err := Set(cfg, "max_hunk_lines", "many"); !errors.As(err, &fieldErr) || fieldErr.Field != "max_hunk_lines" {
+		t.Errorf("Set with a bad number = %v", err)
+	}
+	// Per-run settings aren't in the file
+	if err := Set(cfg, "-", "x"); err == nil {
+		t.Error("Set accepted a setting that isn't saved")
+	}
+	if keys := strings.Join(Keys(), ","); !strings.Contains(keys, "claude_api_key") || strings.Contains(keys, "-") {
+		t.Errorf("Keys = %s", keys)
+	}
+}
+
+func TestPromptsFailWithoutATerminal(t *testing.T) {
+	NonInteractive = true
+	t.Cleanup(func() { NonInteractive = false })
+
+	if _, err := PromptForAPIKey(); !errors.Is(err, ErrNonInteractive) {
+		t.Errorf("PromptForAPIKey = %v, want ErrNonInteractive", err)
+	}
+	if _, err := PromptForValue("Endpoint", "https://example.com"); !errors.Is(err, ErrNonInteractive) {
+		t.Errorf("PromptForValue = %v, want ErrNonInteractive", err)
+	}
+}
+
+func TestSystemConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	SystemConfigPath = filepath.Join(t.TempDir(), ConfigFile)
+	t.Cleanup(func() { SystemConfigPath = systemConfigPath() })
+	system := `{"azure_openai_endpoint": "https://corp.openai.azure.com", "proxy_url": "http://proxy.corp:3128", "concurrency": 2}`
+	if err := os.WriteFile(SystemConfigPath, []byte(system), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The user's own settings override the system's
+	stored, err := LoadStored()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.AzureOpenAIEndpoint != "https://corp.openai.azure.com" || stored.ProxyURL != "http://proxy.corp:3128" || stored.MaxRetries != DefaultMaxRetries {
+		t.Errorf("LoadStored = %+v, want the system settings and defaults", stored)
+	}
+	stored.Concurrency = 6
+	stored.ClaudeAPIKey = "mine"
+	if err := Save(stored); err != nil {
+		t.Fatal(err)
+	}
+
+	// Settings left as the system has them aren't saved, so they keep following it
+	saved, err := os.ReadFile(filepath.Join(os.Getenv("HOME"), ".config", "difx", ConfigFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(saved), "proxy_url") || strings.Contains(string(saved), "azure_openai_endpoint") {
+		t.Errorf("system settings were saved to the user's file:\n%s", saved)
+	}
+
+	system = `{"azure_openai_endpoint": "https://new.openai.azure.com", "concurrency": 2}`
+	if err := os.WriteFile(SystemConfigPath, []byte(system), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AZURE_OPENAI_ENDPOINT", "")
+	t.Setenv("CLAUDE_API_KEY", "")
+	cfg, err := LoadOrCreate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AzureOpenAIEndpoint != "https://new.openai.azure.com" || cfg.Concurrency != 6 || cfg.ClaudeAPIKey != "mine" {
+		t.Errorf("LoadOrCreate = %+v", cfg)
+	}
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// EnvFile is the name of the dotenv file looked for in the project
+const EnvFile = ".env"
+
+// envPrefixes are the variables difx takes from a .env file. Anything else
+// in the file belongs to other tools and is left alone.
+var envPrefixes = []string{"DIFX_", "CLAUDE_", "AZURE_"}
+
+// credentialVars are the only variables taken from a .env file that was found
+// rather than named with --env-file. A cloned repository can't be trusted
+// with the rest: an endpoint, base URL or recording directory of its choosing
+// would send the user's key and diffs, or write them, where it likes.
+var credentialVars = []string{"CLAUDE_API_KEY", "AZURE_OPENAI_KEY", "AZURE_OPENAI_AD_TOKEN"}
+
+// FindEnvFile returns the .env file in the current directory, or else in the
+// root of the git repository containing it. It returns "" if there is none.
+func FindEnvFile() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+
+	if path := filepath.Join(dir, EnvFile); fileExists(path) {
+		return path
+	}
+
+	// Walk up to the repository root, marked by its .git directory or file
+	for {
+		if fileExists(filepath.Join(dir, ".git")) {
+			if path := filepath.Join(dir, EnvFile); fileExists(path) {
+				return path
+			}
+			return ""
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// LoadEnvFile sets the difx related variables from a .env file. Variables
+// that are already set in the environment take precedence and are kept.
+// Unless the file is trusted, because the user named it, only credentials
+// are set; the other difx variables it has are returned, sorted, as skipped.
+func LoadEnvFile(path string, trusted bool) ([]string, error) {
+	values, err := godotenv.Read(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+
+	var skipped []string
+	for key, value := range values {
+		if !hasEnvPrefix(key) {
+			continue
+		}
+		if !trusted && !slices.Contains(credentialVars, key) {
+			skipped = append(skipped, key)
+			continue
+		}
+		if _, set := os.LookupEnv(key); set {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return nil, fmt.Errorf("failed to set %s: %w", key, err)
+		}
+	}
+	sort.Strings(skipped)
+	return skipped, nil
+}
+
+// hasEnvPrefix reports whether the variable is one difx reads
+func hasEnvPrefix(key string) bool {
+	for _, prefix := range envPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileExists reports whether something exists at the path
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// chdir changes the working directory for the duration of a test
+func chdir(t *testing.T, dir string) {
+	old, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(old) })
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), EnvFile)
+	content := "CLAUDE_API_KEY=from-file\nAZURE_OPENAI_KEY=azure-from-file\nDATABASE_URL=postgres://\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The real environment wins over the file
+	t.Setenv("AZURE_OPENAI_KEY", "from-env")
+	t.Setenv("CLAUDE_API_KEY", "")
+	os.Unsetenv("CLAUDE_API_KEY")
+	t.Setenv("DATABASE_URL", "")
+	os.Unsetenv("DATABASE_URL")
+
+	if _, err := LoadEnvFile(path, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := os.Getenv("CLAUDE_API_KEY"); got != "from-file" {
+		t.Errorf("CLAUDE_API_KEY = %q, want the value from the file", got)
+	}
+	if got := os.Getenv("AZURE_OPENAI_KEY"); got != "from-env" {
+		t.Errorf("AZURE_OPENAI_KEY = %q, want the environment to take precedence", got)
+	}
+	if _, set := os.LookupEnv("DATABASE_URL"); set {
+		t.Error("unrelated variables from the file should not be set")
+	}
+}
+
+func TestLoadEnvFileUntrusted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), EnvFile)
+	content := "CLAUDE_API_KEY=from-file\nCLAUDE_BASE_URL=https://evil.example\nAZURE_OPENAI_ENDPOINT=https://evil.example\nDIFX_RECORD=/tmp/loot\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"CLAUDE_API_KEY", "CLAUDE_BASE_URL", "AZURE_OPENAI_ENDPOINT", RecordEnvVar} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+
+	// A .env difx found on its own, as in a cloned repository
+	skipped, err := LoadEnvFile(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := os.Getenv("CLAUDE_API_KEY"); got != "from-file" {
+		t.Errorf("CLAUDE_API_KEY = %q, want the credential from the file", got)
+	}
+	for _, key := range []string{"CLAUDE_BASE_URL", "AZURE_OPENAI_ENDPOINT", RecordEnvVar} {
+		if _, set := os.LookupEnv(key); set {
+			t.Errorf("%s was taken from a .env that wasn't named", key)
+		}
+	}
+	if want := []string{"AZURE_OPENAI_ENDPOINT", "CLAUDE_BASE_URL", RecordEnvVar}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("skipped = %v, want %v", skipped, want)
+	}
+
+	// So the endpoint the config ends up with is still the default
+	t.Setenv("HOME", t.TempDir())
+	SystemConfigPath = filepath.Join(t.TempDir(), ConfigFile)
+	t.Cleanup(func() { SystemConfigPath = systemConfigPath() })
+	cfg, err := LoadOrCreate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ClaudeBaseURL != "" || cfg.AzureOpenAIEndpoint != "" || cfg.RecordDir != "" {
+		t.Errorf("config took the file's endpoints: %q, %q, %q", cfg.ClaudeBaseURL, cfg.AzureOpenAIEndpoint, cfg.RecordDir)
+	}
+}
+
+func TestFindEnvFile(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "pkg", "inner")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// No .env anywhere
+	chdir(t, sub)
+	if got := FindEnvFile(); got != "" {
+		t.Errorf("FindEnvFile() = %q, want none", got)
+	}
+
+	// A .env at the repository root is found from a subdirectory
+	if err := os.WriteFile(filepath.Join(root, EnvFile), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got := FindEnvFile(); filepath.Base(filepath.Dir(got)) != filepath.Base(root) {
+		t.Errorf("FindEnvFile() = %q, want the one in %s", got, root)
+	}
+
+	// One in the current directory is preferred
+	if err := os.WriteFile(filepath.Join(sub, EnvFile), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got := FindEnvFile(); filepath.Base(filepath.Dir(got)) != "inner" {
+		t.Errorf("FindEnvFile() = %q, want the one in %s", got, sub)
+	}
+}
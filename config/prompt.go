@@ -0,0 +1,78 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// NonInteractive turns every prompt into an ErrNonInteractive error, even on
+// a terminal, as --non-interactive asks
+var NonInteractive bool
+
+// ErrNonInteractive is returned instead of prompting when there is no one to
+// answer: stdin isn't a terminal, or NonInteractive is set. Waiting for an
+// answer that never comes would hang a CI job.
+var ErrNonInteractive = errors.New("can't prompt without an interactive terminal")
+
+// CanPrompt reports whether the user can be asked for input
+func CanPrompt() bool {
+	return !NonInteractive && term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// stdinReader is shared by the prompts, so a line buffered for one answer
+// isn't lost when several are typed ahead
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// PromptForSecret asks for a value without echoing it, such as an API key
+func PromptForSecret(prompt string) (string, error) {
+	if !CanPrompt() {
+		return "", ErrNonInteractive
+	}
+	fmt.Fprint(os.Stderr, prompt)
+
+	// The newline ending the answer isn't echoed either
+	defer fmt.Fprintln(os.Stderr)
+
+	secret, err := term.ReadPassword(int(os.Stdin.Fd()))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(secret)), nil
+}
+
+// PromptForValue asks for a value, showing the current one. An empty answer
+// keeps the current value.
+func PromptForValue(prompt string, current string) (string, error) {
+	if !CanPrompt() {
+		return "", ErrNonInteractive
+	}
+	if current != "" {
+		prompt = fmt.Sprintf("%s [%s]", prompt, current)
+	}
+	fmt.Fprint(os.Stderr, prompt+": ")
+
+	value, err := readLine()
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		return current, nil
+	}
+	return value, nil
+}
+
+// readLine reads one trimmed line from stdin. The last line may end without
+// a newline.
+func readLine() (string, error) {
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Keys returns the names of the settings saved in the config file, sorted
+func Keys() []string {
+	var keys []string
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if key := jsonKey(t.Field(i)); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// jsonKey returns the name of a field in the config file, or "" for a field
+// that isn't saved
+func jsonKey(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// Set changes the setting with the given config file name, parsing the value
+// as the setting's type. Booleans take true or false, numbers a whole number,
+// and lists a JSON array or a single value. An empty value clears an
+// optional number such as seed.
+func Set(c *Config, key string, value string) error {
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if jsonKey(v.Type().Field(i)) != key {
+			continue
+		}
+		if key == "active_model" {
+			value = CanonicalModel(value)
+		}
+
+		if err := setValue(v.Field(i), value); err != nil {
+			return &FieldError{Field: key, Err: err}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown setting %q", key)
+}
+
+// setValue parses the value into the field
+func setValue(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", value)
+		}
+		field.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		field.SetFloat(f)
+	case reflect.Pointer:
+		if value == "" {
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		elem := reflect.New(field.Type().Elem())
+		if err := setValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(elem)
+	case reflect.Slice:
+		var list []string
+		if strings.HasPrefix(strings.TrimSpace(value), "[") {
+			if err := json.Unmarshal([]byte(value), &list); err != nil {
+				return fmt.Errorf("%q is not a JSON list of strings", value)
+			}
+		} else if value != "" {
+			list = []string{value}
+		}
+		field.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("can't be set from the command line")
+	}
+	return nil
+}
+
+// maskedSecret replaces a secret that is shown
+const maskedSecret = "********"
+
+// Masked returns a copy of the config with its keys hidden, for showing it
+func Masked(c *Config) *Config {
+	masked := *c
+	if masked.ClaudeAPIKey != "" {
+		masked.ClaudeAPIKey = maskedSecret
+	}
+	if masked.AzureOpenAIKey != "" {
+		masked.AzureOpenAIKey = maskedSecret
+	}
+	return &masked
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StatsFile is the file in the config directory where difx adds up what it
+// has used. It never leaves the machine.
+const StatsFile = "stats.json"
+
+// Stats are the usage counts kept in StatsFile
+type Stats struct {
+	// Since is when counting started, or was last reset
+	Since time.Time `json:"since"`
+
+	// Models holds the counts for each model, such as claude
+	Models map[string]*ModelStats `json:"models"`
+}
+
+// ModelStats counts the use of one model
+type ModelStats struct {
+	// Runs is the number of difx runs that called the model, and Requests
+	// the number of calls, which is higher with --chunked or --strict
+	Runs         int `json:"runs"`
+	Requests     int `json:"requests"`
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+
+	// Cost is in dollars, from the model's prices at the time
+	Cost float64 `json:"cost"`
+}
+
+// getStatsPath returns the full path to the stats file
+func getStatsPath() (string, error) {
+	expandedDir, err := expandPath(ConfigDir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(expandedDir, StatsFile), nil
+}
+
+// LoadStats returns the saved usage counts, or empty ones if there are none yet
+func LoadStats() (*Stats, error) {
+	statsPath, err := getStatsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{Models: map[string]*ModelStats{}}
+	content, err := os.ReadFile(statsPath)
+	if os.IsNotExist(err) {
+		return stats, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stats file: %w", err)
+	}
+
+	if err := json.Unmarshal(content, stats); err != nil {
+		return nil, fmt.Errorf("failed to decode stats file: %w", err)
+	}
+	if stats.Models == nil {
+		stats.Models = map[string]*ModelStats{}
+	}
+	return stats, nil
+}
+
+// SaveStats writes the usage counts, creating the config directory if needed
+func SaveStats(stats *Stats) error {
+	statsPath, err := getStatsPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(statsPath), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	content, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode stats: %w", err)
+	}
+	if err := os.WriteFile(statsPath, append(content, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to save stats file: %w", err)
+	}
+	return nil
+}
+
+// ResetStats deletes the usage counts, so counting starts over
+func ResetStats() error {
+	statsPath, err := getStatsPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(statsPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stats file: %w", err)
+	}
+	return nil
+}
+
+// Add counts a request to a model. newRun is true for the first request of a
+// difx run. Counting starts with the first request after a reset.
+func (s *Stats) Add(model string, newRun bool, inputTokens, outputTokens int, cost float64) {
+	if s.Since.IsZero() {
+		s.Since = time.Now()
+	}
+
+	counts, ok := s.Models[model]
+	if !ok {
+		counts = &ModelStats{}
+		s.Models[model] = counts
+	}
+	if newRun {
+		counts.Runs++
+	}
+	counts.Requests++
+	counts.InputTokens += inputTokens
+	counts.OutputTokens += outputTokens
+	counts.Cost += cost
+}
+
+// Prices returns what a model costs in dollars per million input and output
+// tokens: its own prices, or input_price and output_price for the active
+// model. They are zero when not set.
+func (c *Config) Prices(model string) (input, output float64) {
+	switch model {
+	case ModelClaude:
+		input, output = c.ClaudeInputPrice, c.ClaudeOutputPrice
+	case ModelAzureOpenAI:
+		input, output = c.AzureOpenAIInputPrice, c.AzureOpenAIOutputPrice
+	}
+	if input == 0 && output == 0 && model == c.ActiveModel {
+		return c.InputPrice, c.OutputPrice
+	}
+	return input, output
+}
+
+// RequestCost is the price in dollars of a request to the model
+func RequestCost(cfg *Config, model string, inputTokens, outputTokens int) float64 {
+	input, output := cfg.Prices(model)
+	return (float64(inputTokens)*input + float64(outputTokens)*output) / 1e6
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	stats, err := LoadStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Models) != 0 || !stats.Since.IsZero() {
+		t.Errorf("stats before any request = %+v", stats)
+	}
+
+	cfg := &Config{ActiveModel: ModelClaude, InputPrice: 3, OutputPrice: 15}
+	stats.Add(ModelClaude, true, 1000, 200, RequestCost(cfg, ModelClaude, 1000, 200))
+	stats.Add(ModelClaude, false, 500, 100, RequestCost(cfg, ModelClaude, 500, 100))
+	if err := SaveStats(stats); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err = LoadStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := stats.Models[ModelClaude]
+	if got == nil || got.Runs != 1 || got.Requests != 2 || got.InputTokens != 1500 || got.OutputTokens != 300 || stats.Since.IsZero() {
+		t.Fatalf("saved stats = %+v", got)
+	}
+	if math.Abs(got.Cost-0.009) > 1e-9 {
+		t.Errorf("cost = %g, want 0.009", got.Cost)
+	}
+
+	if err := ResetStats(); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err := LoadStats(); err != nil || len(stats.Models) != 0 {
+		t.Errorf("stats after reset = %+v, %v", stats, err)
+	}
+}
+
+func TestPrices(t *testing.T) {
+	cfg := &Config{ActiveModel: ModelClaude, InputPrice: 3, OutputPrice: 15, AzureOpenAIInputPrice: 2.5, AzureOpenAIOutputPrice: 10}
+	for _, tc := range []struct {
+		model         string
+		input, output float64
+	}{
+		// input_price and output_price belong to the active model
+		{ModelClaude, 3, 15},
+		{ModelAzureOpenAI, 2.5, 10},
+	} {
+		if input, output := cfg.Prices(tc.model); input != tc.input || output != tc.output {
+			t.Errorf("Prices(%s) = %g, %g, want %g, %g", tc.model, input, output, tc.input, tc.output)
+		}
+	}
+
+	// A model's own prices come first, and another model has none of the active one's
+	cfg.ClaudeInputPrice, cfg.ClaudeOutputPrice = 1, 5
+	cfg.AzureOpenAIInputPrice, cfg.AzureOpenAIOutputPrice = 0, 0
+	if input, output := cfg.Prices(ModelClaude); input != 1 || output != 5 {
+		t.Errorf("Prices(claude) with its own prices = %g, %g", input, output)
+	}
+	if input, output := cfg.Prices(ModelAzureOpenAI); input != 0 || output != 0 {
+		t.Errorf("Prices(azure_openai) without its own prices = %g, %g", input, output)
+	}
+}
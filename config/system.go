@@ -0,0 +1,86 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+)
+
+// SystemConfigPath is the config file with an organization's defaults, such
+// as a mandated endpoint or proxy. Each user's config file overrides it. It
+// is a variable so tests can point it elsewhere.
+var SystemConfigPath = systemConfigPath()
+
+// systemConfigPath returns where administrators put the system config
+func systemConfigPath() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("ProgramData"), "difx", ConfigFile)
+	}
+	return filepath.Join("/etc/difx", ConfigFile)
+}
+
+// readSystemConfig returns the settings of the system config file by name,
+// and nil if there is none
+func readSystemConfig() (map[string]json.RawMessage, error) {
+	content, err := os.ReadFile(SystemConfigPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read system config file %s: %w", SystemConfigPath, err)
+	}
+
+	var settings map[string]json.RawMessage
+	if err := json.Unmarshal(content, &settings); err != nil {
+		return nil, fmt.Errorf("failed to decode system config file %s: %w", SystemConfigPath, err)
+	}
+	return settings, nil
+}
+
+// applySystemConfig sets the settings of the system config file on the config
+func applySystemConfig(config *Config, settings map[string]json.RawMessage) error {
+	if len(settings) == 0 {
+		return nil
+	}
+	content, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, config); err != nil {
+		return fmt.Errorf("failed to decode system config file %s: %w", SystemConfigPath, err)
+	}
+	return nil
+}
+
+// withoutSystemSettings returns the config's settings, leaving out those that
+// have the system config's value. Saving them would pin the user to today's
+// system defaults, and a setting in the user's file always wins.
+func withoutSystemSettings(config *Config, system map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+	content, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	var settings map[string]json.RawMessage
+	if err := json.Unmarshal(content, &settings); err != nil {
+		return nil, err
+	}
+
+	for key, systemValue := range system {
+		if value, ok := settings[key]; ok && sameJSON(value, systemValue) {
+			delete(settings, key)
+		}
+	}
+	return settings, nil
+}
+
+// sameJSON tells whether two JSON values are equal, whatever their layout
+func sameJSON(a, b json.RawMessage) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
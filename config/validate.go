@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// FieldError tells which setting of a config is invalid
+type FieldError struct {
+	// Field is the setting's name in the config file, such as active_model
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// invalid returns a FieldError for the field
+func invalid(field string, format string, args ...interface{}) error {
+	return &FieldError{Field: field, Err: fmt.Errorf(format, args...)}
+}
+
+// Validate checks that the config is coherent: known names, usable URLs and
+// no negative limits. It returns a *FieldError for the first invalid setting.
+func Validate(c *Config) error {
+	if !slices.Contains(Models, c.ActiveModel) {
+		return &FieldError{Field: "active_model", Err: UnsupportedModelError(c.ActiveModel)}
+	}
+
+	if c.ClaudeBaseURL != "" {
+		if err := ValidateURL(c.ClaudeBaseURL); err != nil {
+			return &FieldError{Field: "claude_base_url", Err: err}
+		}
+	}
+	if c.AzureOpenAIEndpoint != "" {
+		if err := ValidateURL(c.AzureOpenAIEndpoint); err != nil {
+			return &FieldError{Field: "azure_openai_endpoint", Err: err}
+		}
+	}
+
+	if c.ProxyURL != "" {
+		if err := validateProxyURL(c.ProxyURL); err != nil {
+			return &FieldError{Field: "proxy_url", Err: err}
+		}
+	}
+
+	if !oneOf(c.AzureAuthMode, AzureAuthKey, AzureAuthAAD) {
+		return invalid("azure_auth_mode", "unsupported mode %q (use %s or %s)", c.AzureAuthMode, AzureAuthKey, AzureAuthAAD)
+	}
+	if !oneOf(c.ColorScheme, ColorSchemeDefault, ColorSchemeLight, ColorSchemeColorblind, ColorSchemeCustom) {
+		return invalid("color_scheme", "unknown color scheme %q (use %s, %s, %s or %s)", c.ColorScheme, ColorSchemeDefault, ColorSchemeLight, ColorSchemeColorblind, ColorSchemeCustom)
+	}
+	if !oneOf(c.Theme, ThemeDark, ThemeLight, ThemeMono) {
+		return invalid("theme", "unknown theme %q (use %s, %s or %s)", c.Theme, ThemeDark, ThemeLight, ThemeMono)
+	}
+	if !oneOf(c.DefaultCompare, CompareHead, CompareIndex, CompareWorking) {
+		return invalid("default_compare", "unknown comparison base %q (use %s, %s or %s)", c.DefaultCompare, CompareHead, CompareIndex, CompareWorking)
+	}
+	if !oneOf(c.Persona, PersonaTeacher, PersonaReviewer, PersonaChangelog, PersonaELI5) {
+		return invalid("persona", "unknown persona %q (use %s, %s, %s or %s)", c.Persona, PersonaTeacher, PersonaReviewer, PersonaChangelog, PersonaELI5)
+	}
+	if !oneOf(c.MinSeverity, SeverityAll, SeverityNotable, SeverityMajor) {
+		return invalid("min_severity", "unknown severity %q (use %s, %s or %s)", c.MinSeverity, SeverityAll, SeverityNotable, SeverityMajor)
+	}
+
+	if strings.TrimSpace(c.AnthropicVersion) == "" {
+		return invalid("anthropic_version", "must not be empty (the default is %s)", DefaultAnthropicVersion)
+	}
+
+	limits := []struct {
+		field string
+		value int
+	}{
+		{"max_hunk_lines", c.MaxHunkLines},
+		{"max_line_chars", c.MaxLineChars},
+		{"max_input_tokens", c.MaxInputTokens},
+		{"max_request_bytes", c.MaxRequestBytes},
+		{"concurrency", c.Concurrency},
+		{"max_response_time", c.MaxResponseTime},
+		{"max_retries", c.MaxRetries},
+		{"request_timeout", c.RequestTimeout},
+	}
+	for _, limit := range limits {
+		if limit.value < 0 {
+			return invalid(limit.field, "must not be negative, got %d", limit.value)
+		}
+	}
+
+	// Prices are in dollars per million tokens
+	prices := []struct {
+		field string
+		value float64
+	}{
+		{"input_price", c.InputPrice},
+		{"output_price", c.OutputPrice},
+		{"claude_input_price", c.ClaudeInputPrice},
+		{"claude_output_price", c.ClaudeOutputPrice},
+		{"azure_openai_input_price", c.AzureOpenAIInputPrice},
+		{"azure_openai_output_price", c.AzureOpenAIOutputPrice},
+	}
+	for _, price := range prices {
+		if price.value < 0 {
+			return invalid(price.field, "must not be negative, got %g", price.value)
+		}
+	}
+	if c.Budget < 0 {
+		return invalid("budget", "must not be negative, got %g", c.Budget)
+	}
+
+	return nil
+}
+
+// oneOf tells whether value is empty, meaning the default, or one of the names
+func oneOf(value string, names ...string) bool {
+	return value == "" || slices.Contains(names, value)
+}
+
+// ValidateURL checks that a configured base URL is an absolute http or
+// https URL that API paths can be added to
+func ValidateURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("invalid URL %q: must start with https:// or http://", rawURL)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("invalid URL %q: no host", rawURL)
+	}
+	if parsed.RawQuery != "" || parsed.Fragment != "" {
+		return fmt.Errorf("invalid URL %q: must not have a query or fragment", rawURL)
+	}
+	return nil
+}
+
+// validateProxyURL checks that a proxy is given as an http, https or socks5 URL
+func validateProxyURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	if !slices.Contains([]string{"http", "https", "socks5"}, parsed.Scheme) || parsed.Host == "" {
+		return fmt.Errorf("invalid proxy %q: must be an http://, https:// or socks5:// URL with a host", rawURL)
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package diff
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tydin/difx/config"
+)
+
+// PathAliases maps the paths of a diff to the neutral names sent in their
+// place, such as file1 and file2, and back
+type PathAliases struct {
+	aliases        map[string]string
+	paths          map[string]string
+	keepExtensions bool
+}
+
+// aliasRegex matches a name that may be an alias, with an optional extension
+var aliasRegex = regexp.MustCompile(`file[0-9]+(\.[A-Za-z0-9]+)?`)
+
+// trailingColorCodeRegex matches a color code just before an alias, which
+// counts as a word boundary
+var trailingColorCodeRegex = regexp.MustCompile(`(\\033|\\x1b|\x1b)\[[0-9;]*m$`)
+
+// AnonymizePaths replaces the file paths in the headers of a diff with
+// file1, file2 and so on, numbered in order of appearance. With
+// keepExtensions the aliases keep the extension, such as file1.go, as a hint
+// about the language. The changed lines themselves are left as they are, but
+// not the lines difx writes itself, such as the description of a submodule
+// change. It returns the new diff and the aliases to restore the paths with.
+func AnonymizePaths(diffOutput string, keepExtensions bool) (string, *PathAliases) {
+	aliases := &PathAliases{aliases: map[string]string{}, paths: map[string]string{}, keepExtensions: keepExtensions}
+
+	files, err := Parse(diffOutput)
+	if err != nil || len(files) == 0 {
+		return diffOutput, aliases
+	}
+
+	for i := range files {
+		file := &files[i]
+		for j, line := range file.Header {
+			file.Header[j] = aliases.anonymizeHeaderLine(line, file.OldPath, file.NewPath)
+		}
+		file.OldPath, file.NewPath = aliases.add(file.OldPath), aliases.add(file.NewPath)
+	}
+
+	// Every path has its alias by now, whichever file it was seen in
+	for i := range files {
+		for j := range files[i].Hunks {
+			for k, line := range files[i].Hunks[j].Lines {
+				if line.Kind == LineOther {
+					files[i].Hunks[j].Lines[k].Text = aliases.Hide(line.Text)
+				}
+			}
+		}
+	}
+
+	return Format(files), aliases
+}
+
+// anonymizeRequest anonymizes the paths of the diff, and hides them in the
+// other sections of the prompt that can name them as well: the project
+// context, the commit subjects and message, and the background. It returns
+// the diff and a copy of cfg to build the request from.
+func anonymizeRequest(diffOutput string, cfg *config.Config) (string, *config.Config, *PathAliases) {
+	diffOutput, aliases := AnonymizePaths(diffOutput, cfg.KeepExtensions)
+
+	requestCfg := *cfg
+	requestCfg.ProjectContext = aliases.Hide(cfg.ProjectContext)
+	requestCfg.CommitMessage = aliases.Hide(cfg.CommitMessage)
+	requestCfg.Background = aliases.Hide(cfg.Background)
+	requestCfg.RecentCommits = nil
+	for _, commit := range cfg.RecentCommits {
+		requestCfg.RecentCommits = append(requestCfg.RecentCommits, aliases.Hide(commit))
+	}
+	return diffOutput, &requestCfg, aliases
+}
+
+// add gives a path its alias, or returns the one it already has
+func (a *PathAliases) add(p string) string {
+	if p == "" || p == devNull {
+		return p
+	}
+	if alias, ok := a.aliases[p]; ok {
+		return alias
+	}
+
+	alias := fmt.Sprintf("file%d", len(a.aliases)+1)
+	a.paths[alias] = p
+	// The model may leave the extension out when it names the file
+	if a.keepExtensions {
+		alias += path.Ext(p)
+		a.paths[alias] = p
+	}
+	a.aliases[p] = alias
+	return alias
+}
+
+// anonymizeHeaderLine replaces the old and new paths in a file header line
+func (a *PathAliases) anonymizeHeaderLine(line, oldPath, newPath string) string {
+	// The diff --git line names both sides, even for an added or deleted file
+	if oldName, newName := gitHeaderPaths(line); oldName != "" {
+		return "diff --git a/" + a.add(oldName) + " b/" + a.add(newName)
+	}
+	oldAlias, newAlias := a.add(oldPath), a.add(newPath)
+
+	// Each side only replaces a whole name, with or without git's prefix
+	side := func(name, prefix, real, alias string) string {
+		if real == "" || real == devNull {
+			return name
+		}
+		name, timestamp, hasTimestamp := strings.Cut(name, "\t")
+		switch name {
+		case prefix + real:
+			name = prefix + alias
+		case real:
+			name = alias
+		}
+		if hasTimestamp {
+			name += "\t" + timestamp
+		}
+		return name
+	}
+
+	for _, field := range []struct {
+		prefix     string
+		old        bool
+		pathPrefix string
+	}{
+		{"--- ", true, "a/"},
+		{"+++ ", false, "b/"},
+		{"rename from ", true, ""},
+		{"rename to ", false, ""},
+		{"copy from ", true, ""},
+		{"copy to ", false, ""},
+	} {
+		name, ok := strings.CutPrefix(line, field.prefix)
+		if !ok {
+			continue
+		}
+		if field.old {
+			return field.prefix + side(name, field.pathPrefix, oldPath, oldAlias)
+		}
+		return field.prefix + side(name, field.pathPrefix, newPath, newAlias)
+	}
+
+	if strings.HasPrefix(line, "Binary files ") {
+		return strings.NewReplacer("a/"+oldPath, "a/"+oldAlias, "b/"+newPath, "b/"+newAlias).Replace(line)
+	}
+	return line
+}
+
+// Restore puts the real paths back in place of their aliases in the model's
+// response. Only whole names that were given out as aliases are replaced,
+// so a file1 the diff never had, or a file1.txt for the alias file1, stays.
+func (a *PathAliases) Restore(text string) string {
+	if a == nil || len(a.paths) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, match := range aliasRegex.FindAllStringIndex(text, -1) {
+		start, end := match[0], match[1]
+
+		// Part of a longer word, such as myfile1 or file1_test
+		if start > 0 && isWordByte(text[start-1]) && !trailingColorCodeRegex.MatchString(text[:start]) {
+			continue
+		}
+		if end < len(text) && isWordByte(text[end]) {
+			continue
+		}
+
+		real, ok := a.paths[text[start:end]]
+		if !ok {
+			continue
+		}
+
+		b.WriteString(text[last:start])
+		b.WriteString(real)
+		last = end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// Hide replaces the real paths in text with their aliases. Only whole paths
+// are replaced, longest first, so main.go in cmd/main.go or domain.go is
+// left for the path it is part of.
+func (a *PathAliases) Hide(text string) string {
+	if a == nil || len(a.aliases) == 0 || text == "" {
+		return text
+	}
+
+	paths := make([]string, 0, len(a.aliases))
+	for p := range a.aliases {
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool { return len(paths[i]) > len(paths[j]) })
+
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(text); i++ {
+		if i > 0 && isPathByte(text[i-1]) && !gitPrefixAt(text, i) {
+			continue
+		}
+		for _, p := range paths {
+			end := i + len(p)
+			if !strings.HasPrefix(text[i:], p) || end < len(text) && isPathByte(text[end]) && !isSentenceEnd(text, end) {
+				continue
+			}
+			b.WriteString(text[last:i])
+			b.WriteString(a.aliases[p])
+			last = end
+			i = end - 1
+			break
+		}
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// isPathByte tells whether a byte can be part of a path
+func isPathByte(c byte) bool {
+	return isWordByte(c) || c == '/' || c == '.' || c == '-'
+}
+
+// gitPrefixAt tells whether the path at i has git's a/ or b/ in front of it
+func gitPrefixAt(text string, i int) bool {
+	return i >= 2 && (text[i-2] == 'a' || text[i-2] == 'b') && text[i-1] == '/' && (i == 2 || !isPathByte(text[i-3]))
+}
+
+// isSentenceEnd tells whether the dot at i ends a sentence rather than
+// continuing a path, as in "changes main.go." but not "main.go.orig"
+func isSentenceEnd(text string, i int) bool {
+	return text[i] == '.' && (i+1 == len(text) || !isPathByte(text[i+1]))
+}
+
+// isWordByte tells whether a byte can be part of a name
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// pathRestorer restores the paths in streamed text. An alias may be split
+// between chunks, so the end of a chunk that could still be part of one, or
+// of the color code before one, is held back until the next.
+type pathRestorer struct {
+	aliases *PathAliases
+	emit    func(Event)
+	pending string
+}
+
+// handle restores the paths in the response's events
+func (r *pathRestorer) handle(event Event) {
+	if event.Kind != EventKindText {
+		r.flush()
+		r.emit(event)
+		return
+	}
+
+	r.pending += event.Text
+	if i := strings.LastIndexFunc(r.pending, func(c rune) bool { return !holdable(c) }); i >= 0 {
+		_, size := utf8.DecodeRuneInString(r.pending[i:])
+		r.emit(Event{Kind: EventKindText, Text: r.aliases.Restore(r.pending[:i+size])})
+		r.pending = r.pending[i+size:]
+	}
+}
+
+// holdable tells whether a character may belong to an alias or to a color
+// code in front of one
+func holdable(c rune) bool {
+	return c < utf8.RuneSelf && (isWordByte(byte(c)) || strings.ContainsRune(`.\[;`+"\x1b", c))
+}
+
+// flush writes the text still held back
+func (r *pathRestorer) flush() {
+	if r.pending != "" {
+		r.emit(Event{Kind: EventKindText, Text: r.aliases.Restore(r.pending)})
+		r.pending = ""
+	}
+}
@@ -0,0 +1,174 @@
+package diff
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tydin/difx/config"
+)
+
+const renameDiff = `diff --git a/internal/secret/plan.go b/internal/secret/launch.go
+similarity index 90%
+rename from internal/secret/plan.go
+rename to internal/secret/launch.go
+index 1111111..2222222 100644
+--- a/internal/secret/plan.go
++++ b/internal/secret/launch.go
+@@ -1 +1 @@
+-var day = 1
++var day = 2
+diff --git a/docs/new.md b/docs/new.md
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/docs/new.md
+@@ -0,0 +1 @@
++Hello
+`
+
+func TestAnonymizePaths(t *testing.T) {
+	got, aliases := AnonymizePaths(renameDiff, false)
+	want := `diff --git a/file1 b/file2
+similarity index 90%
+rename from file1
+rename to file2
+index 1111111..2222222 100644
+--- a/file1
++++ b/file2
+@@ -1 +1 @@
+-var day = 1
++var day = 2
+diff --git a/file3 b/file3
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/file3
+@@ -0,0 +1 @@
++Hello
+`
+	if got != want {
+		t.Errorf("AnonymizePaths =\n%s\nwant\n%s", got, want)
+	}
+
+	restored := aliases.Restore("file2 (was file1) and file3 but not myfile1, file12, file1_test or file1.txt")
+	if want := "internal/secret/launch.go (was internal/secret/plan.go) and docs/new.md but not myfile1, file12, file1_test or file1.txt"; restored != want {
+		t.Errorf("Restore = %q, want %q", restored, want)
+	}
+	hidden := aliases.Hide("internal/secret/launch.go, a/docs/new.md and docs/new.md. Not old/docs/new.md or docs/new.md.orig")
+	if want := "file2, a/file3 and file3. Not old/docs/new.md or docs/new.md.orig"; hidden != want {
+		t.Errorf("Hide = %q, want %q", hidden, want)
+	}
+
+	// The parsed paths of the new diff are the aliases
+	if files := GetChangedFiles(got); strings.Join(files, ",") != "file2,file3" {
+		t.Errorf("changed files = %v", files)
+	}
+}
+
+func TestAnonymizePathsKeepExtensions(t *testing.T) {
+	got, aliases := AnonymizePaths(sampleDiff, true)
+	if !strings.HasPrefix(got, "diff --git a/file1.go b/file1.go\n") || !strings.Contains(got, "+++ b/file2.sum\n") {
+		t.Errorf("AnonymizePaths =\n%s", got)
+	}
+
+	// A color code before the name, and a name written without its extension
+	restored := aliases.Restore(`\033[1mfile1.go:\033[0m and file2`)
+	if want := `\033[1mmain.go:\033[0m and go.sum`; restored != want {
+		t.Errorf("Restore = %q, want %q", restored, want)
+	}
+}
+
+func TestPathRestorerSplitAtEveryByte(t *testing.T) {
+	_, aliases := AnonymizePaths(sampleDiff, true)
+	in := "\\033[1mfile1.go:\\033[0m\n\t+ Changed é in file2.sum.\n"
+	want := aliases.Restore(in)
+
+	for i := 1; i < len(in); i++ {
+		handler, events := collectEvents()
+		restorer := &pathRestorer{aliases: aliases, emit: handler}
+		restorer.handle(Event{Kind: EventKindText, Text: in[:i]})
+		restorer.handle(Event{Kind: EventKindText, Text: in[i:]})
+		restorer.handle(Event{Kind: EventKindStop})
+
+		var got strings.Builder
+		for _, event := range *events {
+			got.WriteString(event.Text)
+		}
+		if got.String() != want {
+			t.Errorf("split at %d: got %q, want %q", i, got.String(), want)
+		}
+		if last := (*events)[len(*events)-1]; last.Kind != EventKindStop {
+			t.Errorf("split at %d: last event = %+v", i, last)
+		}
+	}
+}
+
+func TestGetExplanationAnonymizesPaths(t *testing.T) {
+	var sent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request AzureOpenAIRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		for _, message := range request.Messages {
+			sent += message.Content
+		}
+
+		azureChunk(w, "file1.go:\n\t+ Bumped a\nfile2.sum:\n\t+ Updated\n")
+		w.Write([]byte("data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{ActiveModel: config.ModelAzureOpenAI, AzureOpenAIEndpoint: server.URL, Streaming: true, AnonymizePaths: true, KeepExtensions: true}
+	var streamed strings.Builder
+	got, err := GetExplanation(context.Background(), sampleDiff, cfg, func(chunk string) { streamed.WriteString(chunk) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(sent, "main.go") || strings.Contains(sent, "go.sum") || !strings.Contains(sent, "a/file1.go") {
+		t.Errorf("the real paths were sent:\n%s", sent)
+	}
+	want := "main.go:\n\t+ Bumped a\ngo.sum:\n\t+ Updated\n"
+	if got != strings.TrimSpace(want) || streamed.String() != want {
+		t.Errorf("got %q, streamed %q, want %q", got, streamed.String(), want)
+	}
+}
+
+func TestBuildPromptAnonymizesPaths(t *testing.T) {
+	cfg := &config.Config{AnonymizePaths: true}
+	prompt := BuildPrompt(context.Background(), renameDiff, cfg)
+
+	if strings.Contains(prompt, "internal/secret") {
+		t.Errorf("BuildPrompt sent a real path:\n%s", prompt)
+	}
+	if !strings.Contains(prompt, "diff --git a/file1 b/file2") {
+		t.Errorf("BuildPrompt is missing the anonymized diff:\n%s", prompt)
+	}
+}
+
+func TestAnonymizeRequest(t *testing.T) {
+	submoduleDiff := renameDiff + "diff --git a/vendor/lib b/vendor/lib\nindex 1111111..2222222 160000\n--- a/vendor/lib\n+++ b/vendor/lib\n@@ -1 +1 @@\nSubmodule vendor/lib moved from 1111111 to 2222222\n"
+	cfg := &config.Config{
+		ProjectContext: "Launch logic lives in internal/secret/launch.go.",
+		CommitMessage:  "Rename internal/secret/plan.go",
+		Background:     "Ticket: document docs/new.md",
+		RecentCommits:  []string{"Touch vendor/lib"},
+	}
+
+	diffOutput, requestCfg, _ := anonymizeRequest(submoduleDiff, cfg)
+	prompt := buildPrompt(diffOutput, requestCfg)
+	for _, real := range []string{"internal/secret", "docs/new.md", "vendor/lib"} {
+		if strings.Contains(prompt, real) {
+			t.Errorf("the prompt has the real path %s:\n%s", real, prompt)
+		}
+	}
+	if !strings.Contains(prompt, "Submodule file4 moved") {
+		t.Errorf("the submodule description isn't anonymized:\n%s", prompt)
+	}
+	if cfg.ProjectContext != "Launch logic lives in internal/secret/launch.go." {
+		t.Errorf("anonymizeRequest changed the config: %q", cfg.ProjectContext)
+	}
+}
@@ -0,0 +1,102 @@
+package diff
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/tydin/difx/config"
+)
+
+// AzureADTokenEnvVar holds a ready-made Azure AD bearer token. When it is set,
+// difx uses it instead of asking the Azure CLI for one.
+const AzureADTokenEnvVar = "AZURE_OPENAI_AD_TOKEN"
+
+// azureADResource is the resource Azure OpenAI tokens are issued for
+const azureADResource = "https://cognitiveservices.azure.com"
+
+// tokenRefreshMargin is how long before it expires a cached token is replaced,
+// so a request never starts with a token that runs out mid-stream
+const tokenRefreshMargin = 5 * time.Minute
+
+// azureADToken caches the Azure CLI token across the requests of a run
+var azureADToken struct {
+	sync.Mutex
+	value   string
+	expires time.Time
+}
+
+// setAzureAuth authenticates an Azure OpenAI request with the api-key header
+// or, in aad mode, with an Azure AD bearer token
+func setAzureAuth(ctx context.Context, req *http.Request, cfg *config.Config) error {
+	if cfg.AzureAuthMode != config.AzureAuthAAD {
+		req.Header.Set("api-key", cfg.AzureOpenAIKey)
+		return nil
+	}
+
+	token, err := getAzureADToken(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// getAzureADToken returns the token from AZURE_OPENAI_AD_TOKEN, or a cached
+// Azure CLI token that is refreshed shortly before it expires. Long runs such
+// as difx watch keep getting valid tokens this way.
+func getAzureADToken(ctx context.Context) (string, error) {
+	if token := os.Getenv(AzureADTokenEnvVar); token != "" {
+		return token, nil
+	}
+
+	azureADToken.Lock()
+	defer azureADToken.Unlock()
+
+	if azureADToken.value != "" && time.Until(azureADToken.expires) > tokenRefreshMargin {
+		return azureADToken.value, nil
+	}
+
+	token, expires, err := azureCLIToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	azureADToken.value, azureADToken.expires = token, expires
+	return token, nil
+}
+
+// azureCLIToken asks the Azure CLI (az login) for an Azure OpenAI token
+func azureCLIToken(ctx context.Context) (string, time.Time, error) {
+	cmd := exec.CommandContext(ctx, "az", "account", "get-access-token", "--resource", azureADResource, "--output", "json")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := runCommand(cmd); err != nil {
+		if stderr.Len() > 0 {
+			return "", time.Time{}, fmt.Errorf("error getting an Azure AD token from the Azure CLI (set %s or run az login): %s\n%s", AzureADTokenEnvVar, err, stderr.String())
+		}
+		return "", time.Time{}, fmt.Errorf("error getting an Azure AD token from the Azure CLI (set %s or run az login): %s", AzureADTokenEnvVar, err)
+	}
+
+	var response struct {
+		AccessToken string `json:"accessToken"`
+		ExpiresOn   int64  `json:"expires_on"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return "", time.Time{}, fmt.Errorf("error reading the Azure CLI token: %w", err)
+	}
+	if response.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("the Azure CLI returned no access token")
+	}
+
+	// Older CLI versions don't report expires_on, so their tokens are fetched again for every request
+	expires := time.Unix(response.ExpiresOn, 0)
+	return response.AccessToken, expires, nil
+}
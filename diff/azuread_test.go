@@ -0,0 +1,82 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tydin/difx/config"
+)
+
+// resetAzureADToken clears the cached token before and after a test
+func resetAzureADToken(t *testing.T) {
+	azureADToken.value, azureADToken.expires = "", time.Time{}
+	t.Cleanup(func() { azureADToken.value, azureADToken.expires = "", time.Time{} })
+}
+
+func TestAzureADAuth(t *testing.T) {
+	resetAzureADToken(t)
+	t.Setenv(AzureADTokenEnvVar, "")
+
+	var auth, apiKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, apiKey = r.Header.Get("Authorization"), r.Header.Get("api-key")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	expires := time.Now().Add(time.Hour).Unix()
+	calls := fakeGit(t, fmt.Sprintf(`{"accessToken":"cli-token","expires_on":%d}`, expires), nil)
+
+	cfg := &config.Config{AzureOpenAIEndpoint: server.URL, AzureOpenAIKey: "key", AzureAuthMode: config.AzureAuthAAD}
+	for i := 0; i < 2; i++ {
+		if _, err := callAzureOpenAI(context.Background(), userPrompt("prompt"), cfg, func(Event) {}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if auth != "Bearer cli-token" || apiKey != "" {
+		t.Errorf("Authorization = %q, api-key = %q", auth, apiKey)
+	}
+	if len(*calls) != 1 {
+		t.Errorf("the Azure CLI ran %d times, want once while the token is valid", len(*calls))
+	}
+
+	// A token from the environment wins
+	t.Setenv(AzureADTokenEnvVar, "env-token")
+	if _, err := callAzureOpenAI(context.Background(), userPrompt("prompt"), cfg, func(Event) {}); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Bearer env-token" {
+		t.Errorf("Authorization = %q, want the environment token", auth)
+	}
+
+	// Key mode keeps using the api-key header
+	cfg.AzureAuthMode = config.AzureAuthKey
+	if _, err := callAzureOpenAI(context.Background(), userPrompt("prompt"), cfg, func(Event) {}); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "" || apiKey != "key" {
+		t.Errorf("Authorization = %q, api-key = %q", auth, apiKey)
+	}
+}
+
+func TestAzureADTokenRefresh(t *testing.T) {
+	resetAzureADToken(t)
+	t.Setenv(AzureADTokenEnvVar, "")
+
+	// A token that is about to expire is replaced
+	soon := time.Now().Add(time.Minute).Unix()
+	calls := fakeGit(t, fmt.Sprintf(`{"accessToken":"short","expires_on":%d}`, soon), nil)
+
+	for i := 0; i < 2; i++ {
+		if _, err := getAzureADToken(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(*calls) != 2 {
+		t.Errorf("the Azure CLI ran %d times, want a refresh for every request", len(*calls))
+	}
+}
@@ -0,0 +1,40 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tydin/difx/config"
+)
+
+func TestReadBackground(t *testing.T) {
+	text, truncated, err := ReadBackground(strings.NewReader("  Ticket 12: parse dates\n"), 100)
+	if err != nil || truncated || text != "Ticket 12: parse dates" {
+		t.Errorf("ReadBackground = %q, %v, %v", text, truncated, err)
+	}
+
+	// A long background is cut at the last whole line
+	text, truncated, err = ReadBackground(strings.NewReader("first line\nsecond line\nthird line\n"), 25)
+	if err != nil || !truncated || text != "first line\nsecond line" {
+		t.Errorf("ReadBackground = %q, %v, %v", text, truncated, err)
+	}
+}
+
+func TestPromptBackground(t *testing.T) {
+	for _, cfg := range []*config.Config{{}, {Structured: true}, {Changelog: true}} {
+		cfg.Background = "Ticket 12: parse dates"
+		prompt := buildPrompt(sampleDiff, cfg)
+
+		// Labeled, and ahead of the diff
+		i := strings.Index(prompt, "Background:\n")
+		if i < 0 || !strings.Contains(prompt, "<background>\nTicket 12: parse dates\n</background>") {
+			t.Errorf("structured %v, changelog %v: prompt has no background", cfg.Structured, cfg.Changelog)
+		} else if i > strings.Index(prompt, sampleDiff) {
+			t.Errorf("structured %v, changelog %v: background comes after the diff", cfg.Structured, cfg.Changelog)
+		}
+	}
+
+	if prompt := buildPrompt(sampleDiff, &config.Config{}); strings.Contains(prompt, "Background:") {
+		t.Error("prompt has a background without one")
+	}
+}
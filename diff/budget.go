@@ -0,0 +1,48 @@
+package diff
+
+import "sort"
+
+// TrimToTokenBudget keeps the files with the most changed lines that fit in
+// budget estimated tokens. Files are tried from the most to the least
+// changed, and one that doesn't fit is skipped in favor of smaller ones. The
+// kept files stay in their original order; the paths of the others are
+// returned in the same order. A budget of 0 or less keeps everything.
+func TrimToTokenBudget(files []FileDiff, budget int) ([]FileDiff, []string) {
+	if budget <= 0 {
+		return files, nil
+	}
+
+	// Rank by the number of changed lines, keeping diff order for ties
+	order := make([]int, len(files))
+	for i := range order {
+		order[i] = i
+	}
+	changed := func(i int) int {
+		added, deleted := files[i].Stats()
+		return added + deleted
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return changed(order[a]) > changed(order[b])
+	})
+
+	keep := make([]bool, len(files))
+	used := 0
+	for _, i := range order {
+		tokens := EstimateTokens(files[i].String())
+		if used+tokens <= budget {
+			keep[i] = true
+			used += tokens
+		}
+	}
+
+	var kept []FileDiff
+	var omitted []string
+	for i, file := range files {
+		if keep[i] {
+			kept = append(kept, file)
+		} else {
+			omitted = append(omitted, file.Path())
+		}
+	}
+	return kept, omitted
+}
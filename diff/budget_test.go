@@ -0,0 +1,61 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fileWithChanges builds a file diff with n added lines of 40 characters
+func fileWithChanges(path string, n int) FileDiff {
+	hunk := Hunk{Header: "@@ -0,0 +1 @@"}
+	for i := 0; i < n; i++ {
+		hunk.Lines = append(hunk.Lines, Line{Kind: LineAdded, Text: strings.Repeat("x", 40)})
+	}
+	return FileDiff{
+		OldPath: path,
+		NewPath: path,
+		Status:  StatusModified,
+		Header:  []string{"diff --git a/" + path + " b/" + path},
+		Hunks:   []Hunk{hunk},
+	}
+}
+
+func TestTrimToTokenBudget(t *testing.T) {
+	files := []FileDiff{
+		fileWithChanges("small.go", 1),
+		fileWithChanges("large.go", 20),
+		fileWithChanges("medium.go", 10),
+	}
+	size := func(i int) int { return EstimateTokens(files[i].String()) }
+
+	tests := []struct {
+		name        string
+		budget      int
+		wantKept    []string
+		wantOmitted []string
+	}{
+		{name: "no budget", budget: 0, wantKept: []string{"small.go", "large.go", "medium.go"}},
+		{name: "everything fits", budget: size(0) + size(1) + size(2), wantKept: []string{"small.go", "large.go", "medium.go"}},
+		{name: "largest first", budget: size(1) + size(2), wantKept: []string{"large.go", "medium.go"}, wantOmitted: []string{"small.go"}},
+		{name: "smaller files fill the rest", budget: size(1) + size(0), wantKept: []string{"small.go", "large.go"}, wantOmitted: []string{"medium.go"}},
+		{name: "nothing fits", budget: 1, wantOmitted: []string{"small.go", "large.go", "medium.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, omitted := TrimToTokenBudget(files, tt.budget)
+
+			var keptPaths []string
+			for _, file := range kept {
+				keptPaths = append(keptPaths, file.Path())
+			}
+			if !reflect.DeepEqual(keptPaths, tt.wantKept) {
+				t.Errorf("kept = %v, want %v", keptPaths, tt.wantKept)
+			}
+			if !reflect.DeepEqual(omitted, tt.wantOmitted) {
+				t.Errorf("omitted = %v, want %v", omitted, tt.wantOmitted)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package diff
+
+import "github.com/tydin/difx/config"
+
+// Changelog sections, in the order they appear in the release notes
+const (
+	ChangelogBreaking = "### ⚠ BREAKING CHANGES"
+	ChangelogFeatures = "### Features"
+	ChangelogFixes    = "### Bug Fixes"
+	ChangelogChores   = "### Chores"
+)
+
+// buildChangelogPrompt creates the prompt for --changelog, which asks for
+// release notes in the Conventional Changelog style instead of an explanation
+func buildChangelogPrompt(diffOutput string, cfg *config.Config) string {
+	prompt := "I'm going to show you the output of a git diff command. Write release notes for these changes in the Conventional Changelog style, as Markdown for a CHANGELOG file.\n\n"
+	prompt += projectContextSection(cfg.ProjectContext)
+	prompt += commitContext(cfg.RecentCommits)
+	prompt += commitMessageSection(cfg.CommitMessage)
+	prompt += cfg.FileVersions
+	prompt += backgroundSection(cfg.Background)
+	prompt += "Here's the git diff output:\n\n```\n"
+	prompt += diffOutput
+	prompt += "\n```\n\n"
+	prompt += reverseInstruction(cfg.Reverse)
+	prompt += wordDiffInstruction(cfg.WordDiff)
+	prompt += newFilesInstruction(cfg.NewFilesOnly)
+	prompt += "Sort the changes into these sections, in this order, and leave out any section that would be empty:\n\n"
+	prompt += ChangelogBreaking + "\n" + ChangelogFeatures + "\n" + ChangelogFixes + "\n" + ChangelogChores + "\n\n"
+	prompt += "Breaking changes are changes to public APIs, command line options, configuration or data formats that make users change something; say what they need to do. "
+	prompt += "Features are new behavior, fixes correct wrong behavior, and chores are everything users don't see, such as refactoring, tests, documentation, build and dependency updates. "
+	prompt += "Write one bullet (\"* \") per change, starting with a bold scope when the paths make one obvious, like \"* **parser:** handle empty files\". Several files changed for the same reason are one entry. "
+	prompt += "Output only the Markdown sections, without a title, an introduction, code fences around the notes, or ANSI color codes."
+	return prompt
+}
@@ -0,0 +1,146 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/tydin/difx/config"
+)
+
+// ExplainChunks explains each diff chunk with its own API call, running at most
+// concurrency calls at once. The explanations are returned in chunk order.
+// Chunks are never streamed since their output would interleave.
+func ExplainChunks(ctx context.Context, chunks []string, cfg *config.Config, concurrency int) ([]string, error) {
+	return ExplainChunksProgress(ctx, chunks, cfg, concurrency, nil)
+}
+
+// ChunkProgress tells which chunk is being explained. Started counts the
+// chunks that have started so far, this one included.
+type ChunkProgress struct {
+	Started int
+	Total   int
+	Index   int
+	Path    string
+}
+
+// ExplainChunksProgress is like ExplainChunks, but calls onStart, if not nil,
+// as each chunk's API call begins. The calls are never concurrent, so
+// onStart doesn't need to guard its own state. With cfg.Budget set, chunks
+// stop being sent once the next one could take the spend over it, and the
+// explanations so far are returned with a *BudgetError.
+func ExplainChunksProgress(ctx context.Context, chunks []string, cfg *config.Config, concurrency int, onStart func(ChunkProgress)) ([]string, error) {
+	if concurrency <= 0 {
+		concurrency = config.DefaultConcurrency
+	}
+
+	// Each chunk gets a full, non-streaming response
+	chunkCfg := *cfg
+	chunkCfg.Streaming = false
+
+	// Stop the remaining calls as soon as one fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]string, len(chunks))
+	errs := make([]error, len(chunks))
+	skipped := make([]bool, len(chunks))
+	var limit *spendLimit
+	if cfg.Budget > 0 && !cfg.Offline {
+		limit = &spendLimit{cfg: &chunkCfg}
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	// Workers start in any order, so the count is kept under a lock
+	var progressMu sync.Mutex
+	started := 0
+	reportStart := func(i int, chunk string) {
+		if onStart == nil {
+			return
+		}
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		started++
+		onStart(ChunkProgress{Started: started, Total: len(chunks), Index: i, Path: chunkPath(chunk)})
+	}
+
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i int, chunk string) {
+			defer wg.Done()
+
+			// Wait for a free worker slot
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+
+			// Skip the chunk if it could go over the budget
+			var reserved float64
+			if limit != nil {
+				var ok bool
+				if reserved, ok = limit.reserve(chunk); !ok {
+					skipped[i] = true
+					return
+				}
+			}
+
+			reportStart(i, chunk)
+			result, err := GetExplanationResult(ctx, chunk, &chunkCfg, nil)
+			results[i], errs[i] = result.Text, err
+			if limit != nil {
+				limit.settle(reserved, chunk, result)
+			}
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i, chunk)
+	}
+
+	wg.Wait()
+
+	// Report the first real failure rather than the cancellations it caused
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Return what was explained, with the files left out
+	if slices.Contains(skipped, true) {
+		budgetErr := &BudgetError{Budget: cfg.Budget, Spent: limit.spent}
+		for i, skip := range skipped {
+			if skip {
+				path := chunkPath(chunks[i])
+				if path == "" {
+					path = fmt.Sprintf("chunk %d", i+1)
+				}
+				budgetErr.Skipped = append(budgetErr.Skipped, path)
+			}
+		}
+		return results, budgetErr
+	}
+
+	return results, nil
+}
+
+// chunkPath returns the path of the file a chunk changes, or "" if it isn't
+// a single file's diff
+func chunkPath(chunk string) string {
+	files, err := Parse(chunk)
+	if err != nil || len(files) != 1 {
+		return ""
+	}
+	return files[0].Path()
+}
@@ -0,0 +1,115 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/tydin/difx/config"
+)
+
+func TestExplainChunksProgress(t *testing.T) {
+	var chunks []string
+	for _, path := range []string{"a.go", "b.go", "c.go", "d.go"} {
+		chunks = append(chunks, "diff --git a/"+path+" b/"+path+"\n--- a/"+path+"\n+++ b/"+path+"\n@@ -1 +1 @@\n-x\n+y\n")
+	}
+
+	var progress []ChunkProgress
+	cfg := &config.Config{Offline: true}
+	explanations, err := ExplainChunksProgress(context.Background(), chunks, cfg, 2, func(p ChunkProgress) {
+		progress = append(progress, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(explanations) != len(chunks) {
+		t.Fatalf("got %d explanations, want %d", len(explanations), len(chunks))
+	}
+
+	// Every chunk starts once, and the count only goes up
+	var paths []string
+	for i, p := range progress {
+		if p.Started != i+1 || p.Total != len(chunks) {
+			t.Errorf("progress %d = %+v", i, p)
+		}
+		if want := "abcd"[p.Index:p.Index+1] + ".go"; p.Path != want {
+			t.Errorf("chunk %d has path %q, want %q", p.Index, p.Path, want)
+		}
+		paths = append(paths, p.Path)
+	}
+	sort.Strings(paths)
+	if len(paths) != len(chunks) || paths[0] != "a.go" || paths[3] != "d.go" {
+		t.Errorf("started paths = %v", paths)
+	}
+}
+
+func TestExplainChunksBudget(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Explained"},"finish_reason":"stop"}],"usage":{"prompt_tokens":100,"completion_tokens":400}}`)
+	}))
+	defer server.Close()
+
+	var chunks []string
+	for _, path := range []string{"a.go", "b.go", "c.go", "d.go"} {
+		chunks = append(chunks, "diff --git a/"+path+" b/"+path+"\n--- a/"+path+"\n+++ b/"+path+"\n@@ -1 +1 @@\n-x\n+y\n")
+	}
+
+	// The longest response costs $1 and each one sent costs $0.10, so the
+	// fourth chunk could take the spend to $1.30
+	cfg := &config.Config{ActiveModel: config.ModelAzureOpenAI, AzureOpenAIEndpoint: server.URL, OutputPrice: 250, Budget: 1.25}
+	explanations, err := ExplainChunksProgress(context.Background(), chunks, cfg, 1, nil)
+
+	var budgetErr *BudgetError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("err = %v, want a BudgetError", err)
+	}
+	if len(budgetErr.Skipped) != 1 || math.Abs(budgetErr.Spent-0.3) > 1e-9 || budgetErr.Budget != 1.25 {
+		t.Errorf("budget error = %+v", budgetErr)
+	}
+
+	explained := 0
+	for _, explanation := range explanations {
+		if explanation != "" {
+			explained++
+		}
+	}
+	if len(explanations) != len(chunks) || explained != 3 {
+		t.Errorf("explanations = %q", explanations)
+	}
+
+	// A budget that fits everything changes nothing
+	cfg.Budget = 10
+	if _, err := ExplainChunksProgress(context.Background(), chunks, cfg, 2, nil); err != nil {
+		t.Errorf("err = %v with a large budget", err)
+	}
+}
+
+func TestExplainChunksReportsFailureOverCancellation(t *testing.T) {
+	// a.go waits until the failure of b.go cancels its request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "b.go") {
+			http.Error(w, `{"error":{"message":"bad request"}}`, http.StatusBadRequest)
+			return
+		}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	var chunks []string
+	for _, path := range []string{"a.go", "b.go"} {
+		chunks = append(chunks, "diff --git a/"+path+" b/"+path+"\n--- a/"+path+"\n+++ b/"+path+"\n@@ -1 +1 @@\n-x\n+y\n")
+	}
+	cfg := &config.Config{ActiveModel: config.ModelAzureOpenAI, AzureOpenAIEndpoint: server.URL}
+	_, err := ExplainChunks(context.Background(), chunks, cfg, 2)
+	if err == nil || errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "400") {
+		t.Errorf("err = %v, want the failure of b.go", err)
+	}
+}
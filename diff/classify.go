@@ -0,0 +1,269 @@
+package diff
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// HunkClass is a rough label for what a hunk does
+type HunkClass string
+
+// Hunk classes, from the most to the least trivial
+const (
+	ClassWhitespaceOnly HunkClass = "whitespace-only"
+	ClassCommentOnly    HunkClass = "comment-only"
+	ClassAddition       HunkClass = "addition"
+	ClassDeletion       HunkClass = "deletion"
+	ClassRename         HunkClass = "rename"
+	ClassModification   HunkClass = "modification"
+)
+
+// Comment markers shared by several languages. "* " continues a block
+// comment, while a * right before a name dereferences a pointer.
+var (
+	cComments      = []string{"//", "/*", "* ", "*/"}
+	hashComments   = []string{"#"}
+	dashComments   = []string{"--"}
+	semiComments   = []string{";"}
+	markupComments = []string{"<!--", "-->"}
+)
+
+// commentPrefixes maps file extensions to what starts a comment line in that
+// language. Files in other languages have no comment-only hunks, since a
+// marker of one language is code or text in another, like a "--" in Go or a
+// "# heading" in Markdown.
+var commentPrefixes = map[string][]string{
+	".c":     cComments,
+	".cc":    cComments,
+	".cpp":   cComments,
+	".cs":    cComments,
+	".css":   {"/*", "* ", "*/"},
+	".dart":  cComments,
+	".go":    cComments,
+	".h":     cComments,
+	".hpp":   cComments,
+	".java":  cComments,
+	".js":    cComments,
+	".jsx":   cComments,
+	".kt":    cComments,
+	".rs":    cComments,
+	".scala": cComments,
+	".scss":  cComments,
+	".swift": cComments,
+	".ts":    cComments,
+	".tsx":   cComments,
+	".php":   {"//", "/*", "* ", "*/", "#"},
+	".pl":    hashComments,
+	".py":    hashComments,
+	".r":     hashComments,
+	".rb":    hashComments,
+	".sh":    hashComments,
+	".tf":    hashComments,
+	".toml":  hashComments,
+	".yaml":  hashComments,
+	".yml":   hashComments,
+	".hs":    dashComments,
+	".lua":   dashComments,
+	".sql":   dashComments,
+	".clj":   semiComments,
+	".el":    semiComments,
+	".ini":   {";", "#"},
+	".lisp":  semiComments,
+	".html":  markupComments,
+	".md":    markupComments,
+	".svg":   markupComments,
+	".xml":   markupComments,
+}
+
+// Classify labels a hunk with simple rules. A hunk is whitespace-only when
+// its removed and added lines have the same tokens, so indentation, line
+// breaks and spacing between tokens changed but nothing else. It is
+// comment-only when every changed line is a comment in the language of the
+// file at path, or blank, and a rename
+// when each removed line became an added line with one identifier swapped
+// for another throughout. Otherwise it is an addition or deletion when it
+// only adds or removes lines, and a modification when it does both.
+func Classify(hunk Hunk, path string) HunkClass {
+	var deleted, added []string
+	for _, line := range hunk.Lines {
+		switch line.Kind {
+		case LineDeleted:
+			deleted = append(deleted, line.Text)
+		case LineAdded:
+			added = append(added, line.Text)
+		}
+	}
+
+	switch {
+	case len(deleted) == 0 && len(added) == 0:
+		return ClassModification
+	case slices.Equal(tokenize(deleted), tokenize(added)):
+		return ClassWhitespaceOnly
+	case allComments(deleted, path) && allComments(added, path):
+		return ClassCommentOnly
+	case len(deleted) == 0:
+		return ClassAddition
+	case len(added) == 0:
+		return ClassDeletion
+	case isRename(deleted, added):
+		return ClassRename
+	}
+	return ClassModification
+}
+
+// tokenize splits lines into words, quoted strings and single punctuation
+// characters, dropping the whitespace between them. Quoted strings keep
+// their spaces, since those change the program's output. A quote without
+// its closing quote on the same line, like an apostrophe in a comment,
+// takes the rest of the line.
+func tokenize(lines []string) []string {
+	var tokens []string
+	for _, line := range lines {
+		runes := []rune(line)
+		for i := 0; i < len(runes); {
+			r := runes[i]
+			switch {
+			case unicode.IsSpace(r):
+				i++
+			case isWordRune(r):
+				start := i
+				for i < len(runes) && isWordRune(runes[i]) {
+					i++
+				}
+				tokens = append(tokens, string(runes[start:i]))
+			case r == '"' || r == '\'' || r == '`':
+				start := i
+				for i++; i < len(runes) && runes[i] != r; i++ {
+					if runes[i] == '\\' {
+						i++
+					}
+				}
+				i = min(i+1, len(runes))
+				tokens = append(tokens, string(runes[start:i]))
+			default:
+				tokens = append(tokens, string(r))
+				i++
+			}
+		}
+	}
+	return tokens
+}
+
+// isWordRune tells whether r can be part of an identifier or number
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// allComments tells whether every line is a comment in the language of the
+// file at filePath, or blank
+func allComments(lines []string, filePath string) bool {
+	prefixes, ok := commentPrefixes[strings.ToLower(path.Ext(filePath))]
+	if !ok {
+		return false
+	}
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		comment := false
+		for _, prefix := range prefixes {
+			// The space also lets a lone * continue a block comment
+			if strings.HasPrefix(trimmed+" ", prefix) {
+				comment = true
+				break
+			}
+		}
+		if !comment {
+			return false
+		}
+	}
+	return true
+}
+
+// isRename tells whether the added lines are the removed lines with a single
+// identifier consistently replaced by another
+func isRename(deleted, added []string) bool {
+	if len(deleted) != len(added) {
+		return false
+	}
+
+	var from, to string
+	for i := range deleted {
+		oldTokens, newTokens := tokenize(deleted[i:i+1]), tokenize(added[i:i+1])
+		if len(oldTokens) != len(newTokens) {
+			return false
+		}
+		for j := range oldTokens {
+			if oldTokens[j] == newTokens[j] {
+				continue
+			}
+			if !isIdentifier(oldTokens[j]) || !isIdentifier(newTokens[j]) {
+				return false
+			}
+			if from == "" {
+				from, to = oldTokens[j], newTokens[j]
+			} else if oldTokens[j] != from || newTokens[j] != to {
+				return false
+			}
+		}
+	}
+	return from != ""
+}
+
+// isIdentifier tells whether a token is a name rather than a number or symbol
+func isIdentifier(token string) bool {
+	r := []rune(token)[0]
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// HunkLabel is the class of one hunk in a diff
+type HunkLabel struct {
+	Path   string    `json:"path"`
+	Header string    `json:"header"`
+	Class  HunkClass `json:"class"`
+}
+
+// ClassifyHunks labels every hunk of the files. The header is the hunk's
+// line ranges, without the function context git adds after them.
+func ClassifyHunks(files []FileDiff) []HunkLabel {
+	var labels []HunkLabel
+	for _, file := range files {
+		for _, hunk := range file.Hunks {
+			labels = append(labels, HunkLabel{Path: file.Path(), Header: hunkRange(hunk), Class: Classify(hunk, file.Path())})
+		}
+	}
+	return labels
+}
+
+// hunkRange returns the @@ part of a hunk header
+func hunkRange(hunk Hunk) string {
+	if parts := strings.SplitN(hunk.Header, "@@", 3); len(parts) == 3 {
+		return "@@" + parts[1] + "@@"
+	}
+	return hunk.Header
+}
+
+// hunkClassInstruction lists the class of each hunk for the prompt, so the
+// model can pass over the trivial ones
+func hunkClassInstruction(diffOutput string) string {
+	files, err := Parse(diffOutput)
+	if err != nil {
+		return ""
+	}
+	labels := ClassifyHunks(files)
+	if len(labels) == 0 {
+		return ""
+	}
+
+	instruction := "Each hunk of the diff was classified with simple rules:\n\n"
+	for _, label := range labels {
+		instruction += fmt.Sprintf("- %s %s: %s\n", label.Path, label.Header, label.Class)
+	}
+	instruction += "\nHunks labeled " + string(ClassWhitespaceOnly) + " or " + string(ClassCommentOnly) + " are trivial. Skip them in the details unless they are all the file changed, and then just say so briefly.\n\n"
+	return instruction
+}
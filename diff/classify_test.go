@@ -0,0 +1,121 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+// hunkOf builds a hunk from lines written as they appear in a diff
+func hunkOf(lines ...string) Hunk {
+	hunk := Hunk{Header: "@@ -1 +1 @@"}
+	for _, line := range lines {
+		hunk.Lines = append(hunk.Lines, Line{Kind: LineKind(line[0]), Text: line[1:]})
+	}
+	return hunk
+}
+
+func TestClassifyWhitespace(t *testing.T) {
+	tests := []struct {
+		name string
+		hunk Hunk
+		want HunkClass
+	}{
+		{
+			name: "reindented",
+			hunk: hunkOf("-\tif ok {", "-\t\treturn x", "-\t}", "+    if ok {", "+        return x", "+    }"),
+			want: ClassWhitespaceOnly,
+		},
+		{
+			name: "trailing spaces",
+			hunk: hunkOf(" func f() {", "-\treturn x   ", "+\treturn x", " }"),
+			want: ClassWhitespaceOnly,
+		},
+		{
+			name: "spacing around punctuation",
+			hunk: hunkOf("-f(a,b)", "+f( a, b )"),
+			want: ClassWhitespaceOnly,
+		},
+		{
+			name: "arguments split over lines",
+			hunk: hunkOf("-call(first, second)", "+call(", "+\tfirst,", "+\tsecond)"),
+			want: ClassWhitespaceOnly,
+		},
+		{
+			name: "blank lines added",
+			hunk: hunkOf(" a()", "+", "+\t", " b()"),
+			want: ClassWhitespaceOnly,
+		},
+		{
+			name: "words joined",
+			hunk: hunkOf("-return x", "+returnx"),
+			want: ClassModification,
+		},
+		{
+			name: "space inside a string",
+			hunk: hunkOf(`-fmt.Println("a b")`, `+fmt.Println("a  b")`),
+			want: ClassModification,
+		},
+		{
+			name: "escaped quote in a string",
+			hunk: hunkOf(`-s := "say \"hi there\""`, `+s  :=  "say \"hi there\""`),
+			want: ClassWhitespaceOnly,
+		},
+		{
+			name: "reordered lines",
+			hunk: hunkOf("-a()", "-b()", "+b()", "+a()"),
+			want: ClassModification,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.hunk, "main.go"); got != tt.want {
+				t.Errorf("Classify = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		hunk Hunk
+		want HunkClass
+	}{
+		{name: "addition", path: "main.go", hunk: hunkOf(" a()", "+b()"), want: ClassAddition},
+		{name: "deletion", path: "main.go", hunk: hunkOf("-a()", " b()"), want: ClassDeletion},
+		{name: "modification", path: "main.go", hunk: hunkOf("-x := 1", "+x := 2"), want: ClassModification},
+		{name: "comment changed", path: "main.go", hunk: hunkOf("-// Old note", "+// New note", " x := 1"), want: ClassCommentOnly},
+		{name: "block comment", path: "main.c", hunk: hunkOf(" /*", "- * old", "+ * new", "+ *", " */"), want: ClassCommentOnly},
+		{name: "comment added", path: "app.py", hunk: hunkOf("+# explain", "+", " x = 1"), want: ClassCommentOnly},
+		{name: "SQL comment", path: "schema.sql", hunk: hunkOf("--- old note", "+-- new note"), want: ClassCommentOnly},
+		{name: "pointer", path: "main.c", hunk: hunkOf("-*p = 1;", "+*p = 2;"), want: ClassModification},
+		{name: "decrement", path: "main.go", hunk: hunkOf("---n", "+--n; ++m"), want: ClassModification},
+		{name: "Markdown heading", path: "README.md", hunk: hunkOf("-# Usage", "+# Install the tool"), want: ClassModification},
+		{name: "unknown language", path: "notes.txt", hunk: hunkOf("-; old", "+; new note"), want: ClassModification},
+		{name: "rename", path: "main.go", hunk: hunkOf("-count := 0", "-count++", "+total := 0", "+total++"), want: ClassRename},
+		{name: "two names changed", path: "main.go", hunk: hunkOf("-a := b", "+c := d"), want: ClassModification},
+		{name: "number changed", path: "main.go", hunk: hunkOf("-f(1)", "+f(2)"), want: ClassModification},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.hunk, tt.path); got != tt.want {
+				t.Errorf("Classify = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHunkClassInstruction(t *testing.T) {
+	diffOutput := "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1,2 +1,2 @@ func main() {\n-\tx()\n+    x()\n"
+
+	got := hunkClassInstruction(diffOutput)
+	if !strings.Contains(got, "- a.go @@ -1,2 +1,2 @@: whitespace-only\n") {
+		t.Errorf("instruction doesn't label the hunk:\n%s", got)
+	}
+	if hunkClassInstruction("") != "" {
+		t.Error("an empty diff should have no instruction")
+	}
+}
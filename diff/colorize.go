@@ -0,0 +1,50 @@
+package diff
+
+import "strings"
+
+// ANSI sequences Colorize uses for the parts of a diff that aren't changes,
+// the same as git's defaults
+const (
+	colorReset  = "\033[0m"
+	colorHeader = "\033[1m"
+	colorHunk   = "\033[36m"
+)
+
+// Colorize adds ANSI colors to a diff the way git diff --color does: file
+// headers in bold, hunk headers in cyan, and added and removed lines in the
+// given color sequences. Inside a hunk, lines starting with --- or +++ are
+// changes, not headers. Line endings, CRLF included, are kept as they are.
+func Colorize(diffOutput string, added, removed string) string {
+	var b strings.Builder
+	inHunk := false
+	for _, line := range strings.SplitAfter(diffOutput, "\n") {
+		content := strings.TrimRight(line, "\r\n")
+		if content == "" {
+			b.WriteString(line)
+			continue
+		}
+
+		var start string
+		switch {
+		case strings.HasPrefix(content, "diff "):
+			inHunk = false
+			start = colorHeader
+		case strings.HasPrefix(content, "@@"):
+			inHunk = true
+			start = colorHunk
+		case !inHunk:
+			start = colorHeader
+		case content[0] == '+':
+			start = added
+		case content[0] == '-':
+			start = removed
+		default:
+			b.WriteString(line)
+			continue
+		}
+
+		b.WriteString(start + content + colorReset)
+		b.WriteString(line[len(content):])
+	}
+	return b.String()
+}
@@ -0,0 +1,51 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestColorize(t *testing.T) {
+	in := `diff --git a/schema.sql b/schema.sql
+--- a/schema.sql
++++ b/schema.sql
+@@ -1,2 +1,2 @@ create
+ create table t (
+--- a comment
++++ counts as added
+`
+	want := "\033[1mdiff --git a/schema.sql b/schema.sql\033[0m\n" +
+		"\033[1m--- a/schema.sql\033[0m\n" +
+		"\033[1m+++ b/schema.sql\033[0m\n" +
+		"\033[36m@@ -1,2 +1,2 @@ create\033[0m\n" +
+		" create table t (\n" +
+		"<DEL>--- a comment\033[0m\n" +
+		"<ADD>+++ counts as added\033[0m\n"
+
+	if got := Colorize(in, "<ADD>", "<DEL>"); got != want {
+		t.Errorf("Colorize =\n%q\nwant\n%q", got, want)
+	}
+
+	// Stripping the colors gives back the diff
+	plain := strings.NewReplacer("\033[1m", "", "\033[36m", "", "\033[0m", "", "<ADD>", "", "<DEL>", "").Replace(Colorize(sampleDiff, "<ADD>", "<DEL>"))
+	if plain != sampleDiff {
+		t.Errorf("uncolored =\n%s\nwant\n%s", plain, sampleDiff)
+	}
+}
+
+func TestColorizeCRLF(t *testing.T) {
+	in := readFixture(t, "crlf.diff")
+	got := Colorize(in, "<ADD>", "<DEL>")
+
+	// The colors close before the line ending, which is kept
+	for _, want := range []string{
+		"\033[1mdiff --git a/src/App.cs b/src/App.cs\033[0m\r\n",
+		"<DEL>-// --- old banner\033[0m\r\n",
+		"<ADD>+// +++ new banner\033[0m\r\n",
+		" class App\r\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Colorize is missing %q:\n%q", want, got)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package diff
+
+import (
+	"fmt"
+
+	"github.com/tydin/difx/config"
+)
+
+// MaxOutputTokens is the longest answer requested from the model, and the
+// room kept free for it in the context window
+const MaxOutputTokens = 4000
+
+// contextWindows is the number of tokens each model accepts, prompt and
+// answer together
+var contextWindows = map[string]int{
+	ClaudeModel:      200000,
+	AzureOpenAIModel: 128000,
+}
+
+// PromptTooLongError is returned, before anything is sent, for a prompt
+// that leaves the model no room for its answer
+type PromptTooLongError struct {
+	Model  string
+	Tokens int
+	Window int
+}
+
+func (e *PromptTooLongError) Error() string {
+	return fmt.Sprintf("the prompt is about %d tokens, but %s takes at most %d with %d kept for the answer; "+
+		"explain one file at a time with --chunked, cap the diff with --max-input-tokens, or limit it to some paths with difx -- <path>",
+		e.Tokens, e.Model, e.Window-MaxOutputTokens, MaxOutputTokens)
+}
+
+// checkContextWindow refuses a conversation that doesn't fit in the model's
+// context window, rather than let the API reject it with a less helpful error
+func checkContextWindow(messages []Message, cfg *config.Config) error {
+	var model string
+	switch cfg.ActiveModel {
+	case config.ModelClaude:
+		model = ClaudeModel
+	case config.ModelAzureOpenAI:
+		model = AzureOpenAIModel
+	}
+	window, ok := contextWindows[model]
+	if !ok {
+		return nil
+	}
+
+	tokens := 0
+	for _, message := range messages {
+		tokens += EstimateTokens(message.Content)
+	}
+	if tokens+MaxOutputTokens > window {
+		return &PromptTooLongError{Model: model, Tokens: tokens, Window: window}
+	}
+	return nil
+}
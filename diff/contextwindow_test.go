@@ -0,0 +1,53 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tydin/difx/config"
+)
+
+func TestCheckContextWindow(t *testing.T) {
+	cfg := &config.Config{ActiveModel: config.ModelAzureOpenAI}
+
+	// 124,000 tokens of prompt leave exactly the room for the answer
+	fits := []Message{{Role: "user", Content: strings.Repeat("x", 4*(128000-MaxOutputTokens))}}
+	if err := checkContextWindow(fits, cfg); err != nil {
+		t.Errorf("prompt that fits: %v", err)
+	}
+
+	tooLong := append(fits, Message{Role: "user", Content: "more"})
+	var tooLongErr *PromptTooLongError
+	if err := checkContextWindow(tooLong, cfg); !errors.As(err, &tooLongErr) || tooLongErr.Window != 128000 || tooLongErr.Model != AzureOpenAIModel {
+		t.Fatalf("prompt that doesn't fit: %v", err)
+	}
+	if !strings.Contains(tooLongErr.Error(), "--chunked") {
+		t.Errorf("error doesn't suggest what to do: %s", tooLongErr)
+	}
+
+	// Claude has a larger window
+	if err := checkContextWindow(tooLong, &config.Config{ActiveModel: config.ModelClaude}); err != nil {
+		t.Errorf("Claude: %v", err)
+	}
+}
+
+func TestPromptTooLongIsNotSent(t *testing.T) {
+	noRetryDelay(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the prompt was sent")
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{ActiveModel: config.ModelClaude, ClaudeAPIKey: "key", ClaudeBaseURL: server.URL, MaxRetries: 2}
+	huge := sampleDiff + "+" + strings.Repeat("x", 4*200000) + "\n"
+	_, err := GetExplanation(context.Background(), huge, cfg, nil)
+	var tooLong *PromptTooLongError
+	if !errors.As(err, &tooLong) {
+		t.Errorf("err = %v, want a PromptTooLongError", err)
+	}
+}
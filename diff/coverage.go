@@ -0,0 +1,220 @@
+package diff
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/tydin/difx/config"
+)
+
+// Explanation is what difx reads back from a response: its sections and the
+// files DETAILS describes
+type Explanation struct {
+	// Sections maps each section header, such as DETAILS, to its lines
+	Sections map[string][]string
+
+	// Files lists the files DETAILS has an entry for, in order
+	Files []string
+}
+
+// explanationSections are the headers of the sections difx asks for
+var explanationSections = []string{"SUMMARY", "FILE CHANGES", "DETAILS", "TEST COVERAGE", "LIKELY INTENT"}
+
+// colorCodeRegex matches a color code, written out by the model or already converted
+var colorCodeRegex = regexp.MustCompile(`(\\033|\\x1b|\x1b)\[[0-9;]*m`)
+
+// headingDecorations are the markdown and bullet marks the model sometimes puts around a file name
+const headingDecorations = "-*`'\" \t"
+
+// ParseExplanation reads the sections of a plain text explanation, or the
+// details of a structured one, and the files they describe
+func ParseExplanation(text string) Explanation {
+	explanation := Explanation{Sections: map[string][]string{}}
+
+	// A structured explanation lists its files by path
+	if trimmed := strings.TrimSpace(text); strings.HasPrefix(trimmed, "{") {
+		var doc struct {
+			Details []struct {
+				Path string `json:"path"`
+			} `json:"details"`
+		}
+		if err := json.Unmarshal([]byte(trimmed), &doc); err == nil {
+			for _, detail := range doc.Details {
+				explanation.Files = appendUnique(explanation.Files, detail.Path)
+			}
+			explanation.Sections["DETAILS"] = nil
+			return explanation
+		}
+	}
+
+	// The model sometimes writes the line breaks and tabs out
+	text = strings.NewReplacer(`\n`, "\n", `\t`, "\t").Replace(text)
+
+	section := ""
+	for _, line := range strings.Split(text, "\n") {
+		line = colorCodeRegex.ReplaceAllString(line, "")
+		if header, ok := sectionHeader(line); ok {
+			section = header
+			if _, seen := explanation.Sections[section]; !seen {
+				explanation.Sections[section] = nil
+			}
+			continue
+		}
+		if section == "" {
+			continue
+		}
+
+		explanation.Sections[section] = append(explanation.Sections[section], line)
+		if section == "DETAILS" {
+			if file, ok := detailFile(line); ok {
+				explanation.Files = appendUnique(explanation.Files, file)
+			}
+		}
+	}
+
+	return explanation
+}
+
+// sectionHeader returns the section a line starts, if it is a section header.
+// "DETAILS (continued):" from a follow-up request continues DETAILS.
+func sectionHeader(line string) (string, bool) {
+	line = strings.Trim(line, headingDecorations+"#")
+	if !strings.HasSuffix(line, ":") {
+		return "", false
+	}
+	line = strings.TrimSuffix(line, ":")
+	line = strings.TrimSpace(strings.TrimSuffix(line, "(continued)"))
+	for _, section := range explanationSections {
+		if line == section {
+			return section, true
+		}
+	}
+	return "", false
+}
+
+// detailFile returns the file a DETAILS line starts the entry of, such as
+// "file1:". Directory headings from --group-by-dir and the lines describing
+// the changes are not files.
+func detailFile(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "+") || !strings.HasSuffix(line, ":") {
+		return "", false
+	}
+	name := strings.Trim(strings.TrimSuffix(line, ":"), headingDecorations)
+
+	// Drop a note after the name, such as "(new file)"
+	if i := strings.Index(name, " ("); i > 0 {
+		name = name[:i]
+	}
+	// A renamed file is given as "old -> new"
+	for _, arrow := range []string{" -> ", " → ", " => "} {
+		if i := strings.LastIndex(name, arrow); i >= 0 {
+			name = name[i+len(arrow):]
+		}
+	}
+	name = strings.Trim(name, headingDecorations)
+
+	if name == "" || strings.ContainsAny(name, " \t") || strings.HasSuffix(name, "/") {
+		return "", false
+	}
+	return name, true
+}
+
+// HasDetails tells whether the explanation has a DETAILS section to check
+func (e Explanation) HasDetails() bool {
+	_, ok := e.Sections["DETAILS"]
+	return ok
+}
+
+// MissingFiles returns the changed files the explanation doesn't describe.
+// The model may shorten a path, so an entry naming the end of the path, such
+// as root.go for cmd/root.go, also counts when no other changed file ends the
+// same way. Nothing is missing from an explanation without a DETAILS section,
+// since there is nothing to check.
+func MissingFiles(explanation Explanation, changed []string) []string {
+	if !explanation.HasDetails() {
+		return nil
+	}
+
+	var missing []string
+	for _, file := range changed {
+		covered := false
+		for _, mentioned := range explanation.Files {
+			if mentions(mentioned, file, changed) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			missing = append(missing, file)
+		}
+	}
+	return missing
+}
+
+// mentions tells whether the name in DETAILS refers to the changed file
+func mentions(name, file string, changed []string) bool {
+	name = strings.TrimPrefix(strings.TrimPrefix(name, "a/"), "b/")
+	if name == file || strings.HasSuffix(name, "/"+file) {
+		return true
+	}
+	if !strings.HasSuffix(file, "/"+name) {
+		return false
+	}
+
+	// A shortened name must not fit another changed file too
+	for _, other := range changed {
+		if other != file && (other == name || strings.HasSuffix(other, "/"+name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// missingFilesInstruction asks the model to describe the files it left out
+func missingFilesInstruction(missing []string) string {
+	text := "Your explanation left out these changed files:\n\n"
+	for _, file := range missing {
+		text += "  " + file + "\n"
+	}
+	text += "\nDescribe only these files, in the same format as the DETAILS section and with the same color codes. "
+	text += "Start with the line \"DETAILS (continued):\" and don't repeat the SUMMARY, FILE CHANGES or the files already described."
+	return text
+}
+
+// ExplainMissingFiles asks the model, as a follow-up to the explanation it
+// gave for diffOutput, to describe the changed files it left out. It returns
+// the extra DETAILS entries. The callback, if not nil, receives the text as
+// it streams in.
+func ExplainMissingFiles(ctx context.Context, diffOutput string, cfg *config.Config, explanation string, missing []string, callback func(string)) (string, error) {
+	// The earlier explanation and the missing files are sent with the same
+	// aliases as the diff
+	var aliases *PathAliases
+	if cfg.AnonymizePaths {
+		diffOutput, cfg, aliases = anonymizeRequest(diffOutput, cfg)
+		explanation = aliases.Hide(explanation)
+		hidden := make([]string, len(missing))
+		for i, file := range missing {
+			hidden[i] = aliases.Hide(file)
+		}
+		missing = hidden
+	}
+
+	// Plain text only; the follow-up isn't asked for with the structured tool
+	requestCfg := *cfg
+	requestCfg.Structured = false
+
+	messages := append(userPrompt(requestPrompt(ctx, diffOutput, &requestCfg)),
+		Message{Role: "assistant", Content: strings.TrimRight(explanation, " \t\r\n")},
+		Message{Role: "user", Content: missingFilesInstruction(missing)},
+	)
+
+	restorer := &pathRestorer{aliases: aliases, emit: textHandler(callback)}
+	response, err := retryRequest(ctx, &requestCfg, restorer.handle, func() (string, error) {
+		return callModel(ctx, messages, &requestCfg, restorer.handle)
+	})
+	restorer.flush()
+	return aliases.Restore(response), err
+}
@@ -0,0 +1,104 @@
+package diff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tydin/difx/config"
+)
+
+func TestParseExplanation(t *testing.T) {
+	text := `SUMMARY:
+  - Files modified: 3
+
+FILE CHANGES:
+  cmd/root.go | 4 ++--
+
+\033[1mDETAILS:\033[0m
+	cmd/:
+	**cmd/root.go**:
+		+ \033[32;1mAdded a flag:\033[0m
+		- Removed the old one
+	parse.go (new file):
+		+ Parser
+	old.go -> diff/renamed.go:
+		+ Moved
+`
+	explanation := ParseExplanation(text)
+
+	if want := []string{"cmd/root.go", "parse.go", "diff/renamed.go"}; strings.Join(explanation.Files, ",") != strings.Join(want, ",") {
+		t.Errorf("Files = %v, want %v", explanation.Files, want)
+	}
+	if !explanation.HasDetails() || len(explanation.Sections["SUMMARY"]) == 0 || len(explanation.Sections["FILE CHANGES"]) == 0 {
+		t.Errorf("Sections = %v", explanation.Sections)
+	}
+
+	// Written out line breaks and a follow-up's continued DETAILS
+	explanation = ParseExplanation(`DETAILS:\n\ta.go:\n\t\t+ x\n\nDETAILS (continued):\n\tb.go:\n`)
+	if strings.Join(explanation.Files, ",") != "a.go,b.go" {
+		t.Errorf("Files = %v", explanation.Files)
+	}
+
+	// Structured explanations list their paths
+	explanation = ParseExplanation(`{"summary": "x", "files": [], "details": [{"path": "a.go"}, {"path": "b.go"}]}`)
+	if !explanation.HasDetails() || strings.Join(explanation.Files, ",") != "a.go,b.go" {
+		t.Errorf("structured Files = %v", explanation.Files)
+	}
+}
+
+func TestMissingFiles(t *testing.T) {
+	changed := []string{"cmd/root.go", "diff/git.go", "README.md", "cmd/util.go", "diff/util.go"}
+	explanation := Explanation{
+		Sections: map[string][]string{"DETAILS": nil},
+		Files:    []string{"cmd/root.go", "git.go", "b/README.md", "util.go"},
+	}
+
+	// util.go could be either of the two, so neither counts as described
+	if got, want := MissingFiles(explanation, changed), []string{"cmd/util.go", "diff/util.go"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("MissingFiles = %v, want %v", got, want)
+	}
+
+	// Without DETAILS there is nothing to check
+	if got := MissingFiles(ParseExplanation("The diff adds a flag."), changed); got != nil {
+		t.Errorf("MissingFiles without DETAILS = %v", got)
+	}
+}
+
+func TestExplainMissingFiles(t *testing.T) {
+	noRetryDelay(t)
+
+	var messages []AzureOpenAIMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request AzureOpenAIRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		messages = request.Messages
+
+		azureChunk(w, "DETAILS (continued):\n\tREADME.md:\n\t\t+ Docs")
+		fmt.Fprint(w, "data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{ActiveModel: config.ModelAzureOpenAI, AzureOpenAIEndpoint: server.URL, Streaming: true, Structured: true}
+	explanation := "DETAILS:\n\tmain.go:\n\t\t+ Code\n"
+	got, err := ExplainMissingFiles(context.Background(), sampleDiff, cfg, explanation, []string{"README.md"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) != 3 || messages[1].Role != "assistant" || messages[1].Content != strings.TrimSpace(explanation) ||
+		messages[2].Role != "user" || !strings.Contains(messages[2].Content, "  README.md\n") {
+		t.Errorf("messages = %+v", messages)
+	}
+	// The follow-up is in the plain text format
+	if strings.Contains(messages[0].Content, "JSON") {
+		t.Error("the follow-up asked for structured output")
+	}
+	if files := ParseExplanation(explanation + "\n" + got).Files; strings.Join(files, ",") != "main.go,README.md" {
+		t.Errorf("Files = %v", files)
+	}
+}
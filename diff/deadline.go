@@ -0,0 +1,64 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxResponseTimeNote ends a response that was cut off by the max response time
+const MaxResponseTimeNote = "[stopped: exceeded max response time]"
+
+// StopReasonMaxResponseTime is the stop reason of a response cut off by the max response time
+const StopReasonMaxResponseTime = "max_response_time"
+
+// responseTimeUnit is the unit of the max response time. Tests shorten it.
+var responseTimeUnit = time.Second
+
+// callWithDeadline runs the call with a context that expires after
+// maxSeconds (no limit if it is 0). When time runs out, the text received so
+// far is returned with a note instead of an error. The second return value
+// reports whether the response was cut off.
+func callWithDeadline(ctx context.Context, maxSeconds int, emit func(Event), call func(context.Context, func(Event)) (string, error)) (string, bool, error) {
+	if maxSeconds <= 0 {
+		response, err := call(ctx, emit)
+		return response, false, err
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, time.Duration(maxSeconds)*responseTimeUnit)
+	defer cancel()
+
+	// Keep the text received so far to return it if time runs out
+	var mu sync.Mutex
+	var partial strings.Builder
+	response, err := call(callCtx, func(event Event) {
+		mu.Lock()
+		switch event.Kind {
+		case EventKindText:
+			partial.WriteString(event.Text)
+		case EventKindRetry:
+			partial.Reset()
+		}
+		mu.Unlock()
+		emit(event)
+	})
+
+	// Only our own deadline counts, not a cancellation by the caller
+	if err == nil || !errors.Is(callCtx.Err(), context.DeadlineExceeded) || ctx.Err() != nil {
+		return response, false, err
+	}
+
+	mu.Lock()
+	text := strings.TrimSpace(partial.String())
+	mu.Unlock()
+
+	note := MaxResponseTimeNote
+	if text != "" {
+		note = "\n\n" + note
+	}
+	emit(Event{Kind: EventKindText, Text: note})
+	emit(Event{Kind: EventKindStop, StopReason: StopReasonMaxResponseTime})
+	return text + note, true, nil
+}
@@ -0,0 +1,78 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// shortResponseTime makes one unit of max response time last 50ms
+func shortResponseTime(t *testing.T) {
+	old := responseTimeUnit
+	responseTimeUnit = 50 * time.Millisecond
+	t.Cleanup(func() { responseTimeUnit = old })
+}
+
+func TestMaxResponseTimeKeepsPartialText(t *testing.T) {
+	shortResponseTime(t)
+
+	// A stream that sends its first delta and then stalls
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, claudeStream[:strings.Index(claudeStream, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\" world\"}}")])
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	handler, events := collectEvents()
+	got, stopped, err := callWithDeadline(context.Background(), 1, handler, func(ctx context.Context, emit func(Event)) (string, error) {
+		req, _ := http.NewRequestWithContext(ctx, "POST", server.URL, nil)
+		return handleClaudeStreamingResponse(req, emit)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stopped {
+		t.Error("expected the response to be reported as stopped")
+	}
+
+	want := "Hello\n\n" + MaxResponseTimeNote
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	last := (*events)[len(*events)-1]
+	if last.Kind != EventKindStop || last.StopReason != StopReasonMaxResponseTime {
+		t.Errorf("last event = %+v, want a max response time stop", last)
+	}
+}
+
+func TestMaxResponseTimeNotReached(t *testing.T) {
+	shortResponseTime(t)
+
+	got, stopped, err := callWithDeadline(context.Background(), 10, func(Event) {}, func(ctx context.Context, emit func(Event)) (string, error) {
+		return "fast", nil
+	})
+	if err != nil || stopped || got != "fast" {
+		t.Errorf("got %q, %v, %v", got, stopped, err)
+	}
+}
+
+func TestMaxResponseTimeCallerCancel(t *testing.T) {
+	shortResponseTime(t)
+
+	// A cancellation by the caller is still an error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, stopped, err := callWithDeadline(ctx, 10, func(Event) {}, func(ctx context.Context, emit func(Event)) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	if err == nil || stopped {
+		t.Errorf("got %v, stopped %v, want the cancellation error", err, stopped)
+	}
+}
@@ -0,0 +1,28 @@
+package diff
+
+import (
+	"net/url"
+
+	"github.com/tydin/difx/config"
+)
+
+// Destination returns the provider name and the host the diff will be sent to
+func Destination(cfg *config.Config) (string, string) {
+	switch cfg.ActiveModel {
+	case config.ModelClaude:
+		return "Anthropic Claude", hostOf(claudeMessagesURL(cfg))
+	case config.ModelAzureOpenAI:
+		return "Azure OpenAI", hostOf(cfg.AzureOpenAIEndpoint)
+	default:
+		return cfg.ActiveModel, ""
+	}
+}
+
+// hostOf returns the host part of a URL, or the URL itself if it can't be parsed
+func hostOf(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return rawURL
+	}
+	return parsed.Host
+}
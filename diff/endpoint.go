@@ -0,0 +1,56 @@
+package diff
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/tydin/difx/config"
+)
+
+const (
+	// DefaultClaudeBaseURL is where Claude requests go unless claude_base_url is set
+	DefaultClaudeBaseURL = "https://api.anthropic.com"
+
+	// claudeMessagesPath is the Messages API below the Claude base URL
+	claudeMessagesPath = "/v1/messages"
+
+	// azureChatPath is the chat completions API of a deployment below the Azure endpoint
+	azureChatPath = "/openai/deployments/%s/chat/completions"
+)
+
+// ClaudeBaseURL returns the base URL Claude requests are sent to
+func ClaudeBaseURL(cfg *config.Config) string {
+	if cfg.ClaudeBaseURL != "" {
+		return cfg.ClaudeBaseURL
+	}
+	return DefaultClaudeBaseURL
+}
+
+// claudeMessagesURL returns the URL of the Claude Messages API
+func claudeMessagesURL(cfg *config.Config) string {
+	return joinURL(ClaudeBaseURL(cfg), claudeMessagesPath)
+}
+
+// azureChatURL returns the URL of the Azure OpenAI chat completions API
+func azureChatURL(cfg *config.Config) string {
+	endpoint := joinURL(cfg.AzureOpenAIEndpoint, fmt.Sprintf(azureChatPath, AzureOpenAIModel))
+	return endpoint + "?api-version=" + url.QueryEscape(AzureOpenAIAPIVersion)
+}
+
+// joinURL appends an API path to a base URL that may have a path of its
+// own, like a gateway's prefix, with exactly one slash between them. A base
+// URL that already ends with the path is returned as it is.
+func joinURL(base string, path string) string {
+	base = strings.TrimRight(base, "/")
+	if strings.HasSuffix(base, path) {
+		return base
+	}
+	return base + "/" + strings.TrimLeft(path, "/")
+}
+
+// ValidateBaseURL checks that a configured base URL is an absolute http or
+// https URL that API paths can be added to
+func ValidateBaseURL(rawURL string) error {
+	return config.ValidateURL(rawURL)
+}
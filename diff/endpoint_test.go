@@ -0,0 +1,51 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/tydin/difx/config"
+)
+
+func TestClaudeMessagesURL(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		want    string
+	}{
+		{baseURL: "", want: "https://api.anthropic.com/v1/messages"},
+		{baseURL: "https://gateway.example.com", want: "https://gateway.example.com/v1/messages"},
+		{baseURL: "https://gateway.example.com/", want: "https://gateway.example.com/v1/messages"},
+		{baseURL: "https://gateway.example.com/anthropic/", want: "https://gateway.example.com/anthropic/v1/messages"},
+		{baseURL: "https://gateway.example.com/v1/messages", want: "https://gateway.example.com/v1/messages"},
+	}
+
+	for _, tt := range tests {
+		cfg := &config.Config{ClaudeBaseURL: tt.baseURL}
+		if got := claudeMessagesURL(cfg); got != tt.want {
+			t.Errorf("claudeMessagesURL(%q) = %q, want %q", tt.baseURL, got, tt.want)
+		}
+	}
+}
+
+func TestAzureChatURL(t *testing.T) {
+	cfg := &config.Config{AzureOpenAIEndpoint: "https://example.openai.azure.com/"}
+	want := "https://example.openai.azure.com/openai/deployments/" + AzureOpenAIModel + "/chat/completions?api-version=" + AzureOpenAIAPIVersion
+	if got := azureChatURL(cfg); got != want {
+		t.Errorf("azureChatURL = %q, want %q", got, want)
+	}
+}
+
+func TestValidateBaseURL(t *testing.T) {
+	valid := []string{"https://api.anthropic.com", "http://localhost:8080/proxy"}
+	for _, rawURL := range valid {
+		if err := ValidateBaseURL(rawURL); err != nil {
+			t.Errorf("ValidateBaseURL(%q) = %s", rawURL, err)
+		}
+	}
+
+	invalid := []string{"api.anthropic.com", "ftp://example.com", "https://", "https://example.com?key=1", "://bad"}
+	for _, rawURL := range invalid {
+		if err := ValidateBaseURL(rawURL); err == nil {
+			t.Errorf("ValidateBaseURL(%q) accepted an invalid URL", rawURL)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package diff
+
+import "errors"
+
+// EventKind tells which part of a response an Event reports
+type EventKind string
+
+// Kinds of events reported by GetExplanationEvents, common to all providers
+const (
+	// EventKindStart is sent once the model starts responding
+	EventKindStart EventKind = "start"
+	// EventKindText carries a piece of the explanation in Text
+	EventKindText EventKind = "text"
+	// EventKindUsage carries the token counts in Usage, when the provider reports them
+	EventKindUsage EventKind = "usage"
+	// EventKindStop is sent when the response is complete, with the provider's StopReason
+	EventKindStop EventKind = "stop"
+	// EventKindError is sent when the request fails, with the error in Err
+	EventKindError EventKind = "error"
+	// EventKindRetry is sent when the stream broke off and the request is sent
+	// again; the text received so far should be discarded
+	EventKindRetry EventKind = "retry"
+)
+
+// retryNotice is shown to plain text callbacks, which can't take back text
+// they have already received, when the response starts over
+const retryNotice = "\n\n(The connection was lost, starting the explanation again.)\n\n"
+
+// Event is one step of an explanation's response. Only the fields that
+// belong to its Kind are set.
+type Event struct {
+	Kind       EventKind
+	Text       string
+	Usage      Usage
+	StopReason string
+	Err        error
+}
+
+// Usage holds the token counts of a request
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// textHandler adapts a plain text callback to an event handler
+func textHandler(callback func(string)) func(Event) {
+	return func(event Event) {
+		if callback == nil {
+			return
+		}
+
+		switch event.Kind {
+		case EventKindText:
+			callback(event.Text)
+		case EventKindRetry:
+			// A request refused with an error status hasn't sent any text
+			if errors.Is(event.Err, ErrIncompleteStream) {
+				callback(retryNotice)
+			}
+		}
+	}
+}
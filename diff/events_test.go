@@ -0,0 +1,265 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/tydin/difx/config"
+)
+
+// serveBody starts a test server answering every request with the given body
+func serveBody(t *testing.T, body string) *http.Request {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	req, err := http.NewRequest("POST", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+// collectEvents returns an event handler and the events it has received
+func collectEvents() (func(Event), *[]Event) {
+	var events []Event
+	return func(event Event) { events = append(events, event) }, &events
+}
+
+const claudeStream = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude","usage":{"input_tokens":12,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: ping
+data: {"type":"ping"}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" world"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":5}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+func TestClaudeStreamingEvents(t *testing.T) {
+	handler, events := collectEvents()
+	got, err := handleClaudeStreamingResponse(serveBody(t, claudeStream), handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Hello world" {
+		t.Errorf("response = %q", got)
+	}
+
+	want := []Event{
+		{Kind: EventKindStart},
+		{Kind: EventKindText, Text: "Hello"},
+		{Kind: EventKindText, Text: " world"},
+		{Kind: EventKindUsage, Usage: Usage{InputTokens: 12, OutputTokens: 5}},
+		{Kind: EventKindStop, StopReason: "end_turn"},
+	}
+	if !reflect.DeepEqual(*events, want) {
+		t.Errorf("events = %+v, want %+v", *events, want)
+	}
+}
+
+const azureStream = `data: {"id":"1","choices":[{"index":0,"delta":{"role":"assistant"}}]}
+
+data: {"id":"1","choices":[{"index":0,"delta":{"content":"Hi"}}]}
+
+data: {"id":"1","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}
+
+data: [DONE]
+
+`
+
+func TestAzureStreamingEvents(t *testing.T) {
+	handler, events := collectEvents()
+	got, err := handleAzureOpenAIStreamingResponse(serveBody(t, azureStream), handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Hi" {
+		t.Errorf("response = %q", got)
+	}
+
+	want := []Event{
+		{Kind: EventKindStart},
+		{Kind: EventKindText, Text: "Hi"},
+		{Kind: EventKindStop, StopReason: "stop"},
+	}
+	if !reflect.DeepEqual(*events, want) {
+		t.Errorf("events = %+v, want %+v", *events, want)
+	}
+}
+
+// azureFilteredStream is a stream as Azure sends it with content filtering on:
+// prompt_filter_results first, with no choices, and content_filter_results
+// on every choice
+const azureFilteredStream = `data: {"choices":[],"created":0,"id":"","model":"","object":"","prompt_filter_results":[{"prompt_index":0,"content_filter_results":{"hate":{"filtered":false,"severity":"safe"},"self_harm":{"filtered":false,"severity":"safe"},"sexual":{"filtered":false,"severity":"safe"},"violence":{"filtered":false,"severity":"safe"}}}]}
+
+data: {"choices":[{"content_filter_results":{},"delta":{"content":"","role":"assistant"},"finish_reason":null,"index":0}],"created":1714000000,"id":"chatcmpl-1","model":"gpt-4o","object":"chat.completion.chunk","system_fingerprint":"fp_1"}
+
+data: {"choices":[{"content_filter_results":{"hate":{"filtered":false,"severity":"safe"},"self_harm":{"filtered":false,"severity":"safe"},"sexual":{"filtered":false,"severity":"safe"},"violence":{"filtered":false,"severity":"safe"}},"delta":{"content":"Hi"},"finish_reason":null,"index":0}],"created":1714000000,"id":"chatcmpl-1","model":"gpt-4o","object":"chat.completion.chunk","system_fingerprint":"fp_1"}
+
+data: {"choices":[{"content_filter_results":{},"delta":{},"finish_reason":"stop","index":0}],"created":1714000000,"id":"chatcmpl-1","model":"gpt-4o","object":"chat.completion.chunk","system_fingerprint":"fp_1"}
+
+data: [DONE]
+
+`
+
+// The prompt filter chunk, with no choices, and the filter results on each
+// choice must leave the response and its events as they are without filtering
+func TestAzureStreamingPromptFilterResults(t *testing.T) {
+	handler, events := collectEvents()
+	got, err := handleAzureOpenAIStreamingResponse(serveBody(t, azureFilteredStream), handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Hi" {
+		t.Errorf("response = %q", got)
+	}
+
+	want := []Event{
+		{Kind: EventKindStart},
+		{Kind: EventKindText, Text: "Hi"},
+		{Kind: EventKindStop, StopReason: "stop"},
+	}
+	if !reflect.DeepEqual(*events, want) {
+		t.Errorf("events = %+v, want %+v", *events, want)
+	}
+}
+
+func TestClaudeNonStreamingEvents(t *testing.T) {
+	body := `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"Done"}],"stop_reason":"end_turn","usage":{"input_tokens":3,"output_tokens":1}}`
+
+	handler, events := collectEvents()
+	got, err := handleClaudeNonStreamingResponse(serveBody(t, body), handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Done" {
+		t.Errorf("response = %q", got)
+	}
+
+	want := []Event{
+		{Kind: EventKindStart},
+		{Kind: EventKindText, Text: "Done"},
+		{Kind: EventKindUsage, Usage: Usage{InputTokens: 3, OutputTokens: 1}},
+		{Kind: EventKindStop, StopReason: "end_turn"},
+	}
+	if !reflect.DeepEqual(*events, want) {
+		t.Errorf("events = %+v, want %+v", *events, want)
+	}
+}
+
+func TestClaudeNonStreamingMultipleBlocks(t *testing.T) {
+	body := `{"id":"msg_1","type":"message","role":"assistant","content":[` +
+		`{"type":"thinking","thinking":"Look at main.go first","signature":"abc"},` +
+		`{"type":"text","text":"SUMMARY:\n"},` +
+		`{"type":"redacted_thinking","data":"xyz"},` +
+		`{"type":"text","text":"  - Files modified: 1"}` +
+		`],"stop_reason":"end_turn"}`
+
+	handler, events := collectEvents()
+	got, err := handleClaudeNonStreamingResponse(serveBody(t, body), handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "SUMMARY:\n  - Files modified: 1"; got != want {
+		t.Errorf("response = %q, want %q", got, want)
+	}
+	if len(*events) != 3 || (*events)[1].Text != got {
+		t.Errorf("events = %+v", *events)
+	}
+
+	// Only non-text blocks is still an error
+	body = `{"content":[{"type":"thinking","thinking":"hmm"}],"stop_reason":"end_turn"}`
+	if _, err := handleClaudeNonStreamingResponse(serveBody(t, body), func(Event) {}); err == nil {
+		t.Error("expected an error for a response without text")
+	}
+}
+
+func TestTextHandler(t *testing.T) {
+	var text string
+	handler := textHandler(func(s string) { text += s })
+	handler(Event{Kind: EventKindStart})
+	handler(Event{Kind: EventKindText, Text: "a"})
+	handler(Event{Kind: EventKindUsage, Usage: Usage{InputTokens: 1}})
+	handler(Event{Kind: EventKindText, Text: "b"})
+
+	if text != "ab" {
+		t.Errorf("text = %q, want %q", text, "ab")
+	}
+
+	// A nil callback is allowed
+	textHandler(nil)(Event{Kind: EventKindText, Text: "ignored"})
+}
+
+func TestGetExplanationResultUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":5}}`)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{ActiveModel: config.ModelAzureOpenAI, AzureOpenAIEndpoint: server.URL}
+	result, err := GetExplanationResult(context.Background(), "diff", cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Result{Text: "Hi", Usage: Usage{InputTokens: 12, OutputTokens: 5}}
+	if result != want {
+		t.Errorf("result = %+v, want %+v", result, want)
+	}
+}
+
+func TestGetExplanationRecordsUsage(t *testing.T) {
+	body := `{"choices":[{"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":5}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	type request struct {
+		model         string
+		input, output int
+	}
+	var recorded []request
+	cfg := &config.Config{ActiveModel: config.ModelAzureOpenAI, AzureOpenAIEndpoint: server.URL}
+	cfg.RecordUsage = func(model string, input, output int) {
+		recorded = append(recorded, request{model, input, output})
+	}
+	if _, err := GetExplanationResult(context.Background(), "diff", cfg, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without reported counts the tokens are estimated from the text
+	body = `{"choices":[{"message":{"role":"assistant","content":"Twelve chars"},"finish_reason":"stop"}]}`
+	if _, err := GetExplanationResult(context.Background(), "diff", cfg, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recorded) != 2 || recorded[0] != (request{config.ModelAzureOpenAI, 12, 5}) {
+		t.Fatalf("recorded = %+v", recorded)
+	}
+	if estimated := recorded[1]; estimated.input == 0 || estimated.output != EstimateTokens("Twelve chars") {
+		t.Errorf("estimated = %+v", estimated)
+	}
+}
@@ -0,0 +1,139 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// MaxFullContextBytes is the largest file version that --full-context adds
+// to the prompt. Bigger files are only sent as diff hunks.
+const MaxFullContextBytes = 16 * 1024
+
+// blobHashPattern matches an abbreviated or full blob hash. The diff may come
+// from a file or a pull request, so anything else on the index line is
+// refused rather than handed to git.
+var blobHashPattern = regexp.MustCompile(`^[0-9a-f]{7,}$`)
+
+// blobHashes reads the old and new blob hashes from the file's index line
+func blobHashes(file FileDiff) (string, string, bool) {
+	for _, line := range file.Header {
+		rest, ok := strings.CutPrefix(line, "index ")
+		if !ok {
+			continue
+		}
+		hashes, _, _ := strings.Cut(rest, " ")
+		oldHash, newHash, ok := strings.Cut(hashes, "..")
+		if !ok || !blobHashPattern.MatchString(oldHash) || !blobHashPattern.MatchString(newHash) {
+			return "", "", false
+		}
+		return oldHash, newHash, true
+	}
+	return "", "", false
+}
+
+// isNullHash tells whether a blob hash is git's all-zero hash of a missing file
+func isNullHash(hash string) bool {
+	return strings.Trim(hash, "0") == ""
+}
+
+// GetBlobContent returns the content of a blob by its (abbreviated) hash
+func GetBlobContent(ctx context.Context, hash string) (string, error) {
+	return runGit(ctx, "git cat-file", "", "cat-file", "blob", "--end-of-options", hash)
+}
+
+// fileVersion returns one side of a changed file. Blobs of commits and the
+// index are in the object database; a modified working tree file is read
+// from disk if its content still hashes to the blob in the diff.
+func fileVersion(ctx context.Context, root string, hash string, path string) (string, error) {
+	if isNullHash(hash) {
+		return "", nil
+	}
+	if content, err := GetBlobContent(ctx, hash); err == nil {
+		return content, nil
+	}
+
+	// Diff paths are relative to the repository root, not the current directory
+	if root == "" {
+		return "", fmt.Errorf("version %s of %s is not available", hash, path)
+	}
+	path, err := pathInside(root, path)
+	if err != nil {
+		return "", err
+	}
+
+	diskHash, err := runGit(ctx, "git hash-object", "", "hash-object", "--", path)
+	if err != nil || !strings.HasPrefix(strings.TrimSpace(diskHash), hash) {
+		return "", fmt.Errorf("version %s of %s is not available", hash, path)
+	}
+	return GetFileContent(path, "")
+}
+
+// pathInside joins a diff path to the repository root. A path that leads
+// outside of it, with .. or through a symbolic link, is refused, so a crafted
+// diff can't have a file elsewhere sent to the model.
+func pathInside(root string, path string) (string, error) {
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+
+	joined := filepath.Join(root, path)
+	resolved, err := filepath.EvalSymlinks(joined)
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(resolvedRoot, resolved); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return "", fmt.Errorf("%s is outside of the repository", path)
+	}
+	return joined, nil
+}
+
+// FullFileContext returns the complete before and after versions of the
+// changed files as a prompt section, and the paths of the files that were
+// left out because they are binary, too large or not available locally
+// (such as files of a downloaded pull request).
+func FullFileContext(ctx context.Context, diffOutput string, maxBytes int) (string, []string) {
+	files, err := Parse(diffOutput)
+	if err != nil || len(files) == 0 {
+		return "", nil
+	}
+
+	// Without a repository only blobs can be looked up, which fails as well
+	root, _ := RepoRoot(ctx)
+
+	var b strings.Builder
+	var skipped []string
+	for _, file := range files {
+		oldHash, newHash, ok := blobHashes(file)
+		if !ok || file.Binary {
+			skipped = append(skipped, file.Path())
+			continue
+		}
+
+		before, err := fileVersion(ctx, root, oldHash, file.OldPath)
+		if err != nil {
+			skipped = append(skipped, file.Path())
+			continue
+		}
+		after, err := fileVersion(ctx, root, newHash, file.NewPath)
+		if err != nil || len(before) > maxBytes || len(after) > maxBytes {
+			skipped = append(skipped, file.Path())
+			continue
+		}
+
+		if !isNullHash(oldHash) {
+			fmt.Fprintf(&b, "%s before the change:\n\n```\n%s\n```\n\n", file.OldPath, strings.TrimSuffix(before, "\n"))
+		}
+		if !isNullHash(newHash) {
+			fmt.Fprintf(&b, "%s after the change:\n\n```\n%s\n```\n\n", file.NewPath, strings.TrimSuffix(after, "\n"))
+		}
+	}
+
+	if b.Len() == 0 {
+		return "", skipped
+	}
+	return "For context, here are the complete changed files before and after the change:\n\n" + b.String(), skipped
+}
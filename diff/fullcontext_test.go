@@ -0,0 +1,105 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeObjects replaces runCommand with a git that knows the given blobs and
+// hashes every working tree file to worktreeHash
+func fakeObjects(t *testing.T, root string, blobs map[string]string, worktreeHash string) {
+	original := runCommand
+	runCommand = func(cmd *exec.Cmd) error {
+		switch cmd.Args[1] {
+		case "rev-parse":
+			cmd.Stdout.Write([]byte(root + "\n"))
+		case "cat-file":
+			content, ok := blobs[cmd.Args[len(cmd.Args)-1]]
+			if !ok {
+				return errors.New("not a valid object")
+			}
+			cmd.Stdout.Write([]byte(content))
+		case "hash-object":
+			cmd.Stdout.Write([]byte(worktreeHash + "\n"))
+		}
+		return nil
+	}
+	t.Cleanup(func() { runCommand = original })
+}
+
+func TestFullFileContext(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main // edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fakeObjects(t, root, map[string]string{"aaa1111": "package main\n", "ccc3333": "big\n"}, "bbb2222ffff")
+
+	diffOutput := "diff --git a/main.go b/main.go\nindex aaa1111..bbb2222 100644\n--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-package main\n+package main // edited\n" +
+		"diff --git a/gone.go b/gone.go\ndeleted file mode 100644\nindex ccc3333..0000000\n--- a/gone.go\n+++ /dev/null\n@@ -1 +0,0 @@\n-big\n" +
+		"diff --git a/remote.go b/remote.go\nindex ddd4444..eee5555 100644\n--- a/remote.go\n+++ b/remote.go\n@@ -1 +1 @@\n-a\n+b\n"
+
+	got, skipped := FullFileContext(context.Background(), diffOutput, 100)
+	for _, want := range []string{
+		"main.go before the change:\n\n```\npackage main\n```",
+		"main.go after the change:\n\n```\npackage main // edited\n```",
+		"gone.go before the change:\n\n```\nbig\n```",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("context is missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "gone.go after") {
+		t.Errorf("deleted file has an after version:\n%s", got)
+	}
+
+	// remote.go's blobs aren't local and its working tree file doesn't match
+	if len(skipped) != 1 || skipped[0] != "remote.go" {
+		t.Errorf("skipped = %v, want [remote.go]", skipped)
+	}
+
+	// Files over the limit are left out
+	if _, skipped := FullFileContext(context.Background(), diffOutput, 5); len(skipped) != 2 {
+		t.Errorf("skipped = %v, want main.go and remote.go", skipped)
+	}
+}
+
+func TestFullFileContextRefusesUnsafeInput(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("token\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every working tree file matches, so only the checks keep them out
+	var objects []string
+	fakeObjects(t, root, map[string]string{"HEAD:secret.txt": "token\n"}, "bbb2222")
+	original := runCommand
+	runCommand = func(cmd *exec.Cmd) error {
+		if cmd.Args[1] == "cat-file" {
+			objects = append(objects, cmd.Args[len(cmd.Args)-1])
+		}
+		return original(cmd)
+	}
+
+	diffOutput := "diff --git a/secret.txt b/secret.txt\nindex HEAD:secret.txt..bbb2222 100644\n--- a/secret.txt\n+++ b/secret.txt\n@@ -1 +1 @@\n-a\n+b\n" +
+		"diff --git a/../escape.txt b/../escape.txt\nindex 0000000..bbb2222\n--- /dev/null\n+++ b/../escape.txt\n@@ -0,0 +1 @@\n+b\n" +
+		"diff --git a/link.txt b/link.txt\nindex 0000000..bbb2222\n--- /dev/null\n+++ b/link.txt\n@@ -0,0 +1 @@\n+b\n"
+
+	got, skipped := FullFileContext(context.Background(), diffOutput, 100)
+	if strings.Contains(got, "token") || len(skipped) != 3 {
+		t.Errorf("FullFileContext = %q, skipped %v", got, skipped)
+	}
+	for _, object := range objects {
+		if !blobHashPattern.MatchString(object) {
+			t.Errorf("git cat-file was asked for %q", object)
+		}
+	}
+}
@@ -2,23 +2,79 @@ package diff
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
+
+	"github.com/tydin/difx/telemetry"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// runCommand runs a prepared command. Tests replace it to inspect the
+// command line without needing git or a repository.
+var runCommand = func(cmd *exec.Cmd) error {
+	return cmd.Run()
+}
+
+// gitConfig holds the key=value settings git diff runs with, from --git-config
+var gitConfig []string
+
+// SetGitConfig sets the git settings, given as key=value such as
+// diff.algorithm=histogram, that git diff runs with. They only apply to this
+// run, as -c options. A setting without a section.key name is an error.
+func SetGitConfig(settings []string) error {
+	for _, setting := range settings {
+		key, _, ok := strings.Cut(setting, "=")
+		if !ok || !strings.Contains(strings.Trim(key, "."), ".") || strings.ContainsAny(key, " \t") {
+			return fmt.Errorf("invalid git config %q (use key=value, such as diff.algorithm=histogram)", setting)
+		}
+	}
+	gitConfig = settings
+	return nil
+}
+
+// gitDiffArgs builds the full git argument list for a diff, with the -c
+// options of gitConfig ahead of the diff command as git requires.
+// --staged is spelled --cached, and nothing after a -- separator or
+// --end-of-options is rewritten.
+func gitDiffArgs(args []string) []string {
+	var gitArgs []string
+	for _, setting := range gitConfig {
+		gitArgs = append(gitArgs, "-c", setting)
+	}
+	gitArgs = append(gitArgs, "diff")
+	for i, arg := range args {
+		if arg == "--" || arg == "--end-of-options" {
+			return append(gitArgs, args[i:]...)
+		}
+		if arg == "--staged" {
+			arg = "--cached"
+		}
+		gitArgs = append(gitArgs, arg)
+	}
+	return gitArgs
+}
+
 // RunGitDiff executes the git diff command with the provided arguments
-func RunGitDiff(args []string) (string, error) {
+func RunGitDiff(ctx context.Context, args []string) (string, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "git diff")
+	defer span.End()
+
 	// Prepare the git diff command
-	gitArgs := append([]string{"diff"}, args...)
+	gitArgs := gitDiffArgs(args)
+	span.SetAttributes(attribute.StringSlice("git.args", args))
 	
-	cmd := exec.Command("git", gitArgs...)
+	cmd := exec.CommandContext(ctx, "git", gitArgs...)
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 	
-	err := cmd.Run()
+	err := runCommand(cmd)
 	if err != nil {
+		span.RecordError(err)
 		// If there's stderr output, return it as part of the error
 		if stderr.Len() > 0 {
 			return "", fmt.Errorf("git diff error: %s\n%s", err, stderr.String())
@@ -26,9 +82,86 @@ func RunGitDiff(args []string) (string, error) {
 		return "", fmt.Errorf("git diff error: %s", err)
 	}
 	
+	span.SetAttributes(attribute.Int("git.diff_bytes", stdout.Len()))
 	return stdout.String(), nil
 }
 
+// symbolLogArgs builds the git log arguments that trace a function, given as
+// <funcname>:<file>, through the revisions. Without revisions only its most
+// recent change is shown.
+func symbolLogArgs(symbol string, revs []string) ([]string, error) {
+	name, file, ok := strings.Cut(symbol, ":")
+	if !ok || name == "" || file == "" {
+		return nil, fmt.Errorf("invalid symbol %q (use <function>:<file>, such as Parse:diff/parse.go)", symbol)
+	}
+	for _, rev := range revs {
+		if rev == "--" {
+			return nil, fmt.Errorf("paths can't be combined with a symbol, the file is part of it")
+		}
+	}
+
+	// -L takes git's function name syntax :<funcname>:<file>
+	args := []string{"log", "-L", ":" + name + ":" + file, "--format=", "--no-color"}
+	if len(revs) == 0 {
+		return append(args, "-n", "1"), nil
+	}
+	return append(args, revs...), nil
+}
+
+// RunGitSymbolDiff returns the changes to a single function. git diff can't
+// limit a diff to a function, so they come from git log -L, one diff per
+// commit in the revisions that changed it. git's error is returned when the
+// function isn't found.
+func RunGitSymbolDiff(ctx context.Context, symbol string, revs []string) (string, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "git log -L")
+	defer span.End()
+
+	args, err := symbolLogArgs(symbol, revs)
+	if err != nil {
+		return "", err
+	}
+	span.SetAttributes(attribute.StringSlice("git.args", args))
+
+	output, err := runGit(ctx, "git log", "", args...)
+	if err != nil {
+		span.RecordError(err)
+		return "", err
+	}
+	return strings.TrimLeft(output, "\n"), nil
+}
+
+// MaxLogCommits caps how many commit subjects can be added to the prompt
+const MaxLogCommits = 20
+
+// RecentCommits returns the last n commits as "<hash> <subject>" lines, newest first.
+// n is capped at MaxLogCommits.
+func RecentCommits(ctx context.Context, n int) ([]string, error) {
+	n = min(n, MaxLogCommits)
+	if n <= 0 {
+		return nil, nil
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "log", "--oneline", "--no-decorate", "-n", strconv.Itoa(n))
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := runCommand(cmd); err != nil {
+		if stderr.Len() > 0 {
+			return nil, fmt.Errorf("git log error: %s\n%s", err, stderr.String())
+		}
+		return nil, fmt.Errorf("git log error: %s", err)
+	}
+
+	var commits []string
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			commits = append(commits, line)
+		}
+	}
+	return commits, nil
+}
+
 // GetFileContent retrieves the content of a file at a specific commit
 func GetFileContent(filePath string, commitish string) (string, error) {
 	if commitish == "" {
@@ -68,19 +201,73 @@ func GetFileContent(filePath string, commitish string) (string, error) {
 
 // GetChangedFiles returns a list of files that have been changed
 func GetChangedFiles(diffOutput string) []string {
+	parsed, err := Parse(diffOutput)
+	if err != nil {
+		return nil
+	}
+
 	var files []string
-	lines := strings.Split(diffOutput, "\n")
-	
-	for _, line := range lines {
-		if strings.HasPrefix(line, "diff --git ") {
-			parts := strings.Split(line, " ")
-			if len(parts) >= 4 {
-				// Extract the file path from "b/path/to/file"
-				filePath := strings.TrimPrefix(parts[3], "b/")
-				files = append(files, filePath)
-			}
-		}
+	for _, file := range parsed {
+		files = append(files, file.Path())
 	}
-	
 	return files
 }
+
+// RepoRoot returns the top-level directory of the repository containing the
+// current directory
+func RepoRoot(ctx context.Context) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--show-toplevel")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := runCommand(cmd); err != nil {
+		if stderr.Len() > 0 {
+			return "", fmt.Errorf("git rev-parse error: %s\n%s", err, stderr.String())
+		}
+		return "", fmt.Errorf("git rev-parse error: %s", err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// IgnoredDirs returns the directories under root that git ignores, such as
+// node_modules or a build directory, relative to root. The directories
+// inside an ignored one aren't listed on their own.
+func IgnoredDirs(ctx context.Context, root string) ([]string, error) {
+	output, err := runGit(ctx, "git ls-files", "", "-C", root, "ls-files", "-z", "--others", "--ignored", "--exclude-standard", "--directory")
+	if err != nil {
+		return nil, err
+	}
+
+	// ls-files also names untracked directories that only hold ignored
+	// files, which aren't ignored themselves
+	var candidates []string
+	for _, entry := range strings.Split(output, "\x00") {
+		if strings.HasSuffix(entry, "/") {
+			candidates = append(candidates, entry)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	// check-ignore exits with 1 when none of them is ignored
+	output, err = runGit(ctx, "git check-ignore", strings.Join(candidates, "\x00")+"\x00", "-C", root, "check-ignore", "-z", "--stdin")
+	if err != nil {
+		return nil, nil
+	}
+
+	var dirs []string
+	for _, entry := range strings.Split(output, "\x00") {
+		if dir, ok := strings.CutSuffix(entry, "/"); ok {
+			dirs = append(dirs, filepath.FromSlash(dir))
+		}
+	}
+	return dirs, nil
+}
+
+// IsIgnored tells whether git ignores a path. A path git can't tell about,
+// such as one outside the repository, counts as not ignored.
+func IsIgnored(ctx context.Context, path string) bool {
+	_, err := runGit(ctx, "git check-ignore", "", "-C", filepath.Dir(path), "check-ignore", "-q", "--", filepath.Base(path))
+	return err == nil
+}
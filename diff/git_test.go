@@ -0,0 +1,190 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeGit replaces runCommand for the test, recording the argv of each
+// command and writing output to its stdout
+func fakeGit(t *testing.T, output string, err error) *[][]string {
+	t.Helper()
+
+	var calls [][]string
+	original := runCommand
+	runCommand = func(cmd *exec.Cmd) error {
+		calls = append(calls, cmd.Args)
+		cmd.Stdout.Write([]byte(output))
+		return err
+	}
+	t.Cleanup(func() { runCommand = original })
+
+	return &calls
+}
+
+func TestRunGitDiffArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{name: "no arguments", args: nil, want: []string{"git", "diff"}},
+		{name: "revision", args: []string{"HEAD~1"}, want: []string{"git", "diff", "HEAD~1"}},
+		{name: "flags and range", args: []string{"--stat", "main..feature"}, want: []string{"git", "diff", "--stat", "main..feature"}},
+		{name: "pathspecs", args: []string{"HEAD", "--", "cmd/", "*.go"}, want: []string{"git", "diff", "HEAD", "--", "cmd/", "*.go"}},
+		{name: "staged", args: []string{"--staged"}, want: []string{"git", "diff", "--cached"}},
+		{name: "cached", args: []string{"--cached", "-U5"}, want: []string{"git", "diff", "--cached", "-U5"}},
+		{name: "reverse before pathspecs", args: []string{"-R", "HEAD~1", "--", "cmd/"}, want: []string{"git", "diff", "-R", "HEAD~1", "--", "cmd/"}},
+		{name: "staged path after separator", args: []string{"--staged", "--", "--staged"}, want: []string{"git", "diff", "--cached", "--", "--staged"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := fakeGit(t, "diff output", nil)
+
+			got, err := RunGitDiff(context.Background(), tt.args)
+			if err != nil {
+				t.Fatalf("RunGitDiff: %s", err)
+			}
+			if got != "diff output" {
+				t.Errorf("RunGitDiff returned %q", got)
+			}
+			if len(*calls) != 1 {
+				t.Fatalf("ran %d commands, want 1", len(*calls))
+			}
+			if argv := (*calls)[0]; !reflect.DeepEqual(argv, tt.want) {
+				t.Errorf("argv = %q, want %q", argv, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunGitDiffGitConfig(t *testing.T) {
+	calls := fakeGit(t, "", nil)
+	if err := SetGitConfig([]string{"diff.algorithm=histogram", "diff.wordRegex=[^[:space:],]+"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { gitConfig = nil })
+
+	if _, err := RunGitDiff(context.Background(), []string{"--staged", "--", "cmd/"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// git only takes -c before the command
+	want := []string{"git", "-c", "diff.algorithm=histogram", "-c", "diff.wordRegex=[^[:space:],]+", "diff", "--cached", "--", "cmd/"}
+	if argv := (*calls)[0]; !reflect.DeepEqual(argv, want) {
+		t.Errorf("argv = %q, want %q", argv, want)
+	}
+}
+
+func TestSetGitConfigInvalid(t *testing.T) {
+	t.Cleanup(func() { gitConfig = nil })
+
+	for _, setting := range []string{"diff.algorithm", "algorithm=histogram", "=histogram", ".diff=x", "diff .algorithm=x"} {
+		if err := SetGitConfig([]string{setting}); err == nil {
+			t.Errorf("SetGitConfig(%q) succeeded", setting)
+		}
+	}
+	if gitConfig != nil {
+		t.Errorf("gitConfig = %q after invalid settings", gitConfig)
+	}
+
+	// An empty value is how git unsets a setting for one command
+	if err := SetGitConfig([]string{"diff.noprefix="}); err != nil {
+		t.Errorf("SetGitConfig with an empty value: %s", err)
+	}
+}
+
+func TestRunGitDiffError(t *testing.T) {
+	fakeGit(t, "", errors.New("exit status 128"))
+
+	_, err := RunGitDiff(context.Background(), []string{"nope"})
+	if err == nil || !strings.Contains(err.Error(), "exit status 128") {
+		t.Errorf("RunGitDiff error = %v", err)
+	}
+}
+
+func TestRecentCommits(t *testing.T) {
+	calls := fakeGit(t, "abc1234 Add parser\ndef5678 Fix typo\n", nil)
+
+	got, err := RecentCommits(context.Background(), 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"abc1234 Add parser", "def5678 Fix typo"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// The count is capped
+	want := []string{"git", "log", "--oneline", "--no-decorate", "-n", "20"}
+	if len(*calls) != 1 || !reflect.DeepEqual((*calls)[0], want) {
+		t.Errorf("ran %q, want %q", *calls, want)
+	}
+}
+
+func TestSymbolLogArgs(t *testing.T) {
+	tests := []struct {
+		symbol  string
+		revs    []string
+		want    []string
+		wantErr bool
+	}{
+		{symbol: "Parse:diff/parse.go", want: []string{"log", "-L", ":Parse:diff/parse.go", "--format=", "--no-color", "-n", "1"}},
+		{symbol: "Parse:diff/parse.go", revs: []string{"main..HEAD"}, want: []string{"log", "-L", ":Parse:diff/parse.go", "--format=", "--no-color", "main..HEAD"}},
+		{symbol: "Parse", wantErr: true},
+		{symbol: ":diff/parse.go", wantErr: true},
+		{symbol: "Parse:", wantErr: true},
+		{symbol: "Parse:diff/parse.go", revs: []string{"HEAD", "--", "cmd/"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := symbolLogArgs(tt.symbol, tt.revs)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q %v: expected an error, got %v", tt.symbol, tt.revs, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q %v: %s", tt.symbol, tt.revs, err)
+			continue
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%q %v: args = %v, want %v", tt.symbol, tt.revs, got, tt.want)
+		}
+	}
+}
+
+func TestIgnoredDirs(t *testing.T) {
+	// ls-files lists src/ for the ignored files in it; check-ignore leaves it out
+	var stdin string
+	original := runCommand
+	runCommand = func(cmd *exec.Cmd) error {
+		if cmd.Args[3] == "ls-files" {
+			cmd.Stdout.Write([]byte("build/\x00src/\x00src/out/\x00debug.log\x00"))
+			return nil
+		}
+		input, _ := io.ReadAll(cmd.Stdin)
+		stdin = string(input)
+		cmd.Stdout.Write([]byte("build/\x00src/out/\x00"))
+		return nil
+	}
+	t.Cleanup(func() { runCommand = original })
+
+	got, err := IgnoredDirs(context.Background(), "/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"build", filepath.Join("src", "out")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("IgnoredDirs = %v, want %v", got, want)
+	}
+	if want := "build/\x00src/\x00src/out/\x00"; stdin != want {
+		t.Errorf("check-ignore got %q, want %q", stdin, want)
+	}
+}
@@ -0,0 +1,190 @@
+package diff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tydin/difx/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// GitHubAPIURL is the base URL of the GitHub REST API. Tests point it at a local server.
+var GitHubAPIURL = "https://api.github.com"
+
+// GitHubTokenEnvVar is the environment variable holding a token for private repositories
+const GitHubTokenEnvVar = "GITHUB_TOKEN"
+
+// githubFilesPerPage is the page size used when listing a pull request's files
+const githubFilesPerPage = 100
+
+// prURLRegex matches https://github.com/<owner>/<repo>/pull/<number>, with an optional trailing path
+var prURLRegex = regexp.MustCompile(`^https?://(?:www\.)?github\.com/([^/]+)/([^/]+)/pull/(\d+)(?:[/?#].*)?$`)
+
+// nextLinkRegex finds the next page in a GitHub Link header
+var nextLinkRegex = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// PullRequest identifies a GitHub pull request
+type PullRequest struct {
+	Owner  string
+	Repo   string
+	Number int
+}
+
+// ParsePRURL extracts the owner, repository and number from a pull request URL
+func ParsePRURL(rawURL string) (PullRequest, error) {
+	matches := prURLRegex.FindStringSubmatch(strings.TrimSpace(rawURL))
+	if matches == nil {
+		return PullRequest{}, fmt.Errorf("not a GitHub pull request URL: %s", rawURL)
+	}
+
+	number, err := strconv.Atoi(matches[3])
+	if err != nil {
+		return PullRequest{}, fmt.Errorf("invalid pull request number: %w", err)
+	}
+
+	return PullRequest{Owner: matches[1], Repo: strings.TrimSuffix(matches[2], ".git"), Number: number}, nil
+}
+
+// prFile is one entry of the pull request files API
+type prFile struct {
+	Filename         string `json:"filename"`
+	PreviousFilename string `json:"previous_filename"`
+	Status           string `json:"status"`
+	Patch            string `json:"patch"`
+}
+
+// FetchPRDiff downloads the unified diff of a pull request. The token is
+// optional and only needed for private repositories. GitHub refuses to render
+// the diff of very large pull requests in one piece, in which case the diff is
+// rebuilt from the paginated list of changed files.
+func FetchPRDiff(ctx context.Context, pr PullRequest, token string) (string, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "fetch pr diff")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("github.repo", pr.Owner+"/"+pr.Repo),
+		attribute.Int("github.pr", pr.Number),
+	)
+
+	endpoint := fmt.Sprintf("%s/repos/%s/%s/pulls/%d", GitHubAPIURL, url.PathEscape(pr.Owner), url.PathEscape(pr.Repo), pr.Number)
+	resp, err := githubGet(ctx, endpoint, "application/vnd.github.diff", token)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading pull request diff: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return string(body), nil
+	case http.StatusNotAcceptable, http.StatusUnprocessableEntity:
+		// The diff is too large to be generated in one response
+		span.SetAttributes(attribute.Bool("github.paginated", true))
+		return fetchPRFiles(ctx, endpoint+"/files?per_page="+strconv.Itoa(githubFilesPerPage), token)
+	default:
+		return "", githubError(resp.StatusCode, body, token)
+	}
+}
+
+// fetchPRFiles follows the Link headers of the pull request files API and
+// turns the per-file patches back into git diff output
+func fetchPRFiles(ctx context.Context, endpoint string, token string) (string, error) {
+	var sections []string
+	for endpoint != "" {
+		resp, err := githubGet(ctx, endpoint, "application/vnd.github+json", token)
+		if err != nil {
+			return "", err
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return "", fmt.Errorf("error reading pull request files: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return "", githubError(resp.StatusCode, body, token)
+		}
+
+		var files []prFile
+		if err := json.Unmarshal(body, &files); err != nil {
+			return "", fmt.Errorf("error decoding pull request files: %w", err)
+		}
+		for _, file := range files {
+			sections = append(sections, fileDiff(file))
+		}
+
+		endpoint = ""
+		if matches := nextLinkRegex.FindStringSubmatch(resp.Header.Get("Link")); matches != nil {
+			endpoint = matches[1]
+		}
+	}
+
+	return strings.Join(sections, ""), nil
+}
+
+// fileDiff adds the git headers that the files API leaves out of a patch
+func fileDiff(file prFile) string {
+	oldName, newName := file.Filename, file.Filename
+	if file.PreviousFilename != "" {
+		oldName = file.PreviousFilename
+	}
+
+	oldPath, newPath := "a/"+oldName, "b/"+newName
+	switch file.Status {
+	case "added":
+		oldPath = "/dev/null"
+	case "removed":
+		newPath = "/dev/null"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "diff --git a/%s b/%s\n", oldName, newName)
+	if file.Patch == "" {
+		// Binary files and very large files come without a patch
+		fmt.Fprintf(&b, "(no patch available, file %s)\n", file.Status)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldPath, newPath)
+	b.WriteString(file.Patch)
+	if !strings.HasSuffix(file.Patch, "\n") {
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// githubGet sends an authenticated GET request to the GitHub API
+func githubGet(ctx context.Context, endpoint string, accept string, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating GitHub request: %w", err)
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request to GitHub: %w", err)
+	}
+	return resp, nil
+}
+
+// githubError describes a failed GitHub API call, hinting at the token for missing repositories
+func githubError(status int, body []byte, token string) error {
+	if status == http.StatusNotFound && token == "" {
+		return fmt.Errorf("pull request not found (set %s for private repositories)", GitHubTokenEnvVar)
+	}
+	return fmt.Errorf("GitHub API returned non-200 status code: %d, body: %s", status, string(body))
+}
@@ -0,0 +1,113 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParsePRURL(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    PullRequest
+		wantErr bool
+	}{
+		{in: "https://github.com/org/repo/pull/123", want: PullRequest{"org", "repo", 123}},
+		{in: "https://github.com/org/repo/pull/7/files", want: PullRequest{"org", "repo", 7}},
+		{in: "https://github.com/org/repo/pull/7#discussion", want: PullRequest{"org", "repo", 7}},
+		{in: "https://github.com/org/repo/issues/7", wantErr: true},
+		{in: "https://gitlab.com/org/repo/pull/7", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePRURL(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePRURL(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParsePRURL(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// withGitHubServer points the GitHub client at a test server for the duration of the test
+func withGitHubServer(t *testing.T, handler http.HandlerFunc) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	old := GitHubAPIURL
+	GitHubAPIURL = server.URL
+	t.Cleanup(func() { GitHubAPIURL = old })
+}
+
+func TestFetchPRDiff(t *testing.T) {
+	withGitHubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/org/repo/pulls/1" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		fmt.Fprint(w, sampleDiff)
+	})
+
+	got, err := FetchPRDiff(context.Background(), PullRequest{"org", "repo", 1}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != sampleDiff {
+		t.Errorf("got %q, want %q", got, sampleDiff)
+	}
+}
+
+func TestFetchPRDiffPaginates(t *testing.T) {
+	withGitHubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/repos/org/repo/pulls/2":
+			w.WriteHeader(http.StatusNotAcceptable)
+		case r.URL.Query().Get("page") == "":
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s/repos/org/repo/pulls/2/files?page=2>; rel="next"`, r.Host))
+			fmt.Fprint(w, `[{"filename":"a.go","status":"modified","patch":"@@ -1 +1 @@\n-old\n+new"}]`)
+		default:
+			fmt.Fprint(w, `[{"filename":"b.go","status":"added","patch":"@@ -0,0 +1 @@\n+hello"}]`)
+		}
+	})
+
+	got, err := FetchPRDiff(context.Background(), PullRequest{"org", "repo", 2}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"diff --git a/a.go b/a.go",
+		"--- a/a.go",
+		"+++ b/a.go",
+		"@@ -1 +1 @@",
+		"-old",
+		"+new",
+		"diff --git a/b.go b/b.go",
+		"--- /dev/null",
+		"+++ b/b.go",
+		"@@ -0,0 +1 @@",
+		"+hello",
+		"",
+	}, "\n")
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFetchPRDiffNotFound(t *testing.T) {
+	withGitHubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	_, err := FetchPRDiff(context.Background(), PullRequest{"org", "private", 3}, "")
+	if err == nil || !strings.Contains(err.Error(), GitHubTokenEnvVar) {
+		t.Errorf("expected a hint about %s, got %v", GitHubTokenEnvVar, err)
+	}
+}
@@ -0,0 +1,54 @@
+package diff
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/tydin/difx/config"
+)
+
+// httpClient sends every API request, so connections are kept alive and
+// reused between requests, such as the calls of a --chunked run. An
+// http.Client is safe for concurrent use. ConfigureHTTPClient sets it up
+// from the config once at startup; tests replace it.
+var httpClient = newHTTPClient(&config.Config{})
+
+// ConfigureHTTPClient builds the client requests go through from the proxy
+// and timeout settings of the config. Call it before any request is sent.
+func ConfigureHTTPClient(cfg *config.Config) {
+	httpClient = newHTTPClient(cfg)
+}
+
+// newHTTPClient returns a client with its own transport, set up from the config
+func newHTTPClient(cfg *config.Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	// HTTPS_PROXY and friends still apply unless proxy_url is set
+	if cfg.ProxyURL != "" {
+		if proxy, err := url.Parse(cfg.ProxyURL); err == nil {
+			transport.Proxy = http.ProxyURL(proxy)
+		}
+	}
+
+	// Responses stream for as long as the model writes, which max_response_time
+	// limits, so only the wait for the API to start answering is timed here
+	if cfg.RequestTimeout > 0 {
+		transport.ResponseHeaderTimeout = time.Duration(cfg.RequestTimeout) * time.Second
+	}
+
+	// Keep a connection for each concurrent chunk request
+	if cfg.Concurrency > transport.MaxIdleConnsPerHost {
+		transport.MaxIdleConnsPerHost = cfg.Concurrency
+	}
+
+	// Answer from recordings, or save each exchange, for testing without the network
+	switch {
+	case cfg.ReplayDir != "":
+		return &http.Client{Transport: &replayTransport{recordings: &recordings{dir: cfg.ReplayDir}}}
+	case cfg.RecordDir != "":
+		return &http.Client{Transport: &recordingTransport{next: transport, recordings: &recordings{dir: cfg.RecordDir}}}
+	}
+
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,90 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tydin/difx/config"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	client := newHTTPClient(&config.Config{ProxyURL: "http://proxy.example.com:3128", RequestTimeout: 30, Concurrency: 8})
+	transport := client.Transport.(*http.Transport)
+
+	req, _ := http.NewRequest("POST", "https://api.anthropic.com/v1/messages", nil)
+	if proxy, err := transport.Proxy(req); err != nil || proxy == nil || proxy.Host != "proxy.example.com:3128" {
+		t.Errorf("proxy = %v, %v", proxy, err)
+	}
+	if transport.ResponseHeaderTimeout != 30*time.Second {
+		t.Errorf("ResponseHeaderTimeout = %s", transport.ResponseHeaderTimeout)
+	}
+	if transport.MaxIdleConnsPerHost != 8 {
+		t.Errorf("MaxIdleConnsPerHost = %d", transport.MaxIdleConnsPerHost)
+	}
+
+	// The whole response isn't timed, since it streams
+	if client.Timeout != 0 {
+		t.Errorf("Timeout = %s", client.Timeout)
+	}
+}
+
+func TestRequestsReuseConnections(t *testing.T) {
+	old := httpClient
+	t.Cleanup(func() { httpClient = old })
+	httpClient = newHTTPClient(&config.Config{})
+
+	var connections atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}]}`)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	cfg := &config.Config{ActiveModel: config.ModelAzureOpenAI, AzureOpenAIEndpoint: server.URL}
+	for i := 0; i < 3; i++ {
+		if _, err := GetExplanation(context.Background(), sampleDiff, cfg, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := connections.Load(); n != 1 {
+		t.Errorf("3 requests opened %d connections, want 1", n)
+	}
+}
+
+func TestHTTPClientIsInjectable(t *testing.T) {
+	old := httpClient
+	t.Cleanup(func() { httpClient = old })
+
+	var sent *url.URL
+	httpClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		sent = req.URL
+		return nil, fmt.Errorf("offline")
+	})}
+
+	cfg := &config.Config{ActiveModel: config.ModelClaude, ClaudeAPIKey: "key"}
+	if _, err := GetExplanation(context.Background(), sampleDiff, cfg, nil); err == nil {
+		t.Fatal("expected the transport's error")
+	}
+	if sent == nil || sent.Host != "api.anthropic.com" {
+		t.Errorf("request went to %v", sent)
+	}
+}
+
+// roundTripFunc turns a function into an http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
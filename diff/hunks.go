@@ -0,0 +1,116 @@
+package diff
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// NoNewlineMarker is the pseudo-line git adds after a line without a trailing newline
+const NoNewlineMarker = `\ No newline at end of file`
+
+// SplitFileDiffs splits git diff output into one section per file.
+// Each section starts with its "diff --git" line. Text that can't be parsed
+// is returned as a single section.
+func SplitFileDiffs(diffOutput string) []string {
+	files, err := Parse(diffOutput)
+	if err != nil || len(files) == 0 {
+		if diffOutput == "" {
+			return nil
+		}
+		return []string{diffOutput}
+	}
+
+	sections := make([]string, len(files))
+	for i, file := range files {
+		sections[i] = file.String()
+	}
+	return sections
+}
+
+// LimitHunkSize replaces the body of every hunk longer than maxLines with a
+// short placeholder, keeping the file headers and hunk ranges intact
+func LimitHunkSize(diffOutput string, maxLines int) string {
+	if maxLines <= 0 {
+		return diffOutput
+	}
+
+	files, err := Parse(diffOutput)
+	if err != nil {
+		return diffOutput
+	}
+
+	for i := range files {
+		for j, hunk := range files[i].Hunks {
+			if len(hunk.Lines) > maxLines {
+				placeholder := fmt.Sprintf("(large change, %d lines, omitted)", len(hunk.Lines))
+				files[i].Hunks[j].Lines = []Line{{Kind: LineOther, Text: placeholder}}
+			}
+		}
+	}
+
+	return Format(files)
+}
+
+// TruncateLongLines shortens every hunk line whose content is longer than
+// maxChars characters, keeping its +/-/space prefix. It returns the new diff
+// and the number of lines that were truncated.
+func TruncateLongLines(diffOutput string, maxChars int) (string, int) {
+	if maxChars <= 0 {
+		return diffOutput, 0
+	}
+
+	files, err := Parse(diffOutput)
+	if err != nil {
+		return diffOutput, 0
+	}
+
+	truncated := 0
+	for _, file := range files {
+		for _, hunk := range file.Hunks {
+			for k, line := range hunk.Lines {
+				// Count characters rather than bytes so multi-byte text isn't cut mid-rune
+				if line.Kind == LineOther || line.Kind == LineNoNewline || utf8.RuneCountInString(line.Text) <= maxChars {
+					continue
+				}
+				content := []rune(line.Text)
+				hunk.Lines[k].Text = fmt.Sprintf("%s... (%d more characters)", string(content[:maxChars]), len(content)-maxChars)
+				truncated++
+			}
+		}
+	}
+
+	return Format(files), truncated
+}
+
+// StripNoNewlineMarkers removes the "\ No newline at end of file" lines, which
+// are git metadata rather than content. It returns the new diff and the files
+// that had the marker.
+func StripNoNewlineMarkers(diffOutput string) (string, []string) {
+	parsed, err := Parse(diffOutput)
+	if err != nil {
+		return diffOutput, nil
+	}
+
+	var files []string
+	for i, file := range parsed {
+		found := false
+		for j, hunk := range file.Hunks {
+			// git may translate the message, but the line always starts with a backslash
+			var lines []Line
+			for _, line := range hunk.Lines {
+				if line.Kind == LineNoNewline {
+					found = true
+					continue
+				}
+				lines = append(lines, line)
+			}
+			parsed[i].Hunks[j].Lines = lines
+		}
+
+		if found {
+			files = append(files, file.Path())
+		}
+	}
+
+	return Format(parsed), files
+}
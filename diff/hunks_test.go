@@ -0,0 +1,131 @@
+package diff
+
+import "testing"
+
+const sampleDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,3 @@
+ package main
+-var a = 1
++var a = 2
+diff --git a/go.sum b/go.sum
+index 3333333..4444444 100644
+--- a/go.sum
++++ b/go.sum
+@@ -1,2 +1,5 @@
+ one
++two
++three
++four
++five
+`
+
+func TestSplitFileDiffs(t *testing.T) {
+	files := SplitFileDiffs(sampleDiff)
+	if len(files) != 2 {
+		t.Fatalf("SplitFileDiffs returned %d sections, want 2", len(files))
+	}
+	if files[0]+files[1] != sampleDiff {
+		t.Errorf("sections don't add up to the diff:\n%s%s", files[0], files[1])
+	}
+}
+
+func TestLimitHunkSize(t *testing.T) {
+	if got := LimitHunkSize(sampleDiff, 0); got != sampleDiff {
+		t.Errorf("LimitHunkSize with 0 changed the diff:\n%s", got)
+	}
+	if got := LimitHunkSize(sampleDiff, 5); got != sampleDiff {
+		t.Errorf("LimitHunkSize with a large limit changed the diff:\n%s", got)
+	}
+
+	want := `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,3 @@
+ package main
+-var a = 1
++var a = 2
+diff --git a/go.sum b/go.sum
+index 3333333..4444444 100644
+--- a/go.sum
++++ b/go.sum
+@@ -1,2 +1,5 @@
+(large change, 5 lines, omitted)
+`
+	if got := LimitHunkSize(sampleDiff, 3); got != want {
+		t.Errorf("LimitHunkSize(3) =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTruncateLongLines(t *testing.T) {
+	in := `diff --git a/app.min.js b/app.min.js
+--- a/app.min.js
++++ b/app.min.js
+@@ -1 +1 @@
+-var a=1;var b=2;
++var a=1;var b=3;
+ ok
+`
+	want := `diff --git a/app.min.js b/app.min.js
+--- a/app.min.js
++++ b/app.min.js
+@@ -1 +1 @@
+-var a=1;... (8 more characters)
++var a=1;... (8 more characters)
+ ok
+`
+	got, truncated := TruncateLongLines(in, 8)
+	if got != want {
+		t.Errorf("TruncateLongLines =\n%s\nwant\n%s", got, want)
+	}
+	if truncated != 2 {
+		t.Errorf("truncated %d lines, want 2", truncated)
+	}
+
+	if got, truncated := TruncateLongLines(in, 0); got != in || truncated != 0 {
+		t.Errorf("TruncateLongLines with 0 changed the diff")
+	}
+}
+
+func TestStripNoNewlineMarkers(t *testing.T) {
+	in := `diff --git a/VERSION b/VERSION
+index 1111111..2222222 100644
+--- a/VERSION
++++ b/VERSION
+@@ -1 +1 @@
+-1.0.0
+` + NoNewlineMarker + `
++1.1.0
+` + NoNewlineMarker + `
+diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1 +1 @@
+-var a = 1
++var a = 2
+`
+	want := `diff --git a/VERSION b/VERSION
+index 1111111..2222222 100644
+--- a/VERSION
++++ b/VERSION
+@@ -1 +1 @@
+-1.0.0
++1.1.0
+diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1 +1 @@
+-var a = 1
++var a = 2
+`
+	got, files := StripNoNewlineMarkers(in)
+	if got != want {
+		t.Errorf("StripNoNewlineMarkers =\n%s\nwant\n%s", got, want)
+	}
+	if len(files) != 1 || files[0] != "VERSION" {
+		t.Errorf("files = %v, want [VERSION]", files)
+	}
+}
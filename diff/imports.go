@@ -0,0 +1,114 @@
+package diff
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ImportsReorderedNote replaces the lines of a hunk that only reorders imports
+const ImportsReorderedNote = "(imports reordered)"
+
+// importPatterns match a single import line, by file extension. Go's
+// pattern also matches the bare paths inside an import ( ... ) block.
+var importPatterns = map[string]*regexp.Regexp{
+	".go":    regexp.MustCompile(`^\s*(import\s+)?([\w.]+\s+)?"[^"]+"\s*$`),
+	".py":    regexp.MustCompile(`^\s*(import\s+\S|from\s+\S+\s+import\s)`),
+	".js":    jsImportRegex,
+	".jsx":   jsImportRegex,
+	".mjs":   jsImportRegex,
+	".cjs":   jsImportRegex,
+	".ts":    jsImportRegex,
+	".tsx":   jsImportRegex,
+	".java":  regexp.MustCompile(`^\s*import\s+(static\s+)?[\w.*]+\s*;\s*$`),
+	".kt":    regexp.MustCompile(`^\s*import\s+[\w.*]+(\s+as\s+\w+)?\s*$`),
+	".scala": regexp.MustCompile(`^\s*import\s+\S+.*$`),
+	".swift": regexp.MustCompile(`^\s*(@testable\s+)?import\s+\w+\s*$`),
+	".cs":    regexp.MustCompile(`^\s*(global\s+)?using\s+(static\s+)?[\w.=\s]+;\s*$`),
+	".rs":    regexp.MustCompile(`^\s*(pub(\([^)]*\))?\s+)?use\s+.+;\s*$`),
+	".php":   regexp.MustCompile(`^\s*use\s+[\w\\]+.*;\s*$`),
+	".rb":    regexp.MustCompile(`^\s*require(_relative)?\s*\(?\s*['"][^'"]+['"]\s*\)?\s*$`),
+	".c":     cIncludeRegex,
+	".h":     cIncludeRegex,
+	".cc":    cIncludeRegex,
+	".cpp":   cIncludeRegex,
+	".hpp":   cIncludeRegex,
+}
+
+var (
+	// jsImportRegex matches a one-line ES module import, re-export or require
+	jsImportRegex = regexp.MustCompile(`^\s*(import\s.*['"][^'"]+['"];?|export\s.*\sfrom\s+['"][^'"]+['"];?|(const|let|var)\s+.+=\s*require\(\s*['"][^'"]+['"]\s*\);?)\s*$`)
+
+	// cIncludeRegex matches a C or C++ #include
+	cIncludeRegex = regexp.MustCompile(`^\s*#\s*include\s*[<"][^>"]+[>"]\s*$`)
+)
+
+// CollapseImportReorders replaces every hunk that only reorders imports,
+// removing and adding the same import lines, with ImportsReorderedNote. The
+// language is guessed from the file extension; files in other languages are
+// left alone. It returns the new diff and the number of files that changed.
+func CollapseImportReorders(diffOutput string) (string, int) {
+	files, err := Parse(diffOutput)
+	if err != nil {
+		return diffOutput, 0
+	}
+
+	collapsed := 0
+	for i := range files {
+		pattern, ok := importPatterns[strings.ToLower(path.Ext(files[i].Path()))]
+		if !ok {
+			continue
+		}
+
+		changed := false
+		for j, hunk := range files[i].Hunks {
+			if isImportReorder(hunk, pattern) {
+				files[i].Hunks[j].Lines = []Line{{Kind: LineOther, Text: ImportsReorderedNote}}
+				changed = true
+			}
+		}
+		if changed {
+			collapsed++
+		}
+	}
+
+	if collapsed == 0 {
+		return diffOutput, 0
+	}
+	return Format(files), collapsed
+}
+
+// isImportReorder tells whether every line the hunk removes or adds is an
+// import or blank, and the removed imports are the same as the added ones
+func isImportReorder(hunk Hunk, pattern *regexp.Regexp) bool {
+	// Count each import up for a removal and down for an addition
+	counts := make(map[string]int)
+	imports := 0
+	for _, line := range hunk.Lines {
+		if line.Kind != LineAdded && line.Kind != LineDeleted {
+			continue
+		}
+
+		text := strings.TrimSpace(line.Text)
+		if text == "" {
+			continue
+		}
+		if !pattern.MatchString(line.Text) {
+			return false
+		}
+
+		imports++
+		if line.Kind == LineDeleted {
+			counts[text]++
+		} else {
+			counts[text]--
+		}
+	}
+
+	for _, count := range counts {
+		if count != 0 {
+			return false
+		}
+	}
+	return imports > 0
+}
@@ -0,0 +1,107 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCollapseImportReorders(t *testing.T) {
+	diffOutput := `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1,6 +1,6 @@
+ import (
+-	"os"
+ 	"fmt"
++	"os"
+ )
+@@ -20,2 +20,2 @@ func main() {
+-	fmt.Println("a")
++	fmt.Println("b")
+diff --git a/app.py b/app.py
+--- a/app.py
++++ b/app.py
+@@ -1,3 +1,3 @@
+-import sys
+ import os
++import sys
+diff --git a/util.py b/util.py
+--- a/util.py
++++ b/util.py
+@@ -1,2 +1,2 @@
+-import sys
++import json
+diff --git a/notes.txt b/notes.txt
+--- a/notes.txt
++++ b/notes.txt
+@@ -1,2 +1,2 @@
+-import b
+ import a
++import b
+`
+
+	got, collapsed := CollapseImportReorders(diffOutput)
+	if collapsed != 2 {
+		t.Errorf("collapsed %d files, want 2", collapsed)
+	}
+
+	files, err := Parse(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes := map[string][]bool{}
+	for _, file := range files {
+		for _, hunk := range file.Hunks {
+			notes[file.Path()] = append(notes[file.Path()], len(hunk.Lines) == 1 && hunk.Lines[0].Text == ImportsReorderedNote)
+		}
+	}
+
+	// Only pure reorderings in known languages are collapsed
+	want := map[string][]bool{
+		"main.go":   {true, false},
+		"app.py":    {true},
+		"util.py":   {false},
+		"notes.txt": {false},
+	}
+	for path, hunks := range want {
+		if len(notes[path]) != len(hunks) {
+			t.Errorf("%s has %d hunks, want %d", path, len(notes[path]), len(hunks))
+			continue
+		}
+		for i := range hunks {
+			if notes[path][i] != hunks[i] {
+				t.Errorf("%s hunk %d collapsed = %t, want %t", path, i, notes[path][i], hunks[i])
+			}
+		}
+	}
+	if !strings.Contains(got, `+	fmt.Println("b")`) {
+		t.Error("the code change was removed")
+	}
+}
+
+func TestIsImportReorder(t *testing.T) {
+	tests := []struct {
+		name string
+		ext  string
+		hunk Hunk
+		want bool
+	}{
+		{name: "go group moved", ext: ".go", hunk: hunkOf(`-	"strings"`, "-", `+`, `+	"strings"`, ` 	"fmt"`), want: true},
+		{name: "go alias", ext: ".go", hunk: hunkOf(`-	b "lib/b"`, `-	a "lib/a"`, `+	a "lib/a"`, `+	b "lib/b"`), want: true},
+		{name: "import added", ext: ".go", hunk: hunkOf(`+	"errors"`), want: false},
+		{name: "import and code", ext: ".py", hunk: hunkOf("-import a", "+import a", "+x = 1"), want: false},
+		{name: "java", ext: ".java", hunk: hunkOf("-import java.util.List;", "+import java.io.File;", "-import java.io.File;", "+import java.util.List;"), want: true},
+		{name: "csharp", ext: ".cs", hunk: hunkOf("-using System.Linq;", " using System;", "+using System.Linq;"), want: true},
+		{name: "javascript", ext: ".js", hunk: hunkOf(`-import b from "b";`, `-const a = require("a");`, `+const a = require("a");`, `+import b from "b";`), want: true},
+		{name: "include", ext: ".c", hunk: hunkOf("-#include <stdio.h>", "+#include <stdlib.h>", "+#include <stdio.h>", "-#include <stdlib.h>"), want: true},
+		{name: "nothing changed", ext: ".go", hunk: hunkOf(` 	"fmt"`), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isImportReorder(tt.hunk, importPatterns[tt.ext]); got != tt.want {
+				t.Errorf("isImportReorder = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
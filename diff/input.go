@@ -0,0 +1,69 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/atotto/clipboard"
+)
+
+// ReadDiffFile reads a diff from the given path, or from stdin if the path is "-"
+func ReadDiffFile(path string) (string, error) {
+	if path == "-" {
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("error reading diff from stdin: %w", err)
+		}
+		return string(content), nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading diff file: %w", err)
+	}
+	return string(content), nil
+}
+
+// readClipboard returns the text on the system clipboard. Tests replace it.
+var readClipboard = clipboard.ReadAll
+
+// ReadClipboardDiff reads a diff pasted to the system clipboard. The second
+// return value is false if the text doesn't look like a diff.
+func ReadClipboardDiff() (string, bool, error) {
+	text, err := readClipboard()
+	if err != nil {
+		return "", false, fmt.Errorf("error reading the clipboard: %w", err)
+	}
+	// Text copied from a chat or browser on Windows has CRLF line endings
+	text = NormalizeLineEndings(text)
+	return text, LooksLikeDiff(text), nil
+}
+
+// StdinIsPipe reports whether stdin is redirected from a pipe or file rather than a terminal
+func StdinIsPipe() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice == 0
+}
+
+// ReadPipedDiff reads stdin and returns its content if it looks like a diff.
+// The second return value is false if stdin is empty or isn't a diff.
+func ReadPipedDiff() (string, bool, error) {
+	text, err := ReadDiffFile("-")
+	if err != nil {
+		return "", false, err
+	}
+	return text, LooksLikeDiff(text), nil
+}
+
+// LooksLikeDiff reports whether the text starts like git or unified diff output
+func LooksLikeDiff(text string) bool {
+	text = strings.TrimLeft(text, "\r\n")
+	return strings.HasPrefix(text, "diff --git ") ||
+		strings.HasPrefix(text, "--- ") ||
+		strings.HasPrefix(text, "+++ ")
+}
@@ -0,0 +1,46 @@
+package diff
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLooksLikeDiff(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{in: "diff --git a/main.go b/main.go\n", want: true},
+		{in: "--- a/main.go\n+++ b/main.go\n", want: true},
+		{in: "\n+++ b/main.go\n", want: true},
+		{in: "hello world\n", want: false},
+		{in: "", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := LooksLikeDiff(tt.in); got != tt.want {
+			t.Errorf("LooksLikeDiff(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestReadClipboardDiff(t *testing.T) {
+	old := readClipboard
+	t.Cleanup(func() { readClipboard = old })
+
+	readClipboard = func() (string, error) { return "diff --git a/x b/x\r\n+y\r\n", nil }
+	text, isDiff, err := ReadClipboardDiff()
+	if err != nil || !isDiff || text != "diff --git a/x b/x\n+y\n" {
+		t.Errorf("got %q, %v, %v", text, isDiff, err)
+	}
+
+	readClipboard = func() (string, error) { return "see you at 5", nil }
+	if _, isDiff, _ := ReadClipboardDiff(); isDiff {
+		t.Error("plain text was taken for a diff")
+	}
+
+	readClipboard = func() (string, error) { return "", errors.New("no xclip") }
+	if _, _, err := ReadClipboardDiff(); err == nil {
+		t.Error("expected the clipboard error")
+	}
+}
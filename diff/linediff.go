@@ -0,0 +1,53 @@
+package diff
+
+import "strings"
+
+// LineDiff compares two texts line by line and returns the lines of a and b
+// prefixed with "  " when unchanged, "- " when only in a and "+ " when only in b.
+// It uses a longest common subsequence, which is fine for explanation-sized texts.
+func LineDiff(a, b string) []string {
+	aLines := strings.Split(strings.TrimRight(a, "\n"), "\n")
+	bLines := strings.Split(strings.TrimRight(b, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of aLines[i:] and bLines[j:]
+	lcs := make([][]int, len(aLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bLines)+1)
+	}
+	for i := len(aLines) - 1; i >= 0; i-- {
+		for j := len(bLines) - 1; j >= 0; j-- {
+			if aLines[i] == bLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var result []string
+	i, j := 0, 0
+	for i < len(aLines) && j < len(bLines) {
+		switch {
+		case aLines[i] == bLines[j]:
+			result = append(result, "  "+aLines[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			result = append(result, "- "+aLines[i])
+			i++
+		default:
+			result = append(result, "+ "+bLines[j])
+			j++
+		}
+	}
+	for ; i < len(aLines); i++ {
+		result = append(result, "- "+aLines[i])
+	}
+	for ; j < len(bLines); j++ {
+		result = append(result, "+ "+bLines[j])
+	}
+
+	return result
+}
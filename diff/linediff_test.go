@@ -0,0 +1,23 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLineDiff(t *testing.T) {
+	a := "SUMMARY:\n  - Files modified: 1\nDETAILS:\n  main.go\n"
+	b := "SUMMARY:\n  - Files modified: 2\nDETAILS:\n  main.go\n  go.mod\n"
+
+	want := []string{
+		"  SUMMARY:",
+		"-   - Files modified: 1",
+		"+   - Files modified: 2",
+		"  DETAILS:",
+		"    main.go",
+		"+   go.mod",
+	}
+	if got := LineDiff(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("LineDiff =\n%q\nwant\n%q", got, want)
+	}
+}
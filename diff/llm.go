@@ -3,19 +3,25 @@ package diff
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/tydin/difx/cache"
 	"github.com/tydin/difx/config"
+	"github.com/tydin/difx/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 const (
 	// Claude API constants
-	ClaudeAPIURL = "https://api.anthropic.com/v1/messages"
-	ClaudeModel  = "claude-3-7-sonnet-latest"
+	ClaudeModel = "claude-3-7-sonnet-latest"
 	
 	// Azure OpenAI constants
 	AzureOpenAIModel = "gpt-4o"
@@ -24,11 +30,14 @@ const (
 
 // ClaudeRequest represents the request structure for the Claude API
 type ClaudeRequest struct {
-	Model       string    `json:"model"`
-	Messages    []Message `json:"messages"`
-	MaxTokens   int       `json:"max_tokens"`
-	Temperature float64   `json:"temperature,omitempty"`
-	Stream      bool      `json:"stream"`
+	Model         string            `json:"model"`
+	Messages      []Message         `json:"messages"`
+	MaxTokens     int               `json:"max_tokens"`
+	Temperature   float64           `json:"temperature,omitempty"`
+	Stream        bool              `json:"stream"`
+	Tools         []ClaudeTool      `json:"tools,omitempty"`
+	ToolChoice    *ClaudeToolChoice `json:"tool_choice,omitempty"`
+	StopSequences []string          `json:"stop_sequences,omitempty"`
 }
 
 // Message represents a message in the Claude API request
@@ -45,12 +54,16 @@ type ClaudeResponse struct {
 	Content    []ContentBlock `json:"content"`
 	Model      string         `json:"model"`
 	StopReason string         `json:"stop_reason"`
+	Usage      *Usage         `json:"usage,omitempty"`
 }
 
 // ContentBlock represents a block of content in the Claude API response
 type ContentBlock struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type  string          `json:"type"`
+	Text  string          `json:"text"`
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
 }
 
 // Event types for streaming response
@@ -62,6 +75,7 @@ const (
 	EventContentBlockDelta = "content_block_delta"
 	EventContentBlockStop  = "content_block_stop"
 	EventPing              = "ping"
+	EventError             = "error"
 )
 
 // StreamEvent represents a streaming event from Claude API
@@ -71,6 +85,7 @@ type StreamEvent struct {
 	Delta        *StreamDelta   `json:"delta,omitempty"`
 	Index        int            `json:"index,omitempty"`
 	ContentBlock *ContentBlock  `json:"content_block,omitempty"`
+	Usage        *Usage         `json:"usage,omitempty"`
 }
 
 // StreamMessage represents the message in a streaming response
@@ -82,73 +97,278 @@ type StreamMessage struct {
 	Model        string         `json:"model"`
 	StopReason   *string        `json:"stop_reason"`
 	StopSequence *string        `json:"stop_sequence"`
+	Usage        *Usage         `json:"usage,omitempty"`
 }
 
 // StreamDelta represents the delta in a streaming response
 type StreamDelta struct {
 	Type         string  `json:"type,omitempty"`
 	Text         string  `json:"text,omitempty"`
+	PartialJSON  string  `json:"partial_json,omitempty"`
 	StopReason   *string `json:"stop_reason,omitempty"`
 	StopSequence *string `json:"stop_sequence,omitempty"`
 }
 
-// GetExplanation sends the diff to the selected LLM API and returns an explanation
-func GetExplanation(diffOutput string, cfg *config.Config, callback func(string)) (string, error) {
-	// Create the prompt for Claude
-	prompt := "I'm going to show you the output of a git diff command. Please explain these changes in a clear, concise way.\n\n"
-	prompt += "Here's the git diff output:\n\n```\n"
-	prompt += diffOutput
-	prompt += "\n```\n\n"
-	prompt += "Be concise but include every file that was changed in DETAILS. Use the format below and output plaintext without ```. Only include SUMMARY,FILE CHANGES and DETAILS section:\n\n```"
-	prompt += `
---------------------------------------------------
-SUMMARY:
-  - Files modified: {files_modified}
-	- One line summary of the changes
-  - Insertions: {insertions}
-  - Deletions: {deletions}
-
-FILE CHANGES:
-{file_changes}
-
-DETAILS:
-	file1:
-		+ {detailed_breakdown_additions}
-		- {detailed_breakdown_deletions}
-	...
---------------------------------------------------
-`
-	prompt += "\n```\n"
-	prompt += "IMPORTANT: For colored text, use the following ANSI escape codes with the full escape character prefix:\n\n"
-	prompt += "For additions (green text): \\033[32;1m text here \\033[0m\n"
-	prompt += "For deletions (red text): \\033[31;1m text here \\033[0m\n\n"
-	prompt += "Make sure to include the full '\\033' escape character prefix and always close with '\\033[0m' to reset the color."
+// GetExplanation sends the diff to the selected LLM API and returns an explanation.
+// The callback, if not nil, receives the text as it streams in.
+func GetExplanation(ctx context.Context, diffOutput string, cfg *config.Config, callback func(string)) (string, error) {
+	return GetExplanationEvents(ctx, diffOutput, cfg, textHandler(callback))
+}
+
+// GetExplanationEvents is like GetExplanation, but reports the start, text,
+// usage and stop of the response as typed events. A failure is reported as an
+// EventKindError event and returned.
+func GetExplanationEvents(ctx context.Context, diffOutput string, cfg *config.Config, handler func(Event)) (string, error) {
+	result, err := GetExplanationResult(ctx, diffOutput, cfg, handler)
+	return result.Text, err
+}
+
+// Result is a complete explanation with the token counts the provider
+// reported for it. Usage is zero for cached explanations and when the
+// provider doesn't report it.
+type Result struct {
+	Text  string
+	Usage Usage
+}
+
+// GetExplanationResult is like GetExplanationEvents, but also returns the
+// token counts of the request
+func GetExplanationResult(ctx context.Context, diffOutput string, cfg *config.Config, handler func(Event)) (Result, error) {
+	if handler == nil {
+		handler = func(Event) {}
+	}
+
+	var result Result
+	var err error
+	if cfg.AnonymizePaths && !cfg.Offline {
+		result, err = getAnonymizedExplanation(ctx, diffOutput, cfg, handler)
+	} else {
+		result, err = getExplanation(ctx, diffOutput, cfg, handler)
+	}
+	if err != nil {
+		handler(Event{Kind: EventKindError, Err: err})
+	}
+	return result, err
+}
+
+// getExplanation builds the prompt, then answers it from the cache or the model
+func getExplanation(ctx context.Context, diffOutput string, cfg *config.Config, emit func(Event)) (Result, error) {
+	// Describe the diff locally without building a prompt or calling a model
+	if cfg.Offline {
+		text, err := OfflineExplanation(diffOutput, cfg.Structured)
+		if err != nil {
+			return Result{}, err
+		}
+		emit(Event{Kind: EventKindStart})
+		emit(Event{Kind: EventKindText, Text: text})
+		emit(Event{Kind: EventKindStop})
+		return Result{Text: text}, nil
+	}
+
+	prompt := requestPrompt(ctx, diffOutput, cfg)
+
+	// Reuse an earlier response to the exact same prompt and model
+	if cfg.Cache {
+		if response, ok := lookupCache(prompt, cfg); ok {
+			emit(Event{Kind: EventKindStart})
+			emit(Event{Kind: EventKindText, Text: response})
+			emit(Event{Kind: EventKindStop})
+			return Result{Text: response}, nil
+		}
+	}
+
+	ctx, span := telemetry.Tracer().Start(ctx, "llm request")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("difx.model", cfg.ActiveModel),
+		attribute.Int("difx.prompt_tokens_estimate", EstimateTokens(prompt)),
+	)
+
+	// Keep the provider's token counts; a retried request reports those of the last attempt
+	var usage Usage
+	handler := emit
+	emit = func(event Event) {
+		if event.Kind == EventKindUsage {
+			usage = event.Usage
+		}
+		handler(event)
+	}
+
+	// Save streamed text as it arrives, and continue an explanation that was cut off
+	messages := userPrompt(prompt)
+	p := &progress{key: cache.Key(modelName(cfg), prompt), save: savesProgress(cfg)}
+	if cfg.Resume && savesProgress(cfg) {
+		p.resumed = loadResume(p.key)
+	}
+	if p.resumed != "" {
+		messages = append(messages, Message{Role: "assistant", Content: p.resumed})
+	}
+	span.SetAttributes(attribute.Int("difx.resumed_chars", len(p.resumed)))
+
+	start := time.Now()
+	response, stopped, err := callWithDeadline(ctx, cfg.MaxResponseTime, emit, func(ctx context.Context, emit func(Event)) (string, error) {
+		// Inside the deadline, so the text it keeps includes the resumed text
+		p.emit = emit
+		p.start()
+		return retryRequest(ctx, cfg, p.handle, func() (string, error) {
+			return callModel(ctx, messages, cfg, p.handle)
+		})
+	})
+	span.SetAttributes(
+		attribute.Int64("difx.latency_ms", time.Since(start).Milliseconds()),
+		attribute.Bool("difx.max_response_time_exceeded", stopped),
+		attribute.Int("difx.input_tokens", usage.InputTokens),
+		attribute.Int("difx.output_tokens", usage.OutputTokens),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return Result{}, err
+	}
+
+	// Count the request, estimating the tokens the provider didn't report
+	if cfg.RecordUsage != nil {
+		counted := usage
+		if counted.InputTokens == 0 {
+			counted.InputTokens = EstimateTokens(prompt) + EstimateTokens(p.resumed)
+		}
+		if counted.OutputTokens == 0 {
+			counted.OutputTokens = EstimateTokens(response)
+		}
+		cfg.RecordUsage(cfg.ActiveModel, counted.InputTokens, counted.OutputTokens)
+	}
 
+	// A response cut off by the deadline already has the resumed text
+	if !stopped {
+		response = p.result(response)
+		p.finish()
+	}
+
+	// Don't cache a response that was cut short
+	if cfg.Cache && !stopped {
+		// A failed write only means the next run calls the API again
+		_ = storeCache(prompt, cfg, response)
+	}
+
+	return Result{Text: response, Usage: usage}, nil
+}
+
+// getAnonymizedExplanation explains the diff with its paths replaced by
+// aliases, and puts the real paths back in the response
+func getAnonymizedExplanation(ctx context.Context, diffOutput string, cfg *config.Config, handler func(Event)) (Result, error) {
+	diffOutput, cfg, aliases := anonymizeRequest(diffOutput, cfg)
+
+	restorer := &pathRestorer{aliases: aliases, emit: handler}
+	result, err := getExplanation(ctx, diffOutput, cfg, restorer.handle)
+	restorer.flush()
+
+	result.Text = aliases.Restore(result.Text)
+	return result, err
+}
+
+// requestPrompt builds the prompt for a request explaining diffOutput
+func requestPrompt(ctx context.Context, diffOutput string, cfg *config.Config) string {
+	_, promptSpan := telemetry.Tracer().Start(ctx, "build prompt")
+	defer promptSpan.End()
+
+	// Add the complete files this request's diff touches
+	if cfg.FullContext {
+		requestCfg := *cfg
+		var skipped []string
+		requestCfg.FileVersions, skipped = FullFileContext(ctx, diffOutput, MaxFullContextBytes)
+		promptSpan.SetAttributes(attribute.StringSlice("difx.full_context_skipped", skipped))
+		cfg = &requestCfg
+	}
+
+	prompt := buildPrompt(diffOutput, cfg)
+	promptSpan.SetAttributes(attribute.Int("difx.prompt_tokens_estimate", EstimateTokens(prompt)))
+	return prompt
+}
+
+// BuildPrompt returns the prompt a request explaining diffOutput would send,
+// with paths anonymized as they would be, without sending anything
+func BuildPrompt(ctx context.Context, diffOutput string, cfg *config.Config) string {
+	if cfg.AnonymizePaths {
+		diffOutput, cfg, _ = anonymizeRequest(diffOutput, cfg)
+	}
+	return requestPrompt(ctx, diffOutput, cfg)
+}
+
+// decodeBody returns the response body, decompressing it if it is gzip encoded
+func decodeBody(resp *http.Response) (io.Reader, error) {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return resp.Body, nil
+	}
+
+	reader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing response: %w", err)
+	}
+	return reader, nil
+}
+
+// EstimateTokens gives a rough token count for a text (about four characters per token)
+func EstimateTokens(text string) int {
+	return len(text) / 4
+}
+
+// callModel sends the conversation to the API selected by the active model in config
+func callModel(ctx context.Context, messages []Message, cfg *config.Config, emit func(Event)) (string, error) {
+	if err := checkContextWindow(messages, cfg); err != nil {
+		return "", err
+	}
+
+	if !restoresDelimiter(cfg) {
+		return callProvider(ctx, messages, cfg, emit)
+	}
+
+	// Finish a response cut at the closing delimiter as if it had been written
+	restorer := &delimiterRestorer{emit: emit}
+	response, err := callProvider(ctx, messages, cfg, restorer.handle)
+	if err != nil {
+		return "", err
+	}
+	return response + restorer.tail, nil
+}
+
+// callProvider sends the conversation to the API of the active model
+func callProvider(ctx context.Context, messages []Message, cfg *config.Config, emit func(Event)) (string, error) {
 	// Determine which model to use based on the active model in config
 	switch cfg.ActiveModel {
 	case config.ModelClaude:
-		return callClaudeAPI(prompt, cfg, callback)
+		return callClaudeAPI(ctx, messages, cfg, emit)
 	case config.ModelAzureOpenAI:
-		return callAzureOpenAI(prompt, cfg, callback)
+		if cfg.Structured {
+			return "", fmt.Errorf("structured output is only supported with the %s model", config.ModelClaude)
+		}
+		return callAzureOpenAI(ctx, messages, cfg, emit)
 	default:
-		return "", fmt.Errorf("unsupported model: %s", cfg.ActiveModel)
+		return "", config.UnsupportedModelError(cfg.ActiveModel)
 	}
 }
 
-// callClaudeAPI sends the prompt to Claude API and returns the response
-func callClaudeAPI(prompt string, cfg *config.Config, callback func(string)) (string, error) {
+// callClaudeAPI sends the conversation to Claude API and returns the response.
+// A last assistant message is continued rather than answered.
+func callClaudeAPI(ctx context.Context, messages []Message, cfg *config.Config, emit func(Event)) (string, error) {
+	// Refuse a runaway prompt before building a request the API would reject
+	if err := checkRequestSize(messages, cfg); err != nil {
+		return "", err
+	}
+
 	// Create the request for Claude
 	request := ClaudeRequest{
-		Model: ClaudeModel,
-		Messages: []Message{
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
-		MaxTokens:   4000,
-		Temperature: 0.7,
-		Stream:      cfg.Streaming,
+		Model:         ClaudeModel,
+		Messages:      messages,
+		MaxTokens:     MaxOutputTokens,
+		Temperature:   0.7,
+		Stream:        cfg.Streaming,
+		StopSequences: stopSequences(cfg),
+	}
+
+	// Force the explanation through the tool to get structured output
+	if cfg.Structured {
+		request.Tools = []ClaudeTool{structuredTool(cfg)}
+		request.ToolChoice = &ClaudeToolChoice{Type: "tool", Name: ExplanationToolName}
 	}
 
 	// Convert request to JSON
@@ -158,7 +378,7 @@ func callClaudeAPI(prompt string, cfg *config.Config, callback func(string)) (st
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequest("POST", ClaudeAPIURL, bytes.NewBuffer(requestBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", claudeMessagesURL(cfg), bytes.NewBuffer(requestBody))
 	if err != nil {
 		return "", fmt.Errorf("error creating HTTP request: %w", err)
 	}
@@ -166,19 +386,22 @@ func callClaudeAPI(prompt string, cfg *config.Config, callback func(string)) (st
 	// Set headers
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("x-api-key", cfg.ClaudeAPIKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("anthropic-version", cfg.AnthropicVersion)
 	
 	// Handle streaming vs non-streaming
 	if cfg.Streaming {
 		req.Header.Set("Accept", "text/event-stream")
-		return handleClaudeStreamingResponse(req, callback)
+		return handleClaudeStreamingResponse(req, emit)
 	} else {
-		return handleClaudeNonStreamingResponse(req)
+		return handleClaudeNonStreamingResponse(req, emit)
 	}
 }
 
 // handleClaudeStreamingResponse processes a streaming response from Claude API
-func handleClaudeStreamingResponse(req *http.Request, callback func(string)) (string, error) {
+func handleClaudeStreamingResponse(req *http.Request, emit func(Event)) (string, error) {
+	// Ask for an uncompressed stream so chunks arrive as soon as they are sent
+	req.Header.Set("Accept-Encoding", "identity")
+
 	// Create a channel to receive the streamed content
 	contentChan := make(chan string)
 	errChan := make(chan error)
@@ -186,26 +409,36 @@ func handleClaudeStreamingResponse(req *http.Request, callback func(string)) (st
 	// Start a goroutine to process the streaming response
 	go func() {
 		// Send the request
-		client := &http.Client{}
-		resp, err := client.Do(req)
+		resp, err := httpClient.Do(req)
 		if err != nil {
 			errChan <- fmt.Errorf("error sending request to Claude API: %w", err)
 			return
 		}
 		defer resp.Body.Close()
 
+		// Proxies and gateways may gzip the response even when it was not asked for
+		body, err := decodeBody(resp)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
 		// Check for non-200 status code
 		if resp.StatusCode != http.StatusOK {
-			respBody, _ := io.ReadAll(resp.Body)
-			errChan <- fmt.Errorf("Claude API returned non-200 status code: %d, body: %s", resp.StatusCode, string(respBody))
+			respBody, _ := io.ReadAll(body)
+			errChan <- &APIError{Provider: "Claude", StatusCode: resp.StatusCode, Body: string(respBody)}
 			return
 		}
 
 		// Create a scanner to read the SSE stream line by line
-		scanner := bufio.NewScanner(resp.Body)
+		scanner := bufio.NewScanner(body)
 		var eventType string
 		var eventData string
 
+		// Token counts arrive with message_start and message_delta, the stop reason with message_delta
+		var usage Usage
+		var stopReason string
+
 		for scanner.Scan() {
 			line := scanner.Text()
 
@@ -229,6 +462,12 @@ func handleClaudeStreamingResponse(req *http.Request, callback func(string)) (st
 					continue
 				}
 
+				// The API reports failures mid-stream as error events
+				if eventType == EventError {
+					errChan <- fmt.Errorf("Claude API stream error: %s", eventData)
+					return
+				}
+
 				// Parse the event data
 				var streamEvent StreamEvent
 				if err := json.Unmarshal([]byte(eventData), &streamEvent); err != nil {
@@ -239,7 +478,10 @@ func handleClaudeStreamingResponse(req *http.Request, callback func(string)) (st
 				// Process the event based on its type
 				switch eventType {
 				case EventMessageStart:
-					// Message started, nothing to do yet
+					if streamEvent.Message != nil && streamEvent.Message.Usage != nil {
+						usage.InputTokens = streamEvent.Message.Usage.InputTokens
+					}
+					emit(Event{Kind: EventKindStart})
 
 				case EventContentBlockStart:
 					// Content block started, nothing to do yet
@@ -253,19 +495,24 @@ func handleClaudeStreamingResponse(req *http.Request, callback func(string)) (st
 					}
 
 				case EventContentBlockDelta:
-					// Check if this is a text delta
-					if streamEvent.Delta != nil && streamEvent.Delta.Type == "text_delta" {
-						text := streamEvent.Delta.Text
-						if text != "" {
-							// Send the text delta to the channel
-							contentChan <- text
-
-							// Call the callback function with the new content
-							if callback != nil {
-								callback(text)
-							}
+					// Text deltas carry the explanation, and input_json_delta carries
+					// pieces of the tool input in structured mode
+					var text string
+					if streamEvent.Delta != nil {
+						switch streamEvent.Delta.Type {
+						case "text_delta":
+							text = streamEvent.Delta.Text
+						case "input_json_delta":
+							text = streamEvent.Delta.PartialJSON
 						}
 					}
+					if text != "" {
+						// Send the text delta to the channel
+						contentChan <- text
+
+						// Report the new content
+						emit(Event{Kind: EventKindText, Text: text})
+					}
 
 				case EventContentBlockStop:
 					// Content block stopped, nothing to do
@@ -273,19 +520,27 @@ func handleClaudeStreamingResponse(req *http.Request, callback func(string)) (st
 				case EventMessageDelta:
 					// Message delta received, check if it has a stop reason
 					if streamEvent.Delta != nil && streamEvent.Delta.StopReason != nil {
-						// The message is complete
+						stopReason = *streamEvent.Delta.StopReason
+					}
+					if streamEvent.Usage != nil {
+						usage.OutputTokens = streamEvent.Usage.OutputTokens
 					}
 
 				case EventMessageStop:
 					// Message stopped, close the channel
+					emit(Event{Kind: EventKindUsage, Usage: usage})
+					emit(Event{Kind: EventKindStop, StopReason: stopReason})
 					close(contentChan)
 					return
 				}
 			}
 		}
 
+		// The stream ended without message_stop, so the response is cut short
 		if err := scanner.Err(); err != nil {
-			errChan <- fmt.Errorf("error reading stream: %w", err)
+			errChan <- fmt.Errorf("error reading stream: %w: %w", ErrIncompleteStream, err)
+		} else {
+			errChan <- fmt.Errorf("Claude API: %w", ErrIncompleteStream)
 		}
 	}()
 
@@ -306,30 +561,70 @@ func handleClaudeStreamingResponse(req *http.Request, callback func(string)) (st
 }
 
 // handleClaudeNonStreamingResponse processes a non-streaming response from Claude API
-func handleClaudeNonStreamingResponse(req *http.Request) (string, error) {
+func handleClaudeNonStreamingResponse(req *http.Request, emit func(Event)) (string, error) {
+	// Let gateways compress the response, decodeBody unpacks it
+	req.Header.Set("Accept-Encoding", "gzip")
+
 	// Send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("error sending request to Claude API: %w", err)
 	}
 	defer resp.Body.Close()
 
+	// Proxies and gateways may gzip the response even when it was not asked for
+	body, err := decodeBody(resp)
+	if err != nil {
+		return "", err
+	}
+
 	// Check for non-200 status code
 	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("Claude API returned non-200 status code: %d, body: %s", resp.StatusCode, string(respBody))
+		respBody, _ := io.ReadAll(body)
+		return "", &APIError{Provider: "Claude", StatusCode: resp.StatusCode, Body: string(respBody)}
 	}
 
 	// Parse the response
 	var claudeResp ClaudeResponse
-	if err := json.NewDecoder(resp.Body).Decode(&claudeResp); err != nil {
+	if err := json.NewDecoder(body).Decode(&claudeResp); err != nil {
 		return "", fmt.Errorf("error decoding Claude API response: %w", err)
 	}
 
-	// Extract the text from the response
-	if len(claudeResp.Content) > 0 && claudeResp.Content[0].Type == "text" {
-		return claudeResp.Content[0].Text, nil
+	text, err := claudeResponseText(claudeResp)
+	if err != nil {
+		return "", err
+	}
+
+	// Report the whole response at once
+	emit(Event{Kind: EventKindStart})
+	emit(Event{Kind: EventKindText, Text: text})
+	if claudeResp.Usage != nil {
+		emit(Event{Kind: EventKindUsage, Usage: *claudeResp.Usage})
+	}
+	emit(Event{Kind: EventKindStop, StopReason: claudeResp.StopReason})
+	return text, nil
+}
+
+// claudeResponseText extracts the explanation from a complete Claude API response
+func claudeResponseText(claudeResp ClaudeResponse) (string, error) {
+	// Return the tool input as JSON in structured mode
+	for _, block := range claudeResp.Content {
+		if block.Type == "tool_use" && block.Name == ExplanationToolName {
+			return string(block.Input), nil
+		}
+	}
+
+	// Join the text blocks in order, skipping others such as thinking
+	var text strings.Builder
+	found := false
+	for _, block := range claudeResp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+			found = true
+		}
+	}
+	if found {
+		return text.String(), nil
 	}
 
 	return "", fmt.Errorf("no text content found in Claude API response")
@@ -342,6 +637,8 @@ type AzureOpenAIRequest struct {
 	TopP        float64              `json:"top_p"`
 	MaxTokens   int                  `json:"max_tokens"`
 	Stream      bool                 `json:"stream"`
+	Seed        *int                 `json:"seed,omitempty"`
+	Stop        []string             `json:"stop,omitempty"`
 }
 
 // AzureOpenAIMessage represents a message in the Azure OpenAI API request
@@ -357,6 +654,7 @@ type AzureOpenAIResponse struct {
 	Created int64                     `json:"created"`
 	Model   string                    `json:"model"`
 	Choices []AzureOpenAIResponseChoice `json:"choices"`
+	Usage   *AzureOpenAIUsage           `json:"usage,omitempty"`
 }
 
 // AzureOpenAIResponseChoice represents a choice in the Azure OpenAI API response
@@ -373,6 +671,7 @@ type AzureOpenAIStreamResponse struct {
 	Created int64                     `json:"created"`
 	Model   string                    `json:"model"`
 	Choices []AzureOpenAIStreamChoice `json:"choices"`
+	Usage   *AzureOpenAIUsage         `json:"usage,omitempty"`
 }
 
 // AzureOpenAIStreamChoice represents a choice in a streaming response
@@ -382,26 +681,54 @@ type AzureOpenAIStreamChoice struct {
 	FinishReason string              `json:"finish_reason"`
 }
 
+// AzureOpenAIUsage holds the token counts reported by Azure OpenAI
+type AzureOpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// usage converts the token counts to the provider independent form
+func (u AzureOpenAIUsage) usage() Usage {
+	return Usage{InputTokens: u.PromptTokens, OutputTokens: u.CompletionTokens}
+}
+
 // AzureOpenAIDelta represents the delta in a streaming response
 type AzureOpenAIDelta struct {
 	Role    string `json:"role,omitempty"`
 	Content string `json:"content,omitempty"`
 }
 
-// callAzureOpenAI sends the prompt to Azure OpenAI API and returns the response
-func callAzureOpenAI(prompt string, cfg *config.Config, callback func(string)) (string, error) {
+// callAzureOpenAI sends the conversation to Azure OpenAI API and returns the response
+func callAzureOpenAI(ctx context.Context, messages []Message, cfg *config.Config, emit func(Event)) (string, error) {
+	// Refuse a runaway prompt before building a request the API would reject
+	if err := checkRequestSize(messages, cfg); err != nil {
+		return "", err
+	}
+
+	// Chat completions answer the last message instead of continuing it, so
+	// an unfinished assistant message needs an explicit request to go on
+	var azureMessages []AzureOpenAIMessage
+	for _, message := range messages {
+		azureMessages = append(azureMessages, AzureOpenAIMessage{Role: message.Role, Content: message.Content})
+	}
+	if len(messages) > 0 && messages[len(messages)-1].Role == "assistant" {
+		azureMessages = append(azureMessages, AzureOpenAIMessage{Role: "user", Content: continueInstruction})
+	}
+
 	// Create the request for Azure OpenAI
 	request := AzureOpenAIRequest{
-		Messages: []AzureOpenAIMessage{
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
+		Messages:    azureMessages,
 		Temperature: 0.7,
 		TopP:        0.95,
-		MaxTokens:   4000,
+		MaxTokens:   MaxOutputTokens,
 		Stream:      cfg.Streaming,
+		Stop:        stopSequences(cfg),
+	}
+
+	// A fixed seed only makes the output repeatable without sampling randomness
+	if cfg.Seed != nil {
+		request.Seed = cfg.Seed
+		request.Temperature = 0
 	}
 
 	// Convert request to JSON
@@ -410,32 +737,31 @@ func callAzureOpenAI(prompt string, cfg *config.Config, callback func(string)) (
 		return "", fmt.Errorf("error marshalling request: %w", err)
 	}
 
-	// Create the URL for Azure OpenAI API
-	url := fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s", 
-		cfg.AzureOpenAIEndpoint, 
-		AzureOpenAIModel, 
-		AzureOpenAIAPIVersion)
-
 	// Create HTTP request
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", azureChatURL(cfg), bytes.NewBuffer(requestBody))
 	if err != nil {
 		return "", fmt.Errorf("error creating HTTP request: %w", err)
 	}
 
 	// Set headers
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("api-key", cfg.AzureOpenAIKey)
+	if err := setAzureAuth(ctx, req, cfg); err != nil {
+		return "", err
+	}
 
 	// Handle streaming vs non-streaming
 	if cfg.Streaming {
-		return handleAzureOpenAIStreamingResponse(req, callback)
+		return handleAzureOpenAIStreamingResponse(req, emit)
 	} else {
-		return handleAzureOpenAINonStreamingResponse(req)
+		return handleAzureOpenAINonStreamingResponse(req, emit)
 	}
 }
 
 // handleAzureOpenAIStreamingResponse processes a streaming response from Azure OpenAI API
-func handleAzureOpenAIStreamingResponse(req *http.Request, callback func(string)) (string, error) {
+func handleAzureOpenAIStreamingResponse(req *http.Request, emit func(Event)) (string, error) {
+	// Ask for an uncompressed stream so chunks arrive as soon as they are sent
+	req.Header.Set("Accept-Encoding", "identity")
+
 	// Add streaming header
 	req.Header.Set("Accept", "text/event-stream")
 
@@ -446,23 +772,30 @@ func handleAzureOpenAIStreamingResponse(req *http.Request, callback func(string)
 	// Start a goroutine to process the streaming response
 	go func() {
 		// Send the request
-		client := &http.Client{}
-		resp, err := client.Do(req)
+		resp, err := httpClient.Do(req)
 		if err != nil {
 			errChan <- fmt.Errorf("error sending request to Azure OpenAI API: %w", err)
 			return
 		}
 		defer resp.Body.Close()
 
+		// Proxies and gateways may gzip the response even when it was not asked for
+		body, err := decodeBody(resp)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
 		// Check for non-200 status code
 		if resp.StatusCode != http.StatusOK {
-			respBody, _ := io.ReadAll(resp.Body)
-			errChan <- fmt.Errorf("Azure OpenAI API returned non-200 status code: %d, body: %s", resp.StatusCode, string(respBody))
+			respBody, _ := io.ReadAll(body)
+			errChan <- &APIError{Provider: "Azure OpenAI", StatusCode: resp.StatusCode, Body: string(respBody)}
 			return
 		}
 
 		// Create a scanner to read the SSE stream line by line
-		scanner := bufio.NewScanner(resp.Body)
+		scanner := bufio.NewScanner(body)
+		started := false
 
 		for scanner.Scan() {
 			line := scanner.Text()
@@ -478,6 +811,7 @@ func handleAzureOpenAIStreamingResponse(req *http.Request, callback func(string)
 
 				// Check for [DONE] message
 				if data == "[DONE]" {
+					emit(Event{Kind: EventKindStop})
 					close(contentChan)
 					return
 				}
@@ -489,20 +823,28 @@ func handleAzureOpenAIStreamingResponse(req *http.Request, callback func(string)
 					return
 				}
 
+				// The first chunk marks the start of the response
+				if !started {
+					started = true
+					emit(Event{Kind: EventKindStart})
+				}
+				if streamResp.Usage != nil {
+					emit(Event{Kind: EventKindUsage, Usage: streamResp.Usage.usage()})
+				}
+
 				// Process the choices
 				for _, choice := range streamResp.Choices {
 					if choice.Delta.Content != "" {
 						// Send the content delta to the channel
 						contentChan <- choice.Delta.Content
 
-						// Call the callback function with the new content
-						if callback != nil {
-							callback(choice.Delta.Content)
-						}
+						// Report the new content
+						emit(Event{Kind: EventKindText, Text: choice.Delta.Content})
 					}
 
 					// Check if we're done
 					if choice.FinishReason != "" {
+						emit(Event{Kind: EventKindStop, StopReason: choice.FinishReason})
 						close(contentChan)
 						return
 					}
@@ -510,8 +852,11 @@ func handleAzureOpenAIStreamingResponse(req *http.Request, callback func(string)
 			}
 		}
 
+		// The stream ended without [DONE] or a finish reason, so the response is cut short
 		if err := scanner.Err(); err != nil {
-			errChan <- fmt.Errorf("error reading stream: %w", err)
+			errChan <- fmt.Errorf("error reading stream: %w: %w", ErrIncompleteStream, err)
+		} else {
+			errChan <- fmt.Errorf("Azure OpenAI API: %w", ErrIncompleteStream)
 		}
 	}()
 
@@ -532,30 +877,45 @@ func handleAzureOpenAIStreamingResponse(req *http.Request, callback func(string)
 }
 
 // handleAzureOpenAINonStreamingResponse processes a non-streaming response from Azure OpenAI API
-func handleAzureOpenAINonStreamingResponse(req *http.Request) (string, error) {
+func handleAzureOpenAINonStreamingResponse(req *http.Request, emit func(Event)) (string, error) {
+	// Let gateways compress the response, decodeBody unpacks it
+	req.Header.Set("Accept-Encoding", "gzip")
+
 	// Send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("error sending request to Azure OpenAI API: %w", err)
 	}
 	defer resp.Body.Close()
 
+	// Proxies and gateways may gzip the response even when it was not asked for
+	body, err := decodeBody(resp)
+	if err != nil {
+		return "", err
+	}
+
 	// Check for non-200 status code
 	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("Azure OpenAI API returned non-200 status code: %d, body: %s", resp.StatusCode, string(respBody))
+		respBody, _ := io.ReadAll(body)
+		return "", &APIError{Provider: "Azure OpenAI", StatusCode: resp.StatusCode, Body: string(respBody)}
 	}
 
 	// Parse the response
 	var azureResp AzureOpenAIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&azureResp); err != nil {
+	if err := json.NewDecoder(body).Decode(&azureResp); err != nil {
 		return "", fmt.Errorf("error decoding Azure OpenAI API response: %w", err)
 	}
 
 	// Extract the text from the response
 	if len(azureResp.Choices) > 0 {
-		return azureResp.Choices[0].Message.Content, nil
+		choice := azureResp.Choices[0]
+		emit(Event{Kind: EventKindStart})
+		emit(Event{Kind: EventKindText, Text: choice.Message.Content})
+		if azureResp.Usage != nil {
+			emit(Event{Kind: EventKindUsage, Usage: azureResp.Usage.usage()})
+		}
+		emit(Event{Kind: EventKindStop, StopReason: choice.FinishReason})
+		return choice.Message.Content, nil
 	}
 
 	return "", fmt.Errorf("no content found in Azure OpenAI API response")
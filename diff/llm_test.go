@@ -0,0 +1,110 @@
+package diff
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tydin/difx/config"
+)
+
+// serveGzip starts a test server answering every request with the body gzip
+// compressed, whatever the request's Accept-Encoding says
+func serveGzip(t *testing.T, body string) *http.Request {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte(body))
+	zw.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(compressed.Bytes())
+	}))
+	t.Cleanup(server.Close)
+
+	req, err := http.NewRequest("POST", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestGzipNonStreamingResponse(t *testing.T) {
+	body := `{"content":[{"type":"text","text":"compressed"}],"stop_reason":"end_turn"}`
+
+	got, err := handleClaudeNonStreamingResponse(serveGzip(t, body), func(Event) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "compressed" {
+		t.Errorf("response = %q, want %q", got, "compressed")
+	}
+}
+
+func TestGzipStreamingResponse(t *testing.T) {
+	got, err := handleClaudeStreamingResponse(serveGzip(t, claudeStream), func(Event) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Hello world" {
+		t.Errorf("response = %q, want %q", got, "Hello world")
+	}
+
+	got, err = handleAzureOpenAIStreamingResponse(serveGzip(t, azureStream), func(Event) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Hi" {
+		t.Errorf("response = %q, want %q", got, "Hi")
+	}
+}
+
+func TestGzipErrorBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		zw := gzip.NewWriter(w)
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(http.StatusBadGateway)
+		zw.Write([]byte("gateway down"))
+		zw.Close()
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("POST", server.URL, nil)
+	_, err := handleAzureOpenAINonStreamingResponse(req, func(Event) {})
+	if err == nil || !strings.Contains(err.Error(), "gateway down") {
+		t.Errorf("expected the decompressed error body, got %v", err)
+	}
+}
+
+func TestAzureSeed(t *testing.T) {
+	var got AzureOpenAIRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = AzureOpenAIRequest{}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	seed := 42
+	cfg := &config.Config{AzureOpenAIEndpoint: server.URL, Seed: &seed}
+	if _, err := callAzureOpenAI(context.Background(), userPrompt("prompt"), cfg, func(Event) {}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Seed == nil || *got.Seed != 42 || got.Temperature != 0 {
+		t.Errorf("seed = %v, temperature = %v, want 42 and 0", got.Seed, got.Temperature)
+	}
+
+	// Without a seed the request is unchanged
+	cfg.Seed = nil
+	if _, err := callAzureOpenAI(context.Background(), userPrompt("prompt"), cfg, func(Event) {}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Seed != nil || got.Temperature != 0.7 {
+		t.Errorf("seed = %v, temperature = %v, want none and 0.7", got.Seed, got.Temperature)
+	}
+}
@@ -0,0 +1,72 @@
+package diff
+
+import (
+	"fmt"
+	"path"
+)
+
+// lockfileNames are the file names of dependency lockfiles, which package
+// managers generate and which are long but say little
+var lockfileNames = map[string]bool{
+	"go.sum":              true,
+	"go.work.sum":         true,
+	"package-lock.json":   true,
+	"npm-shrinkwrap.json": true,
+	"yarn.lock":           true,
+	"pnpm-lock.yaml":      true,
+	"bun.lock":            true,
+	"deno.lock":           true,
+	"Cargo.lock":          true,
+	"Gemfile.lock":        true,
+	"composer.lock":       true,
+	"poetry.lock":         true,
+	"Pipfile.lock":        true,
+	"uv.lock":             true,
+	"pdm.lock":            true,
+	"mix.lock":            true,
+	"Podfile.lock":        true,
+	"Package.resolved":    true,
+	"pubspec.lock":        true,
+	"packages.lock.json":  true,
+	"gradle.lockfile":     true,
+	"flake.lock":          true,
+}
+
+// IsLockfile tells whether the file is a dependency lockfile, by its name
+func IsLockfile(filePath string) bool {
+	return lockfileNames[path.Base(filePath)]
+}
+
+// SummarizeLockfiles replaces the hunks of every dependency lockfile with a
+// one line summary of how many lines changed. The files keep their headers,
+// so they are still listed. It returns the new diff and the lockfiles that
+// were summarized.
+func SummarizeLockfiles(diffOutput string) (string, []string) {
+	files, err := Parse(diffOutput)
+	if err != nil {
+		return diffOutput, nil
+	}
+
+	var summarized []string
+	for i, file := range files {
+		if !IsLockfile(file.Path()) || len(file.Hunks) == 0 {
+			continue
+		}
+
+		added, deleted := file.Stats()
+		summary := fmt.Sprintf("(dependency lockfile updated: +%d/-%d lines)", added, deleted)
+		first := file.Hunks[0]
+		files[i].Hunks = []Hunk{{
+			Header:   first.Header,
+			OldStart: first.OldStart,
+			NewStart: first.NewStart,
+			Lines:    []Line{{Kind: LineOther, Text: summary}},
+		}}
+		summarized = append(summarized, file.Path())
+	}
+
+	if len(summarized) == 0 {
+		return diffOutput, nil
+	}
+	return Format(files), summarized
+}
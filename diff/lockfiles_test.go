@@ -0,0 +1,66 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+const lockfileDiff = `diff --git a/go.sum b/go.sum
+index 1111111..2222222 100644
+--- a/go.sum
++++ b/go.sum
+@@ -1,3 +1,4 @@
+-example.com/a v1.0.0 h1:old=
++example.com/a v1.1.0 h1:new=
++example.com/b v1.0.0 h1:b=
+ example.com/c v1.0.0 h1:c=
+@@ -10,2 +11,2 @@
+-example.com/x v1.0.0/go.mod h1:old=
++example.com/x v1.1.0/go.mod h1:new=
+diff --git a/web/package.json b/web/package.json
+index 3333333..4444444 100644
+--- a/web/package.json
++++ b/web/package.json
+@@ -1 +1 @@
+-{"version": "1.0.0"}
++{"version": "1.1.0"}
+`
+
+func TestSummarizeLockfiles(t *testing.T) {
+	got, lockfiles := SummarizeLockfiles(lockfileDiff)
+
+	if len(lockfiles) != 1 || lockfiles[0] != "go.sum" {
+		t.Errorf("lockfiles = %v", lockfiles)
+	}
+	if !strings.Contains(got, "@@ -1,3 +1,4 @@\n(dependency lockfile updated: +3/-2 lines)\ndiff --git a/web/package.json") {
+		t.Errorf("go.sum was not summarized:\n%s", got)
+	}
+	if strings.Contains(got, "example.com") {
+		t.Error("lockfile lines were still sent")
+	}
+	// Other files and the file list are unchanged
+	if !strings.HasSuffix(got, "-{\"version\": \"1.0.0\"}\n+{\"version\": \"1.1.0\"}\n") {
+		t.Errorf("package.json was changed:\n%s", got)
+	}
+	if files := GetChangedFiles(got); len(files) != 2 {
+		t.Errorf("changed files = %v", files)
+	}
+
+	plain := lockfileDiff[strings.Index(lockfileDiff, "diff --git a/web"):]
+	if got, lockfiles := SummarizeLockfiles(plain); got != plain || lockfiles != nil {
+		t.Error("a diff without lockfiles was changed")
+	}
+}
+
+func TestIsLockfile(t *testing.T) {
+	for _, path := range []string{"go.sum", "web/package-lock.json", "Cargo.lock", "ios/Podfile.lock"} {
+		if !IsLockfile(path) {
+			t.Errorf("%s is not recognized", path)
+		}
+	}
+	for _, path := range []string{"go.mod", "package.json", "docs/yarn.lock.md"} {
+		if IsLockfile(path) {
+			t.Errorf("%s is taken for a lockfile", path)
+		}
+	}
+}
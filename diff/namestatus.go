@@ -0,0 +1,88 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NameStatus is a changed file as git diff --name-status lists it
+type NameStatus struct {
+	Status FileStatus
+	// OldPath is the path before a rename or copy, and empty otherwise
+	OldPath string
+	Path    string
+	// Similarity is the percentage of a renamed or copied file that is unchanged
+	Similarity int
+}
+
+// nameStatuses maps the status letters of --name-status to file statuses.
+// A type change, such as a file replaced by a symlink, counts as modified.
+var nameStatuses = map[byte]FileStatus{
+	'A': StatusAdded,
+	'M': StatusModified,
+	'T': StatusModified,
+	'D': StatusDeleted,
+	'R': StatusRenamed,
+	'C': StatusCopied,
+}
+
+// ParseNameStatus reads git diff --name-status output, one file per line:
+// a status letter, with a similarity score for renames and copies, then the
+// tab-separated paths. Quoted paths, as git writes unusual names, are unquoted.
+func ParseNameStatus(output string) ([]NameStatus, error) {
+	var files []NameStatus
+	for i, line := range strings.Split(NormalizeLineEndings(output), "\n") {
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		code := fields[0]
+		// An empty status field has no letter to look up
+		var status FileStatus
+		ok := false
+		if code != "" {
+			status, ok = nameStatuses[code[0]]
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown status %q on line %d", code, i+1)
+		}
+
+		paths := fields[1:]
+		for j, path := range paths {
+			if strings.HasPrefix(path, `"`) {
+				if unquoted, err := strconv.Unquote(path); err == nil {
+					paths[j] = unquoted
+				}
+			}
+		}
+
+		// Renames and copies have the old and the new path, the others one
+		file := NameStatus{Status: status}
+		twoPaths := status == StatusRenamed || status == StatusCopied
+		switch {
+		case twoPaths && len(paths) == 2:
+			file.OldPath, file.Path = paths[0], paths[1]
+			file.Similarity, _ = strconv.Atoi(code[1:])
+		case !twoPaths && len(paths) == 1:
+			file.Path = paths[0]
+		default:
+			return nil, fmt.Errorf("unexpected paths for status %q on line %d: %q", code, i+1, line)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// TreeDiff lists the files that differ between two commits, branches or
+// trees, with renames detected. Neither is taken for an option, even when it
+// starts with a dash.
+func TreeDiff(ctx context.Context, a, b string) ([]NameStatus, error) {
+	output, err := RunGitDiff(ctx, []string{"--name-status", "-M", "--end-of-options", a, b})
+	if err != nil {
+		return nil, err
+	}
+	return ParseNameStatus(output)
+}
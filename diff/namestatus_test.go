@@ -0,0 +1,57 @@
+package diff
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseNameStatus(t *testing.T) {
+	output := "A\tdocs/new.md\r\nM\tmain.go\nT\tbin/tool\nD\told.go\nR095\tpkg/a.go\tpkg/b.go\nC100\tLICENSE\tthird_party/LICENSE\nM\t\"caf\\303\\251.txt\"\n"
+	files, err := ParseNameStatus(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []NameStatus{
+		{Status: StatusAdded, Path: "docs/new.md"},
+		{Status: StatusModified, Path: "main.go"},
+		{Status: StatusModified, Path: "bin/tool"},
+		{Status: StatusDeleted, Path: "old.go"},
+		{Status: StatusRenamed, OldPath: "pkg/a.go", Path: "pkg/b.go", Similarity: 95},
+		{Status: StatusCopied, OldPath: "LICENSE", Path: "third_party/LICENSE", Similarity: 100},
+		{Status: StatusModified, Path: "café.txt"},
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("ParseNameStatus =\n%+v\nwant\n%+v", files, want)
+	}
+
+	for _, bad := range []string{"X\tfile", "R100\tonly-one", "M\ta\tb", "\tfile"} {
+		if _, err := ParseNameStatus(bad); err == nil {
+			t.Errorf("ParseNameStatus(%q) didn't fail", bad)
+		}
+	}
+}
+
+func TestTreeDiff(t *testing.T) {
+	calls := fakeGit(t, "M\tmain.go\n", nil)
+
+	files, err := TreeDiff(context.Background(), "main", "feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Path != "main.go" {
+		t.Errorf("files = %+v", files)
+	}
+	if want := []string{"git", "diff", "--name-status", "-M", "--end-of-options", "main", "feature"}; !reflect.DeepEqual((*calls)[0], want) {
+		t.Errorf("git args = %q, want %q", (*calls)[0], want)
+	}
+
+	// Refs that look like options are passed on as they are
+	if _, err := TreeDiff(context.Background(), "--output=x", "--staged"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"git", "diff", "--name-status", "-M", "--end-of-options", "--output=x", "--staged"}; !reflect.DeepEqual((*calls)[1], want) {
+		t.Errorf("git args = %q, want %q", (*calls)[1], want)
+	}
+}
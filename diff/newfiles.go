@@ -0,0 +1,52 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// skippedOrder is the order of the counts in SkippedSummary
+var skippedOrder = []FileStatus{StatusModified, StatusDeleted, StatusRenamed, StatusCopied}
+
+// OnlyNewFiles keeps the files the change adds and drops the others. It
+// returns the new diff and how many files of each other status were dropped.
+func OnlyNewFiles(diffOutput string) (string, map[FileStatus]int, error) {
+	files, err := Parse(diffOutput)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var kept []FileDiff
+	skipped := map[FileStatus]int{}
+	for _, file := range files {
+		if file.Status == StatusAdded {
+			kept = append(kept, file)
+		} else {
+			skipped[file.Status]++
+		}
+	}
+	return Format(kept), skipped, nil
+}
+
+// SkippedSummary describes the counts of OnlyNewFiles, such as
+// "2 modified, 1 deleted", and returns "" when nothing was dropped
+func SkippedSummary(skipped map[FileStatus]int) string {
+	var parts []string
+	for _, status := range skippedOrder {
+		if skipped[status] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", skipped[status], status))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// newFilesInstruction tells the model that the diff was narrowed to the
+// added files, so it explains what they are for instead of looking for the
+// rest of the change
+func newFilesInstruction(newFilesOnly bool) string {
+	if !newFilesOnly {
+		return ""
+	}
+	return "Only the files this change adds are shown; the files it modifies or deletes were left out on purpose. " +
+		"Explain what each new file is for and how the new files fit together, and don't guess at changes that aren't shown.\n\n"
+}
@@ -0,0 +1,36 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tydin/difx/config"
+)
+
+func TestOnlyNewFiles(t *testing.T) {
+	added, skipped, err := OnlyNewFiles(readFixture(t, "git.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files := GetChangedFiles(added); len(files) != 1 || files[0] != "added.txt" {
+		t.Errorf("kept %v, want only added.txt", files)
+	}
+	if got := SkippedSummary(skipped); got != "4 modified, 1 deleted, 1 renamed" {
+		t.Errorf("SkippedSummary = %q", got)
+	}
+
+	// Nothing is new in a diff of changes only
+	if added, _, err := OnlyNewFiles(readFixture(t, "dashes.diff")); err != nil || added != "" {
+		t.Errorf("OnlyNewFiles = %q, %v", added, err)
+	}
+}
+
+func TestPromptNewFilesOnly(t *testing.T) {
+	if prompt := buildPrompt(sampleDiff, &config.Config{NewFilesOnly: true}); !strings.Contains(prompt, "Only the files this change adds are shown") {
+		t.Error("prompt doesn't say the diff only has new files")
+	}
+	if prompt := buildPrompt(sampleDiff, &config.Config{}); strings.Contains(prompt, "this change adds") {
+		t.Error("prompt mentions new files without --new-files-only")
+	}
+}
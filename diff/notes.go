@@ -0,0 +1,94 @@
+package diff
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// ErrNotSingleCommit is returned by NoteCommit when the arguments don't
+// select the changes of exactly one commit
+var ErrNotSingleCommit = errors.New("the arguments don't select a single commit (use <commit>^! or <commit>~..<commit>)")
+
+// runGit runs git with the given arguments and input, returning its output.
+// name is used in the error, which includes git's stderr.
+func runGit(ctx context.Context, name string, stdin string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := runCommand(cmd); err != nil {
+		if stderr.Len() > 0 {
+			return "", fmt.Errorf("%s error: %s\n%s", name, err, stderr.String())
+		}
+		return "", fmt.Errorf("%s error: %s", name, err)
+	}
+	return stdout.String(), nil
+}
+
+// NoteCommit returns the full hash of the commit whose changes the git diff
+// arguments select, such as <commit>^! or <commit>~..<commit>. Paths after --
+// are ignored.
+func NoteCommit(ctx context.Context, args []string) (string, error) {
+	if i := slices.Index(args, "--"); i >= 0 {
+		args = args[:i]
+	}
+	if len(args) == 0 {
+		return "", ErrNotSingleCommit
+	}
+
+	output, err := runGit(ctx, "git rev-parse", "", append([]string{"rev-parse", "--revs-only"}, args...)...)
+	if err != nil {
+		return "", err
+	}
+
+	// A single commit is one revision with its parents excluded
+	var commits, excluded []string
+	for _, rev := range strings.Fields(output) {
+		if parent, ok := strings.CutPrefix(rev, "^"); ok {
+			excluded = append(excluded, parent)
+		} else {
+			commits = append(commits, rev)
+		}
+	}
+	if len(commits) != 1 || len(excluded) == 0 {
+		return "", ErrNotSingleCommit
+	}
+
+	output, err = runGit(ctx, "git rev-parse", "", "rev-parse", commits[0]+"^@")
+	if err != nil {
+		return "", err
+	}
+	parents := strings.Fields(output)
+	slices.Sort(parents)
+	slices.Sort(excluded)
+	if !slices.Equal(parents, excluded) {
+		return "", ErrNotSingleCommit
+	}
+
+	return commits[0], nil
+}
+
+// HasNote tells whether the commit already has a git note
+func HasNote(ctx context.Context, commit string) bool {
+	// git notes list exits with an error when there is no note
+	_, err := runGit(ctx, "git notes", "", "notes", "list", commit)
+	return err == nil
+}
+
+// AddNote attaches text to the commit as a git note. An existing note is
+// only replaced when overwrite is set; otherwise git refuses.
+func AddNote(ctx context.Context, commit string, text string, overwrite bool) error {
+	args := []string{"notes", "add", "-F", "-"}
+	if overwrite {
+		args = append(args, "-f")
+	}
+	_, err := runGit(ctx, "git notes", text, append(args, commit)...)
+	return err
+}
@@ -0,0 +1,95 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// fakeGitSequence replaces runCommand, answering each command with the next
+// output in turn and recording the argv and stdin of each
+func fakeGitSequence(t *testing.T, outputs ...string) (*[][]string, *[]string) {
+	t.Helper()
+
+	var calls [][]string
+	var inputs []string
+	original := runCommand
+	runCommand = func(cmd *exec.Cmd) error {
+		calls = append(calls, cmd.Args)
+		input, _ := io.ReadAll(cmd.Stdin)
+		inputs = append(inputs, string(input))
+		if len(outputs) == 0 {
+			return errors.New("unexpected command")
+		}
+		cmd.Stdout.Write([]byte(outputs[0]))
+		outputs = outputs[1:]
+		return nil
+	}
+	t.Cleanup(func() { runCommand = original })
+
+	return &calls, &inputs
+}
+
+func TestNoteCommit(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		outputs []string
+		want    string
+		wantErr bool
+	}{
+		{name: "commit and its parent", args: []string{"abc^!"}, outputs: []string{"abc\n^p1\n", "p1\n"}, want: "abc"},
+		{name: "merge", args: []string{"abc^!", "--", "cmd/"}, outputs: []string{"abc\n^p1\n^p2\n", "p2\np1\n"}, want: "abc"},
+		{name: "longer range", args: []string{"main..feature"}, outputs: []string{"feature\n^main\n", "p1\n"}, wantErr: true},
+		{name: "working tree", args: []string{"HEAD"}, outputs: []string{"abc\n"}, wantErr: true},
+		{name: "two commits", args: []string{"a", "b"}, outputs: []string{"a\nb\n"}, wantErr: true},
+		{name: "no arguments", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, _ := fakeGitSequence(t, tt.outputs...)
+
+			got, err := NoteCommit(context.Background(), tt.args)
+			if tt.wantErr {
+				if !errors.Is(err, ErrNotSingleCommit) {
+					t.Errorf("expected ErrNotSingleCommit, got %q, %v", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("commit = %q, want %q", got, tt.want)
+			}
+			if argv := strings.Join((*calls)[0], " "); strings.Contains(argv, "cmd/") {
+				t.Errorf("paths were passed to rev-parse: %s", argv)
+			}
+		})
+	}
+}
+
+func TestAddNote(t *testing.T) {
+	calls, inputs := fakeGitSequence(t, "", "")
+
+	if err := AddNote(context.Background(), "abc", "explanation", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddNote(context.Background(), "abc", "explanation", true); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"git notes add -F - abc", "git notes add -F - -f abc"}
+	for i, w := range want {
+		if got := strings.Join((*calls)[i], " "); got != w {
+			t.Errorf("call %d = %q, want %q", i, got, w)
+		}
+		if (*inputs)[i] != "explanation" {
+			t.Errorf("call %d stdin = %q, want the explanation", i, (*inputs)[i])
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// declarationRegex matches the start of a function, method or type
+// declaration in common languages and captures its keyword and name
+var declarationRegex = regexp.MustCompile(`^\s*(?:export\s+)?(?:pub(?:\([^)]*\))?\s+)?(?:async\s+)?(func|def|function|class|fn|type|interface|struct|enum)\s+(?:\([^)]*\)\s*)?([A-Za-z_][A-Za-z0-9_]*)`)
+
+// fileSummary is the heuristic description of one changed file
+type fileSummary struct {
+	path       string
+	status     string
+	binary     bool
+	insertions int
+	deletions  int
+
+	// context lists the enclosing declarations from the hunk headers
+	context []string
+	// hunks counts the hunks of each class, like "2 modification"
+	hunks []string
+	// added, removed and changed are declarations, by keyword and name
+	added   []string
+	removed []string
+	changed []string
+}
+
+// summarizeFile describes a file from its hunks without any model
+func summarizeFile(file FileDiff) fileSummary {
+	s := fileSummary{path: file.Path(), status: string(file.Status), binary: file.Binary}
+	s.insertions, s.deletions = file.Stats()
+	if file.Status == StatusRenamed || file.Status == StatusCopied {
+		s.path = file.OldPath + " -> " + file.NewPath
+	}
+
+	classes := make(map[HunkClass]int)
+	var order []HunkClass
+	var added, removed []string
+	for _, hunk := range file.Hunks {
+		class := Classify(hunk, file.Path())
+		if classes[class] == 0 {
+			order = append(order, class)
+		}
+		classes[class]++
+
+		// git puts the enclosing function after the second @@
+		if parts := strings.SplitN(hunk.Header, "@@", 3); len(parts) == 3 {
+			if context := strings.TrimSpace(parts[2]); context != "" {
+				s.context = appendUnique(s.context, context)
+			}
+		}
+
+		for _, line := range hunk.Lines {
+			matches := declarationRegex.FindStringSubmatch(line.Text)
+			if matches == nil {
+				continue
+			}
+			declaration := matches[1] + " " + matches[2]
+			switch line.Kind {
+			case LineAdded:
+				added = appendUnique(added, declaration)
+			case LineDeleted:
+				removed = appendUnique(removed, declaration)
+			}
+		}
+	}
+
+	for _, class := range order {
+		s.hunks = append(s.hunks, fmt.Sprintf("%d %s", classes[class], class))
+	}
+
+	// A declaration on both sides had its signature changed
+	for _, declaration := range added {
+		if slices.Contains(removed, declaration) {
+			s.changed = append(s.changed, declaration)
+		} else {
+			s.added = append(s.added, declaration)
+		}
+	}
+	for _, declaration := range removed {
+		if !slices.Contains(added, declaration) {
+			s.removed = append(s.removed, declaration)
+		}
+	}
+	return s
+}
+
+// description is the one-line account of the file's change
+func (s fileSummary) description() string {
+	if s.binary {
+		return fmt.Sprintf("Binary file %s", s.status)
+	}
+	return fmt.Sprintf("%s%s, %d lines added and %d removed", strings.ToUpper(s.status[:1]), s.status[1:], s.insertions, s.deletions)
+}
+
+// OfflineExplanation describes the diff with fixed rules instead of a model:
+// the changed files, their line counts, and the declarations that were added,
+// removed or changed. The result is deterministic and uses the same layout
+// as a model's explanation, or the structured JSON when structured is set.
+func OfflineExplanation(diffOutput string, structured bool) (string, error) {
+	files, err := Parse(diffOutput)
+	if err != nil {
+		return "", fmt.Errorf("can't describe the diff offline: %w", err)
+	}
+
+	summaries := make([]fileSummary, len(files))
+	insertions, deletions := 0, 0
+	statuses := make(map[string]int)
+	var order []string
+	for i, file := range files {
+		summaries[i] = summarizeFile(file)
+		insertions += summaries[i].insertions
+		deletions += summaries[i].deletions
+		if statuses[summaries[i].status] == 0 {
+			order = append(order, summaries[i].status)
+		}
+		statuses[summaries[i].status]++
+	}
+
+	var counts []string
+	for _, status := range order {
+		counts = append(counts, fmt.Sprintf("%d %s", statuses[status], status))
+	}
+	summary := fmt.Sprintf("%d files changed (%s), described offline without a model", len(files), strings.Join(counts, ", "))
+
+	if structured {
+		return offlineJSON(summary, summaries)
+	}
+
+	var b strings.Builder
+	b.WriteString(Delimiter + "\n")
+	b.WriteString("SUMMARY:\n")
+	fmt.Fprintf(&b, "  - Files modified: %d\n", len(files))
+	fmt.Fprintf(&b, "  - %s\n", summary)
+	fmt.Fprintf(&b, "  - Insertions: %d\n", insertions)
+	fmt.Fprintf(&b, "  - Deletions: %d\n", deletions)
+
+	b.WriteString("\nFILE CHANGES:\n")
+	for _, s := range summaries {
+		fmt.Fprintf(&b, "  %s (%s, \\033[32;1m+%d\\033[0m \\033[31;1m-%d\\033[0m)\n", s.path, s.status, s.insertions, s.deletions)
+	}
+
+	b.WriteString("\nDETAILS:\n")
+	for _, s := range summaries {
+		fmt.Fprintf(&b, "\t%s:\n", s.path)
+		fmt.Fprintf(&b, "\t\t%s\n", s.description())
+		if len(s.hunks) > 0 {
+			fmt.Fprintf(&b, "\t\tHunks: %s\n", strings.Join(s.hunks, ", "))
+		}
+		if len(s.context) > 0 {
+			fmt.Fprintf(&b, "\t\tChanges in: %s\n", strings.Join(s.context, "; "))
+		}
+		for _, declaration := range s.added {
+			fmt.Fprintf(&b, "\t\t\\033[32;1m+ Adds %s\\033[0m\n", declaration)
+		}
+		for _, declaration := range s.removed {
+			fmt.Fprintf(&b, "\t\t\\033[31;1m- Removes %s\\033[0m\n", declaration)
+		}
+		for _, declaration := range s.changed {
+			fmt.Fprintf(&b, "\t\tChanges the signature of %s\n", declaration)
+		}
+	}
+	b.WriteString(Delimiter)
+
+	return b.String(), nil
+}
+
+// offlineJSON returns the summaries in the structured explanation's shape
+func offlineJSON(summary string, summaries []fileSummary) (string, error) {
+	type file struct {
+		Path       string `json:"path"`
+		Insertions int    `json:"insertions"`
+		Deletions  int    `json:"deletions"`
+	}
+	type detail struct {
+		Path      string   `json:"path"`
+		Additions []string `json:"additions"`
+		Deletions []string `json:"deletions"`
+	}
+	doc := struct {
+		Summary string   `json:"summary"`
+		Files   []file   `json:"files"`
+		Details []detail `json:"details"`
+	}{Summary: summary, Files: []file{}, Details: []detail{}}
+
+	for _, s := range summaries {
+		doc.Files = append(doc.Files, file{Path: s.path, Insertions: s.insertions, Deletions: s.deletions})
+
+		d := detail{Path: s.path, Additions: []string{s.description()}, Deletions: []string{}}
+		for _, declaration := range s.added {
+			d.Additions = append(d.Additions, "Adds "+declaration)
+		}
+		for _, declaration := range s.changed {
+			d.Additions = append(d.Additions, "Changes the signature of "+declaration)
+		}
+		for _, declaration := range s.removed {
+			d.Deletions = append(d.Deletions, "Removes "+declaration)
+		}
+		doc.Details = append(doc.Details, d)
+	}
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return "", fmt.Errorf("error encoding the offline explanation: %w", err)
+	}
+	return string(out), nil
+}
+
+// appendUnique appends value unless the list already has it
+func appendUnique(list []string, value string) []string {
+	if slices.Contains(list, value) {
+		return list
+	}
+	return append(list, value)
+}
@@ -0,0 +1,123 @@
+package diff
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const offlineDiff = `diff --git a/server.go b/server.go
+index 1111111..2222222 100644
+--- a/server.go
++++ b/server.go
+@@ -10,7 +10,9 @@ func (s *Server) Start() error {
+-func handle(w http.ResponseWriter) {
++func handle(w http.ResponseWriter, r *http.Request) {
+ 	s.mux.Handle("/", s)
+-func legacy() {}
++func health() {
++}
+diff --git a/README.md b/README.md
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/README.md
+@@ -0,0 +1,2 @@
++# server
++Docs
+`
+
+func TestSummarizeFile(t *testing.T) {
+	files, err := Parse(offlineDiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := summarizeFile(files[0])
+	if s.insertions != 3 || s.deletions != 2 {
+		t.Errorf("stats = +%d -%d, want +3 -2", s.insertions, s.deletions)
+	}
+	if want := []string{"func (s *Server) Start() error {"}; !reflect.DeepEqual(s.context, want) {
+		t.Errorf("context = %q, want %q", s.context, want)
+	}
+	if want := []string{"func health"}; !reflect.DeepEqual(s.added, want) {
+		t.Errorf("added = %q, want %q", s.added, want)
+	}
+	if want := []string{"func legacy"}; !reflect.DeepEqual(s.removed, want) {
+		t.Errorf("removed = %q, want %q", s.removed, want)
+	}
+	if want := []string{"func handle"}; !reflect.DeepEqual(s.changed, want) {
+		t.Errorf("changed = %q, want %q", s.changed, want)
+	}
+}
+
+func TestOfflineExplanation(t *testing.T) {
+	text, err := OfflineExplanation(offlineDiff, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"SUMMARY:",
+		"2 files changed (1 modified, 1 added)",
+		"README.md (added, ",
+		"Added, 2 lines added and 0 removed",
+		"Adds func health",
+		"Removes func legacy",
+		"Changes the signature of func handle",
+		"Hunks: 1 modification",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("explanation is missing %q:\n%s", want, text)
+		}
+	}
+
+	again, _ := OfflineExplanation(offlineDiff, false)
+	if again != text {
+		t.Error("the offline explanation isn't deterministic")
+	}
+}
+
+func TestOfflineExplanationStructured(t *testing.T) {
+	text, err := OfflineExplanation(offlineDiff, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Summary string `json:"summary"`
+		Files   []struct {
+			Path       string `json:"path"`
+			Insertions int    `json:"insertions"`
+			Deletions  int    `json:"deletions"`
+		} `json:"files"`
+		Details []struct {
+			Path      string   `json:"path"`
+			Deletions []string `json:"deletions"`
+		} `json:"details"`
+	}
+	if err := json.Unmarshal([]byte(text), &doc); err != nil {
+		t.Fatalf("invalid JSON %q: %s", text, err)
+	}
+	if len(doc.Files) != 2 || doc.Files[1].Path != "README.md" || doc.Files[1].Insertions != 2 {
+		t.Errorf("files = %+v", doc.Files)
+	}
+	if want := []string{"Removes func legacy"}; !reflect.DeepEqual(doc.Details[0].Deletions, want) {
+		t.Errorf("deletions = %q, want %q", doc.Details[0].Deletions, want)
+	}
+}
+
+func TestOfflineExplanationCRLF(t *testing.T) {
+	text, err := OfflineExplanation(readFixture(t, "crlf.diff"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(text, "\r") {
+		t.Errorf("carriage returns reached the explanation:\n%q", text)
+	}
+	for _, want := range []string{"2 files changed (1 modified, 1 added)", "src/App.cs (modified, ", "docs/Notes.txt (added, "} {
+		if !strings.Contains(text, want) {
+			t.Errorf("explanation is missing %q:\n%s", want, text)
+		}
+	}
+}
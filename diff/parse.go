@@ -0,0 +1,317 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FileStatus tells what happened to a file in a diff
+type FileStatus string
+
+// File statuses, as git reports them in the extended header lines
+const (
+	StatusModified FileStatus = "modified"
+	StatusAdded    FileStatus = "added"
+	StatusDeleted  FileStatus = "deleted"
+	StatusRenamed  FileStatus = "renamed"
+	StatusCopied   FileStatus = "copied"
+)
+
+// devNull is the path git uses for the missing side of an added or deleted file
+const devNull = "/dev/null"
+
+// LineKind is the prefix character that tells what a hunk line is
+type LineKind byte
+
+// Kinds of hunk lines
+const (
+	LineContext   LineKind = ' '
+	LineAdded     LineKind = '+'
+	LineDeleted   LineKind = '-'
+	LineNoNewline LineKind = '\\'
+	// LineOther is a line that isn't part of the diff format, such as a blank
+	// line left by an editor that strips trailing spaces. Its Text is the whole line.
+	LineOther LineKind = 0
+)
+
+// Line is one line of a hunk. Text doesn't include the prefix character.
+type Line struct {
+	Kind LineKind
+	Text string
+}
+
+// String returns the line as it appears in the diff
+func (l Line) String() string {
+	if l.Kind == LineOther {
+		return l.Text
+	}
+	return string(l.Kind) + l.Text
+}
+
+// Hunk is a single @@ section of a file diff. The line counts are -1 when the
+// header doesn't have them, as in combined diffs.
+type Hunk struct {
+	Header   string
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []Line
+}
+
+// FileDiff is the diff of a single file
+type FileDiff struct {
+	OldPath string
+	NewPath string
+	Status  FileStatus
+	Binary  bool
+
+	// Header holds the lines before the first hunk (diff --git, index, ---, +++ ...)
+	Header []string
+	Hunks  []Hunk
+}
+
+// Path returns the path of the file after the change, or before it for deleted files
+func (f FileDiff) Path() string {
+	if f.Status == StatusDeleted || f.NewPath == "" {
+		return f.OldPath
+	}
+	return f.NewPath
+}
+
+// Stats counts the added and deleted lines of the file
+func (f FileDiff) Stats() (added int, deleted int) {
+	for _, hunk := range f.Hunks {
+		for _, line := range hunk.Lines {
+			switch line.Kind {
+			case LineAdded:
+				added++
+			case LineDeleted:
+				deleted++
+			}
+		}
+	}
+	return added, deleted
+}
+
+// String returns the file diff as it appears in diff output, ending with a newline
+func (f FileDiff) String() string {
+	var b strings.Builder
+	for _, line := range f.Header {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	for _, hunk := range f.Hunks {
+		b.WriteString(hunk.Header)
+		b.WriteByte('\n')
+		for _, line := range hunk.Lines {
+			b.WriteString(line.String())
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// Format turns parsed files back into diff output
+func Format(files []FileDiff) string {
+	var b strings.Builder
+	for _, file := range files {
+		b.WriteString(file.String())
+	}
+	return b.String()
+}
+
+// hunkHeaderRegex matches a two-way hunk header and captures its ranges
+var hunkHeaderRegex = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// Parse splits git diff or unified diff output into files and hunks. The
+// result formats back to the same text, apart from blank lines before the
+// first file, a missing final newline, and CRLF line endings, which become LF.
+// The commit message and mail headers that git show and git format-patch put
+// before the first file are skipped, as is the signature of a patch.
+func Parse(diffOutput string) ([]FileDiff, error) {
+	if diffOutput == "" {
+		return nil, nil
+	}
+
+	var files []FileDiff
+	var file *FileDiff
+	var hunk *Hunk
+
+	// Lines left in the current hunk, to tell "--- x" content from a file header
+	oldLeft, newLeft := 0, 0
+
+	// The first line of text before the first file, if there is any
+	preamble := 0
+
+	lines := strings.Split(strings.TrimSuffix(NormalizeLineEndings(diffOutput), "\n"), "\n")
+	for i, line := range lines {
+		if hunk != nil {
+			inRange := oldLeft > 0 || newLeft > 0 || hunk.OldLines < 0
+
+			switch {
+			case !inRange && line == "-- ":
+				// The signature git format-patch ends a patch with
+				return files, nil
+			case strings.HasPrefix(line, `\`):
+				hunk.Lines = append(hunk.Lines, Line{Kind: LineNoNewline, Text: line[1:]})
+				continue
+			case strings.HasPrefix(line, "diff --") || strings.HasPrefix(line, "@@"):
+				// Always the start of something new, whatever the counts say
+			case !inRange && (strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "+++ ")):
+				// A plain unified diff of the next file
+			case line == "":
+				oldLeft, newLeft = oldLeft-1, newLeft-1
+				hunk.Lines = append(hunk.Lines, Line{Kind: LineOther})
+				continue
+			case line[0] == ' ':
+				oldLeft, newLeft = oldLeft-1, newLeft-1
+				hunk.Lines = append(hunk.Lines, Line{Kind: LineContext, Text: line[1:]})
+				continue
+			case line[0] == '-':
+				oldLeft--
+				hunk.Lines = append(hunk.Lines, Line{Kind: LineDeleted, Text: line[1:]})
+				continue
+			case line[0] == '+':
+				newLeft--
+				hunk.Lines = append(hunk.Lines, Line{Kind: LineAdded, Text: line[1:]})
+				continue
+			default:
+				// Keep unknown lines such as placeholders where they are
+				hunk.Lines = append(hunk.Lines, Line{Kind: LineOther, Text: line})
+				continue
+			}
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff --"):
+			files = append(files, FileDiff{Status: StatusModified})
+			file, hunk = &files[len(files)-1], nil
+			file.OldPath, file.NewPath = gitHeaderPaths(line)
+
+		case strings.HasPrefix(line, "--- ") && (hunk != nil || file == nil && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")):
+			// Unified diffs without git's header start straight with ---
+			files = append(files, FileDiff{Status: StatusModified})
+			file, hunk = &files[len(files)-1], nil
+			file.OldPath = headerPath(line[4:], "a/")
+
+		case file == nil:
+			// A commit message or mail headers, or text that isn't a diff
+			if strings.TrimSpace(line) != "" && preamble == 0 {
+				preamble = i + 1
+			}
+			continue
+
+		case strings.HasPrefix(line, "@@"):
+			file.Hunks = append(file.Hunks, parseHunkHeader(line))
+			hunk = &file.Hunks[len(file.Hunks)-1]
+			oldLeft, newLeft = hunk.OldLines, hunk.NewLines
+			continue
+
+		case strings.HasPrefix(line, "--- "):
+			file.OldPath = headerPath(line[4:], "a/")
+
+		case strings.HasPrefix(line, "+++ "):
+			file.NewPath = headerPath(line[4:], "b/")
+
+		case strings.HasPrefix(line, "new file mode"):
+			file.Status = StatusAdded
+		case strings.HasPrefix(line, "deleted file mode"):
+			file.Status = StatusDeleted
+		case strings.HasPrefix(line, "rename from "):
+			file.Status = StatusRenamed
+			file.OldPath = line[len("rename from "):]
+		case strings.HasPrefix(line, "rename to "):
+			file.NewPath = line[len("rename to "):]
+		case strings.HasPrefix(line, "copy from "):
+			file.Status = StatusCopied
+			file.OldPath = line[len("copy from "):]
+		case strings.HasPrefix(line, "copy to "):
+			file.NewPath = line[len("copy to "):]
+		case strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch":
+			file.Binary = true
+		}
+
+		// The missing side of an added or deleted file is /dev/null
+		if file.OldPath == devNull {
+			file.Status, file.OldPath = StatusAdded, ""
+		}
+		if file.NewPath == devNull {
+			file.Status, file.NewPath = StatusDeleted, ""
+		}
+
+		file.Header = append(file.Header, line)
+	}
+
+	if len(files) == 0 && preamble > 0 {
+		return nil, fmt.Errorf("no file diff found, starting with line %d: %q", preamble, lines[preamble-1])
+	}
+	return files, nil
+}
+
+// NormalizeLineEndings turns the CRLF line endings of a diff taken on Windows,
+// or of files checked out with them, into LF, which the parsing expects
+func NormalizeLineEndings(diffOutput string) string {
+	if !strings.Contains(diffOutput, "\r\n") {
+		return diffOutput
+	}
+	return strings.ReplaceAll(diffOutput, "\r\n", "\n")
+}
+
+// parseHunkHeader reads the line ranges of a hunk header
+func parseHunkHeader(header string) Hunk {
+	hunk := Hunk{Header: header, OldLines: -1, NewLines: -1}
+
+	matches := hunkHeaderRegex.FindStringSubmatch(header)
+	if matches == nil {
+		return hunk
+	}
+
+	// An omitted count means a single line
+	count := func(s string) int {
+		if s == "" {
+			return 1
+		}
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	hunk.OldStart, _ = strconv.Atoi(matches[1])
+	hunk.OldLines = count(matches[2])
+	hunk.NewStart, _ = strconv.Atoi(matches[3])
+	hunk.NewLines = count(matches[4])
+	return hunk
+}
+
+// gitHeaderPaths reads the paths from a "diff --git a/old b/new" line. Names
+// containing " b/" are ambiguous there, so the ---/+++ lines, when present,
+// take over later.
+func gitHeaderPaths(line string) (string, string) {
+	rest, ok := strings.CutPrefix(line, "diff --git ")
+	if !ok {
+		return "", ""
+	}
+
+	// Without a rename both names are equal, so split in the middle
+	if n := len(rest); n >= 7 && n%2 == 1 && rest[n/2] == ' ' && strings.HasPrefix(rest, "a/") && rest[n/2+1:n/2+3] == "b/" {
+		if rest[2:n/2] == rest[n/2+3:] {
+			return rest[2 : n/2], rest[n/2+3:]
+		}
+	}
+
+	if i := strings.Index(rest, " b/"); i >= 0 {
+		return strings.TrimPrefix(rest[:i], "a/"), rest[i+3:]
+	}
+	return "", ""
+}
+
+// headerPath reads the path from a ---/+++ line, dropping git's a/ or b/
+// prefix and the timestamp that diff -u appends after a tab
+func headerPath(name string, prefix string) string {
+	name, _, _ = strings.Cut(name, "\t")
+	if name == devNull {
+		return name
+	}
+	return strings.TrimPrefix(name, prefix)
+}
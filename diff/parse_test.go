@@ -0,0 +1,197 @@
+package diff
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readFixture reads a diff from testdata
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	for _, name := range []string{"git.diff", "dashes.diff", "unified.diff"} {
+		t.Run(name, func(t *testing.T) {
+			in := readFixture(t, name)
+			files, err := Parse(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Format(files); got != in {
+				t.Errorf("Format(Parse(diff)) =\n%s\nwant\n%s", got, in)
+			}
+		})
+	}
+}
+
+func TestParseGitFixture(t *testing.T) {
+	files, err := Parse(readFixture(t, "git.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type summary struct {
+		OldPath, NewPath string
+		Status           FileStatus
+		Binary           bool
+		Hunks            int
+	}
+	var got []summary
+	for _, f := range files {
+		got = append(got, summary{f.OldPath, f.NewPath, f.Status, f.Binary, len(f.Hunks)})
+	}
+
+	want := []summary{
+		{"VERSION", "VERSION", StatusModified, false, 1},
+		{"", "added.txt", StatusAdded, false, 1},
+		{"gone.txt", "", StatusDeleted, false, 1},
+		{"logo.png", "logo.png", StatusModified, true, 0},
+		{"main.go", "main.go", StatusModified, false, 1},
+		{"moved.txt", "renamed.txt", StatusRenamed, false, 1},
+		{"run.sh", "run.sh", StatusModified, false, 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%+v\nwant\n%+v", got, want)
+	}
+
+	if paths := GetChangedFiles(readFixture(t, "git.diff")); !reflect.DeepEqual(paths, []string{
+		"VERSION", "added.txt", "gone.txt", "logo.png", "main.go", "renamed.txt", "run.sh",
+	}) {
+		t.Errorf("GetChangedFiles = %q", paths)
+	}
+}
+
+func TestParseHunkLines(t *testing.T) {
+	files, err := Parse(readFixture(t, "git.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// VERSION has a no-newline marker after each side
+	version := files[0].Hunks[0]
+	wantVersion := []Line{
+		{Kind: LineDeleted, Text: "1.0.0"},
+		{Kind: LineNoNewline, Text: " No newline at end of file"},
+		{Kind: LineAdded, Text: "1.1.0"},
+		{Kind: LineNoNewline, Text: " No newline at end of file"},
+	}
+	if !reflect.DeepEqual(version.Lines, wantVersion) {
+		t.Errorf("VERSION lines = %+v", version.Lines)
+	}
+
+	// The renamed file's hunk has a section heading after its ranges
+	renamed := files[5].Hunks[0]
+	if renamed.OldStart != 3 || renamed.OldLines != 4 || renamed.NewStart != 3 || renamed.NewLines != 4 {
+		t.Errorf("renamed.txt ranges = %+v", renamed)
+	}
+	if renamed.Header != "@@ -3,4 +3,4 @@ line2" {
+		t.Errorf("renamed.txt header = %q", renamed.Header)
+	}
+
+	// "// --- not a header" is an added line of main.go
+	main := files[4].Hunks[0]
+	last := main.Lines[len(main.Lines)-2]
+	if last != (Line{Kind: LineAdded, Text: "\t// --- not a header"}) {
+		t.Errorf("main.go line = %+v", last)
+	}
+}
+
+func TestParseDashedContent(t *testing.T) {
+	// Lines like "--- schema" and "+++ new" are content when the hunk isn't done
+	files, err := Parse(readFixture(t, "dashes.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("got %d files, want 1", len(files))
+	}
+
+	want := []Line{
+		{Kind: LineDeleted, Text: "-- schema"},
+		{Kind: LineContext, Text: "CREATE TABLE t (id int);"},
+		{Kind: LineDeleted, Text: "++counter;"},
+		{Kind: LineAdded, Text: "++ new"},
+	}
+	if got := files[0].Hunks[0].Lines; !reflect.DeepEqual(got, want) {
+		t.Errorf("lines = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseUnifiedDiff(t *testing.T) {
+	files, err := Parse(readFixture(t, "unified.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].OldPath != "old/notes.txt" || files[0].NewPath != "new/notes.txt" {
+		t.Errorf("files = %+v", files)
+	}
+
+	// Two unified diffs in a row are two files
+	files, err = Parse(readFixture(t, "unified.diff") + readFixture(t, "unified.diff"))
+	if err != nil || len(files) != 2 {
+		t.Errorf("got %d files, %v, want 2", len(files), err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	if files, err := Parse(""); files != nil || err != nil {
+		t.Errorf("Parse(\"\") = %v, %v", files, err)
+	}
+	if _, err := Parse("not a diff\n"); err == nil {
+		t.Error("expected an error for text that isn't a diff")
+	}
+}
+
+func TestParseFormatPatch(t *testing.T) {
+	// Mail headers, a commit message with a --- line, the diffstat and the
+	// signature surround the diff
+	files, err := Parse(readFixture(t, "format-patch.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Path() != "main.go" {
+		t.Fatalf("got %d files, want main.go", len(files))
+	}
+	if added, deleted := files[0].Stats(); added != 3 || deleted != 0 {
+		t.Errorf("Stats() = +%d -%d, want +3 -0", added, deleted)
+	}
+	if got := Format(files); !strings.HasPrefix(got, "diff --git a/main.go b/main.go\n") || !strings.HasSuffix(got, " }\n") {
+		t.Errorf("Format(Parse(patch)) =\n%s", got)
+	}
+}
+
+func TestParseCRLF(t *testing.T) {
+	in := readFixture(t, "crlf.diff")
+	files, err := Parse(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := Parse(NormalizeLineEndings(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("CRLF diff parsed as\n%+v\nwant\n%+v", files, want)
+	}
+
+	// The "--- old banner" line is a deletion, not the next file's header
+	if len(files) != 2 || files[0].Path() != "src/App.cs" || files[1].Path() != "docs/Notes.txt" || files[1].Status != StatusAdded {
+		t.Fatalf("files = %+v", files)
+	}
+	if added, deleted := files[0].Stats(); added != 1 || deleted != 1 {
+		t.Errorf("src/App.cs stats = +%d -%d, want +1 -1", added, deleted)
+	}
+	if got := Format(files); got != NormalizeLineEndings(in) || strings.Contains(got, "\r") {
+		t.Errorf("Format =\n%q", got)
+	}
+}
@@ -0,0 +1,28 @@
+package diff
+
+import "github.com/tydin/difx/config"
+
+// personas holds the tone-setting preamble of each --persona. The default,
+// neutral tone has none.
+var personas = map[string]string{
+	config.PersonaTeacher:   "Write for a junior developer who is new to this code. Explain why each change was made and any concepts or idioms it relies on, briefly and without talking down.",
+	config.PersonaReviewer:  "Write for an experienced engineer reviewing the change. Be terse, skip what the code makes obvious, and call out risks, edge cases and questionable decisions.",
+	config.PersonaChangelog: "Write for the readers of a changelog. Describe user-visible effects and behavior changes in plain terms, and leave out internal refactoring unless it matters to users.",
+	config.PersonaELI5:      "Explain the changes as simply as you can, for someone with no programming background. Use everyday words and short sentences, and avoid jargon.",
+}
+
+// IsPersona tells whether name is a known persona. The empty name selects
+// the default tone.
+func IsPersona(name string) bool {
+	_, ok := personas[name]
+	return ok || name == ""
+}
+
+// personaPreamble returns the instructions for the persona's tone, or nothing
+// for the default tone
+func personaPreamble(name string) string {
+	if preamble, ok := personas[name]; ok {
+		return preamble + "\n\n"
+	}
+	return ""
+}
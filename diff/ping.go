@@ -0,0 +1,26 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tydin/difx/config"
+)
+
+// pingPrompt asks for the shortest possible answer
+const pingPrompt = "Reply with the single word OK."
+
+// Ping sends a minimal request to the active model to check that its
+// endpoint and credentials work. The reply itself doesn't matter.
+func Ping(ctx context.Context, cfg *config.Config) error {
+	// Nothing is streamed, structured or resumed
+	pingCfg := *cfg
+	pingCfg.Streaming = false
+	pingCfg.Structured = false
+	pingCfg.Offline = false
+
+	if _, err := callModel(ctx, userPrompt(pingPrompt), &pingCfg, func(Event) {}); err != nil {
+		return fmt.Errorf("ping failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package diff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tydin/difx/config"
+)
+
+func TestPing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "good-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`)
+			return
+		}
+
+		// The check is a small, plain request
+		var request ClaudeRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		if request.Stream || len(request.Tools) > 0 {
+			t.Errorf("request = %+v", request)
+		}
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"OK"}],"stop_reason":"end_turn"}`)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{ActiveModel: config.ModelClaude, ClaudeBaseURL: server.URL, ClaudeAPIKey: "good-key", Streaming: true, Structured: true}
+	if err := Ping(context.Background(), cfg); err != nil {
+		t.Errorf("Ping with a good key: %s", err)
+	}
+
+	cfg.ClaudeAPIKey = "expired-key"
+	if err := Ping(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Ping with an expired key = %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultContextFile is where a repository keeps background for the model,
+// relative to its root
+const DefaultContextFile = ".difx/context.md"
+
+// MaxProjectContextBytes caps how much of the context file is sent with each request
+const MaxProjectContextBytes = 16 * 1024
+
+// ReadProjectContext reads the project context file. A relative path is
+// taken from the repository root. Text beyond maxBytes is left out, cut at a
+// line break, and the second return value reports that it was. A missing
+// file is an error that matches os.ErrNotExist.
+func ReadProjectContext(ctx context.Context, path string, maxBytes int) (string, bool, error) {
+	if !filepath.IsAbs(path) {
+		root, err := RepoRoot(ctx)
+		if err != nil {
+			return "", false, err
+		}
+		path = filepath.Join(root, path)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("error reading project context: %w", err)
+	}
+
+	text, truncated := truncateAtLine(string(content), maxBytes)
+	return text, truncated, nil
+}
+
+// truncateAtLine cuts text down to at most maxBytes and trims it. It reports
+// whether anything was left out.
+func truncateAtLine(text string, maxBytes int) (string, bool) {
+	if len(text) <= maxBytes {
+		return strings.TrimSpace(text), false
+	}
+
+	// Keep whole lines, which also keeps multi-byte characters whole
+	text = text[:maxBytes]
+	if i := strings.LastIndex(text, "\n"); i > 0 {
+		text = text[:i]
+	}
+	return strings.TrimSpace(text), true
+}
+
+// projectContextSection adds the repository's own background to the prompt
+func projectContextSection(text string) string {
+	if text == "" {
+		return ""
+	}
+	return "Here is background about this project from its maintainers, such as its architecture and naming conventions. Use it to explain the changes in the project's own terms:\n\n<project-context>\n" + text + "\n</project-context>\n\n"
+}
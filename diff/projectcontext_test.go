@@ -0,0 +1,57 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tydin/difx/config"
+)
+
+func TestReadProjectContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "context.md")
+	if err := os.WriteFile(path, []byte("# Architecture\n\ncmd parses flags.\ndiff talks to git.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	text, truncated, err := ReadProjectContext(context.Background(), path, MaxProjectContextBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if truncated || text != "# Architecture\n\ncmd parses flags.\ndiff talks to git." {
+		t.Errorf("text = %q, truncated = %t", text, truncated)
+	}
+
+	// A cut keeps whole lines
+	text, truncated, err = ReadProjectContext(context.Background(), path, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !truncated || text != "# Architecture\n\ncmd parses flags." {
+		t.Errorf("cut text = %q, truncated = %t", text, truncated)
+	}
+
+	_, _, err = ReadProjectContext(context.Background(), filepath.Join(t.TempDir(), "missing.md"), MaxProjectContextBytes)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file error = %v", err)
+	}
+}
+
+func TestPromptProjectContext(t *testing.T) {
+	for _, cfg := range []*config.Config{
+		{ProjectContext: "Handlers live in cmd/."},
+		{ProjectContext: "Handlers live in cmd/.", Structured: true},
+		{ProjectContext: "Handlers live in cmd/.", Changelog: true},
+	} {
+		if prompt := buildPrompt(sampleDiff, cfg); !strings.Contains(prompt, "<project-context>\nHandlers live in cmd/.\n</project-context>") {
+			t.Errorf("prompt (structured %t, changelog %t) is missing the project context", cfg.Structured, cfg.Changelog)
+		}
+	}
+
+	if prompt := buildPrompt(sampleDiff, &config.Config{}); strings.Contains(prompt, "project-context") {
+		t.Error("prompt has a project context section without a context")
+	}
+}
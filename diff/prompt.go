@@ -0,0 +1,285 @@
+package diff
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tydin/difx/config"
+)
+
+// languageTags maps file extensions to the language hint used on code fences
+var languageTags = map[string]string{
+	".c":     "c",
+	".cpp":   "cpp",
+	".cs":    "csharp",
+	".css":   "css",
+	".go":    "go",
+	".h":     "c",
+	".html":  "html",
+	".java":  "java",
+	".js":    "javascript",
+	".json":  "json",
+	".jsx":   "jsx",
+	".kt":    "kotlin",
+	".md":    "markdown",
+	".php":   "php",
+	".py":    "python",
+	".rb":    "ruby",
+	".rs":    "rust",
+	".sh":    "bash",
+	".sql":   "sql",
+	".swift": "swift",
+	".toml":  "toml",
+	".ts":    "typescript",
+	".tsx":   "tsx",
+	".yaml":  "yaml",
+	".yml":   "yaml",
+}
+
+// buildPrompt creates the prompt sent to the model for the given diff
+func buildPrompt(diffOutput string, cfg *config.Config) string {
+	// Release notes replace the explanation entirely
+	if cfg.Changelog {
+		return buildChangelogPrompt(diffOutput, cfg)
+	}
+	// So do review comments
+	if cfg.Review {
+		return buildReviewPrompt(diffOutput, cfg)
+	}
+
+	if cfg.Structured {
+		prompt := buildStructuredPrompt(diffOutput, cfg)
+		prompt += reverseInstruction(cfg.Reverse)
+		prompt += wordDiffInstruction(cfg.WordDiff)
+		prompt += newFilesInstruction(cfg.NewFilesOnly)
+		if cfg.CheckTests {
+			prompt += testCoverageInstruction(GetChangedFiles(diffOutput), "the test_coverage field")
+		}
+		if cfg.Intent {
+			prompt += intentInstruction(cfg.RecentCommits, "the likely_intent field")
+		}
+		if cfg.GroupByDir {
+			prompt += directoryInstruction(GetChangedFiles(diffOutput), "List the details entries grouped by these directories, in this order.")
+		}
+		return prompt
+	}
+
+	// Create the prompt for Claude
+	prompt := "I'm going to show you the output of a git diff command. Please explain these changes in a clear, concise way.\n\n"
+	prompt += personaPreamble(cfg.Persona)
+	prompt += projectContextSection(cfg.ProjectContext)
+	prompt += commitContext(cfg.RecentCommits)
+	prompt += commitMessageSection(cfg.CommitMessage)
+	prompt += cfg.FileVersions
+	prompt += backgroundSection(cfg.Background)
+	prompt += "Here's the git diff output:\n\n```\n"
+	prompt += diffOutput
+	prompt += "\n```\n\n"
+	prompt += reverseInstruction(cfg.Reverse)
+	prompt += wordDiffInstruction(cfg.WordDiff)
+	prompt += newFilesInstruction(cfg.NewFilesOnly)
+	// Word diff lines have no + or - prefix to classify hunks by
+	if !cfg.WordDiff {
+		prompt += hunkClassInstruction(diffOutput)
+	}
+	prompt += detailsInstruction(cfg.MinSeverity, "DETAILS")
+	if cfg.GroupByDir {
+		prompt += directoryInstruction(GetChangedFiles(diffOutput), "In DETAILS, put the files under a heading for each of these directories, in this order, with the heading on its own line ending in a slash.")
+	}
+	sections := "SUMMARY,FILE CHANGES and DETAILS section"
+	if cfg.CheckTests {
+		prompt += testCoverageInstruction(GetChangedFiles(diffOutput), "a TEST COVERAGE section after DETAILS")
+		sections = "SUMMARY, FILE CHANGES, DETAILS and TEST COVERAGE sections"
+	}
+	if cfg.Intent {
+		if cfg.CheckTests {
+			prompt += intentInstruction(cfg.RecentCommits, "a LIKELY INTENT section after TEST COVERAGE")
+			sections = "SUMMARY, FILE CHANGES, DETAILS, TEST COVERAGE and LIKELY INTENT sections"
+		} else {
+			prompt += intentInstruction(cfg.RecentCommits, "a LIKELY INTENT section after DETAILS")
+			sections = "SUMMARY, FILE CHANGES, DETAILS and LIKELY INTENT sections"
+		}
+	}
+	if cfg.WrapCode {
+		prompt += " Use the format below. Only include " + sections + ":\n\n```"
+	} else {
+		prompt += " Use the format below and output plaintext without ```. Only include " + sections + ":\n\n```"
+	}
+	prompt += `
+--------------------------------------------------
+SUMMARY:
+  - Files modified: {files_modified}
+	- One line summary of the changes
+  - Insertions: {insertions}
+  - Deletions: {deletions}
+
+FILE CHANGES:
+{file_changes}
+
+DETAILS:
+	file1:
+		+ {detailed_breakdown_additions}
+		- {detailed_breakdown_deletions}
+	...
+`
+	if cfg.CheckTests {
+		prompt += `
+TEST COVERAGE:
+	file1: {tests changed, or which tests are missing}
+	...
+`
+	}
+	if cfg.Intent {
+		prompt += `
+LIKELY INTENT:
+	Purpose: {why the change was most likely made}
+	Risks: {what could go wrong because of it}
+`
+	}
+	prompt += `--------------------------------------------------
+`
+	prompt += "\n```\n"
+	prompt += "IMPORTANT: For colored text, use the following ANSI escape codes with the full escape character prefix:\n\n"
+	prompt += "For additions (green text): \\033[32;1m text here \\033[0m\n"
+	prompt += "For deletions (red text): \\033[31;1m text here \\033[0m\n\n"
+	prompt += "Make sure to include the full '\\033' escape character prefix and always close with '\\033[0m' to reset the color."
+
+	if cfg.WrapCode {
+		prompt += codeFenceInstructions(GetChangedFiles(diffOutput))
+	}
+
+	return prompt
+}
+
+// commitContext lists the recent commits so the model knows what the author
+// has been working on. It is empty when there are none.
+func commitContext(commits []string) string {
+	if len(commits) == 0 {
+		return ""
+	}
+
+	text := "For context, these are the most recent commits, newest first:\n\n"
+	for _, commit := range commits {
+		text += "  " + commit + "\n"
+	}
+	return text + "\nUse them to understand the intent of the changes, but only explain what is in the diff.\n\n"
+}
+
+// reverseInstruction frames a diff taken with -R, whose sides are swapped,
+// as undoing the changes
+func reverseInstruction(reverse bool) string {
+	if !reverse {
+		return ""
+	}
+	return "This diff undoes earlier changes, as a revert or rollback would: the removed lines are the changes being reverted and the added lines are what they replaced. " +
+		"Frame the explanation that way, starting the summary with \"This undoes\" and what the reverted changes did, and describe what behavior goes away or comes back.\n\n"
+}
+
+// detailsInstruction tells the model which changes to describe in the given
+// section. By default every file is included; higher severities leave out
+// trivial changes.
+func detailsInstruction(severity string, section string) string {
+	switch severity {
+	case config.SeverityNotable:
+		return "Be concise. In " + section + ", leave out trivial changes such as whitespace, formatting, import reordering and comment typos, and skip files that only have such changes."
+	case config.SeverityMajor:
+		return "Be concise. In " + section + ", only describe major changes: behavior, public APIs, data formats, security and performance. Skip refactoring, renames, formatting and other minor changes, and skip files that only have such changes."
+	default:
+		return "Be concise but include every file that was changed in " + section + "."
+	}
+}
+
+// rootGroup is the heading of files at the top of the repository
+const rootGroup = "(root)"
+
+// groupByDir groups file paths by their first path segment, keeping the
+// order in which each directory first appears
+func groupByDir(files []string) ([]string, map[string][]string) {
+	var dirs []string
+	groups := make(map[string][]string)
+	for _, file := range files {
+		dir := rootGroup
+		if first, _, ok := strings.Cut(file, "/"); ok {
+			dir = first + "/"
+		}
+		if _, seen := groups[dir]; !seen {
+			dirs = append(dirs, dir)
+		}
+		groups[dir] = append(groups[dir], file)
+	}
+	return dirs, groups
+}
+
+// directoryInstruction lists the changed files by top-level directory and
+// tells the model how to use the grouping
+func directoryInstruction(files []string, how string) string {
+	dirs, groups := groupByDir(files)
+	if len(dirs) == 0 {
+		return ""
+	}
+
+	instruction := "\n\nThe changed files by top-level directory:\n\n"
+	for _, dir := range dirs {
+		instruction += "  " + dir + "\n"
+		for _, file := range groups[dir] {
+			instruction += "    " + file + "\n"
+		}
+	}
+	return instruction + "\n" + how
+}
+
+// testCoverageInstruction asks the model to check, for each changed source
+// file, whether the diff also changes its tests. The changed files are listed
+// so the model can match sources to tests by name.
+func testCoverageInstruction(files []string, where string) string {
+	instruction := "\n\nAlso review test coverage. These files were changed:\n\n"
+	for _, file := range files {
+		instruction += "  " + file + "\n"
+	}
+	instruction += "\nFor each changed source file, say whether its tests were changed too, matching by name and location (such as foo_test.go for foo.go, or files under a test directory). Point out source changes that have no test changes. Skip tests, docs and config files themselves. Put this in " + where + "."
+	return instruction
+}
+
+// intentInstruction asks the model to infer why the change was made and what
+// it risks. The recent commits, when there are any, are pointed out since
+// they say most about what the author is working toward.
+func intentInstruction(commits []string, where string) string {
+	instruction := "\n\nAlso infer the likely intent of the change: the purpose it most probably serves, judged from the code, the names and the context above, and the risks it brings, such as behavior that changes for callers, cases it doesn't handle or what could break. "
+	if len(commits) > 0 {
+		instruction += "Ground this in the recent commits listed above. "
+	}
+	instruction += "Where the diff leaves the purpose unclear, say that it is a guess. Put this in " + where + "."
+	return instruction
+}
+
+// codeFenceInstructions tells the model to wrap code snippets in fenced blocks,
+// listing the language tag to use for each file extension in the diff
+func codeFenceInstructions(files []string) string {
+	instructions := "\n\nWhen quoting code, wrap each snippet in a fenced code block (```) with a language tag after the opening fence."
+
+	// Collect the unique extensions of the changed files
+	seen := make(map[string]bool)
+	var hints []string
+	for _, file := range files {
+		ext := strings.ToLower(filepath.Ext(file))
+		if ext == "" || seen[ext] {
+			continue
+		}
+		seen[ext] = true
+
+		tag, ok := languageTags[ext]
+		if !ok {
+			tag = strings.TrimPrefix(ext, ".")
+		}
+		hints = append(hints, fmt.Sprintf("%s -> %s", ext, tag))
+	}
+	sort.Strings(hints)
+
+	if len(hints) > 0 {
+		instructions += " Use these language tags for the changed files: " + strings.Join(hints, ", ") + "."
+	}
+
+	return instructions
+}
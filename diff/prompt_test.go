@@ -0,0 +1,189 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tydin/difx/config"
+)
+
+func TestPromptSeverity(t *testing.T) {
+	tests := []struct {
+		severity string
+		want     string
+	}{
+		{severity: "", want: "include every file that was changed in DETAILS"},
+		{severity: config.SeverityAll, want: "include every file that was changed in DETAILS"},
+		{severity: config.SeverityNotable, want: "leave out trivial changes"},
+		{severity: config.SeverityMajor, want: "only describe major changes"},
+	}
+
+	for _, tt := range tests {
+		for _, structured := range []bool{false, true} {
+			cfg := &config.Config{MinSeverity: tt.severity, Structured: structured}
+			prompt := buildPrompt(sampleDiff, cfg)
+
+			want := tt.want
+			if structured && (tt.severity == "" || tt.severity == config.SeverityAll) {
+				want = "include every file that was changed."
+			}
+			if !strings.Contains(prompt, want) {
+				t.Errorf("severity %q, structured %v: prompt does not contain %q", tt.severity, structured, want)
+			}
+		}
+	}
+}
+
+func TestPromptRecentCommits(t *testing.T) {
+	cfg := &config.Config{RecentCommits: []string{"abc1234 Add parser"}}
+	if prompt := buildPrompt(sampleDiff, cfg); !strings.Contains(prompt, "  abc1234 Add parser\n") {
+		t.Error("prompt does not list the recent commits")
+	}
+
+	if prompt := buildPrompt(sampleDiff, &config.Config{}); strings.Contains(prompt, "most recent commits") {
+		t.Error("prompt mentions commits without any")
+	}
+}
+
+func TestPromptCheckTests(t *testing.T) {
+	cfg := &config.Config{CheckTests: true}
+	prompt := buildPrompt(sampleDiff, cfg)
+	for _, want := range []string{"TEST COVERAGE:", "TEST COVERAGE sections", "Also review test coverage"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt does not contain %q", want)
+		}
+	}
+	for _, file := range GetChangedFiles(sampleDiff) {
+		if !strings.Contains(prompt, "  "+file+"\n") {
+			t.Errorf("prompt does not list %s", file)
+		}
+	}
+
+	if prompt := buildPrompt(sampleDiff, &config.Config{}); strings.Contains(prompt, "TEST COVERAGE") {
+		t.Error("prompt asks for test coverage without --check-tests")
+	}
+
+	// Structured mode asks for the field and adds it to the schema
+	cfg.Structured = true
+	if prompt := buildPrompt(sampleDiff, cfg); !strings.Contains(prompt, "test_coverage field") {
+		t.Error("structured prompt does not ask for test_coverage")
+	}
+	properties := structuredTool(cfg).InputSchema["properties"].(map[string]interface{})
+	if _, ok := properties["test_coverage"]; !ok {
+		t.Error("schema has no test_coverage property")
+	}
+	if _, ok := explanationTool.InputSchema["properties"].(map[string]interface{})["test_coverage"]; ok {
+		t.Error("the shared schema was changed")
+	}
+}
+
+func TestPromptIntent(t *testing.T) {
+	cfg := &config.Config{Intent: true}
+	prompt := buildPrompt(sampleDiff, cfg)
+	for _, want := range []string{"LIKELY INTENT:", "DETAILS and LIKELY INTENT sections", "section after DETAILS"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt does not contain %q", want)
+		}
+	}
+	if strings.Contains(prompt, "recent commits listed above") {
+		t.Error("prompt points to recent commits without any")
+	}
+
+	if prompt := buildPrompt(sampleDiff, &config.Config{}); strings.Contains(prompt, "LIKELY INTENT") {
+		t.Error("prompt asks for the intent without --intent")
+	}
+
+	// It comes after TEST COVERAGE, and uses the commits of --with-log
+	cfg = &config.Config{Intent: true, CheckTests: true, RecentCommits: []string{"abc1234 Add parser"}}
+	prompt = buildPrompt(sampleDiff, cfg)
+	for _, want := range []string{"TEST COVERAGE and LIKELY INTENT sections", "section after TEST COVERAGE", "recent commits listed above"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt with --check-tests and --with-log does not contain %q", want)
+		}
+	}
+
+	// Structured mode asks for the field and adds it to the schema
+	cfg.Structured = true
+	if prompt := buildPrompt(sampleDiff, cfg); !strings.Contains(prompt, "likely_intent field") {
+		t.Error("structured prompt does not ask for likely_intent")
+	}
+	schema := structuredTool(cfg).InputSchema
+	properties := schema["properties"].(map[string]interface{})
+	for _, field := range []string{"test_coverage", "likely_intent"} {
+		if _, ok := properties[field]; !ok {
+			t.Errorf("schema has no %s property", field)
+		}
+	}
+	if required := schema["required"].([]string); len(required) != 5 {
+		t.Errorf("schema requires %v", required)
+	}
+}
+
+func TestPromptPersona(t *testing.T) {
+	neutral := buildPrompt(sampleDiff, &config.Config{})
+	for name, preamble := range personas {
+		for _, structured := range []bool{false, true} {
+			prompt := buildPrompt(sampleDiff, &config.Config{Persona: name, Structured: structured})
+			if !strings.Contains(prompt, preamble) {
+				t.Errorf("persona %s, structured %v: prompt does not contain its preamble", name, structured)
+			}
+		}
+		if strings.Contains(neutral, preamble) {
+			t.Errorf("the default prompt contains the %s preamble", name)
+		}
+	}
+
+	if !IsPersona("") || !IsPersona(config.PersonaELI5) || IsPersona("pirate") {
+		t.Error("IsPersona accepts the wrong names")
+	}
+}
+
+func TestGroupByDir(t *testing.T) {
+	dirs, groups := groupByDir([]string{"cmd/root.go", "README.md", "diff/git.go", "cmd/tui.go", "go.mod"})
+
+	if want := []string{"cmd/", rootGroup, "diff/"}; strings.Join(dirs, ",") != strings.Join(want, ",") {
+		t.Errorf("dirs = %v, want %v", dirs, want)
+	}
+	if got := groups["cmd/"]; len(got) != 2 || got[1] != "cmd/tui.go" {
+		t.Errorf("cmd/ = %v", got)
+	}
+	if got := groups[rootGroup]; len(got) != 2 || got[0] != "README.md" {
+		t.Errorf("root = %v", got)
+	}
+
+	for _, structured := range []bool{false, true} {
+		prompt := buildPrompt(sampleDiff, &config.Config{GroupByDir: true, Structured: structured})
+		if !strings.Contains(prompt, "The changed files by top-level directory") {
+			t.Errorf("structured %v: prompt has no directory grouping", structured)
+		}
+	}
+}
+
+func TestPromptChangelog(t *testing.T) {
+	cfg := &config.Config{Changelog: true, RecentCommits: []string{"abc1234 Add parser"}, GroupByDir: true, FileVersions: "Complete main.go:\n"}
+	prompt := buildPrompt(sampleDiff, cfg)
+
+	for _, want := range []string{ChangelogBreaking, ChangelogFeatures, ChangelogFixes, ChangelogChores, "  abc1234 Add parser\n", cfg.FileVersions, sampleDiff} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("changelog prompt is missing %q", want)
+		}
+	}
+	// The explanation's own format isn't asked for
+	if strings.Contains(prompt, "SUMMARY:") || strings.Contains(prompt, "DETAILS") {
+		t.Error("changelog prompt asks for the explanation format")
+	}
+}
+
+func TestPromptReverse(t *testing.T) {
+	for _, cfg := range []*config.Config{{}, {Structured: true}, {Changelog: true}, {Review: true}} {
+		if strings.Contains(buildPrompt(sampleDiff, cfg), "This undoes") {
+			t.Errorf("structured %v, changelog %v, review %v: prompt frames the diff as a revert", cfg.Structured, cfg.Changelog, cfg.Review)
+		}
+
+		cfg.Reverse = true
+		prompt := buildPrompt(sampleDiff, cfg)
+		if i := strings.Index(prompt, "This undoes"); i < strings.Index(prompt, sampleDiff) {
+			t.Errorf("structured %v, changelog %v, review %v: no revert framing after the diff", cfg.Structured, cfg.Changelog, cfg.Review)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package diff
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// secretHeaders carry credentials, which are never written to a recording
+var secretHeaders = []string{"x-api-key", "api-key", "Authorization"}
+
+// redacted replaces a secret in a recording
+const redacted = "REDACTED"
+
+// recording is an exchange with an API as it is saved to disk
+type recording struct {
+	Request struct {
+		Method string      `json:"method"`
+		URL    string      `json:"url"`
+		Header http.Header `json:"header"`
+		Body   string      `json:"body"`
+	} `json:"request"`
+	Response struct {
+		StatusCode int         `json:"status_code"`
+		Header     http.Header `json:"header"`
+		// Body is the whole response, a streamed one included, uncompressed
+		Body string `json:"body"`
+	} `json:"response"`
+}
+
+// recordings names the files of a directory of recordings. A request is
+// known by its method, path and body, so replaying doesn't depend on the
+// endpoint or the key, and the same request sent again, as on a retry, gets
+// a file of its own.
+type recordings struct {
+	dir string
+
+	mu   sync.Mutex
+	seen map[string]int
+}
+
+// next returns the file for the request's next exchange
+func (r *recordings) next(req *http.Request, body []byte) string {
+	sum := sha256.Sum256([]byte(req.Method + " " + req.URL.RequestURI() + "\n" + string(body)))
+	key := hex.EncodeToString(sum[:8])
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.seen == nil {
+		r.seen = map[string]int{}
+	}
+	r.seen[key]++
+	if n := r.seen[key]; n > 1 {
+		key = fmt.Sprintf("%s-%d", key, n)
+	}
+	return filepath.Join(r.dir, key+".json")
+}
+
+// readRequestBody returns the body of a request and puts it back for sending
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// scrubHeader returns a copy of the header without its credentials
+func scrubHeader(header http.Header) http.Header {
+	scrubbed := header.Clone()
+	for _, name := range secretHeaders {
+		if scrubbed.Get(name) != "" {
+			scrubbed.Set(name, redacted)
+		}
+	}
+	return scrubbed
+}
+
+// recordingTransport saves every exchange that goes through it to a
+// directory, for replayTransport to serve later
+type recordingTransport struct {
+	next       http.RoundTripper
+	recordings *recordings
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the request to record: %w", err)
+	}
+	path := t.recordings.next(req, body)
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var rec recording
+	rec.Request.Method = req.Method
+	rec.Request.URL = req.URL.String()
+	rec.Request.Header = scrubHeader(req.Header)
+	rec.Request.Body = string(body)
+	rec.Response.StatusCode = resp.StatusCode
+	rec.Response.Header = scrubHeader(resp.Header)
+
+	// The response keeps streaming to the caller, and is saved once it is closed
+	resp.Body = &recordingBody{ReadCloser: resp.Body, path: path, recording: &rec}
+	return resp, nil
+}
+
+// recordingBody copies a response body as it is read, and saves the
+// recording when it is closed
+type recordingBody struct {
+	io.ReadCloser
+	path      string
+	recording *recording
+	read      bytes.Buffer
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read.Write(p[:n])
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	err := b.ReadCloser.Close()
+	if saveErr := b.save(); saveErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not save the recording: %s\n", saveErr)
+	}
+	return err
+}
+
+// save writes the recording, with a compressed body saved uncompressed
+func (b *recordingBody) save() error {
+	body := b.read.Bytes()
+	header := b.recording.Response.Header
+	if strings.EqualFold(header.Get("Content-Encoding"), "gzip") {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("error decompressing the response: %w", err)
+		}
+		if body, err = io.ReadAll(reader); err != nil {
+			return fmt.Errorf("error decompressing the response: %w", err)
+		}
+		header.Del("Content-Encoding")
+		header.Del("Content-Length")
+	}
+	b.recording.Response.Body = string(body)
+
+	content, err := json.MarshalIndent(b.recording, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(b.path, append(content, '\n'), 0600)
+}
+
+// replayTransport answers requests from the recordings in a directory
+// instead of sending them. A request that wasn't recorded is an error.
+type replayTransport struct {
+	recordings *recordings
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the request to replay: %w", err)
+	}
+	path := t.recordings.next(req, body)
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no recording of %s %s in %s (the request has changed since it was recorded)", req.Method, req.URL.Path, t.recordings.dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading the recording: %w", err)
+	}
+
+	var rec recording
+	if err := json.Unmarshal(content, &rec); err != nil {
+		return nil, fmt.Errorf("error decoding the recording %s: %w", path, err)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.Response.StatusCode, http.StatusText(rec.Response.StatusCode)),
+		StatusCode:    rec.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rec.Response.Header,
+		Body:          io.NopCloser(strings.NewReader(rec.Response.Body)),
+		ContentLength: int64(len(rec.Response.Body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,96 @@
+package diff
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tydin/difx/config"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	old := httpClient
+	t.Cleanup(func() { httpClient = old })
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, claudeStream)
+	}))
+	dir := t.TempDir()
+	cfg := &config.Config{ActiveModel: config.ModelClaude, ClaudeAPIKey: "sk-secret", ClaudeBaseURL: server.URL, Streaming: true, RecordDir: dir}
+	httpClient = newHTTPClient(cfg)
+	recorded, err := GetExplanation(context.Background(), sampleDiff, cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("recordings = %v", files)
+	}
+	content, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "sk-secret") || !strings.Contains(string(content), redacted) {
+		t.Errorf("the API key wasn't scrubbed:\n%s", content)
+	}
+
+	// The stream is served from the recording, with the server gone and no key
+	cfg.RecordDir, cfg.ReplayDir, cfg.ClaudeAPIKey = "", dir, ""
+	httpClient = newHTTPClient(cfg)
+	replayed, err := GetExplanation(context.Background(), sampleDiff, cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed != recorded || replayed != "Hello world" {
+		t.Errorf("replayed %q, recorded %q", replayed, recorded)
+	}
+
+	// A request that wasn't recorded isn't sent anywhere
+	if _, err := GetExplanation(context.Background(), "another diff", cfg, nil); err == nil || !strings.Contains(err.Error(), "no recording") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestRecordingDecompressesResponses(t *testing.T) {
+	old := httpClient
+	t.Cleanup(func() { httpClient = old })
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		fmt.Fprint(zw, `{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}]}`)
+		zw.Close()
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	cfg := &config.Config{ActiveModel: config.ModelAzureOpenAI, AzureOpenAIEndpoint: server.URL, AzureOpenAIKey: "secret", RecordDir: dir}
+	httpClient = newHTTPClient(cfg)
+	if _, err := GetExplanation(context.Background(), sampleDiff, cfg, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("recordings = %v", files)
+	}
+	content, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(content), `finish_reason`) || strings.Contains(string(content), "Content-Encoding") {
+		t.Errorf("the response was saved compressed:\n%s", content)
+	}
+
+	cfg.RecordDir, cfg.ReplayDir = "", dir
+	httpClient = newHTTPClient(cfg)
+	if got, err := GetExplanation(context.Background(), sampleDiff, cfg, nil); err != nil || got != "ok" {
+		t.Errorf("replayed %q, %v", got, err)
+	}
+}
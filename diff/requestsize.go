@@ -0,0 +1,42 @@
+package diff
+
+import (
+	"fmt"
+
+	"github.com/tydin/difx/config"
+)
+
+// RequestTooLargeError is returned instead of sending a request whose
+// messages are larger than cfg.MaxRequestBytes
+type RequestTooLargeError struct {
+	Size  int
+	Limit int
+}
+
+func (e *RequestTooLargeError) Error() string {
+	return fmt.Sprintf("the request would be %d KB, over the %d KB limit of max_request_bytes; narrow the diff with paths, use --chunked or --max-input-tokens, or raise max_request_bytes",
+		kilobytes(e.Size), kilobytes(e.Limit))
+}
+
+// kilobytes rounds a size up to whole kilobytes, so nothing over the limit
+// looks equal to it
+func kilobytes(size int) int {
+	return (size + 1023) / 1024
+}
+
+// checkRequestSize adds up the messages of a request and returns a
+// *RequestTooLargeError if they are over cfg.MaxRequestBytes. A limit of 0
+// lets everything through.
+func checkRequestSize(messages []Message, cfg *config.Config) error {
+	if cfg.MaxRequestBytes <= 0 {
+		return nil
+	}
+	size := 0
+	for _, message := range messages {
+		size += len(message.Content)
+	}
+	if size > cfg.MaxRequestBytes {
+		return &RequestTooLargeError{Size: size, Limit: cfg.MaxRequestBytes}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tydin/difx/config"
+)
+
+func TestRequestSizeLimit(t *testing.T) {
+	old := httpClient
+	t.Cleanup(func() { httpClient = old })
+
+	sent := 0
+	httpClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		sent++
+		return nil, errors.New("offline")
+	})}
+
+	for _, model := range []string{config.ModelClaude, config.ModelAzureOpenAI} {
+		cfg := &config.Config{ActiveModel: model, ClaudeAPIKey: "key", AzureOpenAIEndpoint: "https://example.openai.azure.com", MaxRequestBytes: 2048}
+		_, err := GetExplanation(context.Background(), strings.Repeat("+line\n", 1000), cfg, nil)
+
+		var tooLarge *RequestTooLargeError
+		if !errors.As(err, &tooLarge) || tooLarge.Limit != 2048 || tooLarge.Size <= 6000 {
+			t.Fatalf("%s: err = %v, want a RequestTooLargeError", model, err)
+		}
+		if !strings.Contains(err.Error(), "over the 2 KB limit") {
+			t.Errorf("%s: error = %q", model, err)
+		}
+	}
+	if sent != 0 {
+		t.Errorf("%d requests were sent", sent)
+	}
+}
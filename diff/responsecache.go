@@ -0,0 +1,51 @@
+package diff
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/tydin/difx/cache"
+	"github.com/tydin/difx/config"
+)
+
+// modelName returns the full name of the model the config sends prompts to
+func modelName(cfg *config.Config) string {
+	switch cfg.ActiveModel {
+	case config.ModelClaude:
+		return cfg.ActiveModel + "/" + ClaudeModel
+	case config.ModelAzureOpenAI:
+		return cfg.ActiveModel + "/" + AzureOpenAIModel
+	default:
+		return cfg.ActiveModel
+	}
+}
+
+// optionsHash hashes the config options, leaving out credentials
+func optionsHash(cfg *config.Config) string {
+	options := *cfg
+	options.ClaudeAPIKey = ""
+	options.AzureOpenAIEndpoint = ""
+	options.AzureOpenAIKey = ""
+
+	data, _ := json.Marshal(options)
+	return cache.Hash(string(data))
+}
+
+// lookupCache returns the cached response for a prompt, if there is one
+func lookupCache(prompt string, cfg *config.Config) (string, bool) {
+	entry, ok, err := cache.LoadResponse(cache.Key(modelName(cfg), prompt))
+	if err != nil || !ok {
+		return "", false
+	}
+	return entry.Response, true
+}
+
+// storeCache saves the response for a prompt
+func storeCache(prompt string, cfg *config.Config, response string) error {
+	return cache.StoreResponse(cache.Key(modelName(cfg), prompt), cache.Entry{
+		Model:       modelName(cfg),
+		CreatedAt:   time.Now(),
+		OptionsHash: optionsHash(cfg),
+		Response:    response,
+	})
+}
@@ -0,0 +1,34 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/tydin/difx/config"
+)
+
+func TestCacheKeyedOnPrompt(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	cfg := &config.Config{ActiveModel: config.ModelClaude, Cache: true}
+	prompt := buildPrompt(sampleDiff, cfg)
+	if err := storeCache(prompt, cfg, "cached explanation"); err != nil {
+		t.Fatalf("storeCache: %s", err)
+	}
+
+	if got, ok := lookupCache(prompt, cfg); !ok || got != "cached explanation" {
+		t.Fatalf("lookupCache for the same prompt = %q, %v", got, ok)
+	}
+
+	// Same diff, different template
+	wrapped := &config.Config{ActiveModel: config.ModelClaude, Cache: true, WrapCode: true}
+	if got, ok := lookupCache(buildPrompt(sampleDiff, wrapped), wrapped); ok {
+		t.Errorf("lookupCache hit a stale entry after the template changed: %q", got)
+	}
+
+	// Same prompt, different model
+	azure := &config.Config{ActiveModel: config.ModelAzureOpenAI, Cache: true}
+	if got, ok := lookupCache(prompt, azure); ok {
+		t.Errorf("lookupCache hit an entry from another model: %q", got)
+	}
+}
@@ -0,0 +1,121 @@
+package diff
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/tydin/difx/cache"
+	"github.com/tydin/difx/config"
+)
+
+// continueInstruction asks a model that can't continue an assistant message
+// by itself to pick up where the earlier answer stopped
+const continueInstruction = "Your answer above was cut off. Continue it exactly where it stopped, without repeating any of it or adding an introduction."
+
+// incompleteEscapeRegex matches a color code cut off at the end of the text,
+// either as the model writes it (\033[32;1m) or as a raw escape character
+var incompleteEscapeRegex = regexp.MustCompile(`(\\(0(3(3(\[[0-9;]*)?)?)?)?|\x1b(\[[0-9;]*)?)$`)
+
+// userPrompt is a conversation with just the prompt
+func userPrompt(prompt string) []Message {
+	return []Message{{Role: "user", Content: prompt}}
+}
+
+// resumeText prepares a partial explanation to be sent back as the start of
+// the answer. A color code that was cut off is dropped so the model writes
+// it again in full, and trailing whitespace is removed because Claude
+// rejects an assistant message that ends with it.
+func resumeText(partial string) string {
+	text := strings.TrimRightFunc(partial, isSpace)
+	text = incompleteEscapeRegex.ReplaceAllString(text, "")
+	return strings.TrimRightFunc(text, isSpace)
+}
+
+// isSpace tells whether r is whitespace
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+// savesProgress tells whether the explanation is streamed as plain text,
+// which can be saved as it arrives and continued later. Structured output
+// comes as tool input, which the model can't be asked to continue.
+func savesProgress(cfg *config.Config) bool {
+	return cfg.Streaming && !cfg.Structured
+}
+
+// loadResume returns the partial explanation saved for the prompt's key,
+// ready to be continued, or "" if there is none
+func loadResume(key string) string {
+	partial, ok, err := cache.LoadPartial(key)
+	if err != nil || !ok {
+		return ""
+	}
+	return resumeText(partial)
+}
+
+// progress sits between the provider and the caller's handler. It saves the
+// streamed text under key as it arrives and, when an earlier partial
+// explanation is being continued, shows that text first, including again
+// after a retry.
+type progress struct {
+	key     string
+	resumed string
+	save    bool
+	emit    func(Event)
+
+	// text is what this attempt has received so far
+	text strings.Builder
+}
+
+// start shows the resumed text before the request is sent
+func (p *progress) start() {
+	if p.resumed == "" {
+		return
+	}
+	p.emit(Event{Kind: EventKindStart})
+	p.emit(Event{Kind: EventKindText, Text: p.resumed})
+}
+
+// handle records an event from the provider and passes it on
+func (p *progress) handle(event Event) {
+	switch event.Kind {
+	case EventKindStart:
+		p.text.Reset()
+		if p.save {
+			// A failed write only means the run can't be resumed
+			_ = cache.StartPartial(p.key, p.resumed)
+		}
+		// The caller already had the start with the resumed text
+		if p.resumed != "" {
+			return
+		}
+	case EventKindText:
+		p.text.WriteString(event.Text)
+		if p.save {
+			_ = cache.AppendPartial(p.key, event.Text)
+		}
+	case EventKindRetry:
+		p.emit(event)
+		if p.resumed != "" {
+			p.emit(Event{Kind: EventKindText, Text: p.resumed})
+		}
+		return
+	}
+	p.emit(event)
+}
+
+// result returns the whole explanation once the response is complete. A
+// continued explanation is the resumed text followed by the new text.
+func (p *progress) result(response string) string {
+	if p.resumed == "" {
+		return response
+	}
+	return strings.TrimSpace(p.resumed + p.text.String())
+}
+
+// finish forgets the saved progress of a complete explanation
+func (p *progress) finish() {
+	if p.save || p.resumed != "" {
+		_ = cache.RemovePartial(p.key)
+	}
+}
@@ -0,0 +1,89 @@
+package diff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tydin/difx/cache"
+	"github.com/tydin/difx/config"
+)
+
+func TestResumeText(t *testing.T) {
+	tests := []struct {
+		partial string
+		want    string
+	}{
+		{partial: "SUMMARY:\n  - Files \n", want: "SUMMARY:\n  - Files"},
+		{partial: `  + \033[32;1madded\033[0m`, want: `  + \033[32;1madded\033[0m`},
+		{partial: `  + \033[32;1madded \033[`, want: `  + \033[32;1madded`},
+		{partial: `  + \033[32`, want: "  +"},
+		{partial: `  + \03`, want: "  +"},
+		{partial: `  + \`, want: "  +"},
+		{partial: "  + \x1b[3", want: "  +"},
+		{partial: "  + \x1b", want: "  +"},
+	}
+
+	for _, tt := range tests {
+		if got := resumeText(tt.partial); got != tt.want {
+			t.Errorf("resumeText(%q) = %q, want %q", tt.partial, got, tt.want)
+		}
+	}
+}
+
+// azureChunk is one streamed piece of an Azure OpenAI response
+func azureChunk(w http.ResponseWriter, content string) {
+	data, _ := json.Marshal(content)
+	fmt.Fprintf(w, "data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":%s}}]}\n\n", data)
+}
+
+func TestGetExplanationResume(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	noRetryDelay(t)
+
+	var messages []AzureOpenAIMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request AzureOpenAIRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		messages = request.Messages
+
+		// The first run breaks off in the middle of a color code
+		if len(messages) == 1 {
+			azureChunk(w, "SUMMARY:\n  - Files ")
+			azureChunk(w, `\033[3`)
+			return
+		}
+		azureChunk(w, " modified: 1")
+		fmt.Fprint(w, "data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{ActiveModel: config.ModelAzureOpenAI, AzureOpenAIEndpoint: server.URL, Streaming: true}
+	if _, err := GetExplanation(context.Background(), "diff", cfg, nil); err == nil {
+		t.Fatal("the broken stream should fail")
+	}
+
+	cfg.Resume = true
+	var streamed string
+	got, err := GetExplanation(context.Background(), "diff", cfg, func(text string) { streamed += text })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) != 3 || messages[1].Role != "assistant" || messages[1].Content != "SUMMARY:\n  - Files" || messages[2].Content != continueInstruction {
+		t.Errorf("messages = %+v", messages)
+	}
+	if want := "SUMMARY:\n  - Files modified: 1"; got != want || streamed != want {
+		t.Errorf("explanation = %q, streamed %q, want %q", got, streamed, want)
+	}
+
+	// The complete explanation leaves nothing to resume
+	prompt := buildPrompt("diff", cfg)
+	if _, ok, _ := cache.LoadPartial(cache.Key(modelName(cfg), prompt)); ok {
+		t.Error("the partial explanation was kept after it was completed")
+	}
+}
@@ -0,0 +1,101 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tydin/difx/config"
+)
+
+// ErrIncompleteStream is returned when a streamed response ends before the
+// provider's stop event, for example because the connection dropped
+var ErrIncompleteStream = errors.New("stream ended before the response was complete")
+
+// APIError is returned when a provider answers a request with an error status
+type APIError struct {
+	// Provider is the name of the API, such as Claude
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API returned non-200 status code: %d, body: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// statusOverloaded is the status Claude answers with when it is overloaded
+const statusOverloaded = 529
+
+// Temporary tells whether the same request may succeed when it is sent again
+func (e *APIError) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout, statusOverloaded:
+		return true
+	}
+	return false
+}
+
+// retryDelay is the backoff before the given retry (1 for the first one).
+// Tests replace it to avoid waiting.
+var retryDelay = func(retry int) time.Duration {
+	return time.Duration(1<<(retry-1)) * 500 * time.Millisecond
+}
+
+// retryable tells whether a failed request is worth sending again
+func retryable(err error) bool {
+	if errors.Is(err, ErrIncompleteStream) {
+		return true
+	}
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Temporary()
+}
+
+// retryRequest calls the model again with a new request when the stream was
+// cut short or the provider answered with a temporary error, such as being
+// overloaded, up to cfg.MaxRetries times. Other errors, and a cancelled
+// context, are returned right away. Before each retry an EventKindRetry
+// event tells the handler to throw away the partial text it has received,
+// and cfg.Notify, if set, is told why the response is delayed.
+func retryRequest(ctx context.Context, cfg *config.Config, emit func(Event), call func() (string, error)) (string, error) {
+	attempts := cfg.MaxRetries + 1
+	for attempt := 1; ; attempt++ {
+		response, err := call()
+		if err == nil || !retryable(err) || ctx.Err() != nil || attempt >= attempts {
+			return response, err
+		}
+
+		delay := retryDelay(attempt)
+		emit(Event{Kind: EventKindRetry, Err: err})
+		if cfg.Notify != nil {
+			cfg.Notify(retryMessage(err, delay, attempt+1, attempts))
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// retryMessage explains a retry, such as "Claude overloaded (HTTP 529),
+// retrying in 4s (attempt 2/3)..."
+func retryMessage(err error, delay time.Duration, attempt, attempts int) string {
+	reason := "Connection lost"
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case statusOverloaded:
+			reason = fmt.Sprintf("%s overloaded (HTTP %d)", apiErr.Provider, apiErr.StatusCode)
+		case http.StatusTooManyRequests:
+			reason = fmt.Sprintf("%s rate limited (HTTP %d)", apiErr.Provider, apiErr.StatusCode)
+		default:
+			reason = fmt.Sprintf("%s unavailable (HTTP %d)", apiErr.Provider, apiErr.StatusCode)
+		}
+	}
+	return fmt.Sprintf("%s, retrying in %s (attempt %d/%d)...", reason, delay, attempt, attempts)
+}
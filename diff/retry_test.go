@@ -0,0 +1,148 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tydin/difx/config"
+)
+
+func TestTruncatedStreamIsIncomplete(t *testing.T) {
+	// Cut the stream off before message_stop
+	truncated := claudeStream[:strings.Index(claudeStream, "event: message_stop")]
+	_, err := handleClaudeStreamingResponse(serveBody(t, truncated), func(Event) {})
+	if !errors.Is(err, ErrIncompleteStream) {
+		t.Errorf("Claude: got %v, want ErrIncompleteStream", err)
+	}
+
+	truncated = azureStream[:strings.Index(azureStream, `data: {"id":"1","choices":[{"index":0,"delta":{},"finish_reason"`)]
+	_, err = handleAzureOpenAIStreamingResponse(serveBody(t, truncated), func(Event) {})
+	if !errors.Is(err, ErrIncompleteStream) {
+		t.Errorf("Azure: got %v, want ErrIncompleteStream", err)
+	}
+}
+
+func TestCompleteStreamIsNotRetried(t *testing.T) {
+	_, err := handleClaudeStreamingResponse(serveBody(t, claudeStream), func(Event) {})
+	if err != nil {
+		t.Errorf("complete stream returned %v", err)
+	}
+}
+
+// noRetryDelay removes the backoff for the duration of a test
+func noRetryDelay(t *testing.T) {
+	old := retryDelay
+	retryDelay = func(int) time.Duration { return 0 }
+	t.Cleanup(func() { retryDelay = old })
+}
+
+func TestRetryIncompleteStream(t *testing.T) {
+	noRetryDelay(t)
+
+	calls := 0
+	handler, events := collectEvents()
+	got, err := retryRequest(context.Background(), &config.Config{MaxRetries: 2}, handler, func() (string, error) {
+		calls++
+		if calls == 1 {
+			return "", ErrIncompleteStream
+		}
+		return "full response", nil
+	})
+	if err != nil || got != "full response" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+	if len(*events) != 1 || (*events)[0].Kind != EventKindRetry {
+		t.Errorf("events = %+v, want one retry event", *events)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	noRetryDelay(t)
+
+	calls := 0
+	_, err := retryRequest(context.Background(), &config.Config{MaxRetries: 2}, func(Event) {}, func() (string, error) {
+		calls++
+		return "", ErrIncompleteStream
+	})
+	if !errors.Is(err, ErrIncompleteStream) || calls != 3 {
+		t.Errorf("got %v after %d calls, want ErrIncompleteStream after 3", err, calls)
+	}
+}
+
+func TestRetryOnlyTemporaryErrors(t *testing.T) {
+	noRetryDelay(t)
+
+	for _, other := range []error{errors.New("connection refused"), &APIError{Provider: "Claude", StatusCode: 401}} {
+		calls := 0
+		_, err := retryRequest(context.Background(), &config.Config{MaxRetries: 2}, func(Event) {}, func() (string, error) {
+			calls++
+			return "", other
+		})
+		if err != other || calls != 1 {
+			t.Errorf("got %v after %d calls, want the error after 1", err, calls)
+		}
+	}
+}
+
+func TestRetryTemporaryStatus(t *testing.T) {
+	noRetryDelay(t)
+
+	var notes []string
+	cfg := &config.Config{MaxRetries: 2, Notify: func(message string) { notes = append(notes, message) }}
+	calls := 0
+	got, err := retryRequest(context.Background(), cfg, func(Event) {}, func() (string, error) {
+		calls++
+		if calls == 1 {
+			return "", &APIError{Provider: "Claude", StatusCode: 529, Body: "overloaded"}
+		}
+		if calls == 2 {
+			return "", &APIError{Provider: "Azure OpenAI", StatusCode: 429}
+		}
+		return "full response", nil
+	})
+	if err != nil || got != "full response" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+
+	want := []string{
+		"Claude overloaded (HTTP 529), retrying in 0s (attempt 2/3)...",
+		"Azure OpenAI rate limited (HTTP 429), retrying in 0s (attempt 3/3)...",
+	}
+	if strings.Join(notes, "\n") != strings.Join(want, "\n") {
+		t.Errorf("notes = %q, want %q", notes, want)
+	}
+}
+
+func TestRetryDisabled(t *testing.T) {
+	calls := 0
+	_, err := retryRequest(context.Background(), &config.Config{}, func(Event) {}, func() (string, error) {
+		calls++
+		return "", &APIError{Provider: "Claude", StatusCode: 503}
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("got %v after %d calls, want the error after 1", err, calls)
+	}
+}
+
+func TestRetryMessage(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&APIError{Provider: "Claude", StatusCode: 529}, "Claude overloaded (HTTP 529), retrying in 4s (attempt 2/3)..."},
+		{&APIError{Provider: "Claude", StatusCode: 502}, "Claude unavailable (HTTP 502), retrying in 4s (attempt 2/3)..."},
+		{fmt.Errorf("reading: %w", ErrIncompleteStream), "Connection lost, retrying in 4s (attempt 2/3)..."},
+	}
+	for _, tt := range tests {
+		if got := retryMessage(tt.err, 4*time.Second, 2, 3); got != tt.want {
+			t.Errorf("retryMessage(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package diff
+
+import (
+	"fmt"
+
+	"github.com/tydin/difx/config"
+)
+
+// Kinds of review comments, given in brackets after the location
+const (
+	ReviewBug        = "bug"
+	ReviewRisk       = "risk"
+	ReviewStyle      = "style"
+	ReviewSuggestion = "suggestion"
+)
+
+// NoReviewComments is what the model answers when it has nothing to point out
+const NoReviewComments = "No comments."
+
+// buildReviewPrompt creates the prompt for --review, which asks for
+// actionable review comments on the hunks instead of a description
+func buildReviewPrompt(diffOutput string, cfg *config.Config) string {
+	prompt := "I'm going to show you the output of a git diff command. Review these changes like an experienced engineer reviewing a pull request.\n\n"
+	prompt += projectContextSection(cfg.ProjectContext)
+	prompt += commitContext(cfg.RecentCommits)
+	prompt += commitMessageSection(cfg.CommitMessage)
+	prompt += cfg.FileVersions
+	prompt += backgroundSection(cfg.Background)
+	prompt += "Here's the git diff output:\n\n```\n"
+	prompt += diffOutput
+	prompt += "\n```\n\n"
+	prompt += reverseInstruction(cfg.Reverse)
+	prompt += wordDiffInstruction(cfg.WordDiff)
+	prompt += newFilesInstruction(cfg.NewFilesOnly)
+	prompt += hunkRanges(diffOutput)
+	prompt += "Write one comment per problem or improvement worth raising, such as a potential bug, an unhandled edge case, a style problem or a simpler way to do it. "
+	prompt += "Start each comment on a new line with the location and kind, like \"path/to/file.go:42: [" + ReviewBug + "] \", where the line is in the new version of the file and inside one of the hunks above (for removed code, the line of the hunk where it was). "
+	prompt += "The kind is one of " + ReviewBug + ", " + ReviewRisk + ", " + ReviewStyle + " or " + ReviewSuggestion + ". "
+	prompt += "Say what is wrong and what to do instead in one or two sentences, and show the suggested code on indented lines below the comment when it helps. "
+	prompt += "Order the comments by file and line, don't describe or praise what the change does, and skip hunks with nothing worth saying. "
+	prompt += "If there is nothing to point out, answer only \"" + NoReviewComments + "\". Output plain text without a title, Markdown headings or ANSI color codes."
+	return prompt
+}
+
+// hunkRanges lists the lines each hunk covers in the new version of its file,
+// for the model to give the location of its comments
+func hunkRanges(diffOutput string) string {
+	files, err := Parse(diffOutput)
+	if err != nil || len(files) == 0 {
+		return ""
+	}
+
+	text := "The hunks cover these lines of the new files:\n\n"
+	for _, file := range files {
+		for _, hunk := range file.Hunks {
+			// Combined diffs have no line counts
+			if hunk.NewStart < 0 || hunk.NewLines < 0 {
+				continue
+			}
+			text += fmt.Sprintf("- %s: %s\n", file.Path(), lineRange(hunk.NewStart, hunk.NewLines))
+		}
+	}
+	return text + "\n"
+}
+
+// lineRange formats the lines of a hunk, such as "lines 10-24". A hunk that
+// only removes lines has none left, so it is placed after its start line.
+func lineRange(start, count int) string {
+	switch {
+	case count == 0:
+		return fmt.Sprintf("line %d (lines removed)", max(start, 1))
+	case count == 1:
+		return fmt.Sprintf("line %d", start)
+	default:
+		return fmt.Sprintf("lines %d-%d", start, start+count-1)
+	}
+}
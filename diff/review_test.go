@@ -0,0 +1,38 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tydin/difx/config"
+)
+
+func TestPromptReview(t *testing.T) {
+	prompt := buildPrompt(sampleDiff, &config.Config{Review: true, RecentCommits: []string{"abc1234 Bump a"}, FileVersions: "Complete main.go:\n"})
+
+	for _, want := range []string{sampleDiff, "- main.go: lines 1-3\n", "- go.sum: lines 1-5\n", "  abc1234 Bump a\n", "Complete main.go:\n", "[" + ReviewBug + "]", NoReviewComments} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("review prompt is missing %q", want)
+		}
+	}
+	if strings.Contains(prompt, "DETAILS") || strings.Contains(prompt, `\033`) {
+		t.Error("review prompt asks for the explanation format")
+	}
+}
+
+func TestLineRange(t *testing.T) {
+	tests := []struct {
+		start, count int
+		want         string
+	}{
+		{10, 15, "lines 10-24"},
+		{7, 1, "line 7"},
+		{12, 0, "line 12 (lines removed)"},
+		{0, 0, "line 1 (lines removed)"},
+	}
+	for _, tt := range tests {
+		if got := lineRange(tt.start, tt.count); got != tt.want {
+			t.Errorf("lineRange(%d, %d) = %q, want %q", tt.start, tt.count, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Commit is the metadata of a single commit, as difx show prints it
+type Commit struct {
+	Hash   string
+	Author string
+	Email  string
+	Date   time.Time
+	// Message is the full commit message, subject and body
+	Message string
+}
+
+// Subject returns the first line of the commit message
+func (c Commit) Subject() string {
+	subject, _, _ := strings.Cut(c.Message, "\n")
+	return subject
+}
+
+// ShortHash returns the abbreviated hash git shows
+func (c Commit) ShortHash() string {
+	return shortHash(c.Hash)
+}
+
+// commitFormat separates the fields of git show's output with NUL bytes,
+// which can't appear in them, and ends with the message
+const commitFormat = "%H%x00%an%x00%ae%x00%aI%x00%B"
+
+// ResolveCommit returns the full hash of a commit given by hash, branch, tag
+// or any other revision, and an error if it doesn't name a commit
+func ResolveCommit(ctx context.Context, rev string) (string, error) {
+	output, err := runGit(ctx, "git rev-parse", "", "rev-parse", "--verify", "--quiet", "--end-of-options", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unknown commit %q", rev)
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// ShowCommit returns the metadata of a commit and the diff it introduced.
+// A merge is compared with its first parent, the branch it was merged into,
+// and the first commit with the empty tree.
+func ShowCommit(ctx context.Context, rev string) (Commit, string, error) {
+	hash, err := ResolveCommit(ctx, rev)
+	if err != nil {
+		return Commit{}, "", err
+	}
+
+	output, err := runGit(ctx, "git show", "", "show", "--no-patch", "--format="+commitFormat, hash)
+	if err != nil {
+		return Commit{}, "", err
+	}
+	commit, err := parseCommit(output)
+	if err != nil {
+		return Commit{}, "", err
+	}
+
+	diffOutput, err := runGit(ctx, "git show", "", "show", "--format=", "--no-color", "--no-ext-diff", "-m", "--first-parent", hash)
+	if err != nil {
+		return Commit{}, "", err
+	}
+	return commit, diffOutput, nil
+}
+
+// parseCommit reads the fields written with commitFormat
+func parseCommit(output string) (Commit, error) {
+	fields := strings.SplitN(output, "\x00", 5)
+	if len(fields) != 5 {
+		return Commit{}, fmt.Errorf("unexpected git show output: %q", output)
+	}
+	date, err := time.Parse(time.RFC3339, fields[3])
+	if err != nil {
+		return Commit{}, fmt.Errorf("unexpected commit date %q: %w", fields[3], err)
+	}
+	return Commit{
+		Hash:    fields[0],
+		Author:  fields[1],
+		Email:   fields[2],
+		Date:    date,
+		Message: strings.TrimSpace(fields[4]),
+	}, nil
+}
+
+// commitMessageSection adds the message of the commit being explained to the
+// prompt. It tells what the author meant to do, which the model checks
+// against the diff rather than repeating.
+func commitMessageSection(message string) string {
+	if message == "" {
+		return ""
+	}
+	return "These changes are a single commit. Its message, written by the author, says what they meant to do; use it to explain why the changes were made, but describe what the diff actually does:\n\n<commit_message>\n" + message + "\n</commit_message>\n\n"
+}
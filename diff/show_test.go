@@ -0,0 +1,79 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tydin/difx/config"
+)
+
+func TestShowCommit(t *testing.T) {
+	hash := "1a2b3c4d5e6f708192a3b4c5d6e7f80912a3b4c5"
+	outputs := []string{
+		hash + "\n",
+		hash + "\x00Jane Doe\x00jane@example.com\x002026-10-01T09:30:00+02:00\x00Add parser\n\nHandles empty files too.\n\n",
+		sampleDiff,
+	}
+	var calls [][]string
+	original := runCommand
+	runCommand = func(cmd *exec.Cmd) error {
+		calls = append(calls, cmd.Args)
+		cmd.Stdout.Write([]byte(outputs[len(calls)-1]))
+		return nil
+	}
+	t.Cleanup(func() { runCommand = original })
+
+	commit, diffOutput, err := ShowCommit(context.Background(), "main~2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(calls[0], " "); got != "git rev-parse --verify --quiet --end-of-options main~2^{commit}" {
+		t.Errorf("rev-parse call = %s", got)
+	}
+	if diffOutput != sampleDiff {
+		t.Errorf("diff = %q", diffOutput)
+	}
+
+	want := Commit{
+		Hash:    hash,
+		Author:  "Jane Doe",
+		Email:   "jane@example.com",
+		Date:    time.Date(2026, 10, 1, 9, 30, 0, 0, time.FixedZone("", 2*60*60)),
+		Message: "Add parser\n\nHandles empty files too.",
+	}
+	if commit.Hash != want.Hash || commit.Author != want.Author || commit.Email != want.Email || !commit.Date.Equal(want.Date) || commit.Message != want.Message {
+		t.Errorf("commit = %+v, want %+v", commit, want)
+	}
+	if commit.Subject() != "Add parser" || commit.ShortHash() != "1a2b3c4" {
+		t.Errorf("subject %q, short hash %q", commit.Subject(), commit.ShortHash())
+	}
+}
+
+func TestShowUnknownCommit(t *testing.T) {
+	fakeGit(t, "", errors.New("exit status 1"))
+
+	if _, _, err := ShowCommit(context.Background(), "nope"); err == nil || !strings.Contains(err.Error(), `unknown commit "nope"`) {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestPromptCommitMessage(t *testing.T) {
+	cfg := &config.Config{CommitMessage: "Add parser\n\nHandles empty files too."}
+	for name, prompt := range map[string]string{
+		"explanation": buildPrompt(sampleDiff, cfg),
+		"structured":  buildPrompt(sampleDiff, &config.Config{CommitMessage: cfg.CommitMessage, Structured: true}),
+		"review":      buildPrompt(sampleDiff, &config.Config{CommitMessage: cfg.CommitMessage, Review: true}),
+	} {
+		if !strings.Contains(prompt, "<commit_message>\nAdd parser\n\nHandles empty files too.\n</commit_message>") {
+			t.Errorf("%s prompt does not include the commit message", name)
+		}
+	}
+
+	if prompt := buildPrompt(sampleDiff, &config.Config{}); strings.Contains(prompt, "commit_message") {
+		t.Error("prompt has a commit message section without a message")
+	}
+}
@@ -0,0 +1,71 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/tydin/difx/config"
+)
+
+// BudgetError is returned by ExplainChunksProgress when it stopped sending
+// chunks to stay within cfg.Budget. The explanations of the chunks that were
+// sent are returned with it; the skipped ones are empty.
+type BudgetError struct {
+	Budget float64
+	// Spent is the estimated cost of the chunks that were explained
+	Spent float64
+	// Skipped holds the paths of the chunks that weren't sent
+	Skipped []string
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("stopped at the $%.2f budget after spending about $%.2f; skipped %d of the files: %s",
+		e.Budget, e.Spent, len(e.Skipped), strings.Join(e.Skipped, ", "))
+}
+
+// spendLimit keeps the spend of concurrent requests within a budget. Before
+// a request starts, its worst case is reserved: the estimated prompt and the
+// longest response allowed. Once it is done, the reservation is replaced by
+// what it cost. After the first request that doesn't fit, no more are sent,
+// so a run stops rather than picking out the smaller files.
+type spendLimit struct {
+	cfg *config.Config
+
+	mu       sync.Mutex
+	spent    float64
+	reserved float64
+	stopped  bool
+}
+
+// reserve returns the worst case cost of explaining the chunk, and false if
+// it would take the spend over the budget
+func (l *spendLimit) reserve(chunk string) (float64, bool) {
+	cost := config.RequestCost(l.cfg, l.cfg.ActiveModel, EstimateTokens(buildPrompt(chunk, l.cfg)), MaxOutputTokens)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopped || l.spent+l.reserved+cost > l.cfg.Budget {
+		l.stopped = true
+		return 0, false
+	}
+	l.reserved += cost
+	return cost, true
+}
+
+// settle replaces a reservation with the cost of the finished request. Token
+// counts the provider didn't report are estimated, as for the usage stats.
+func (l *spendLimit) settle(reserved float64, chunk string, result Result) {
+	usage := result.Usage
+	if usage.InputTokens == 0 {
+		usage.InputTokens = EstimateTokens(buildPrompt(chunk, l.cfg))
+	}
+	if usage.OutputTokens == 0 {
+		usage.OutputTokens = EstimateTokens(result.Text)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reserved -= reserved
+	l.spent += config.RequestCost(l.cfg, l.cfg.ActiveModel, usage.InputTokens, usage.OutputTokens)
+}
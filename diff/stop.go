@@ -0,0 +1,73 @@
+package diff
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/tydin/difx/config"
+)
+
+// Delimiter is the dash line the explanation format opens and closes with
+const Delimiter = "--------------------------------------------------"
+
+// DelimiterStopSequence ends the response at the closing delimiter. The
+// newline in front keeps it from matching the opening delimiter, which starts
+// the response.
+const DelimiterStopSequence = "\n" + Delimiter
+
+// MaxAzureStopSequences is the most stop sequences Azure OpenAI accepts
+const MaxAzureStopSequences = 4
+
+// Stop reasons of a response that ended normally or at a stop sequence
+const (
+	StopReasonStopSequence = "stop_sequence"
+	StopReasonAzureStop    = "stop"
+)
+
+// stopSequences returns the stop sequences to send with a request. Tool
+// input is JSON that a stop sequence would only cut short, so structured
+// requests get none.
+func stopSequences(cfg *config.Config) []string {
+	if cfg.Structured {
+		return nil
+	}
+	return cfg.StopSequences
+}
+
+// delimiterRestorer puts back the closing delimiter of a response that
+// DelimiterStopSequence ended, since providers leave the stop sequence out.
+// It passes every event on and adds the delimiter as text before the stop.
+type delimiterRestorer struct {
+	emit func(Event)
+	text strings.Builder
+
+	// tail is the text that was added, to add it to the returned response too
+	tail string
+}
+
+// handle watches the response's events
+func (r *delimiterRestorer) handle(event Event) {
+	switch event.Kind {
+	case EventKindStart:
+		// A retried request starts over
+		r.text.Reset()
+		r.tail = ""
+	case EventKindText:
+		r.text.WriteString(event.Text)
+	case EventKindStop:
+		// Only an explanation that opened with the delimiter is missing its
+		// closing one; Azure OpenAI doesn't say whether a stop sequence matched
+		text := strings.TrimSpace(r.text.String())
+		stopped := event.StopReason == StopReasonStopSequence || event.StopReason == StopReasonAzureStop
+		if stopped && strings.HasPrefix(text, Delimiter) && !strings.HasSuffix(text, Delimiter) {
+			r.tail = DelimiterStopSequence + "\n"
+			r.emit(Event{Kind: EventKindText, Text: r.tail})
+		}
+	}
+	r.emit(event)
+}
+
+// restoresDelimiter tells whether requests stop at the closing delimiter
+func restoresDelimiter(cfg *config.Config) bool {
+	return slices.Contains(stopSequences(cfg), DelimiterStopSequence)
+}
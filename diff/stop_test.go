@@ -0,0 +1,85 @@
+package diff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tydin/difx/config"
+)
+
+func TestStopAtDelimiter(t *testing.T) {
+	// The response is cut at the closing delimiter, which isn't sent back
+	stream := strings.NewReplacer(
+		`"text":"Hello"`, `"text":"`+Delimiter+`\nSUMMARY:"`,
+		`"text":" world"`, `"text":"\n  - Files modified: 1"`,
+		`"stop_reason":"end_turn"`, `"stop_reason":"stop_sequence","stop_sequence":"\n`+Delimiter+`"`,
+	).Replace(claudeStream)
+
+	var request ClaudeRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&request)
+		fmt.Fprint(w, stream)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{ActiveModel: config.ModelClaude, ClaudeBaseURL: server.URL, Streaming: true, StopSequences: []string{DelimiterStopSequence}}
+	var streamed string
+	got, err := GetExplanation(context.Background(), sampleDiff, cfg, func(text string) { streamed += text })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(request.StopSequences, []string{DelimiterStopSequence}) {
+		t.Errorf("stop_sequences = %q", request.StopSequences)
+	}
+	// It finishes as if the model had written the delimiter
+	want := Delimiter + "\nSUMMARY:\n  - Files modified: 1\n" + Delimiter + "\n"
+	if got != want || streamed != want {
+		t.Errorf("response = %q, streamed %q, want %q", got, streamed, want)
+	}
+
+	// Structured tool input gets no stop sequences
+	cfg.Structured = true
+	cfg.Streaming = false
+	request = ClaudeRequest{}
+	GetExplanation(context.Background(), sampleDiff, cfg, nil)
+	if request.StopSequences != nil {
+		t.Errorf("structured stop_sequences = %q", request.StopSequences)
+	}
+}
+
+func TestDelimiterRestorer(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		reason string
+		want   string
+	}{
+		{name: "closed", text: Delimiter + "\nSUMMARY:\n" + Delimiter + "\n", reason: StopReasonAzureStop},
+		{name: "cut", text: Delimiter + "\nSUMMARY:", reason: StopReasonAzureStop, want: DelimiterStopSequence + "\n"},
+		{name: "max tokens", text: Delimiter + "\nSUMMARY:", reason: "length"},
+		{name: "no delimiter", text: "## Features", reason: StopReasonAzureStop},
+	}
+
+	for _, tt := range tests {
+		handler, events := collectEvents()
+		r := &delimiterRestorer{emit: handler}
+		r.handle(Event{Kind: EventKindStart})
+		r.handle(Event{Kind: EventKindText, Text: tt.text})
+		r.handle(Event{Kind: EventKindStop, StopReason: tt.reason})
+
+		if r.tail != tt.want {
+			t.Errorf("%s: tail = %q, want %q", tt.name, r.tail, tt.want)
+		}
+		// The added text comes before the stop
+		if last := (*events)[len(*events)-1]; last.Kind != EventKindStop {
+			t.Errorf("%s: last event = %+v", tt.name, last)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package diff
+
+import "github.com/tydin/difx/config"
+
+// ExplanationToolName is the tool Claude is forced to call in structured mode
+const ExplanationToolName = "return_explanation"
+
+// ClaudeTool represents a tool definition in the Claude API request
+type ClaudeTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+// ClaudeToolChoice tells Claude which tool it must use
+type ClaudeToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+// explanationTool describes the structured explanation Claude returns as tool input
+var explanationTool = ClaudeTool{
+	Name:        ExplanationToolName,
+	Description: "Return the explanation of the git diff as structured data.",
+	InputSchema: map[string]interface{}{
+		"type":     "object",
+		"required": []string{"summary", "files", "details"},
+		"properties": map[string]interface{}{
+			"summary": map[string]interface{}{
+				"type":        "string",
+				"description": "One line summary of the changes",
+			},
+			"files": map[string]interface{}{
+				"type":        "array",
+				"description": "Every file that was changed",
+				"items": map[string]interface{}{
+					"type":     "object",
+					"required": []string{"path", "insertions", "deletions"},
+					"properties": map[string]interface{}{
+						"path":       map[string]interface{}{"type": "string"},
+						"insertions": map[string]interface{}{"type": "integer"},
+						"deletions":  map[string]interface{}{"type": "integer"},
+					},
+				},
+			},
+			"details": map[string]interface{}{
+				"type":        "array",
+				"description": "Detailed breakdown of the changes in each file",
+				"items": map[string]interface{}{
+					"type":     "object",
+					"required": []string{"path", "additions", "deletions"},
+					"properties": map[string]interface{}{
+						"path": map[string]interface{}{"type": "string"},
+						"additions": map[string]interface{}{
+							"type":  "array",
+							"items": map[string]interface{}{"type": "string"},
+						},
+						"deletions": map[string]interface{}{
+							"type":  "array",
+							"items": map[string]interface{}{"type": "string"},
+						},
+					},
+				},
+			},
+		},
+	},
+}
+
+// testCoverageProperty is added to the tool schema with --check-tests
+var testCoverageProperty = map[string]interface{}{
+	"type":        "array",
+	"description": "For each changed source file, whether its tests were changed",
+	"items": map[string]interface{}{
+		"type":     "object",
+		"required": []string{"path", "tests_changed"},
+		"properties": map[string]interface{}{
+			"path":          map[string]interface{}{"type": "string"},
+			"tests_changed": map[string]interface{}{"type": "boolean"},
+			"note":          map[string]interface{}{"type": "string"},
+		},
+	},
+}
+
+// likelyIntentProperty is added to the tool schema with --intent
+var likelyIntentProperty = map[string]interface{}{
+	"type":        "object",
+	"description": "The most likely reason for the change and the risks it brings",
+	"required":    []string{"purpose", "risks"},
+	"properties": map[string]interface{}{
+		"purpose": map[string]interface{}{"type": "string"},
+		"risks": map[string]interface{}{
+			"type":  "array",
+			"items": map[string]interface{}{"type": "string"},
+		},
+	},
+}
+
+// structuredTool returns the explanation tool, with a test_coverage field
+// when the config asks for a test coverage check and a likely_intent field
+// when it asks for the intent
+func structuredTool(cfg *config.Config) ClaudeTool {
+	if !cfg.CheckTests && !cfg.Intent {
+		return explanationTool
+	}
+
+	// Copy the schema down to the properties so the shared tool isn't changed
+	schema := make(map[string]interface{}, len(explanationTool.InputSchema))
+	for key, value := range explanationTool.InputSchema {
+		schema[key] = value
+	}
+	properties := make(map[string]interface{})
+	for key, value := range explanationTool.InputSchema["properties"].(map[string]interface{}) {
+		properties[key] = value
+	}
+	required := []string{"summary", "files", "details"}
+	if cfg.CheckTests {
+		properties["test_coverage"] = testCoverageProperty
+		required = append(required, "test_coverage")
+	}
+	if cfg.Intent {
+		properties["likely_intent"] = likelyIntentProperty
+		required = append(required, "likely_intent")
+	}
+	schema["properties"] = properties
+	schema["required"] = required
+
+	tool := explanationTool
+	tool.InputSchema = schema
+	return tool
+}
+
+// buildStructuredPrompt creates the prompt used when the explanation is returned through the tool
+func buildStructuredPrompt(diffOutput string, cfg *config.Config) string {
+	prompt := "I'm going to show you the output of a git diff command. Please explain these changes in a clear, concise way.\n\n"
+	prompt += personaPreamble(cfg.Persona)
+	prompt += projectContextSection(cfg.ProjectContext)
+	prompt += commitContext(cfg.RecentCommits)
+	prompt += commitMessageSection(cfg.CommitMessage)
+	prompt += cfg.FileVersions
+	prompt += backgroundSection(cfg.Background)
+	prompt += "Here's the git diff output:\n\n```\n"
+	prompt += diffOutput
+	prompt += "\n```\n\n"
+	prompt += hunkClassInstruction(diffOutput)
+	if cfg.MinSeverity == config.SeverityNotable || cfg.MinSeverity == config.SeverityMajor {
+		prompt += detailsInstruction(cfg.MinSeverity, "details")
+	} else {
+		prompt += "Be concise but include every file that was changed."
+	}
+	prompt += " Return the explanation by calling the " + ExplanationToolName + " tool."
+	return prompt
+}
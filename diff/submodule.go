@@ -0,0 +1,173 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// MaxSubmoduleLog is how many commits of a submodule bump are listed
+const MaxSubmoduleLog = 10
+
+// subprojectRegex matches the line git writes for a submodule's commit
+var subprojectRegex = regexp.MustCompile(`^Subproject commit ([0-9a-f]{7,64})(-dirty)?$`)
+
+// SubmoduleChange is a change to the commit a submodule points at. From is
+// empty for an added submodule and To for a removed one.
+type SubmoduleChange struct {
+	Path string
+	From string
+	To   string
+	// Log holds the one-line subjects of the commits between From and To,
+	// when the submodule is checked out
+	Log []string
+}
+
+// Submodule returns the submodule change a file diff describes. The second
+// return value is false if the diff isn't a submodule pointer change.
+func (f FileDiff) Submodule() (SubmoduleChange, bool) {
+	change := SubmoduleChange{Path: f.Path()}
+	found := false
+	for _, hunk := range f.Hunks {
+		for _, line := range hunk.Lines {
+			if line.Kind != LineAdded && line.Kind != LineDeleted {
+				continue
+			}
+			matches := subprojectRegex.FindStringSubmatch(line.Text)
+			if matches == nil {
+				return SubmoduleChange{}, false
+			}
+			if line.Kind == LineDeleted {
+				change.From = matches[1]
+			} else {
+				change.To = matches[1]
+			}
+			found = true
+		}
+	}
+	return change, found
+}
+
+// Describe returns a one-line account of the change
+func (s SubmoduleChange) Describe() string {
+	switch {
+	case s.From == "":
+		return fmt.Sprintf("submodule %s added at %s", s.Path, shortHash(s.To))
+	case s.To == "":
+		return fmt.Sprintf("submodule %s removed (was at %s)", s.Path, shortHash(s.From))
+	case s.From == s.To:
+		return fmt.Sprintf("submodule %s has changes that aren't committed (at %s)", s.Path, shortHash(s.To))
+	default:
+		return fmt.Sprintf("submodule %s bumped from %s to %s", s.Path, shortHash(s.From), shortHash(s.To))
+	}
+}
+
+// shortHash abbreviates a commit hash the way git log --oneline does
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+// Lines returns the description followed by the indented commit log
+func (s SubmoduleChange) Lines() []string {
+	lines := []string{s.Describe()}
+	for _, commit := range s.Log {
+		lines = append(lines, "  "+commit)
+	}
+	return lines
+}
+
+// SeparateSubmodules takes the submodule pointer changes out of the diff,
+// which the model tends to misread. It returns the rest of the diff and the
+// submodule changes in diff order.
+func SeparateSubmodules(diffOutput string) (string, []SubmoduleChange) {
+	files, err := Parse(diffOutput)
+	if err != nil {
+		return diffOutput, nil
+	}
+
+	var kept []FileDiff
+	var changes []SubmoduleChange
+	for _, file := range files {
+		if change, ok := file.Submodule(); ok {
+			changes = append(changes, change)
+		} else {
+			kept = append(kept, file)
+		}
+	}
+
+	if len(changes) == 0 {
+		return diffOutput, nil
+	}
+	return Format(kept), changes
+}
+
+// DescribeSubmodules keeps the submodule changes in the diff, but replaces
+// each one's "Subproject commit" lines with the description and commit log,
+// which the model reads correctly
+func DescribeSubmodules(ctx context.Context, diffOutput string) string {
+	files, err := Parse(diffOutput)
+	if err != nil {
+		return diffOutput
+	}
+
+	changed := false
+	for i, file := range files {
+		change, ok := file.Submodule()
+		if !ok || len(file.Hunks) == 0 {
+			continue
+		}
+		change.Log = SubmoduleLog(ctx, change)
+
+		var lines []Line
+		for _, text := range change.Lines() {
+			lines = append(lines, Line{Kind: LineOther, Text: text})
+		}
+		files[i].Hunks = []Hunk{{Header: file.Hunks[0].Header, Lines: lines}}
+		changed = true
+	}
+
+	if !changed {
+		return diffOutput
+	}
+	return Format(files)
+}
+
+// SubmoduleLog returns the one-line subjects of the commits a bump brings
+// in, newest first and at most MaxSubmoduleLog of them. It is empty when the
+// submodule isn't checked out or doesn't have the commits, since the
+// description is still useful without it.
+func SubmoduleLog(ctx context.Context, change SubmoduleChange) []string {
+	if change.From == "" || change.To == "" || change.From == change.To {
+		return nil
+	}
+
+	// Without its own .git, git would run in the superproject instead
+	root, err := RepoRoot(ctx)
+	if err != nil {
+		return nil
+	}
+	dir := filepath.Join(root, change.Path)
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		return nil
+	}
+
+	out, err := runGit(ctx, "git log", "", "-C", dir,
+		"log", "--oneline", "--no-decorate", "--no-color", fmt.Sprintf("-n%d", MaxSubmoduleLog), change.From+".."+change.To)
+	if err != nil {
+		return nil
+	}
+
+	var log []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if line != "" {
+			log = append(log, line)
+		}
+	}
+	return log
+}
@@ -0,0 +1,77 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSeparateSubmodules(t *testing.T) {
+	rest, changes := SeparateSubmodules(readFixture(t, "submodule.diff"))
+
+	want := []SubmoduleChange{
+		{Path: "vendor/lib", From: "1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d", To: "5d6e7f8091a2b3c4d1a2b3c4d5e6f708192a3b4c"},
+		{Path: "vendor/tools", To: "9f8e7d6c5b4a39281706f5e4d3c2b1a098765432"},
+		{Path: "vendor/old", From: "2468ace0246813579bdf02468ace0246813579bd", To: "2468ace0246813579bdf02468ace0246813579bd"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+
+	// Only the .gitmodules change is left for the model
+	if files := GetChangedFiles(rest); !reflect.DeepEqual(files, []string{".gitmodules"}) {
+		t.Errorf("remaining files = %v", files)
+	}
+}
+
+func TestSubmoduleDescribe(t *testing.T) {
+	_, changes := SeparateSubmodules(readFixture(t, "submodule.diff"))
+
+	var got []string
+	for _, change := range changes {
+		got = append(got, change.Describe())
+	}
+	want := []string{
+		"submodule vendor/lib bumped from 1a2b3c4 to 5d6e7f8",
+		"submodule vendor/tools added at 9f8e7d6",
+		"submodule vendor/old has changes that aren't committed (at 2468ace)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("descriptions = %q, want %q", got, want)
+	}
+
+	removed := SubmoduleChange{Path: "vendor/gone", From: "abcdef0123"}
+	if got := removed.Describe(); got != "submodule vendor/gone removed (was at abcdef0)" {
+		t.Errorf("removed = %q", got)
+	}
+}
+
+func TestDescribeSubmodules(t *testing.T) {
+	// No submodule is checked out, so there is no commit log
+	fakeGit(t, "", errors.New("not a git repository"))
+
+	got := DescribeSubmodules(context.Background(), readFixture(t, "submodule.diff"))
+	if strings.Contains(got, "Subproject commit") {
+		t.Errorf("the Subproject commit lines are still there:\n%s", got)
+	}
+	if !strings.Contains(got, "\n@@ -1 +1 @@\nsubmodule vendor/lib bumped from 1a2b3c4 to 5d6e7f8\n") {
+		t.Errorf("the bump isn't described:\n%s", got)
+	}
+	if !strings.Contains(got, "+[submodule \"vendor/tools\"]") {
+		t.Error("the other changes were lost")
+	}
+}
+
+func TestFileDiffSubmodule(t *testing.T) {
+	files, err := Parse(readFixture(t, "git.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if _, ok := file.Submodule(); ok {
+			t.Errorf("%s was taken for a submodule", file.Path())
+		}
+	}
+}
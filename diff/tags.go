@@ -0,0 +1,40 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoTags means the repository has no tag reachable from HEAD
+var ErrNoTags = errors.New("no tags found; create a release tag with git tag <name> or use --from <commit>")
+
+// LatestTag returns the most recent tag reachable from HEAD, as found by
+// git describe --tags --abbrev=0. It returns ErrNoTags when there is none.
+func LatestTag(ctx context.Context) (string, error) {
+	out, err := runGit(ctx, "git describe", "", "describe", "--tags", "--abbrev=0")
+	if err != nil {
+		// git says "No names found" or "No tags can describe" without tags
+		message := err.Error()
+		if strings.Contains(message, "No names found") || strings.Contains(message, "No tags can describe") {
+			return "", ErrNoTags
+		}
+		return "", err
+	}
+
+	tag := strings.TrimSpace(out)
+	if tag == "" {
+		return "", ErrNoTags
+	}
+	return tag, nil
+}
+
+// ValidateTag checks that a tag exists. A branch or commit of the same name
+// doesn't count.
+func ValidateTag(ctx context.Context, tag string) error {
+	if _, err := runGit(ctx, "git rev-parse", "", "rev-parse", "--verify", "--quiet", "refs/tags/"+tag); err != nil {
+		return fmt.Errorf("tag %q doesn't exist", tag)
+	}
+	return nil
+}
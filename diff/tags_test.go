@@ -0,0 +1,39 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLatestTag(t *testing.T) {
+	calls := fakeGit(t, "v1.2.0\n", nil)
+	tag, err := LatestTag(context.Background())
+	if err != nil || tag != "v1.2.0" {
+		t.Fatalf("LatestTag = %q, %v", tag, err)
+	}
+	if got := strings.Join((*calls)[0], " "); got != "git describe --tags --abbrev=0" {
+		t.Errorf("ran %q", got)
+	}
+
+	fakeGit(t, "", errors.New("fatal: No names found, cannot describe anything."))
+	if _, err := LatestTag(context.Background()); !errors.Is(err, ErrNoTags) {
+		t.Errorf("err = %v, want ErrNoTags", err)
+	}
+}
+
+func TestValidateTag(t *testing.T) {
+	calls := fakeGit(t, "", nil)
+	if err := ValidateTag(context.Background(), "v1.2.0"); err != nil {
+		t.Errorf("ValidateTag = %v", err)
+	}
+	if got := strings.Join((*calls)[0], " "); got != "git rev-parse --verify --quiet refs/tags/v1.2.0" {
+		t.Errorf("ran %q", got)
+	}
+
+	fakeGit(t, "", errors.New("exit status 1"))
+	if err := ValidateTag(context.Background(), "v9"); err == nil || !strings.Contains(err.Error(), `"v9"`) {
+		t.Errorf("ValidateTag of a missing tag = %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoUpstream means the current branch doesn't track a remote branch
+var ErrNoUpstream = errors.New("the current branch has no upstream branch; set one with git branch --set-upstream-to=<remote>/<branch> or push with git push -u")
+
+// Upstream returns the branch the current branch tracks, such as origin/main.
+// It returns an error matching ErrNoUpstream when there is none, including
+// on a detached HEAD.
+func Upstream(ctx context.Context) (string, error) {
+	out, err := runGit(ctx, "git rev-parse", "", "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{u}")
+	if err != nil {
+		message := err.Error()
+		if strings.Contains(message, "no upstream configured") || strings.Contains(message, "does not point to a branch") {
+			return "", ErrNoUpstream
+		}
+		return "", err
+	}
+
+	upstream := strings.TrimSpace(out)
+	if upstream == "" {
+		return "", ErrNoUpstream
+	}
+	return upstream, nil
+}
+
+// UpstreamRange is the git diff range of the commits on HEAD that upstream
+// doesn't have, diffed from where the two branches split
+func UpstreamRange(upstream string) string {
+	return fmt.Sprintf("%s...HEAD", upstream)
+}
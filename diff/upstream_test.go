@@ -0,0 +1,30 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUpstream(t *testing.T) {
+	calls := fakeGit(t, "origin/main\n", nil)
+	upstream, err := Upstream(context.Background())
+	if err != nil || upstream != "origin/main" {
+		t.Fatalf("Upstream = %q, %v", upstream, err)
+	}
+	if got := strings.Join((*calls)[0], " "); got != "git rev-parse --abbrev-ref --symbolic-full-name @{u}" {
+		t.Errorf("ran %q", got)
+	}
+	if got := UpstreamRange(upstream); got != "origin/main...HEAD" {
+		t.Errorf("UpstreamRange = %q", got)
+	}
+
+	// Without an upstream, or on a detached HEAD, the error says how to set one
+	for _, message := range []string{"fatal: no upstream configured for branch 'topic'", "fatal: HEAD does not point to a branch"} {
+		fakeGit(t, "", errors.New(message))
+		if _, err := Upstream(context.Background()); !errors.Is(err, ErrNoUpstream) {
+			t.Errorf("%s: err = %v, want ErrNoUpstream", message, err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package diff
+
+import "strings"
+
+// WordKind tells whether a piece of a word diff line is unchanged, added or
+// removed
+type WordKind int
+
+const (
+	WordContext WordKind = iota
+	WordAdded
+	WordDeleted
+)
+
+// WordSegment is a run of text in a line of git diff --word-diff=plain output
+type WordSegment struct {
+	Kind WordKind
+	Text string
+}
+
+// Markers git puts around the changed words of a plain word diff
+const (
+	wordAddedStart   = "{+"
+	wordAddedEnd     = "+}"
+	wordDeletedStart = "[-"
+	wordDeletedEnd   = "-]"
+)
+
+// ParseWordDiffLine splits a line of git diff --word-diff=plain output into
+// its unchanged, {+added+} and [-removed-] runs of text, without the
+// markers. A marker that is never closed is kept as text.
+func ParseWordDiffLine(line string) []WordSegment {
+	var segments []WordSegment
+	for line != "" {
+		start, kind, end := nextWordMarker(line)
+		length := -1
+		if start >= 0 {
+			length = strings.Index(line[start+2:], end)
+		}
+		if length < 0 {
+			segments = append(segments, WordSegment{Kind: WordContext, Text: line})
+			break
+		}
+
+		if start > 0 {
+			segments = append(segments, WordSegment{Kind: WordContext, Text: line[:start]})
+		}
+		segments = append(segments, WordSegment{Kind: kind, Text: line[start+2 : start+2+length]})
+		line = line[start+2+length+2:]
+	}
+	return segments
+}
+
+// nextWordMarker finds the first opening marker in line, returning where it
+// starts, the kind of change it opens and the marker that closes it. The
+// start is -1 when there is none.
+func nextWordMarker(line string) (int, WordKind, string) {
+	added := strings.Index(line, wordAddedStart)
+	deleted := strings.Index(line, wordDeletedStart)
+	switch {
+	case added < 0 && deleted < 0:
+		return -1, WordContext, ""
+	case deleted < 0 || (added >= 0 && added < deleted):
+		return added, WordAdded, wordAddedEnd
+	default:
+		return deleted, WordDeleted, wordDeletedEnd
+	}
+}
+
+// ColorizeWordDiff adds ANSI colors to git diff --word-diff=plain output:
+// headers as Colorize does, and the added and removed words, without their
+// markers, in the given color sequences
+func ColorizeWordDiff(diffOutput string, added, removed string) string {
+	var b strings.Builder
+	inHunk := false
+	for _, line := range strings.SplitAfter(diffOutput, "\n") {
+		content := strings.TrimRight(line, "\r\n")
+		switch {
+		case strings.HasPrefix(content, "diff "):
+			inHunk = false
+		case strings.HasPrefix(content, "@@"):
+			inHunk = true
+		case inHunk:
+			for _, segment := range ParseWordDiffLine(content) {
+				switch segment.Kind {
+				case WordAdded:
+					b.WriteString(added + segment.Text + colorReset)
+				case WordDeleted:
+					b.WriteString(removed + segment.Text + colorReset)
+				default:
+					b.WriteString(segment.Text)
+				}
+			}
+			b.WriteString(line[len(content):])
+			continue
+		}
+		b.WriteString(Colorize(line, added, removed))
+	}
+	return b.String()
+}
+
+// wordDiffInstruction explains the markers of a word diff to the model. It
+// is empty for a line diff.
+func wordDiffInstruction(wordDiff bool) string {
+	if !wordDiff {
+		return ""
+	}
+	return "This diff was taken word by word, with git diff --word-diff: lines have no + or - prefix, removed words are shown as [-words-] and added words as {+words+}, and the rest of each line is unchanged. " +
+		"Describe the edits to the wording, such as rephrasings, corrections and added or removed sentences, rather than whole lines.\n\n"
+}
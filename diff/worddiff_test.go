@@ -0,0 +1,68 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tydin/difx/config"
+)
+
+func TestParseWordDiffLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []WordSegment
+	}{
+		{line: "jumps over", want: []WordSegment{{WordContext, "jumps over"}}},
+		{line: "The quick [-brown-]{+red+} fox", want: []WordSegment{
+			{WordContext, "The quick "}, {WordDeleted, "brown"}, {WordAdded, "red"}, {WordContext, " fox"},
+		}},
+		{line: "{+new line+}", want: []WordSegment{{WordAdded, "new line"}}},
+		{line: "a [-b", want: []WordSegment{{WordContext, "a [-b"}}},
+		{line: "", want: nil},
+	}
+
+	for _, tt := range tests {
+		if got := ParseWordDiffLine(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseWordDiffLine(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestColorizeWordDiff(t *testing.T) {
+	got := ColorizeWordDiff(readFixture(t, "worddiff.diff"), "<ADD>", "<DEL>")
+	want := "\033[1mdiff --git a/docs/guide.md b/docs/guide.md\033[0m\n" +
+		"\033[1mindex 5771c9c..b6fe6cc 100644\033[0m\n" +
+		"\033[1m--- a/docs/guide.md\033[0m\n" +
+		"\033[1m+++ b/docs/guide.md\033[0m\n" +
+		"\033[36m@@ -1,3 +1,4 @@\033[0m\n" +
+		"The quick <DEL>brown\033[0m<ADD>red\033[0m fox\n" +
+		"jumps over\n" +
+		"the <ADD>very\033[0m lazy <DEL>dog\033[0m<ADD>cat\033[0m\n" +
+		"<ADD>new line\033[0m\n"
+	if got != want {
+		t.Errorf("ColorizeWordDiff =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestWordDiffPrompt(t *testing.T) {
+	diffOutput := readFixture(t, "worddiff.diff")
+
+	if prompt := buildPrompt(diffOutput, &config.Config{}); strings.Contains(prompt, "--word-diff") {
+		t.Error("a line diff's prompt explains word diff markers")
+	}
+	for _, cfg := range []*config.Config{{WordDiff: true}, {WordDiff: true, Review: true}, {WordDiff: true, Changelog: true}} {
+		if prompt := buildPrompt(diffOutput, cfg); !strings.Contains(prompt, "[-words-]") {
+			t.Errorf("prompt with %+v doesn't explain the word diff markers", *cfg)
+		}
+	}
+}
+
+func TestWordDiffPromptSkipsHunkClasses(t *testing.T) {
+	// "- one" is a context line of a list in a word diff, not a removal
+	diffOutput := readFixture(t, "worddiff-list.diff")
+
+	if prompt := buildPrompt(diffOutput, &config.Config{WordDiff: true}); strings.Contains(prompt, "Each hunk of the diff was classified") {
+		t.Errorf("word diff prompt classifies hunks by their line prefixes:\n%s", prompt)
+	}
+}
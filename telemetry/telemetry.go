@@ -0,0 +1,51 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// EndpointEnvVar is the environment variable that enables tracing when set
+const EndpointEnvVar = "OTEL_EXPORTER_OTLP_ENDPOINT"
+
+// TracerName is the instrumentation name used for all difx spans
+const TracerName = "github.com/tydin/difx"
+
+// Setup installs an OTLP trace exporter if OTEL_EXPORTER_OTLP_ENDPOINT is set.
+// When it isn't, the global no-op tracer provider is left in place and the
+// returned shutdown function does nothing.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	if os.Getenv(EndpointEnvVar) == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// The exporter reads the endpoint, headers and protocol options from the standard OTEL_* variables
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName("difx"),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer used to create difx spans
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}